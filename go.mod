@@ -2,11 +2,19 @@ module github.com/harche/crio-mcp-server
 
 go 1.23.8
 
-require github.com/mark3labs/mcp-go v0.32.0
+require (
+	github.com/coreos/go-oidc/v3 v3.14.1
+	github.com/mark3labs/mcp-go v0.32.0
+	github.com/ulikunitz/xz v0.5.15
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
+	github.com/go-jose/go-jose/v4 v4.0.5 // indirect
 	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
+	golang.org/x/crypto v0.36.0 // indirect
+	golang.org/x/oauth2 v0.28.0 // indirect
 )
@@ -0,0 +1,268 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir is the directory where artifacts are written. It defaults to the
+// CRIO_MCP_ARTIFACT_DIR environment variable or a crio-mcp-artifacts directory
+// under the system temporary directory. Tests may override it.
+var Dir = defaultDir()
+
+func defaultDir() string {
+	if d := os.Getenv("CRIO_MCP_ARTIFACT_DIR"); d != "" {
+		return d
+	}
+	return filepath.Join(os.TempDir(), "crio-mcp-artifacts")
+}
+
+// Artifact describes a file stored in the artifact directory.
+type Artifact struct {
+	Name   string
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// String returns a one-line description suitable for tool output.
+func (a Artifact) String() string {
+	return fmt.Sprintf("%s (%d bytes, sha256 %s)", a.Path, a.Size, a.SHA256)
+}
+
+// Save writes data to a new artifact with the given name and returns its
+// location and checksum. Path separators in name are replaced so that every
+// artifact lives directly under Dir.
+func Save(name string, data []byte) (Artifact, error) {
+	if err := os.MkdirAll(Dir, 0o700); err != nil {
+		return Artifact{}, fmt.Errorf("create artifact dir: %w", err)
+	}
+	name = sanitize(name)
+	path := filepath.Join(Dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return Artifact{}, fmt.Errorf("write artifact: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return Artifact{
+		Name:   name,
+		Path:   path,
+		Size:   int64(len(data)),
+		SHA256: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// NewDir creates a new, uniquely named directory under Dir for tools that
+// write several files, such as must-gather.
+func NewDir(prefix string) (string, error) {
+	if err := os.MkdirAll(Dir, 0o700); err != nil {
+		return "", fmt.Errorf("create artifact dir: %w", err)
+	}
+	return os.MkdirTemp(Dir, sanitize(prefix)+"-")
+}
+
+// Resolve returns the absolute path of p, following symbolic links, if it
+// lies below Dir, and an error otherwise. Tools that read a caller-supplied
+// path and send it off the server use it so that only artifacts can leave.
+func Resolve(p string) (string, error) {
+	real, err := realPath(p)
+	if err != nil {
+		return "", err
+	}
+	ok, err := within(Dir, real)
+	if err != nil {
+		return "", fmt.Errorf("artifact dir: %w", err)
+	}
+	if !ok {
+		return "", fmt.Errorf("%s is not in the artifact directory %s", p, Dir)
+	}
+	return real, nil
+}
+
+// ReadDirsEnv lists directories, separated by the system's path list
+// separator, whose contents the analysis tools may read in addition to Dir.
+const ReadDirsEnv = "CRIO_MCP_READ_DIRS"
+
+// ResolveReadable returns the absolute path of p, following symbolic links,
+// if it lies below Dir or below one of the directories listed in ReadDirsEnv,
+// and an error otherwise. Tools that read and parse a caller-supplied path on
+// the server use it, so that callers cannot make them read arbitrary files.
+func ResolveReadable(p string) (string, error) {
+	real, err := realPath(p)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range append([]string{Dir}, filepath.SplitList(os.Getenv(ReadDirsEnv))...) {
+		if root == "" {
+			continue
+		}
+		// A root that does not exist yet, such as an artifact directory
+		// nothing has been written to, contains nothing.
+		if ok, _ := within(root, real); ok {
+			return real, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not in the artifact directory %s or a directory listed in %s", p, Dir, ReadDirsEnv)
+}
+
+// realPath returns the absolute path of p with symbolic links resolved.
+func realPath(p string) (string, error) {
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(real)
+}
+
+// within reports whether the resolved path real lies below root.
+func within(root, real string) (bool, error) {
+	root, err := realPath(root)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(root, real)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+// Writer streams data into a new artifact, computing its size and checksum
+// as it is written so that large payloads never need to be held in memory.
+// Data goes to a temporary file that Commit renames into place; Abort
+// removes it.
+type Writer struct {
+	name string
+	path string
+	f    *os.File
+	h    hash.Hash
+	n    int64
+}
+
+// Create starts a new artifact with the given name.
+func Create(name string) (*Writer, error) {
+	if err := os.MkdirAll(Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create artifact dir: %w", err)
+	}
+	name = sanitize(name)
+	f, err := os.CreateTemp(Dir, "."+name+".partial-")
+	if err != nil {
+		return nil, fmt.Errorf("write artifact: %w", err)
+	}
+	return &Writer{name: name, path: filepath.Join(Dir, name), f: f, h: sha256.New()}, nil
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	w.h.Write(p[:n])
+	w.n += int64(n)
+	return n, err
+}
+
+// Commit closes the artifact and moves it into place.
+func (w *Writer) Commit() (Artifact, error) {
+	if err := w.f.Close(); err != nil {
+		os.Remove(w.f.Name())
+		return Artifact{}, fmt.Errorf("write artifact: %w", err)
+	}
+	if err := os.Rename(w.f.Name(), w.path); err != nil {
+		os.Remove(w.f.Name())
+		return Artifact{}, fmt.Errorf("write artifact: %w", err)
+	}
+	return Artifact{
+		Name:   w.name,
+		Path:   w.path,
+		Size:   w.n,
+		SHA256: hex.EncodeToString(w.h.Sum(nil)),
+	}, nil
+}
+
+// Abort discards the partially written artifact. It is safe to call after
+// Commit, in which case it does nothing.
+func (w *Writer) Abort() {
+	if w.f.Close() == nil {
+		os.Remove(w.f.Name())
+	}
+}
+
+// SaveDir archives the directory src as a gzip-compressed tarball artifact
+// with the given name. Entries are stored relative to the parent of src so
+// the archive unpacks into a single top-level directory.
+func SaveDir(name, src string) (Artifact, error) {
+	w, err := Create(name)
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer w.Abort()
+	if err := writeTarGz(w, src); err != nil {
+		return Artifact{}, fmt.Errorf("archive %s: %w", src, err)
+	}
+	return w.Commit()
+}
+
+func writeTarGz(w io.Writer, src string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	base := filepath.Dir(filepath.Clean(src))
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(tw, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func sanitize(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(name)
+	if name == "" {
+		name = "artifact"
+	}
+	return name
+}
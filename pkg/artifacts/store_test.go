@@ -0,0 +1,190 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSave(t *testing.T) {
+	orig := Dir
+	Dir = t.TempDir()
+	defer func() { Dir = orig }()
+
+	a, err := Save("../prof/cpu.pb.gz", []byte("hello"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Dir(a.Path) != Dir {
+		t.Fatalf("artifact escaped dir: %s", a.Path)
+	}
+	if a.Size != 5 {
+		t.Fatalf("unexpected size %d", a.Size)
+	}
+	if a.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatalf("unexpected checksum %s", a.SHA256)
+	}
+	data, err := os.ReadFile(a.Path)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected content %q: %v", data, err)
+	}
+}
+
+func TestSaveDir(t *testing.T) {
+	orig := Dir
+	Dir = t.TempDir()
+	defer func() { Dir = orig }()
+
+	src, err := NewDir("must-gather")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(src, "ns"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "ns", "pod.yaml"), []byte("kind: Pod"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := SaveDir("mg.tar.gz", src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	if a.Size != int64(len(data)) || a.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("artifact metadata does not match content: %+v", a)
+	}
+
+	gz, err := gzip.NewReader(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	top := filepath.Base(src)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.Name == top+"/ns/pod.yaml" {
+			body, _ := io.ReadAll(tr)
+			if string(body) != "kind: Pod" {
+				t.Fatalf("unexpected content %q", body)
+			}
+		}
+	}
+	sort.Strings(names)
+	want := []string{top + "/", top + "/ns/", top + "/ns/pod.yaml"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected entries %v", names)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	orig := Dir
+	Dir = t.TempDir()
+	defer func() { Dir = orig }()
+
+	w, err := Create("../logs.txt.gz")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Abort()
+	io.WriteString(w, "hel")
+	io.WriteString(w, "lo")
+	if _, err := os.Stat(filepath.Join(Dir, "__logs.txt.gz")); !os.IsNotExist(err) {
+		t.Fatalf("artifact visible before commit: %v", err)
+	}
+	a, err := w.Commit()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Abort()
+	if a.Path != filepath.Join(Dir, "__logs.txt.gz") || a.Size != 5 || a.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatalf("unexpected artifact %+v", a)
+	}
+	if data, err := os.ReadFile(a.Path); err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected content %q: %v", data, err)
+	}
+
+	w, err = Create("aborted")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	io.WriteString(w, "partial")
+	w.Abort()
+	entries, _ := os.ReadDir(Dir)
+	if len(entries) != 1 {
+		t.Fatalf("aborted artifact left behind: %v", entries)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	orig := Dir
+	Dir = t.TempDir()
+	defer func() { Dir = orig }()
+
+	a, err := Save("sos.tar.xz", []byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := Resolve(a.Path); err != nil || filepath.Base(p) != "sos.tar.xz" {
+		t.Fatalf("unexpected result %q: %v", p, err)
+	}
+	outside := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(Dir, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{outside, link, Dir, filepath.Join(Dir, "..", filepath.Base(outside))} {
+		if _, err := Resolve(p); err == nil {
+			t.Errorf("%s resolved", p)
+		}
+	}
+}
+
+func TestResolveReadable(t *testing.T) {
+	orig := Dir
+	Dir = t.TempDir()
+	defer func() { Dir = orig }()
+	extra := t.TempDir()
+	t.Setenv(ReadDirsEnv, filepath.Join(t.TempDir(), "missing")+string(filepath.ListSeparator)+extra)
+
+	mg := filepath.Join(extra, "must-gather.local.1")
+	if err := os.Mkdir(mg, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Save("stacks.log", []byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{a.Path, mg} {
+		if _, err := ResolveReadable(p); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+	for _, p := range []string{t.TempDir(), extra, filepath.Join(mg, "..", ".."), "/etc"} {
+		if _, err := ResolveReadable(p); err == nil {
+			t.Errorf("%s resolved", p)
+		}
+	}
+}
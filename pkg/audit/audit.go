@@ -0,0 +1,238 @@
+// Package audit records every tool call in an append-only JSON-lines log.
+// Each record carries the hash of the previous one, so deleting or editing a
+// record in the middle of the log breaks the chain and is reported by Verify.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Client identifies the MCP session and client that made a call, and the
+// authenticated user when the server is reached over HTTP.
+type Client struct {
+	Session string   `json:"session,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Version string   `json:"version,omitempty"`
+	User    string   `json:"user,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+}
+
+// Record is a single audited tool call.
+type Record struct {
+	Time      time.Time      `json:"time"`
+	Client    Client         `json:"client"`
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Cluster   string         `json:"cluster,omitempty"`
+	Node      string         `json:"node,omitempty"`
+	Duration  float64        `json:"duration_seconds"`
+	// Status is StatusStarted before the tool runs, then StatusOK,
+	// StatusError when the tool returned an error result, or StatusFailed
+	// when the handler itself failed.
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	OutputSHA256 string `json:"output_sha256,omitempty"`
+	// Call is shared by the started record of a call and its outcome.
+	Call string `json:"call,omitempty"`
+	// Prev is the hash of the preceding record, empty for the first one.
+	Prev string `json:"prev"`
+}
+
+// Record statuses.
+const (
+	StatusStarted = "started"
+	StatusOK      = "ok"
+	StatusError   = "error"
+	StatusFailed  = "failed"
+)
+
+// Sink receives encoded records, one JSON object per call without a
+// trailing newline.
+type Sink interface {
+	Write(line []byte) error
+	Close() error
+}
+
+// Logger hash-chains records and writes them to its sinks.
+type Logger struct {
+	mu    sync.Mutex
+	sinks []Sink
+	prev  string
+}
+
+// New returns a Logger writing to sinks. prev is the hash of the last record
+// already in the log, so a restarted server continues the existing chain.
+func New(prev string, sinks ...Sink) *Logger {
+	return &Logger{sinks: sinks, prev: prev}
+}
+
+// Log chains rec to the previous record and writes it to every sink. The
+// returned error joins the errors of all failing sinks.
+func (l *Logger) Log(rec Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec.Prev = l.prev
+	rec.Time = rec.Time.UTC()
+	line, hash, err := encode(rec)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range l.sinks {
+		if err := s.Write(line); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Advance the chain even if a sink failed: the record may have reached
+	// the others, and a failing sink already shows up as a broken chain.
+	l.prev = hash
+	return errors.Join(errs...)
+}
+
+// Close closes all sinks.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var errs []error
+	for _, s := range l.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// encode marshals rec and appends its hash as the last field. The hash covers
+// the exact bytes of the record without the hash field, so verification does
+// not depend on how a JSON decoder would re-encode the arguments.
+func encode(rec Record) ([]byte, string, error) {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	line := append(body[:len(body)-1:len(body)-1], []byte(`,"hash":"`+hash+`"}`)...)
+	return line, hash, nil
+}
+
+var hashSuffixRE = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"}$`)
+
+// Verify reads a JSON-lines audit log and checks that every record hashes to
+// the value it carries and names its predecessor's hash. It returns the number
+// of valid records and an error identifying the first line that breaks the
+// chain. Records removed from the end of the log cannot be detected from the
+// log alone; compare the last hash with a copy kept elsewhere, such as syslog.
+func Verify(r io.Reader) (int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	prev := ""
+	n := 0
+	for line := 1; sc.Scan(); line++ {
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		m := hashSuffixRE.FindSubmatchIndex(text)
+		if m == nil {
+			return n, fmt.Errorf("line %d: missing record hash", line)
+		}
+		hash := string(text[m[2]:m[3]])
+		body := append(append([]byte{}, text[:m[0]]...), '}')
+		sum := sha256.Sum256(body)
+		if hex.EncodeToString(sum[:]) != hash {
+			return n, fmt.Errorf("line %d: record was modified", line)
+		}
+		var rec Record
+		if err := json.Unmarshal(body, &rec); err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Prev != prev {
+			return n, fmt.Errorf("line %d: chain broken, previous record missing or reordered", line)
+		}
+		prev = hash
+		n++
+	}
+	return n, sc.Err()
+}
+
+// lastHash returns the hash of the last record in the log at path, or an
+// empty string if the file does not exist or is empty.
+func lastHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	const tail = 1 << 20
+	off := fi.Size() - tail
+	if off < 0 {
+		off = 0
+	}
+	data, err := io.ReadAll(io.NewSectionReader(f, off, fi.Size()-off))
+	if err != nil {
+		return "", err
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	last := lines[len(lines)-1]
+	if len(last) == 0 {
+		return "", nil
+	}
+	m := hashSuffixRE.FindSubmatch(last)
+	if m == nil {
+		return "", fmt.Errorf("%s: last record has no hash", path)
+	}
+	return string(m[1]), nil
+}
+
+// FileSink appends records to a file opened in append-only mode.
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFile opens the audit log at path for appending, creating it with
+// owner-only permissions, and returns the sink with the hash of the last
+// record already in the file.
+func OpenFile(path string) (*FileSink, string, error) {
+	prev, err := lastHash(path)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, "", err
+	}
+	return &FileSink{f: f}, prev, nil
+}
+
+// Write appends line and syncs it to disk.
+func (s *FileSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(line[:len(line):len(line)], '\n')); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
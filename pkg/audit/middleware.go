@@ -0,0 +1,239 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/harche/crio-mcp-server/pkg/authz"
+	"github.com/harche/crio-mcp-server/pkg/redact"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Environment variables read by FromEnv.
+const (
+	LogFileEnv = "CRIO_MCP_AUDIT_LOG"
+	SyslogEnv  = "CRIO_MCP_AUDIT_SYSLOG"
+)
+
+// DefaultSyslogTag is used when SyslogEnv is set to a boolean true value.
+const DefaultSyslogTag = "crio-mcp-server"
+
+// FromEnv builds a Logger from CRIO_MCP_AUDIT_LOG, the path of a JSON-lines
+// file, and CRIO_MCP_AUDIT_SYSLOG, a syslog tag or "true" for the default
+// tag. It returns nil if neither is set. When a file is configured the chain
+// continues from its last record.
+func FromEnv() (*Logger, error) {
+	path := os.Getenv(LogFileEnv)
+	tag := os.Getenv(SyslogEnv)
+	if on, err := strconv.ParseBool(tag); err == nil {
+		tag = ""
+		if on {
+			tag = DefaultSyslogTag
+		}
+	}
+	if path == "" && tag == "" {
+		return nil, nil
+	}
+	var (
+		sinks []Sink
+		prev  string
+	)
+	if path != "" {
+		f, last, err := OpenFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("open audit log: %w", err)
+		}
+		sinks = append(sinks, f)
+		prev = last
+	}
+	if tag != "" {
+		s, err := OpenSyslog(tag)
+		if err != nil {
+			for _, s := range sinks {
+				s.Close()
+			}
+			return nil, fmt.Errorf("open syslog: %w", err)
+		}
+		sinks = append(sinks, s)
+	}
+	return New(prev, sinks...), nil
+}
+
+// secretArgRE matches argument names whose values must not be logged.
+var secretArgRE = regexp.MustCompile(`(?i)(token|password|passwd|secret|credential|api_?key|private_?key)`)
+
+// Redacted replaces the values of secret arguments.
+const Redacted = "[REDACTED]"
+
+// redactArgs returns a copy of args with the values of secret arguments
+// replaced and every other string, including those in nested objects and
+// lists such as debug_node commands, passed through r.
+func redactArgs(r *redact.Redactor, args map[string]any) map[string]any {
+	if args == nil {
+		return nil
+	}
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		if secretArgRE.MatchString(k) {
+			out[k] = Redacted
+			continue
+		}
+		out[k] = redactValue(r, v)
+	}
+	return out
+}
+
+// redactValue applies redactArgs to v and its elements.
+func redactValue(r *redact.Redactor, v any) any {
+	switch v := v.(type) {
+	case string:
+		s, _ := r.String(v)
+		return s
+	case map[string]any:
+		return redactArgs(r, v)
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = redactValue(r, e)
+		}
+		return out
+	case []string:
+		out := make([]string, len(v))
+		for i, e := range v {
+			out[i], _ = r.String(e)
+		}
+		return out
+	}
+	return v
+}
+
+// targetNode returns the node a call operates on, if any.
+func targetNode(args map[string]any) string {
+	for _, k := range []string{"node_name", "node"} {
+		if s, ok := args[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// clientFromContext describes the MCP session making the call.
+func clientFromContext(ctx context.Context) Client {
+	var c Client
+	if id := authz.FromContext(ctx); id.Authenticated() {
+		c.User, c.Groups = id.Name(), id.GroupNames()
+	}
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return c
+	}
+	c.Session = session.SessionID()
+	if info, ok := session.(server.SessionWithClientInfo); ok {
+		impl := info.GetClientInfo()
+		c.Name, c.Version = impl.Name, impl.Version
+	}
+	return c
+}
+
+// outputHash returns the SHA-256 of the JSON encoding of the result content.
+func outputHash(res *mcp.CallToolResult) string {
+	if res == nil {
+		return ""
+	}
+	data, err := json.Marshal(res.Content)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// resultError returns the text of an error result, shortened for the log.
+func resultError(res *mcp.CallToolResult) string {
+	for _, c := range res.Content {
+		if t, ok := c.(mcp.TextContent); ok {
+			const max = 512
+			if len(t.Text) > max {
+				return t.Text[:max] + "..."
+			}
+			return t.Text
+		}
+	}
+	return ""
+}
+
+// callID returns a random identifier shared by the records of one call.
+func callID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Middleware returns a tool handler middleware that writes two records per
+// call: one with StatusStarted before the tool runs and one with the outcome
+// once it returns. cluster, which may be nil, names the cluster the call
+// targets, and r removes secrets from the logged arguments. If the first
+// record cannot be written the tool is not run; if the second cannot, the
+// call's result is withheld. Either way an error is returned, so no
+// privileged action goes unrecorded silently, and a call that never
+// completes still leaves its started record behind.
+func Middleware(l *Logger, r *redact.Redactor, cluster func(context.Context) string) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			start := time.Now()
+			args := req.GetArguments()
+			rec := Record{
+				Time:      start,
+				Call:      callID(),
+				Client:    clientFromContext(ctx),
+				Tool:      req.Params.Name,
+				Arguments: redactArgs(r, args),
+				Node:      targetNode(args),
+				Status:    StatusStarted,
+			}
+			if cluster != nil {
+				rec.Cluster = cluster(ctx)
+			}
+			if logErr := l.Log(rec); logErr != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("audit log write failed, call not run: %v", logErr)), nil
+			}
+
+			res, err := next(ctx, req)
+			rec.Duration = time.Since(start).Seconds()
+			rec.Status = StatusOK
+			switch {
+			case err != nil:
+				rec.Status = StatusFailed
+				rec.Error = err.Error()
+			case res != nil && res.IsError:
+				rec.Status = StatusError
+				rec.Error = resultError(res)
+			}
+			rec.OutputSHA256 = outputHash(res)
+			if logErr := l.Log(rec); logErr != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("audit log write failed, result withheld: %v", logErr)), nil
+			}
+			return res, err
+		}
+	}
+}
+
+// Unavailable returns a middleware that rejects every call with err. It is
+// used when auditing is configured but the log cannot be opened, so the
+// server fails closed instead of running tools unaudited.
+func Unavailable(err error) server.ToolHandlerMiddleware {
+	return func(server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultError(fmt.Sprintf("audit log unavailable: %v", err)), nil
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows && !plan9
+
+package audit
+
+import "log/syslog"
+
+// SyslogSink sends records to the local syslog daemon with the
+// LOG_AUTHPRIV facility, which is normally readable only by root.
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// OpenSyslog connects to the local syslog daemon using tag.
+func OpenSyslog(tag string) (*SyslogSink, error) {
+	w, err := syslog.New(syslog.LOG_AUTHPRIV|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+// Write sends line as a single syslog message.
+func (s *SyslogSink) Write(line []byte) error {
+	return s.w.Info(string(line))
+}
+
+// Close closes the syslog connection.
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
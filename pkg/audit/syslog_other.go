@@ -0,0 +1,19 @@
+//go:build windows || plan9
+
+package audit
+
+import "errors"
+
+// SyslogSink is not available on this platform.
+type SyslogSink struct{}
+
+// OpenSyslog always fails because syslog is not available on this platform.
+func OpenSyslog(tag string) (*SyslogSink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+// Write is never called because OpenSyslog fails.
+func (s *SyslogSink) Write(line []byte) error { return nil }
+
+// Close is never called because OpenSyslog fails.
+func (s *SyslogSink) Close() error { return nil }
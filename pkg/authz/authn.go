@@ -0,0 +1,177 @@
+package authz
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidToken is returned by authenticators for credentials they do not
+// accept.
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// Authenticator verifies a bearer token and returns the caller it belongs to.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (Identity, error)
+}
+
+// Chain tries each authenticator in turn and returns the first identity.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator.
+func (c Chain) Authenticate(ctx context.Context, token string) (Identity, error) {
+	var errs []error
+	for _, a := range c {
+		id, err := a.Authenticate(ctx, token)
+		if err == nil {
+			return id, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return Identity{}, ErrInvalidToken
+	}
+	return Identity{}, errors.Join(errs...)
+}
+
+// StaticToken maps a bearer token to an identity. Either Token or its
+// hex-encoded SHA256 may be given, so the file need not hold the plain token.
+type StaticToken struct {
+	Token       string   `yaml:"token"`
+	TokenSHA256 string   `yaml:"tokenSHA256"`
+	Subject     string   `yaml:"subject"`
+	Groups      []string `yaml:"groups"`
+}
+
+// StaticTokens authenticates a fixed set of tokens, for service accounts and
+// small deployments without an identity provider.
+type StaticTokens struct {
+	byHash map[[sha256.Size]byte]Identity
+}
+
+// NewStaticTokens validates entries and returns an authenticator for them.
+func NewStaticTokens(entries []StaticToken) (*StaticTokens, error) {
+	st := &StaticTokens{byHash: map[[sha256.Size]byte]Identity{}}
+	for i, e := range entries {
+		if e.Subject == "" {
+			return nil, fmt.Errorf("token %d: subject required", i+1)
+		}
+		var sum [sha256.Size]byte
+		switch {
+		case e.Token != "":
+			sum = sha256.Sum256([]byte(e.Token))
+		case e.TokenSHA256 != "":
+			b, err := hex.DecodeString(e.TokenSHA256)
+			if err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("token %d: tokenSHA256 must be a hex-encoded SHA256", i+1)
+			}
+			copy(sum[:], b)
+		default:
+			return nil, fmt.Errorf("token %d: token or tokenSHA256 required", i+1)
+		}
+		st.byHash[sum] = Identity{Subject: e.Subject, Groups: e.Groups}
+	}
+	return st, nil
+}
+
+// LoadStaticTokens reads a YAML list of StaticToken entries.
+func LoadStaticTokens(path string) (*StaticTokens, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read tokens file: %w", err)
+	}
+	var entries []StaticToken
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse tokens file: %w", err)
+	}
+	return NewStaticTokens(entries)
+}
+
+// Authenticate implements Authenticator. Tokens are compared by hash in
+// constant time.
+func (st *StaticTokens) Authenticate(_ context.Context, token string) (Identity, error) {
+	sum := sha256.Sum256([]byte(token))
+	for h, id := range st.byHash {
+		if subtle.ConstantTimeCompare(h[:], sum[:]) == 1 {
+			return id, nil
+		}
+	}
+	return Identity{}, ErrInvalidToken
+}
+
+// Environment variables read by AuthenticatorFromEnv.
+const (
+	TokensFileEnv   = "CRIO_MCP_AUTH_TOKENS_FILE"
+	OIDCIssuerEnv   = "CRIO_MCP_OIDC_ISSUER"
+	OIDCAudienceEnv = "CRIO_MCP_OIDC_AUDIENCE"
+	OIDCUsernameEnv = "CRIO_MCP_OIDC_USERNAME_CLAIM"
+	OIDCGroupsEnv   = "CRIO_MCP_OIDC_GROUPS_CLAIM"
+)
+
+// AuthenticatorFromEnv builds an authenticator from CRIO_MCP_AUTH_TOKENS_FILE
+// and the CRIO_MCP_OIDC_* variables. It returns nil if neither is configured.
+func AuthenticatorFromEnv(ctx context.Context) (Authenticator, error) {
+	var chain Chain
+	if path := os.Getenv(TokensFileEnv); path != "" {
+		st, err := LoadStaticTokens(path)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, st)
+	}
+	if issuer := os.Getenv(OIDCIssuerEnv); issuer != "" {
+		o, err := NewOIDC(ctx, OIDCConfig{
+			Issuer:        issuer,
+			Audience:      os.Getenv(OIDCAudienceEnv),
+			UsernameClaim: os.Getenv(OIDCUsernameEnv),
+			GroupsClaim:   os.Getenv(OIDCGroupsEnv),
+		})
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, o)
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// bearerToken extracts the token from an Authorization header.
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Handler wraps an MCP HTTP transport, such as server.StreamableHTTPServer or
+// server.SSEServer, and rejects requests without a valid bearer token. The
+// caller's identity is stored in the request context, which both transports
+// pass on to tool handlers and tool filters.
+func Handler(a Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="crio-mcp-server"`)
+			http.Error(w, "bearer token required", http.StatusUnauthorized)
+			return
+		}
+		id, err := a.Authenticate(r.Context(), token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="crio-mcp-server", error="invalid_token"`)
+			http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
+}
@@ -0,0 +1,85 @@
+package authz
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticTokens(t *testing.T) {
+	sum := sha256.Sum256([]byte("bot-token"))
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	os.WriteFile(path, []byte(`
+- token: alice-token
+  subject: alice
+  groups: [sre]
+- tokenSHA256: `+hex.EncodeToString(sum[:])+`
+  subject: ci-bot
+`), 0o600)
+	st, err := LoadStaticTokens(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	id, err := st.Authenticate(context.Background(), "alice-token")
+	if err != nil || id.Subject != "alice" || len(id.Groups) != 1 {
+		t.Fatalf("unexpected identity %+v, %v", id, err)
+	}
+	if id, err := st.Authenticate(context.Background(), "bot-token"); err != nil || id.Subject != "ci-bot" {
+		t.Fatalf("unexpected identity %+v, %v", id, err)
+	}
+	if _, err := st.Authenticate(context.Background(), "nope"); err == nil {
+		t.Fatal("expected invalid token")
+	}
+	if _, err := NewStaticTokens([]StaticToken{{Token: "x"}}); err == nil {
+		t.Fatal("expected missing subject error")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	st, _ := NewStaticTokens([]StaticToken{{Token: "alice-token", Subject: "alice"}})
+	var got Identity
+	h := Handler(Chain{st}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+	for _, c := range []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Basic YWxpY2U6eA==", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer alice-token", http.StatusOK},
+	} {
+		got = Identity{}
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Fatalf("%q: got status %d, want %d", c.header, rec.Code, c.status)
+		}
+		if c.status == http.StatusOK && got.Subject != "alice" {
+			t.Fatalf("identity not passed on: %+v", got)
+		}
+		if c.status != http.StatusOK && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%q: missing WWW-Authenticate", c.header)
+		}
+	}
+}
+
+func TestFromContextAnonymous(t *testing.T) {
+	id := FromContext(context.Background())
+	if id.Authenticated() || id.Subject != Anonymous {
+		t.Fatalf("unexpected identity %+v", id)
+	}
+	if g := id.memberOf(); len(g) != 1 || g[0] != GroupUnauthenticated {
+		t.Fatalf("unexpected groups %v", g)
+	}
+}
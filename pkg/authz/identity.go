@@ -0,0 +1,78 @@
+// Package authz authenticates HTTP callers and decides which tools they may
+// run. Authentication puts an Identity in the request context; a Policy then
+// allows or denies each tool call and hides tools the caller cannot use.
+package authz
+
+import "context"
+
+// Well-known subject and groups, following Kubernetes conventions.
+const (
+	Anonymous            = "system:anonymous"
+	GroupAuthenticated   = "system:authenticated"
+	GroupUnauthenticated = "system:unauthenticated"
+)
+
+// Identity is an authenticated caller. Issuer is the OIDC issuer that
+// vouched for the caller and is empty for static tokens, so that a provider's
+// user cannot take the name of a service account or of another provider's
+// user.
+type Identity struct {
+	Issuer  string
+	Subject string
+	Groups  []string
+}
+
+// qualify prefixes name with the identity's issuer and a "#", the form the
+// API server gives OIDC users without a configured prefix.
+func (id Identity) qualify(name string) string {
+	if id.Issuer == "" {
+		return name
+	}
+	return id.Issuer + "#" + name
+}
+
+// Name returns the name policy rules match against: the subject, qualified
+// with the issuer for OIDC callers, as in https://idp.example.com#alice.
+func (id Identity) Name() string {
+	return id.qualify(id.Subject)
+}
+
+// GroupNames returns the groups policy rules match against, qualified with
+// the issuer for OIDC callers like Name.
+func (id Identity) GroupNames() []string {
+	groups := make([]string, 0, len(id.Groups))
+	for _, g := range id.Groups {
+		groups = append(groups, id.qualify(g))
+	}
+	return groups
+}
+
+// Authenticated reports whether the identity came from a verified credential.
+func (id Identity) Authenticated() bool {
+	return id.Subject != "" && id.Subject != Anonymous
+}
+
+// memberOf returns the groups used for policy matching, including
+// system:authenticated or system:unauthenticated.
+func (id Identity) memberOf() []string {
+	if id.Authenticated() {
+		return append(id.GroupNames(), GroupAuthenticated)
+	}
+	return []string{GroupUnauthenticated}
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the caller's identity, or the anonymous identity if the
+// request was not authenticated, as is the case for the stdio transport.
+func FromContext(ctx context.Context) Identity {
+	if id, ok := ctx.Value(identityKey{}).(Identity); ok {
+		return id
+	}
+	return Identity{Subject: Anonymous}
+}
@@ -0,0 +1,155 @@
+package authz
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+type testIssuer struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	keys   []map[string]string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &testIssuer{rsaKey: rk, ecKey: ek}
+	iss.keys = []map[string]string{
+		{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": b64.EncodeToString(rk.N.Bytes()), "e": b64.EncodeToString(big.NewInt(int64(rk.E)).Bytes())},
+	}
+	mux := http.NewServeMux()
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": iss.keys})
+	})
+	return iss
+}
+
+func (iss *testIssuer) addECKey() {
+	k := iss.ecKey
+	iss.keys = append(iss.keys, map[string]string{
+		"kty": "EC", "kid": "ec1", "crv": "P-256",
+		"x": b64.EncodeToString(k.X.FillBytes(make([]byte, 32))),
+		"y": b64.EncodeToString(k.Y.FillBytes(make([]byte, 32))),
+	})
+}
+
+func (iss *testIssuer) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch alg {
+	case "RS256":
+		s, err := rsa.SignPKCS1v15(rand.Reader, iss.rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = s
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, iss.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + b64.EncodeToString(sig)
+}
+
+func TestOIDC(t *testing.T) {
+	iss := newTestIssuer(t)
+	o, err := NewOIDC(context.Background(), OIDCConfig{Issuer: iss.URL, Audience: "crio-mcp", UsernameClaim: "email"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now()
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":    iss.URL,
+			"aud":    []string{"other", "crio-mcp"},
+			"exp":    now.Add(time.Hour).Unix(),
+			"sub":    "1234",
+			"email":  "alice@example.com",
+			"groups": []string{"sre", "dev"},
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	id, err := o.Authenticate(context.Background(), iss.sign(t, "RS256", "rsa1", claims(nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id.Subject != "alice@example.com" || len(id.Groups) != 2 || id.Groups[0] != "sre" {
+		t.Fatalf("unexpected identity %+v", id)
+	}
+	if id.Name() != iss.URL+"#alice@example.com" || id.GroupNames()[0] != iss.URL+"#sre" {
+		t.Fatalf("OIDC names not qualified with the issuer: %q %q", id.Name(), id.GroupNames())
+	}
+
+	for name, token := range map[string]string{
+		"expired":      iss.sign(t, "RS256", "rsa1", claims(map[string]any{"exp": now.Add(-time.Hour).Unix()})),
+		"audience":     iss.sign(t, "RS256", "rsa1", claims(map[string]any{"aud": "other"})),
+		"issuer":       iss.sign(t, "RS256", "rsa1", claims(map[string]any{"iss": "https://evil.example.com"})),
+		"not yet":      iss.sign(t, "RS256", "rsa1", claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})),
+		"unknown key":  iss.sign(t, "RS256", "rsa2", claims(nil)),
+		"alg mismatch": iss.sign(t, "ES256", "rsa1", claims(nil)),
+		"garbage":      "not.a.jwt",
+	} {
+		if _, err := o.Authenticate(context.Background(), token); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	tampered := iss.sign(t, "RS256", "rsa1", claims(nil))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+	if _, err := o.Authenticate(context.Background(), tampered); err == nil {
+		t.Fatal("tampered signature accepted")
+	}
+
+	// A rotated-in key is fetched when a token names it.
+	iss.addECKey()
+	if id, err := o.Authenticate(context.Background(), iss.sign(t, "ES256", "ec1", claims(nil))); err != nil || id.Subject != "alice@example.com" {
+		t.Fatalf("rotated key not used: %+v, %v", id, err)
+	}
+}
+
+func TestNewOIDCIssuerMismatch(t *testing.T) {
+	iss := newTestIssuer(t)
+	if _, err := NewOIDC(context.Background(), OIDCConfig{Issuer: iss.URL + "/", Audience: "x"}); err == nil {
+		t.Fatal("expected issuer mismatch")
+	}
+	if _, err := NewOIDC(context.Background(), OIDCConfig{Issuer: iss.URL}); err == nil {
+		t.Fatal("expected audience to be required")
+	}
+}
@@ -0,0 +1,352 @@
+package authz
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
+	"github.com/harche/crio-mcp-server/pkg/openshift"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule grants the identities it names access to a set of tools. The optional
+// constraints further limit which clusters, nodes and crictl subcommands those
+// tools may be used with; an empty constraint allows everything.
+type Rule struct {
+	Name     string   `yaml:"name"`
+	Subjects []string `yaml:"subjects"`
+	Groups   []string `yaml:"groups"`
+	// Tools lists tool names; "*" allows every tool.
+	Tools []string `yaml:"tools"`
+	// Clusters lists API server URLs as printed by "oc whoami --show-server".
+	Clusters []string `yaml:"clusters"`
+	// NodeSelector must match the labels of every node named by the call's
+	// node_name, node or pool argument.
+	NodeSelector map[string]string `yaml:"nodeSelector"`
+	// CrictlSubcommands limits run_crictl to these subcommands.
+	CrictlSubcommands []string `yaml:"crictlSubcommands"`
+}
+
+// Policy is an ordered list of allow rules. A call is allowed if any rule
+// that names the caller and the tool has all its constraints satisfied;
+// everything else is denied.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadPolicy reads a YAML policy file. Unknown fields are rejected so that a
+// misspelt constraint does not silently grant more than intended.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("policy %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	for i, r := range p.Rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+		if len(r.Subjects) == 0 && len(r.Groups) == 0 {
+			return fmt.Errorf("%s: subjects or groups required", name)
+		}
+		if len(r.Tools) == 0 {
+			return fmt.Errorf("%s: tools required", name)
+		}
+	}
+	return nil
+}
+
+// PolicyFileEnv names the policy file read by PolicyFromEnv.
+const PolicyFileEnv = "CRIO_MCP_POLICY_FILE"
+
+// PolicyFromEnv loads the policy named by CRIO_MCP_POLICY_FILE, or returns nil
+// when it is unset and every caller may use every tool.
+func PolicyFromEnv() (*Policy, error) {
+	path := os.Getenv(PolicyFileEnv)
+	if path == "" {
+		return nil, nil
+	}
+	return LoadPolicy(path)
+}
+
+func (r Rule) matchesIdentity(id Identity) bool {
+	if slices.Contains(r.Subjects, id.Name()) {
+		return true
+	}
+	for _, g := range id.memberOf() {
+		if slices.Contains(r.Groups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) allowsTool(tool string) bool {
+	return slices.Contains(r.Tools, "*") || slices.Contains(r.Tools, tool)
+}
+
+// Tools reports whether id may use tool under some rule, ignoring the
+// constraints that depend on the call's arguments.
+func (p *Policy) Tools(id Identity, tool string) bool {
+	for _, r := range p.Rules {
+		if r.matchesIdentity(id) && r.allowsTool(tool) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup resolves the cluster and node facts that rule constraints refer to.
+type Lookup struct {
+	Cluster    func(ctx context.Context) string
+	NodeLabels func(ctx context.Context, node string) (map[string]string, error)
+	PoolNodes  func(ctx context.Context, pool string) ([]string, error)
+	// ToolNodes resolves, by tool name, the nodes of tools that find their
+	// node themselves rather than taking it as an argument, such as a tool
+	// that runs on the node of a given pod.
+	ToolNodes map[string]func(ctx context.Context, args map[string]any) ([]string, error)
+}
+
+// call caches lookups while the rules for one tool call are evaluated.
+type call struct {
+	lookup  Lookup
+	tool    string
+	args    map[string]any
+	cluster *string
+	nodes   []string
+	nodeErr error
+	labels  map[string]map[string]string
+}
+
+func (c *call) currentCluster(ctx context.Context) string {
+	if c.cluster == nil {
+		s := ""
+		if c.lookup.Cluster != nil {
+			s = strings.TrimSuffix(c.lookup.Cluster(ctx), "/")
+		}
+		c.cluster = &s
+	}
+	return *c.cluster
+}
+
+// targetNodes returns the nodes named by the call's arguments and those the
+// tool's ToolNodes resolver finds.
+func (c *call) targetNodes(ctx context.Context) ([]string, error) {
+	if c.nodes != nil || c.nodeErr != nil {
+		return c.nodes, c.nodeErr
+	}
+	c.nodes = []string{}
+	for _, k := range []string{"node_name", "node"} {
+		if s, ok := c.args[k].(string); ok && s != "" {
+			c.nodes = append(c.nodes, s)
+		}
+	}
+	if pool, ok := c.args["pool"].(string); ok && pool != "" {
+		if c.lookup.PoolNodes == nil {
+			c.nodeErr = fmt.Errorf("cannot resolve pool %s", pool)
+			return nil, c.nodeErr
+		}
+		nodes, err := c.lookup.PoolNodes(ctx, pool)
+		if err != nil {
+			c.nodeErr = err
+			return nil, err
+		}
+		c.nodes = append(c.nodes, nodes...)
+	}
+	if resolve := c.lookup.ToolNodes[c.tool]; resolve != nil {
+		nodes, err := resolve(ctx, c.args)
+		if err != nil {
+			c.nodeErr = err
+			return nil, err
+		}
+		c.nodes = append(c.nodes, nodes...)
+	}
+	return c.nodes, nil
+}
+
+func (c *call) nodeLabels(ctx context.Context, node string) (map[string]string, error) {
+	if l, ok := c.labels[node]; ok {
+		return l, nil
+	}
+	if c.lookup.NodeLabels == nil {
+		return nil, fmt.Errorf("cannot look up labels of node %s", node)
+	}
+	l, err := c.lookup.NodeLabels(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	if c.labels == nil {
+		c.labels = map[string]map[string]string{}
+	}
+	c.labels[node] = l
+	return l, nil
+}
+
+// shellMeta are characters a shell would treat specially. run_crictl quotes
+// its arguments, but a call that needs them is never what a subcommand
+// restriction is meant to allow.
+const shellMeta = ";&|`$<>()\\\n"
+
+// crictlShellMeta reports whether an argument of a run_crictl call contains
+// shell metacharacters.
+func crictlShellMeta(args map[string]any) bool {
+	list, _ := args["args"].([]any)
+	for _, a := range list {
+		if strings.ContainsAny(fmt.Sprint(a), shellMeta) {
+			return true
+		}
+	}
+	return false
+}
+
+// crictlSubcommand returns the subcommand of a run_crictl call, "ps" when no
+// arguments are given and an empty string when it cannot be determined.
+func crictlSubcommand(args map[string]any) string {
+	list, _ := args["args"].([]any)
+	if len(list) == 0 {
+		return "ps"
+	}
+	words := make([]string, len(list))
+	for i, a := range list {
+		words[i] = fmt.Sprint(a)
+	}
+	return openshift.CrictlSubcommand(words)
+}
+
+// check returns nil if the rule's constraints allow the call, or the reason
+// it does not.
+func (r Rule) check(ctx context.Context, c *call) error {
+	if len(r.Clusters) > 0 {
+		cluster := c.currentCluster(ctx)
+		ok := false
+		for _, allowed := range r.Clusters {
+			if strings.TrimSuffix(allowed, "/") == cluster {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("cluster %q is not allowed", cluster)
+		}
+	}
+	if len(r.NodeSelector) > 0 {
+		nodes, err := c.targetNodes(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot check node selector: %w", err)
+		}
+		// Without a node to check, the call could reach any node.
+		if len(nodes) == 0 {
+			return fmt.Errorf("cannot check node selector: %s does not name a node", c.tool)
+		}
+		for _, n := range nodes {
+			labels, err := c.nodeLabels(ctx, n)
+			if err != nil {
+				return fmt.Errorf("cannot check node selector: %w", err)
+			}
+			for k, v := range r.NodeSelector {
+				if got, ok := labels[k]; !ok || got != v {
+					return fmt.Errorf("node %s does not match the allowed node selector", n)
+				}
+			}
+		}
+	}
+	if len(r.CrictlSubcommands) > 0 && c.tool == "run_crictl" {
+		if crictlShellMeta(c.args) {
+			return fmt.Errorf("crictl arguments with shell metacharacters are not allowed")
+		}
+		sub := crictlSubcommand(c.args)
+		if !slices.Contains(r.CrictlSubcommands, sub) {
+			return fmt.Errorf("crictl subcommand %q is not allowed", sub)
+		}
+	}
+	return nil
+}
+
+// Authorize returns nil if the policy allows id to call tool with args, and
+// an error explaining the denial otherwise.
+func (p *Policy) Authorize(ctx context.Context, id Identity, tool string, args map[string]any, lookup Lookup) error {
+	c := &call{lookup: lookup, tool: tool, args: args}
+	var reason error
+	for _, r := range p.Rules {
+		if !r.matchesIdentity(id) || !r.allowsTool(tool) {
+			continue
+		}
+		err := r.check(ctx, c)
+		if err == nil {
+			return nil
+		}
+		if reason == nil {
+			reason = err
+		}
+	}
+	if reason == nil {
+		return fmt.Errorf("permission denied: %s may not use %s", id.Name(), tool)
+	}
+	return fmt.Errorf("permission denied: %s may not use %s here: %w", id.Name(), tool, reason)
+}
+
+// Middleware returns a tool handler middleware that evaluates p before the
+// handler runs and returns an error result for denied calls. During a dry run
+// the decision is recorded in the plan instead; a denied call stops there.
+func Middleware(p *Policy, lookup Lookup) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			plan := dryrun.FromContext(ctx)
+			err := p.Authorize(dryrun.Live(ctx), FromContext(ctx), req.Params.Name, req.GetArguments(), lookup)
+			switch {
+			case plan != nil && err != nil:
+				plan.SetPolicy("denied: " + err.Error())
+				return &mcp.CallToolResult{}, nil
+			case plan != nil:
+				plan.SetPolicy("allowed")
+			case err != nil:
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// ToolFilter returns a filter for server.WithToolFilter that hides the tools
+// the caller may not use.
+func ToolFilter(p *Policy) server.ToolFilterFunc {
+	return func(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+		id := FromContext(ctx)
+		var out []mcp.Tool
+		for _, t := range tools {
+			if p.Tools(id, t.Name) {
+				out = append(out, t)
+			}
+		}
+		return out
+	}
+}
+
+// Unavailable returns a middleware that rejects every call with err. It is
+// used when a policy is configured but cannot be loaded.
+func Unavailable(err error) server.ToolHandlerMiddleware {
+	return func(server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultError(fmt.Sprintf("authorization policy unavailable: %v", err)), nil
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const testPolicy = `
+rules:
+  - name: sre
+    groups: [sre]
+    tools: ["*"]
+    clusters: ["https://api.prod.example.com:6443/"]
+  - name: support
+    subjects: [bob]
+    tools: [run_crictl, debug_node, check_cve_exposure, diagnose_pod]
+    nodeSelector:
+      node-role.kubernetes.io/worker: ""
+    crictlSubcommands: [ps, inspect, logs]
+  - name: everyone
+    groups: [system:authenticated]
+    tools: [search_kcs]
+`
+
+func loadTestPolicy(t *testing.T, text string) *Policy {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(path, []byte(text), 0o600)
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return p
+}
+
+var testLookup = Lookup{
+	Cluster: func(context.Context) string { return "https://api.prod.example.com:6443" },
+	NodeLabels: func(_ context.Context, node string) (map[string]string, error) {
+		switch node {
+		case "worker-0", "worker-1":
+			return map[string]string{"node-role.kubernetes.io/worker": ""}, nil
+		case "master-0":
+			return map[string]string{"node-role.kubernetes.io/master": ""}, nil
+		}
+		return nil, errors.New("not found")
+	},
+	PoolNodes: func(_ context.Context, pool string) ([]string, error) {
+		if pool == "worker" {
+			return []string{"worker-0", "worker-1"}, nil
+		}
+		return []string{"master-0"}, nil
+	},
+	ToolNodes: map[string]func(context.Context, map[string]any) ([]string, error){
+		"diagnose_pod": func(_ context.Context, args map[string]any) ([]string, error) {
+			switch args["pod_name"] {
+			case "web-0":
+				return []string{"worker-0"}, nil
+			case "etcd-0":
+				return []string{"master-0"}, nil
+			}
+			return nil, errors.New("pod not found")
+		},
+	},
+}
+
+func TestAuthorize(t *testing.T) {
+	p := loadTestPolicy(t, testPolicy)
+	alice := Identity{Subject: "alice", Groups: []string{"sre"}}
+	bob := Identity{Subject: "bob"}
+	carol := Identity{Subject: "carol"}
+	anon := Identity{Subject: Anonymous}
+	oidcBob := Identity{Issuer: "https://idp.example.com", Subject: "bob", Groups: []string{"sre"}}
+	cases := []struct {
+		name string
+		id   Identity
+		tool string
+		args map[string]any
+		deny string
+	}{
+		{"sre any tool", alice, "collect_must_gather", nil, ""},
+		{"worker node", bob, "debug_node", map[string]any{"node_name": "worker-0"}, ""},
+		{"master node", bob, "debug_node", map[string]any{"node_name": "master-0"}, "does not match the allowed node selector"},
+		{"unknown node", bob, "debug_node", map[string]any{"node_name": "gone"}, "cannot check node selector"},
+		{"no node", bob, "debug_node", map[string]any{"commands": []any{"uptime"}}, "debug_node does not name a node"},
+		{"pod on worker", bob, "diagnose_pod", map[string]any{"namespace": "app", "pod_name": "web-0"}, ""},
+		{"pod on master", bob, "diagnose_pod", map[string]any{"namespace": "openshift-etcd", "pod_name": "etcd-0"}, "node master-0 does not match"},
+		{"unknown pod", bob, "diagnose_pod", map[string]any{"namespace": "app", "pod_name": "gone"}, "cannot check node selector: pod not found"},
+		{"worker pool", bob, "check_cve_exposure", map[string]any{"pool": "worker"}, ""},
+		{"master pool", bob, "check_cve_exposure", map[string]any{"pool": "master"}, "master-0"},
+		{"crictl read", bob, "run_crictl", map[string]any{"node_name": "worker-1", "args": []any{"-r", "unix:///var/run/crio/crio.sock", "inspect", "abc"}}, ""},
+		{"crictl flag value", bob, "run_crictl", map[string]any{"node_name": "worker-1", "args": []any{"-r", "inspect", "rmp", "-a"}}, `subcommand "rmp"`},
+		{"crictl unknown flag", bob, "run_crictl", map[string]any{"node_name": "worker-1", "args": []any{"--bogus", "ps", "rmp"}}, `subcommand ""`},
+		{"crictl default", bob, "run_crictl", map[string]any{"node_name": "worker-1"}, ""},
+		{"crictl write", bob, "run_crictl", map[string]any{"node_name": "worker-1", "args": []any{"rmp", "-a"}}, `subcommand "rmp"`},
+		{"crictl chained", bob, "run_crictl", map[string]any{"node_name": "worker-1", "args": []any{"ps", ";", "crictl", "rmp", "-a"}}, "shell metacharacters"},
+		{"crictl joined", bob, "run_crictl", map[string]any{"node_name": "worker-1", "args": []any{"ps;", "crictl", "rmp", "-a"}}, "shell metacharacters"},
+		{"crictl substitution", bob, "run_crictl", map[string]any{"node_name": "worker-1", "args": []any{"logs", "$(crictl rmp -a)"}}, "shell metacharacters"},
+		{"tool not granted", bob, "collect_must_gather", nil, "bob may not use collect_must_gather"},
+		{"authenticated group", carol, "search_kcs", nil, ""},
+		{"oidc user named like a static token", oidcBob, "debug_node", map[string]any{"node_name": "worker-0"}, "https://idp.example.com#bob may not use debug_node"},
+		{"oidc group named like a static group", oidcBob, "collect_must_gather", nil, "may not use collect_must_gather"},
+		{"oidc authenticated group", oidcBob, "search_kcs", nil, ""},
+		{"anonymous", anon, "search_kcs", nil, "system:anonymous may not use search_kcs"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := p.Authorize(context.Background(), c.id, c.tool, c.args, testLookup)
+			switch {
+			case c.deny == "" && err != nil:
+				t.Fatalf("unexpected denial: %v", err)
+			case c.deny != "" && (err == nil || !strings.Contains(err.Error(), c.deny)):
+				t.Fatalf("expected denial containing %q, got %v", c.deny, err)
+			}
+		})
+	}
+
+	other := testLookup
+	other.Cluster = func(context.Context) string { return "https://api.staging.example.com:6443" }
+	if err := p.Authorize(context.Background(), alice, "debug_node", nil, other); err == nil || !strings.Contains(err.Error(), "cluster") {
+		t.Fatalf("expected cluster denial, got %v", err)
+	}
+}
+
+func TestLoadPolicyRejectsMistakes(t *testing.T) {
+	for name, text := range map[string]string{
+		"unknown field": "rules:\n  - groups: [sre]\n    tools: [\"*\"]\n    nodeselector: {a: b}\n",
+		"no identity":   "rules:\n  - tools: [\"*\"]\n",
+		"no tools":      "rules:\n  - subjects: [alice]\n",
+	} {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		os.WriteFile(path, []byte(text), 0o600)
+		if _, err := LoadPolicy(path); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestToolFilterAndMiddleware(t *testing.T) {
+	p := loadTestPolicy(t, testPolicy)
+	ctx := WithIdentity(context.Background(), Identity{Subject: "bob"})
+	tools := ToolFilter(p)(ctx, []mcp.Tool{{Name: "debug_node"}, {Name: "collect_must_gather"}, {Name: "search_kcs"}})
+	if len(tools) != 2 || tools[0].Name != "debug_node" || tools[1].Name != "search_kcs" {
+		t.Fatalf("unexpected tools %+v", tools)
+	}
+
+	called := false
+	h := Middleware(p, testLookup)(func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("ok"), nil
+	})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "debug_node", Arguments: map[string]any{"node_name": "master-0"}}}
+	res, _ := h(ctx, req)
+	if called || !res.IsError {
+		t.Fatalf("denied call reached the handler: %+v", res)
+	}
+	req.Params.Arguments = map[string]any{"node_name": "worker-0"}
+	if res, _ := h(ctx, req); !called || res.IsError {
+		t.Fatalf("allowed call rejected: %+v", res)
+	}
+}
+
+func TestMiddlewareDryRun(t *testing.T) {
+	p := loadTestPolicy(t, testPolicy)
+	called := false
+	h := dryrun.Middleware(true, nil)(Middleware(p, testLookup)(func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("ok"), nil
+	}))
+	ctx := WithIdentity(context.Background(), Identity{Subject: "bob"})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "run_crictl", Arguments: map[string]any{"node_name": "worker-0", "args": []any{"rmp", "abc"}}}}
+	res, _ := h(ctx, req)
+	text := res.Content[0].(mcp.TextContent).Text
+	if called || !strings.Contains(text, `policy: denied: permission denied: bob may not use run_crictl here: crictl subcommand "rmp" is not allowed`) {
+		t.Fatalf("unexpected dry run (handler called: %v):\n%s", called, text)
+	}
+	req.Params.Arguments = map[string]any{"node_name": "worker-0", "args": []any{"ps"}}
+	res, _ = h(ctx, req)
+	if text := res.Content[0].(mcp.TextContent).Text; !called || !strings.Contains(text, "policy: allowed") {
+		t.Fatalf("unexpected dry run (handler called: %v):\n%s", called, text)
+	}
+}
@@ -0,0 +1,98 @@
+// Package catalog loads named definitions, such as prompts and runbooks, from
+// a directory embedded in the binary and from an optional directory on disk
+// that teams use to add their own or replace built-in ones.
+package catalog
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// Loader reads definitions of type T. Kind names them in error messages, as
+// in "prompt" or "runbook".
+type Loader[T any] struct {
+	Kind string
+	// Exts lists the file extensions that hold definitions, such as ".md".
+	// Other files and subdirectories are ignored.
+	Exts []string
+	// Parse parses one file. file is only used in error messages.
+	Parse func(file string, data []byte) (T, error)
+	// Name returns the name a definition is looked up by.
+	Name func(T) string
+}
+
+// ParseFS parses every definition file in dir of fsys. Two files defining the
+// same name are an error.
+func (l Loader[T]) ParseFS(fsys fs.FS, dir string) ([]T, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []T
+	files := map[string]string{}
+	for _, e := range entries {
+		if e.IsDir() || !slices.Contains(l.Exts, path.Ext(e.Name())) {
+			continue
+		}
+		name := path.Join(dir, e.Name())
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := l.Parse(name, data)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := files[l.Name(v)]; ok {
+			return nil, fmt.Errorf("%s: %s %s is already defined in %s", name, l.Kind, l.Name(v), prev)
+		}
+		files[l.Name(v)] = name
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Builtin parses the definitions in dir of the embedded fsys. They are part
+// of the binary, so an error is a programming error and panics.
+func (l Loader[T]) Builtin(fsys fs.FS, dir string) []T {
+	out, err := l.ParseFS(fsys, dir)
+	if err != nil {
+		panic(fmt.Sprintf("embedded %ss: %v", l.Kind, err))
+	}
+	return out
+}
+
+// Load reads the definitions in dir.
+func (l Loader[T]) Load(dir string) ([]T, error) {
+	return l.ParseFS(os.DirFS(filepath.Clean(dir)), ".")
+}
+
+// FromEnv returns builtin together with the definitions in the directory
+// named by the environment variable env, sorted by name. A file with the name
+// of a built-in definition replaces it.
+func (l Loader[T]) FromEnv(builtin []T, env string) ([]T, error) {
+	byName := map[string]T{}
+	for _, v := range builtin {
+		byName[l.Name(v)] = v
+	}
+	if dir := os.Getenv(env); dir != "" {
+		extra, err := l.Load(dir)
+		if err != nil {
+			return nil, fmt.Errorf("load %ss from %s: %w", l.Kind, dir, err)
+		}
+		for _, v := range extra {
+			byName[l.Name(v)] = v
+		}
+	}
+	out := make([]T, 0, len(byName))
+	for _, v := range byName {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return l.Name(out[i]) < l.Name(out[j]) })
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type def struct{ name, body string }
+
+var loader = Loader[def]{
+	Kind: "widget",
+	Exts: []string{".txt"},
+	Parse: func(file string, data []byte) (def, error) {
+		name, body, ok := strings.Cut(string(data), "\n")
+		if !ok {
+			return def{}, fmt.Errorf("%s: missing body", file)
+		}
+		return def{name, body}, nil
+	},
+	Name: func(d def) string { return d.name },
+}
+
+func TestFromEnv(t *testing.T) {
+	builtin := loader.Builtin(fstest.MapFS{
+		"defs/a.txt":     {Data: []byte("a\nbuilt-in")},
+		"defs/b.txt":     {Data: []byte("b\nbuilt-in")},
+		"defs/README.md": {Data: []byte("ignored")},
+	}, "defs")
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b\ncustom"), 0o600)
+	os.WriteFile(filepath.Join(dir, "c.txt"), []byte("c\ncustom"), 0o600)
+	t.Setenv("WIDGETS_DIR", dir)
+
+	got, err := loader.FromEnv(builtin, "WIDGETS_DIR")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(got) != "[{a built-in} {b custom} {c custom}]" {
+		t.Fatalf("unexpected definitions %v", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "d.txt"), []byte("c\nagain"), 0o600)
+	if _, err := loader.FromEnv(builtin, "WIDGETS_DIR"); err == nil || !strings.Contains(err.Error(), "widget c is already defined in c.txt") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	t.Setenv("WIDGETS_DIR", filepath.Join(dir, "missing"))
+	if _, err := loader.FromEnv(builtin, "WIDGETS_DIR"); err == nil || !strings.HasPrefix(err.Error(), "load widgets from") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+// Package confirm holds back risky tool calls until a person approves them.
+// Calls that stop or remove workloads or images, change systemd units or run
+// custom must-gather images are classified as actions; instead of running
+// them the server returns the exact command with a single-use confirmation
+// token, and the call only proceeds when it is repeated unchanged with that
+// token.
+package confirm
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harche/crio-mcp-server/pkg/openshift"
+)
+
+// TokenArg is the tool argument that carries a confirmation token.
+const TokenArg = "confirmation_token"
+
+// Action describes a risky call awaiting confirmation.
+type Action struct {
+	Tool string
+	// Node is the target node, empty for cluster-wide actions.
+	Node string
+	// Command is what will run, exactly as it is passed to the node or oc.
+	Command string
+	// Reason explains why the call needs confirmation.
+	Reason string
+}
+
+// crictlReadOnly are the crictl subcommands that only read runtime state.
+// Every other subcommand needs confirmation.
+var crictlReadOnly = map[string]bool{
+	"ps":          true,
+	"pods":        true,
+	"images":      true,
+	"image":       true,
+	"img":         true,
+	"inspect":     true,
+	"inspecti":    true,
+	"inspectp":    true,
+	"logs":        true,
+	"stats":       true,
+	"statsp":      true,
+	"imagefsinfo": true,
+	"info":        true,
+	"version":     true,
+}
+
+// crictlActions maps the crictl subcommands that change runtime state to
+// what they do.
+var crictlActions = map[string]string{
+	"rm":     "removes containers",
+	"rmi":    "removes images",
+	"rmp":    "removes pod sandboxes and their containers",
+	"stop":   "stops running containers",
+	"stopp":  "stops pod sandboxes and their containers",
+	"update": "changes container resource limits",
+}
+
+// crictlOther are the remaining crictl subcommands that are not read-only.
+// A word after "crictl" in a shell script that is none of these, nor in
+// crictlReadOnly or crictlActions, is not taken as a subcommand, since
+// crictl would refuse it; this keeps "grep crictl /var/log/messages" from
+// needing confirmation.
+var crictlOther = map[string]bool{
+	"attach":                true,
+	"checkpoint":            true,
+	"config":                true,
+	"create":                true,
+	"exec":                  true,
+	"port-forward":          true,
+	"pull":                  true,
+	"run":                   true,
+	"runp":                  true,
+	"start":                 true,
+	"update-runtime-config": true,
+}
+
+// systemctlRE matches systemctl invocations that change unit or system state.
+var systemctlRE = regexp.MustCompile(`\bsystemctl\b[^;&|\n]*\b(?:restart|try-restart|reload-or-restart|stop|kill|disable|mask|isolate|reboot|poweroff|halt)\b`)
+
+// powerRE matches commands that reboot or halt the node.
+var powerRE = regexp.MustCompile(`(?:^|[;&|\n]\s*)(?:reboot|shutdown|poweroff|halt)\b`)
+
+// crictlReason explains why the crictl subcommand sub needs confirmation.
+func crictlReason(sub string) string {
+	if r, ok := crictlActions[sub]; ok {
+		return "crictl " + sub + " " + r
+	}
+	if sub != "" {
+		return "crictl " + sub + " is not a read-only subcommand"
+	}
+	return "the crictl subcommand could not be determined"
+}
+
+// shellUnquote removes quotes and backslashes from a script, so that a
+// command name such as c"ri"ctl is seen as the command the shell runs.
+var shellUnquote = strings.NewReplacer(`"`, "", `'`, "", `\`, "")
+
+// commandExpansionRE matches an expansion in command position, at the start
+// of a command or of a command substitution and after any variable
+// assignments, where it decides which command runs, as in "$c rmp -a" or
+// "$(echo crictl) rmp -a".
+var commandExpansionRE = regexp.MustCompile("(?:^|[;&|\n({}`])\\s*(?:\\w+=\\S*\\s+)*[$`]")
+
+// interpreters run code from their arguments or input that cannot be checked
+// here. "." is only one in command position, as elsewhere it names the
+// current directory.
+var interpreters = map[string]bool{
+	"eval": true, "source": true, ".": true, "exec": true,
+	"sh": true, "bash": true, "dash": true, "zsh": true,
+	"python": true, "python3": true, "perl": true,
+}
+
+// isShellSeparator reports whether c separates the commands of a script.
+func isShellSeparator(c rune) bool {
+	return strings.ContainsRune(";&|\n(){}`", c)
+}
+
+// shellReason returns why a shell script run on a node needs confirmation,
+// or "" if it only runs commands known to be safe. It is used for every tool
+// that runs caller-supplied shell. A script that reboots the node, changes
+// systemd units or runs a crictl subcommand that is not read-only, also
+// inside a command substitution, needs confirmation, as does one that cannot
+// be checked because it calls a shell or interpreter or runs a command named
+// by a variable or substitution.
+func shellReason(script string) string {
+	plain := shellUnquote.Replace(script)
+	if commandExpansionRE.MatchString(plain) {
+		return "the commands run a command named by a variable or substitution, which cannot be checked"
+	}
+	switch {
+	case powerRE.MatchString(plain):
+		return "the commands reboot or halt the node"
+	case systemctlRE.MatchString(plain):
+		return "the commands stop, restart or disable systemd units such as crio or kubelet"
+	}
+	for _, cmd := range strings.FieldsFunc(plain, isShellSeparator) {
+		words := strings.Fields(cmd)
+		for i, w := range words {
+			name := path.Base(w)
+			switch {
+			case interpreters[name] && (i == 0 || w != "."):
+				return "the commands run " + name + ", whose code cannot be checked"
+			case name == "crictl" && i+1 < len(words):
+				sub := openshift.CrictlSubcommand(words[i+1:])
+				if _, ok := crictlActions[sub]; ok || sub == "" || crictlOther[sub] {
+					return crictlReason(sub)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Classify returns the action for a tool call that needs confirmation, or
+// nil if the call may run without it.
+func Classify(tool string, args map[string]any) *Action {
+	node, _ := args["node_name"].(string)
+	switch tool {
+	case "run_crictl":
+		list := stringArgs(args["args"])
+		if len(list) == 0 {
+			return nil
+		}
+		sub := openshift.CrictlSubcommand(list)
+		if crictlReadOnly[sub] {
+			return nil
+		}
+		return &Action{Tool: tool, Node: node, Command: "crictl " + openshift.ShellJoin(list), Reason: crictlReason(sub)}
+	case "debug_node":
+		if b, _ := args["collect_files"].(bool); b {
+			if paths, _ := args["paths"].([]any); len(paths) > 0 {
+				return nil
+			}
+		}
+		// Each command runs in its own debug pod.
+		cmds := stringArgs(args["commands"])
+		for _, c := range cmds {
+			if reason := shellReason(c); reason != "" {
+				return &Action{Tool: tool, Node: node, Command: strings.Join(cmds, "\n"), Reason: reason}
+			}
+		}
+	case "traverse_cgroupfs":
+		// The commands run as one script, as the handler joins them.
+		script := strings.Join(stringArgs(args["commands"]), " && ")
+		if reason := shellReason(script); reason != "" {
+			return &Action{Tool: tool, Node: node, Command: script, Reason: reason}
+		}
+	case "prune_image_storage":
+		cmd := "crictl rmi --prune"
+		if images := stringArgs(args["images"]); len(images) > 0 {
+			cmd = "crictl rmi " + strings.Join(images, " ")
+		}
+		return &Action{Tool: tool, Node: node, Command: cmd, Reason: "removes images from the node; pods that need them pull them again"}
+	case "collect_must_gather":
+		extra := stringArgs(args["extra_args"])
+		for _, a := range extra {
+			if a == "--image" || a == "--image-stream" || strings.HasPrefix(a, "--image=") || strings.HasPrefix(a, "--image-stream=") {
+				cmd := []string{"oc", "adm", "must-gather"}
+				if dest, _ := args["dest_dir"].(string); dest != "" {
+					cmd = append(cmd, "--dest-dir="+dest)
+				}
+				return &Action{Tool: tool, Command: strings.Join(append(cmd, extra...), " "), Reason: "a custom must-gather image runs with cluster-admin privileges on the cluster"}
+			}
+		}
+	}
+	return nil
+}
+
+// stringArgs converts an array argument to strings.
+func stringArgs(v any) []string {
+	list, _ := v.([]any)
+	out := make([]string, len(list))
+	for i, a := range list {
+		out[i] = fmt.Sprint(a)
+	}
+	return out
+}
+
+// Errors returned by Verify.
+var (
+	ErrInvalidToken = errors.New("invalid confirmation token")
+	ErrExpiredToken = errors.New("confirmation token expired")
+	ErrReusedToken  = errors.New("confirmation token already used")
+)
+
+// DefaultTTL is how long a confirmation token stays valid.
+const DefaultTTL = 5 * time.Minute
+
+// Confirmer issues and checks confirmation tokens. A token is an HMAC over
+// the caller, the tool and its arguments, so it only confirms the exact call
+// it was issued for, and it can be used once.
+type Confirmer struct {
+	TTL time.Duration
+
+	key  []byte
+	now  func() time.Time
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// New returns a Confirmer with a random key. Tokens do not survive a restart.
+func New() *Confirmer {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("confirm: read random key: %v", err))
+	}
+	return &Confirmer{TTL: DefaultTTL, key: key, now: time.Now, used: map[string]time.Time{}}
+}
+
+func (c *Confirmer) mac(expiry int64, subject, tool string, args map[string]any) []byte {
+	rest := make(map[string]any, len(args))
+	for k, v := range args {
+		if k != TokenArg {
+			rest[k] = v
+		}
+	}
+	// encoding/json sorts map keys, which makes the encoding canonical.
+	data, _ := json.Marshal(rest)
+	m := hmac.New(sha256.New, c.key)
+	fmt.Fprintf(m, "%d\x00%s\x00%s\x00", expiry, subject, tool)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// Token returns a token confirming the call of tool with args by subject.
+func (c *Confirmer) Token(subject, tool string, args map[string]any) string {
+	expiry := c.now().Add(c.TTL).Unix()
+	return strconv.FormatInt(expiry, 10) + "." + base64.RawURLEncoding.EncodeToString(c.mac(expiry, subject, tool, args))
+}
+
+// Verify checks that token was issued for this exact call and marks it used.
+func (c *Confirmer) Verify(token, subject, tool string, args map[string]any) error {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+	expiry, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, c.mac(expiry, subject, tool, args)) {
+		return ErrInvalidToken
+	}
+	now := c.now()
+	if now.Unix() > expiry {
+		return ErrExpiredToken
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for t, exp := range c.used {
+		if now.After(exp) {
+			delete(c.used, t)
+		}
+	}
+	if _, ok := c.used[token]; ok {
+		return ErrReusedToken
+	}
+	c.used[token] = time.Unix(expiry, 0)
+	return nil
+}
@@ -0,0 +1,99 @@
+package confirm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		tool    string
+		args    map[string]any
+		command string
+	}{
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"rmp", "-f", "abc"}}, "crictl rmp -f abc"},
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"--timeout=5s", "stopp", "abc"}}, "crictl --timeout=5s stopp abc"},
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"ps", "-a"}}, ""},
+		{"run_crictl", map[string]any{"node_name": "n1"}, ""},
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"-r", "unix:///var/run/crio/crio.sock", "rmp", "-a"}}, "crictl -r unix:///var/run/crio/crio.sock rmp -a"},
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"--timeout", "5s", "stopp", "abc"}}, "crictl --timeout 5s stopp abc"},
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"-r", "ps", "rm", "abc"}}, "crictl -r ps rm abc"},
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"--bogus", "ps"}}, "crictl --bogus ps"},
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"exec", "abc", "sh"}}, "crictl exec abc sh"},
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"ps;", "crictl", "rmp", "-a"}}, "crictl 'ps;' crictl rmp -a"},
+		{"run_crictl", map[string]any{"node_name": "n1", "args": []any{"-r", "unix:///var/run/crio/crio.sock", "inspect", "abc"}}, ""},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"systemctl restart crio"}}, "systemctl restart crio"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"uptime", "systemctl --no-block stop kubelet"}}, "uptime\nsystemctl --no-block stop kubelet"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"sync && reboot"}}, "sync && reboot"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"systemctl status crio", "journalctl -u crio | grep restart"}}, ""},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"systemctl restart crio"}, "collect_files": true, "paths": []any{"/etc/crio"}}, ""},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"crictl rmp -af"}}, "crictl rmp -af"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"crictl ps", "crictl stopp abc"}}, "crictl ps\ncrictl stopp abc"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"crictl images -q | xargs /usr/bin/crictl rmi"}}, "crictl images -q | xargs /usr/bin/crictl rmi"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"crictl --timeout 5s rmp abc"}}, "crictl --timeout 5s rmp abc"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{`c"ri"ctl rmp -a`}}, `c"ri"ctl rmp -a`},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"crictl --bogus rmp"}}, "crictl --bogus rmp"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"$(echo crictl) rmp -a"}}, "$(echo crictl) rmp -a"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"echo Y3JpY3RsIHJtcCAtYQ== | base64 -d | sh"}}, "echo Y3JpY3RsIHJtcCAtYQ== | base64 -d | sh"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"c=crictl; $c rmp -a"}}, "c=crictl; $c rmp -a"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"echo $(crictl rmp -a)"}}, "echo $(crictl rmp -a)"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"echo `systemctl restart crio`"}}, "echo `systemctl restart crio`"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"X=1 `echo crictl` rmp -a"}}, "X=1 `echo crictl` rmp -a"},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{`echo "running $(crictl ps -q | wc -l) on $(hostname)"`}}, ""},
+		{"debug_node", map[string]any{"node_name": "n1", "commands": []any{"crictl ps -a | grep -c Running", "crictl inspect abc", "grep crictl /var/log/messages"}}, ""},
+		{"traverse_cgroupfs", map[string]any{"node_name": "n1", "commands": []any{"cat /sys/fs/cgroup/cpu.max", "systemctl restart crio"}}, "cat /sys/fs/cgroup/cpu.max && systemctl restart crio"},
+		{"traverse_cgroupfs", map[string]any{"node_name": "n1", "commands": []any{"crictl rmp -af"}}, "crictl rmp -af"},
+		{"traverse_cgroupfs", map[string]any{"node_name": "n1", "commands": []any{"find /sys/fs/cgroup -name memory.max | xargs grep -H ."}}, ""},
+		{"traverse_cgroupfs", map[string]any{"node_name": "n1"}, ""},
+		{"collect_must_gather", map[string]any{"dest_dir": "/tmp/mg", "extra_args": []any{"--image=quay.io/acme/gather:latest"}}, "oc adm must-gather --dest-dir=/tmp/mg --image=quay.io/acme/gather:latest"},
+		{"collect_must_gather", map[string]any{"extra_args": []any{"--image-stream", "openshift/must-gather"}}, "oc adm must-gather --image-stream openshift/must-gather"},
+		{"collect_must_gather", map[string]any{"extra_args": []any{"--timeout=10m"}}, ""},
+		{"prune_image_storage", map[string]any{"node_name": "n1"}, "crictl rmi --prune"},
+		{"prune_image_storage", map[string]any{"node_name": "n1", "images": []any{"abc", "quay.io/app:v1"}}, "crictl rmi abc quay.io/app:v1"},
+		{"collect_node_logs", map[string]any{"node_name": "n1"}, ""},
+	}
+	for _, tt := range tests {
+		a := Classify(tt.tool, tt.args)
+		switch {
+		case tt.command == "" && a != nil:
+			t.Errorf("%s %v: unexpected action %+v", tt.tool, tt.args, a)
+		case tt.command != "" && a == nil:
+			t.Errorf("%s %v: expected confirmation", tt.tool, tt.args)
+		case a != nil && a.Command != tt.command:
+			t.Errorf("%s %v: unexpected action %+v", tt.tool, tt.args, a)
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	c := New()
+	now := time.Unix(1_700_000_000, 0)
+	c.now = func() time.Time { return now }
+	args := map[string]any{"node_name": "n1", "args": []any{"rmp", "abc"}}
+	token := c.Token("alice", "run_crictl", args)
+
+	if err := c.Verify(token, "bob", "run_crictl", args); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token accepted for another caller: %v", err)
+	}
+	other := map[string]any{"node_name": "n2", "args": []any{"rmp", "abc"}}
+	if err := c.Verify(token, "alice", "run_crictl", other); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token accepted for other arguments: %v", err)
+	}
+	if err := c.Verify("garbage", "alice", "run_crictl", args); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("garbage accepted: %v", err)
+	}
+	withToken := map[string]any{"node_name": "n1", "args": []any{"rmp", "abc"}, TokenArg: token}
+	if err := c.Verify(token, "alice", "run_crictl", withToken); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Verify(token, "alice", "run_crictl", args); !errors.Is(err, ErrReusedToken) {
+		t.Fatalf("token reused: %v", err)
+	}
+
+	token = c.Token("alice", "run_crictl", args)
+	now = now.Add(DefaultTTL + time.Second)
+	if err := c.Verify(token, "alice", "run_crictl", args); !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("expired token accepted: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package confirm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harche/crio-mcp-server/pkg/authz"
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RequireEnv turns confirmation off when set to false, for automation that
+// has its own change review.
+const RequireEnv = "CRIO_MCP_REQUIRE_CONFIRMATION"
+
+// Required reports whether risky calls need confirmation. It is on unless
+// RequireEnv is a valid false value.
+func Required() bool {
+	on, err := strconv.ParseBool(os.Getenv(RequireEnv))
+	return on || err != nil
+}
+
+// Prompt returns the message shown in place of the result of a risky call.
+func (a *Action) Prompt(token string, ttl time.Duration) string {
+	var b strings.Builder
+	where := "on the cluster"
+	if a.Node != "" {
+		where = "on node " + a.Node
+	}
+	fmt.Fprintf(&b, "confirmation required: %s would run %s:\n\n", a.Tool, where)
+	for _, line := range strings.Split(a.Command, "\n") {
+		fmt.Fprintf(&b, "    %s\n", line)
+	}
+	fmt.Fprintf(&b, "\nThis needs confirmation because %s. Show the command and target to the user. Only if they approve, repeat the same call with identical arguments and %s=%q (valid for %s, once).", a.Reason, TokenArg, token, ttl)
+	return b.String()
+}
+
+// Middleware returns a tool handler middleware that holds back risky calls.
+// The first call returns a prompt with a confirmation token; the handler
+// only runs when the same caller repeats the call with that token.
+//
+// The MCP SDK used by this server does not support elicitation, so the
+// confirmation always goes through the model and the user via the token.
+// Dry runs are not held back; the reason is recorded in the plan instead.
+func Middleware(c *Confirmer) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			args := req.GetArguments()
+			a := Classify(req.Params.Name, args)
+			if a == nil {
+				return next(ctx, req)
+			}
+			if plan := dryrun.FromContext(ctx); plan != nil {
+				plan.SetConfirmation(a.Reason)
+				return next(ctx, req)
+			}
+			subject := authz.FromContext(ctx).Name()
+			token, _ := args[TokenArg].(string)
+			if token == "" {
+				return mcp.NewToolResultError(a.Prompt(c.Token(subject, a.Tool, args), c.TTL)), nil
+			}
+			if err := c.Verify(token, subject, a.Tool, args); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("%v\n\n%s", err, a.Prompt(c.Token(subject, a.Tool, args), c.TTL))), nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
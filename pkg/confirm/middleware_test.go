@@ -0,0 +1,61 @@
+package confirm
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/harche/crio-mcp-server/pkg/authz"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var tokenRE = regexp.MustCompile(TokenArg + `="([^"]+)"`)
+
+func TestMiddleware(t *testing.T) {
+	ran := 0
+	h := Middleware(New())(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ran++
+		return mcp.NewToolResultText("ok"), nil
+	})
+	ctx := authz.WithIdentity(context.Background(), authz.Identity{Subject: "alice"})
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "debug_node"
+	req.Params.Arguments = map[string]any{"node_name": "worker-0", "commands": []any{"systemctl restart crio"}}
+
+	res, _ := h(ctx, req)
+	text := res.Content[0].(mcp.TextContent).Text
+	if ran != 0 || !res.IsError || !strings.Contains(text, "on node worker-0") || !strings.Contains(text, "    systemctl restart crio\n") {
+		t.Fatalf("expected confirmation prompt, got %q", text)
+	}
+	m := tokenRE.FindStringSubmatch(text)
+	if m == nil {
+		t.Fatalf("no token in %q", text)
+	}
+
+	req.Params.Arguments.(map[string]any)[TokenArg] = m[1]
+	if res, _ := h(authz.WithIdentity(context.Background(), authz.Identity{Subject: "bob"}), req); ran != 0 || !res.IsError {
+		t.Fatal("token accepted for another caller")
+	}
+	if res, _ := h(ctx, req); ran != 1 || res.IsError {
+		t.Fatalf("confirmed call did not run: %+v", res)
+	}
+	res, _ = h(ctx, req)
+	if text := res.Content[0].(mcp.TextContent).Text; ran != 1 || !strings.Contains(text, "already used") || !tokenRE.MatchString(text) {
+		t.Fatalf("reused token: %q", text)
+	}
+
+	req.Params.Arguments = map[string]any{"node_name": "worker-0", "commands": []any{"uptime"}}
+	if res, _ := h(ctx, req); ran != 2 || res.IsError {
+		t.Fatal("safe call was held back")
+	}
+}
+
+func TestRequired(t *testing.T) {
+	for v, want := range map[string]bool{"": true, "true": true, "false": false, "0": false, "nope": true} {
+		t.Setenv(RequireEnv, v)
+		if got := Required(); got != want {
+			t.Errorf("%s=%q: got %v, want %v", RequireEnv, v, got, want)
+		}
+	}
+}
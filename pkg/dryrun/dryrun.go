@@ -0,0 +1,123 @@
+// Package dryrun lets a tool call be planned without being executed. A call
+// made with a Plan in its context reaches the executors as usual, but they
+// record the fully resolved command or request in the Plan and return
+// ErrSkipped instead of running it.
+package dryrun
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrSkipped is returned by executors in place of running a command.
+var ErrSkipped = errors.New("not executed: dry run")
+
+// Plan collects what a dry-run call would do.
+type Plan struct {
+	mu           sync.Mutex
+	steps        []string
+	policy       string
+	confirmation string
+	// calls describes the tool calls planned within this call, such as the
+	// steps of a runbook.
+	calls []string
+}
+
+type planKey struct{}
+
+// With returns a context that makes every executor record into a new Plan.
+func With(ctx context.Context) (context.Context, *Plan) {
+	p := &Plan{}
+	return context.WithValue(ctx, planKey{}, p), p
+}
+
+// FromContext returns the Plan of a dry-run call, or nil when the call is
+// meant to run.
+func FromContext(ctx context.Context) *Plan {
+	p, _ := ctx.Value(planKey{}).(*Plan)
+	return p
+}
+
+// Live returns a context in which executors run even during a dry run. It is
+// used for the read-only lookups needed to evaluate the call, such as the
+// labels of the target node.
+func Live(ctx context.Context) context.Context {
+	return context.WithValue(ctx, planKey{}, (*Plan)(nil))
+}
+
+// Record adds a step and returns ErrSkipped, which the executor returns to
+// its caller.
+func (p *Plan) Record(step string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.steps = append(p.steps, step)
+	return ErrSkipped
+}
+
+// Steps returns the recorded steps in order.
+func (p *Plan) Steps() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.steps...)
+}
+
+// SetPolicy records the authorization decision for the call.
+func (p *Plan) SetPolicy(decision string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = decision
+}
+
+// SetConfirmation records why the call would need confirmation.
+func (p *Plan) SetConfirmation(reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.confirmation = reason
+}
+
+// merge adds the plan of a tool call made within p's call to p: its steps are
+// appended to p's, and its decisions and failure are noted under its name.
+func (p *Plan) merge(tool string, child *Plan, result string) {
+	child.mu.Lock()
+	call := tool
+	var notes []string
+	if child.policy != "" {
+		notes = append(notes, "policy: "+child.policy)
+	}
+	if child.confirmation != "" {
+		notes = append(notes, "confirmation: required because "+child.confirmation)
+	}
+	if result != "" {
+		notes = append(notes, "would fail: "+result)
+	}
+	if len(notes) > 0 {
+		call += ": " + strings.Join(notes, "; ")
+	}
+	steps := child.steps
+	child.mu.Unlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, call)
+	p.steps = append(p.steps, steps...)
+}
+
+// safeArg matches arguments that need no quoting in a POSIX shell.
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./~-]+$`)
+
+// Command formats an argv so that it can be pasted into a shell and runs
+// exactly the same command.
+func Command(name string, args ...string) string {
+	quoted := make([]string, 0, len(args)+1)
+	for _, a := range append([]string{name}, args...) {
+		if safeArg.MatchString(a) {
+			quoted = append(quoted, a)
+			continue
+		}
+		quoted = append(quoted, "'"+strings.ReplaceAll(a, "'", `'\''`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
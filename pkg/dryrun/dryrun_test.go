@@ -0,0 +1,35 @@
+package dryrun
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	got := Command("oc", "--as=alice", "debug", "node/n1", "--", "chroot", "/host", "sh", "-c", "crictl ps -a", "it's")
+	want := `oc --as=alice debug node/n1 -- chroot /host sh -c 'crictl ps -a' 'it'\''s'`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Fatal("plan without dry run")
+	}
+	ctx, p := With(context.Background())
+	if FromContext(ctx) != p {
+		t.Fatal("plan not in context")
+	}
+	if FromContext(Live(ctx)) != nil {
+		t.Fatal("plan visible in live context")
+	}
+	if err := p.Record("oc get nodes"); !errors.Is(err, ErrSkipped) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	p.Record("oc adm top nodes")
+	if s := p.Steps(); len(s) != 2 || s[1] != "oc adm top nodes" {
+		t.Fatalf("unexpected steps %v", s)
+	}
+}
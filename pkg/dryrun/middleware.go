@@ -0,0 +1,144 @@
+package dryrun
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Arg is the tool argument that requests a dry run.
+const Arg = "dry_run"
+
+// Env makes every call a dry run when set to true, for reviewing what an
+// agent would do against a production cluster.
+const Env = "CRIO_MCP_DRY_RUN"
+
+// Forced reports whether Env makes every call a dry run.
+func Forced() bool {
+	on, _ := strconv.ParseBool(os.Getenv(Env))
+	return on
+}
+
+// Requested reports whether req asks for a dry run.
+func Requested(req mcp.CallToolRequest) bool {
+	return req.GetBool(Arg, false)
+}
+
+// Middleware returns a tool handler middleware that turns dry-run calls into
+// a report. The rest of the chain runs with a Plan in its context, so the
+// middleware behind it records its decisions and the executors record the
+// commands; whatever the handler returns is replaced by the report. A call
+// made within a dry run, such as a runbook step, adds its commands and
+// decisions to the plan of the enclosing call and returns the handler's
+// result, so the enclosing call reports them together. cluster names the
+// cluster the commands would run against.
+func Middleware(forced bool, cluster func(ctx context.Context) string) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if outer := FromContext(ctx); outer != nil {
+				pctx, p := With(ctx)
+				res, err := next(pctx, req)
+				outer.merge(req.Params.Name, p, outcome(res, err))
+				return res, err
+			}
+			if !forced && !Requested(req) {
+				return next(ctx, req)
+			}
+			r := report{tool: req.Params.Name, nodes: targetNodes(req.GetArguments())}
+			if cluster != nil {
+				r.cluster = cluster(ctx)
+			}
+			pctx, p := With(ctx)
+			res, err := next(pctx, req)
+			r.plan = p
+			r.result = outcome(res, err)
+			return mcp.NewToolResultText(r.String()), nil
+		}
+	}
+}
+
+// targetNodes returns the nodes and pool named by a call's arguments.
+func targetNodes(args map[string]any) []string {
+	var nodes []string
+	for _, k := range []string{"node_name", "node"} {
+		if s, ok := args[k].(string); ok && s != "" {
+			nodes = append(nodes, s)
+		}
+	}
+	if s, ok := args["pool"].(string); ok && s != "" {
+		nodes = append(nodes, "pool "+s)
+	}
+	return nodes
+}
+
+// outcome returns the error a handler stopped with, unless it stopped
+// because a step was skipped.
+func outcome(res *mcp.CallToolResult, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	if res == nil || !res.IsError {
+		return ""
+	}
+	for _, c := range res.Content {
+		if t, ok := c.(mcp.TextContent); ok && !strings.Contains(t.Text, ErrSkipped.Error()) {
+			return t.Text
+		}
+	}
+	return ""
+}
+
+type report struct {
+	tool    string
+	cluster string
+	nodes   []string
+	plan    *Plan
+	result  string
+}
+
+func (r report) String() string {
+	p := r.plan
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "dry run of %s: nothing was executed\n", r.tool)
+	if r.cluster != "" {
+		fmt.Fprintf(&b, "cluster: %s\n", r.cluster)
+	}
+	if len(r.nodes) > 0 {
+		fmt.Fprintf(&b, "node: %s\n", strings.Join(r.nodes, ", "))
+	}
+	switch {
+	case p.policy != "":
+		fmt.Fprintf(&b, "policy: %s\n", p.policy)
+	case r.result == "":
+		b.WriteString("policy: no policy configured, allowed\n")
+	}
+	if p.confirmation != "" {
+		fmt.Fprintf(&b, "confirmation: required because %s\n", p.confirmation)
+	}
+	if r.result != "" {
+		fmt.Fprintf(&b, "would fail: %s\n", r.result)
+	}
+	if len(p.calls) > 0 {
+		b.WriteString("tool calls:\n")
+		for _, c := range p.calls {
+			fmt.Fprintf(&b, "  %s\n", c)
+		}
+	}
+	if len(p.steps) == 0 {
+		b.WriteString("commands: none\n")
+		return b.String()
+	}
+	b.WriteString("commands:\n")
+	for _, s := range p.steps {
+		fmt.Fprintf(&b, "  %s\n", s)
+	}
+	b.WriteString("Commands that need the output of an earlier command cannot be resolved and are not listed.\n")
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package dryrun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestMiddleware(t *testing.T) {
+	cluster := func(context.Context) string { return "https://api.example.com:6443" }
+	h := Middleware(false, cluster)(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		p := FromContext(ctx)
+		if p == nil {
+			return mcp.NewToolResultText("executed"), nil
+		}
+		p.SetPolicy("allowed")
+		p.SetConfirmation("crictl rmp removes pod sandboxes")
+		err := p.Record("oc debug node/n1 -- chroot /host sh -c 'crictl rmp abc'")
+		return mcp.NewToolResultError(fmt.Sprintf("oc debug failed: %v", err)), nil
+	})
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "run_crictl"
+	req.Params.Arguments = map[string]any{"node_name": "n1", "args": []any{"rmp", "abc"}}
+
+	if res, _ := h(context.Background(), req); res.Content[0].(mcp.TextContent).Text != "executed" {
+		t.Fatal("call without dry_run was not executed")
+	}
+
+	req.Params.Arguments.(map[string]any)[Arg] = true
+	res, _ := h(context.Background(), req)
+	got := res.Content[0].(mcp.TextContent).Text
+	want := `dry run of run_crictl: nothing was executed
+cluster: https://api.example.com:6443
+node: n1
+policy: allowed
+confirmation: required because crictl rmp removes pod sandboxes
+commands:
+  oc debug node/n1 -- chroot /host sh -c 'crictl rmp abc'
+`
+	if res.IsError || !strings.HasPrefix(got, want) {
+		t.Fatalf("unexpected report:\n%s", got)
+	}
+}
+
+func TestMiddlewareReportsFailure(t *testing.T) {
+	h := Middleware(true, nil)(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError(`required argument "node_name" not found`), nil
+	})
+	res, _ := h(context.Background(), mcp.CallToolRequest{})
+	got := res.Content[0].(mcp.TextContent).Text
+	if !strings.Contains(got, `would fail: required argument "node_name" not found`) || !strings.Contains(got, "commands: none") || strings.Contains(got, "policy:") {
+		t.Fatalf("unexpected report:\n%s", got)
+	}
+}
+
+func TestForced(t *testing.T) {
+	t.Setenv(Env, "true")
+	if !Forced() {
+		t.Fatal("dry run not forced")
+	}
+	t.Setenv(Env, "")
+	if Forced() {
+		t.Fatal("dry run forced by default")
+	}
+}
+
+func TestMiddlewareNested(t *testing.T) {
+	mw := Middleware(false, nil)
+	step := mw(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		p := FromContext(ctx)
+		p.SetPolicy("allowed")
+		p.SetConfirmation("systemctl restart changes the state of a service")
+		err := p.Record("oc debug node/" + req.GetString("node_name", "") + " -- chroot /host sh -c 'systemctl restart crio'")
+		return mcp.NewToolResultError(err.Error()), nil
+	})
+	h := mw(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var out []string
+		for _, node := range []string{"n1", "n2"} {
+			sreq := mcp.CallToolRequest{}
+			sreq.Params.Name = "debug_node"
+			sreq.Params.Arguments = map[string]any{"node_name": node}
+			res, _ := step(ctx, sreq)
+			out = append(out, res.Content[0].(mcp.TextContent).Text)
+		}
+		return mcp.NewToolResultText(strings.Join(out, "\n")), nil
+	})
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "run_runbook"
+	req.Params.Arguments = map[string]any{Arg: true}
+	res, _ := h(context.Background(), req)
+	got := res.Content[0].(mcp.TextContent).Text
+	want := `dry run of run_runbook: nothing was executed
+policy: no policy configured, allowed
+tool calls:
+  debug_node: policy: allowed; confirmation: required because systemctl restart changes the state of a service
+  debug_node: policy: allowed; confirmation: required because systemctl restart changes the state of a service
+commands:
+  oc debug node/n1 -- chroot /host sh -c 'systemctl restart crio'
+  oc debug node/n2 -- chroot /host sh -c 'systemctl restart crio'
+`
+	if !strings.HasPrefix(got, want) || strings.Count(got, "dry run of") != 1 {
+		t.Fatalf("unexpected report:\n%s", got)
+	}
+}
@@ -0,0 +1,479 @@
+// Package exposure decides whether a node is affected by a CVE by comparing
+// the affected_release and package_state data of a Red Hat Security Data
+// document with the RPMs and RHCOS build installed on the node.
+package exposure
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/harche/crio-mcp-server/pkg/redhat"
+)
+
+// RHCOSPackage is the pseudo package name under which the RHCOS build is
+// compared against Security Data entries such as "rhcos-414.92.202402011424-0".
+const RHCOSPackage = "rhcos"
+
+// Inventory describes what is installed on a node.
+type Inventory struct {
+	Node string
+	// Packages maps an RPM name to its installed EVR. When several versions of
+	// a package are installed, as is usual for the kernel, it holds the
+	// running kernel or otherwise the oldest version.
+	Packages map[string]EVR
+	// Kernel is the running kernel release as printed by uname -r, e.g.
+	// "5.14.0-284.40.1.el9_2.x86_64".
+	Kernel string
+	// RHCOS is the RHCOS build, e.g. "414.92.202310210434-0", or empty on
+	// nodes that do not run RHCOS.
+	RHCOS string
+	// OpenShift is the OpenShift minor release the node belongs to, e.g. "4.14".
+	OpenShift string
+	// RHEL is the RHEL release the node is based on, e.g. "9.2".
+	RHEL string
+}
+
+// KernelKey is the key under which openshift.NodeInventory prints the
+// running kernel release among the os-release variables.
+const KernelKey = "RUNNING_KERNEL"
+
+// ParseInventory parses the output of openshift.NodeInventory: rpm lines of
+// the form "NAME EPOCH:VERSION-RELEASE" followed by separator, a
+// RUNNING_KERNEL=<uname -r> line and the node's /etc/os-release. Lines that do
+// not fit, such as rpm's "package x is not installed" or oc's own messages,
+// are ignored.
+func ParseInventory(node, out, separator string) Inventory {
+	inv := Inventory{Node: node, Packages: map[string]EVR{}}
+	versions := map[string][]EVR{}
+	osRelease := map[string]string{}
+	inRelease := false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == separator {
+			inRelease = true
+			continue
+		}
+		if inRelease {
+			if k, v, ok := strings.Cut(line, "="); ok {
+				osRelease[k] = strings.Trim(v, `"'`)
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.Contains(fields[1], ":") || !strings.Contains(fields[1], "-") {
+			continue
+		}
+		evr := strings.Replace(fields[1], "(none):", "0:", 1)
+		versions[fields[0]] = append(versions[fields[0]], ParseEVR(evr))
+	}
+	inv.Kernel = osRelease[KernelKey]
+	for name, evrs := range versions {
+		inv.Packages[name] = inv.pick(name, evrs)
+	}
+	if osRelease["ID"] == "rhcos" {
+		inv.RHCOS = osRelease["OSTREE_VERSION"]
+		if inv.RHCOS == "" {
+			inv.RHCOS = osRelease["VERSION"]
+		}
+	}
+	inv.OpenShift = osRelease["OPENSHIFT_VERSION"]
+	inv.RHEL = osRelease["RHEL_VERSION"]
+	if inv.RHEL == "" && osRelease["ID"] == "rhel" {
+		inv.RHEL = osRelease["VERSION_ID"]
+	}
+	return inv
+}
+
+// pick chooses the version of a package to check when several are
+// installed: the running kernel for the kernel package, otherwise the oldest,
+// so that a vulnerable version is not hidden by a fixed one.
+func (inv Inventory) pick(name string, evrs []EVR) EVR {
+	oldest := evrs[0]
+	for _, evr := range evrs {
+		if name == "kernel" && inv.Kernel != "" {
+			vr := evr.Version + "-" + evr.Release
+			if inv.Kernel == vr || strings.HasPrefix(inv.Kernel, vr+".") {
+				return evr
+			}
+		}
+		if CompareEVR(evr, oldest) < 0 {
+			oldest = evr
+		}
+	}
+	return oldest
+}
+
+// installed returns the installed EVR of pkg, treating the RHCOS build as a
+// package.
+func (inv Inventory) installed(pkg string) (EVR, bool) {
+	if pkg == RHCOSPackage {
+		if inv.RHCOS == "" {
+			return EVR{}, false
+		}
+		return ParseEVR(inv.RHCOS), true
+	}
+	evr, ok := inv.Packages[pkg]
+	return evr, ok
+}
+
+// names returns the installed package names in a stable order with the
+// RHCOS build last.
+func (inv Inventory) names() []string {
+	var names []string
+	for name := range inv.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if inv.RHCOS != "" {
+		names = append(names, RHCOSPackage)
+	}
+	return names
+}
+
+// Status is the exposure of one package to a CVE.
+type Status string
+
+const (
+	// Affected means the installed version is vulnerable.
+	Affected Status = "affected"
+	// Fixed means the installed version contains the fix.
+	Fixed Status = "fixed"
+	// NotAffected means Red Hat states the package is not affected, or the
+	// CVE does not list the package at all.
+	NotAffected Status = "not affected"
+	// Unknown means Red Hat is still investigating the package.
+	Unknown Status = "unknown"
+)
+
+// Finding is the exposure of one installed package.
+type Finding struct {
+	Package   string
+	Installed EVR
+	Status    Status
+	// FixedIn and Advisory name the erratum that fixes the CVE for the
+	// node's product, if there is one.
+	FixedIn  EVR
+	Advisory string
+	Product  string
+	// Note carries the Security Data fix_state when no erratum applies.
+	Note string
+}
+
+// Report is the exposure of a single node.
+type Report struct {
+	Inventory Inventory
+	Findings  []Finding
+	// Err is set when the node's inventory could not be collected.
+	Err error
+}
+
+// Exposed reports whether any package on the node is affected.
+func (r Report) Exposed() bool {
+	for _, f := range r.Findings {
+		if f.Status == Affected {
+			return true
+		}
+	}
+	return false
+}
+
+// Check evaluates every package in inv against cve.
+func Check(cve *redhat.CVE, inv Inventory) Report {
+	r := Report{Inventory: inv}
+	for _, pkg := range inv.names() {
+		installed, _ := inv.installed(pkg)
+		r.Findings = append(r.Findings, check(cve, inv, pkg, installed))
+	}
+	return r
+}
+
+type candidate struct {
+	release redhat.AffectedRelease
+	fixed   EVR
+	score   int
+}
+
+func check(cve *redhat.CVE, inv Inventory, pkg string, installed EVR) Finding {
+	f := Finding{Package: pkg, Installed: installed}
+	var best []candidate
+	bestScore := -1
+	for _, ar := range cve.AffectedRelease {
+		name, fixed := ParseNEVRA(ar.Package)
+		if name != pkg {
+			continue
+		}
+		score := relevance(ar.ProductName, ar.CPE, fixed.Release, inv)
+		switch {
+		case score < 0 || score < bestScore:
+			continue
+		case score > bestScore:
+			best, bestScore = nil, score
+		}
+		best = append(best, candidate{release: ar, fixed: fixed, score: score})
+	}
+	if len(best) > 0 {
+		// Several errata can apply to the same product stream. The
+		// package is fixed when it is at least as new as one of them;
+		// otherwise the oldest fix is the one to apply.
+		sort.Slice(best, func(i, j int) bool { return CompareEVR(best[i].fixed, best[j].fixed) < 0 })
+		pick := best[0]
+		f.Status = Affected
+		for _, c := range best {
+			if CompareEVR(installed, c.fixed) >= 0 {
+				pick = c
+				f.Status = Fixed
+			}
+		}
+		f.FixedIn = pick.fixed
+		f.Advisory = pick.release.Advisory
+		f.Product = pick.release.ProductName
+		return f
+	}
+
+	var state *redhat.PackageState
+	bestScore = -1
+	for i, ps := range cve.PackageState {
+		if ps.PackageName != pkg && !strings.HasSuffix(ps.PackageName, "/"+pkg) {
+			continue
+		}
+		score := relevance(ps.ProductName, ps.CPE, "", inv)
+		if score < 0 || score < bestScore || (score == bestScore && fixStatus(ps.FixState) != Affected) {
+			continue
+		}
+		state, bestScore = &cve.PackageState[i], score
+	}
+	if state == nil {
+		f.Status = NotAffected
+		f.Note = "not listed"
+		return f
+	}
+	f.Status = fixStatus(state.FixState)
+	f.Product = state.ProductName
+	f.Note = state.FixState
+	return f
+}
+
+// fixStatus maps a Security Data fix_state to a Status.
+func fixStatus(state string) Status {
+	switch strings.ToLower(state) {
+	case "not affected":
+		return NotAffected
+	case "under investigation", "new":
+		return Unknown
+	default:
+		// Affected, Fix deferred, Will not fix, Out of support scope.
+		return Affected
+	}
+}
+
+var (
+	ocpProductRE = regexp.MustCompile(`OpenShift Container Platform (\d+\.\d+)\b`)
+	ocpCPERE     = regexp.MustCompile(`:openshift:(\d+\.\d+)\b`)
+	rhelMinorRE  = regexp.MustCompile(`:rhel_(?:eus|aus|e4s|tus):(\d+\.\d+)\b`)
+	rhelMajorRE  = regexp.MustCompile(`:(?:enterprise_linux|rhel_[a-z0-9]+):(\d+)\b`)
+	distRE       = regexp.MustCompile(`\.el(\d+)`)
+)
+
+// relevance scores how well a Security Data product entry matches the node.
+// A negative score means the entry is for a different OpenShift or RHEL
+// release; higher scores are more specific matches.
+func relevance(product, cpe, release string, inv Inventory) int {
+	score := 0
+	ocp := ""
+	if m := ocpProductRE.FindStringSubmatch(product); m != nil {
+		ocp = m[1]
+	} else if m := ocpCPERE.FindStringSubmatch(cpe); m != nil {
+		ocp = m[1]
+	}
+	if ocp != "" && inv.OpenShift != "" {
+		if ocp != inv.OpenShift {
+			return -1
+		}
+		score += 4
+	}
+	major, _, _ := strings.Cut(inv.RHEL, ".")
+	if m := rhelMinorRE.FindStringSubmatch(cpe); m != nil && inv.RHEL != "" {
+		if m[1] != inv.RHEL {
+			return -1
+		}
+		score += 2
+	} else if m := rhelMajorRE.FindStringSubmatch(cpe); m != nil && major != "" {
+		if m[1] != major {
+			return -1
+		}
+		score++
+	}
+	if m := distRE.FindStringSubmatch(release); m != nil && major != "" && m[1] != major {
+		return -1
+	}
+	return score
+}
+
+// describe summarises a finding in a few words.
+func (f Finding) describe() string {
+	switch {
+	case f.Status == Fixed:
+		return fmt.Sprintf("fixed (%s)", f.Advisory)
+	case f.Status == Affected && f.Advisory != "":
+		return fmt.Sprintf("AFFECTED, fix %s", f.Advisory)
+	case f.Status == Affected:
+		return fmt.Sprintf("AFFECTED, %s", strings.ToLower(f.Note))
+	default:
+		return string(f.Status)
+	}
+}
+
+// header describes the CVE itself.
+func header(cve *redhat.CVE) string {
+	var b strings.Builder
+	b.WriteString(cve.Name)
+	var attrs []string
+	if cve.ThreatSeverity != "" {
+		attrs = append(attrs, cve.ThreatSeverity)
+	}
+	if cve.CVSS3.BaseScore != "" {
+		attrs = append(attrs, "CVSS "+cve.CVSS3.BaseScore)
+	}
+	if len(attrs) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(attrs, ", "))
+	}
+	if cve.Bugzilla.Description != "" {
+		fmt.Fprintf(&b, ": %s", strings.TrimSpace(cve.Bugzilla.Description))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// Format renders a single node's report.
+func (r Report) Format(cve *redhat.CVE) string {
+	var b strings.Builder
+	b.WriteString(header(cve))
+	inv := r.Inventory
+	fmt.Fprintf(&b, "Node %s", inv.Node)
+	if inv.RHCOS != "" {
+		fmt.Fprintf(&b, ": RHCOS %s", inv.RHCOS)
+	}
+	if inv.OpenShift != "" {
+		fmt.Fprintf(&b, ", OpenShift %s", inv.OpenShift)
+	}
+	if inv.RHEL != "" {
+		fmt.Fprintf(&b, ", RHEL %s", inv.RHEL)
+	}
+	if inv.Kernel != "" {
+		fmt.Fprintf(&b, ", kernel %s", inv.Kernel)
+	}
+	b.WriteString("\n")
+	if r.Err != nil {
+		fmt.Fprintf(&b, "error: %v\n", r.Err)
+		return b.String()
+	}
+	if r.Exposed() {
+		b.WriteString("Result: EXPOSED\n")
+	} else {
+		b.WriteString("Result: not exposed\n")
+	}
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "- %s %s: %s", f.Package, f.Installed, f.Status)
+		if f.Advisory != "" {
+			fmt.Fprintf(&b, "; fixed in %s by %s", f.FixedIn, f.Advisory)
+		} else if f.Note != "" && !strings.EqualFold(f.Note, string(f.Status)) {
+			fmt.Fprintf(&b, "; %s", f.Note)
+		}
+		if f.Product != "" {
+			fmt.Fprintf(&b, " [%s]", f.Product)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// FormatFleet renders one row per node with the status of each package and a
+// summary of the errata needed to remediate the affected nodes.
+func FormatFleet(cve *redhat.CVE, reports []Report) string {
+	var b strings.Builder
+	b.WriteString(header(cve))
+
+	seen := map[string]bool{}
+	var pkgs []string
+	for _, r := range reports {
+		for _, f := range r.Findings {
+			if !seen[f.Package] {
+				seen[f.Package] = true
+				pkgs = append(pkgs, f.Package)
+			}
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		// Keep the RHCOS column last, matching single node reports.
+		if (pkgs[i] == RHCOSPackage) != (pkgs[j] == RHCOSPackage) {
+			return pkgs[j] == RHCOSPackage
+		}
+		return pkgs[i] < pkgs[j]
+	})
+
+	b.WriteString("\n| Node | Exposed | " + strings.Join(pkgs, " | ") + " |\n")
+	b.WriteString("|---|---|" + strings.Repeat("---|", len(pkgs)) + "\n")
+	exposed, failed := 0, 0
+	advisories := map[string]int{}
+	for _, r := range reports {
+		row := []string{r.Inventory.Node}
+		switch {
+		case r.Err != nil:
+			failed++
+			row = append(row, "error: "+strings.ReplaceAll(r.Err.Error(), "\n", " "))
+			for range pkgs {
+				row = append(row, "")
+			}
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+			continue
+		case r.Exposed():
+			exposed++
+			row = append(row, "YES")
+		default:
+			row = append(row, "no")
+		}
+		byPkg := map[string]Finding{}
+		for _, f := range r.Findings {
+			byPkg[f.Package] = f
+			if f.Status == Affected && f.Advisory != "" {
+				advisories[f.Advisory]++
+			}
+		}
+		for _, p := range pkgs {
+			if f, ok := byPkg[p]; ok {
+				row = append(row, f.describe())
+			} else {
+				row = append(row, "-")
+			}
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+	}
+
+	fmt.Fprintf(&b, "\n%d of %d nodes exposed", exposed, len(reports)-failed)
+	if failed > 0 {
+		fmt.Fprintf(&b, ", %d could not be checked", failed)
+	}
+	b.WriteString("\n")
+	if len(advisories) > 0 {
+		var names []string
+		for a := range advisories {
+			names = append(names, a)
+		}
+		sort.Strings(names)
+		b.WriteString("Errata to apply:\n")
+		for _, a := range names {
+			fmt.Fprintf(&b, "- %s (%s)\n", a, plural(advisories[a], "node"))
+		}
+	}
+	return b.String()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
@@ -0,0 +1,188 @@
+package exposure
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/harche/crio-mcp-server/pkg/redhat"
+)
+
+func TestRPMVerCmp(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0.1", "2.0", 1},
+		{"1.1.12", "1.1.9", 1},
+		{"1.0a", "1.0", 1},
+		{"1.0", "1.0a", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0^git1", "1.0", 1},
+		{"010", "10", 0},
+		{"1.a", "1.1", -1},
+		{"6.rhaos4.14.git.el9", "5.rhaos4.14.git.el9", 1},
+		{"284.40.1.el9_2", "284.45.1.el9_2", -1},
+	}
+	for _, c := range cases {
+		if got := rpmvercmp(c.a, c.b); got != c.want {
+			t.Errorf("rpmvercmp(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestParseNEVRA(t *testing.T) {
+	name, evr := ParseNEVRA("cri-o-0:1.27.1-6.rhaos4.14.git.el9")
+	if name != "cri-o" || evr != (EVR{0, "1.27.1", "6.rhaos4.14.git.el9"}) {
+		t.Fatalf("unexpected %s %+v", name, evr)
+	}
+	name, evr = ParseNEVRA("runc-4:1.1.12-1.rhaos4.14.el9.x86_64")
+	if name != "runc" || evr != (EVR{4, "1.1.12", "1.rhaos4.14.el9"}) {
+		t.Fatalf("unexpected %s %+v", name, evr)
+	}
+	name, evr = ParseNEVRA("rhcos-414.92.202402011424-0")
+	if name != "rhcos" || evr.String() != "0:414.92.202402011424-0" {
+		t.Fatalf("unexpected %s %+v", name, evr)
+	}
+}
+
+const inventoryOutput = `Starting pod/n1-debug ...
+cri-o 0:1.27.1-6.rhaos4.14.git.el9
+runc 4:1.1.10-1.rhaos4.14.el9
+package crun is not installed
+conmon 3:2.1.7-1.rhaos4.14.el9
+kernel 0:5.14.0-284.40.1.el9_2
+--- os-release ---
+NAME="Red Hat Enterprise Linux CoreOS"
+ID="rhcos"
+VERSION="414.92.202310210434-0"
+OPENSHIFT_VERSION="4.14"
+RHEL_VERSION="9.2"
+OSTREE_VERSION='414.92.202310210434-0'
+`
+
+func TestParseInventory(t *testing.T) {
+	inv := ParseInventory("n1", inventoryOutput, "--- os-release ---")
+	if len(inv.Packages) != 4 {
+		t.Fatalf("unexpected packages %v", inv.Packages)
+	}
+	if inv.Packages["runc"] != (EVR{4, "1.1.10", "1.rhaos4.14.el9"}) {
+		t.Fatalf("unexpected runc %+v", inv.Packages["runc"])
+	}
+	if inv.RHCOS != "414.92.202310210434-0" || inv.OpenShift != "4.14" || inv.RHEL != "9.2" {
+		t.Fatalf("unexpected inventory %+v", inv)
+	}
+}
+
+func TestParseInventoryKernels(t *testing.T) {
+	out := strings.Replace(inventoryOutput, "kernel 0:5.14.0-284.40.1.el9_2\n", "kernel 0:5.14.0-284.40.1.el9_2\nkernel 0:5.14.0-284.20.1.el9_2\n", 1)
+	for running, want := range map[string]string{
+		"5.14.0-284.40.1.el9_2.x86_64": "0:5.14.0-284.40.1.el9_2",
+		"5.14.0-284.20.1.el9_2.x86_64": "0:5.14.0-284.20.1.el9_2",
+		"":                             "0:5.14.0-284.20.1.el9_2",
+	} {
+		withKernel := strings.Replace(out, "--- os-release ---\n", "--- os-release ---\nRUNNING_KERNEL="+running+"\n", 1)
+		inv := ParseInventory("n1", withKernel, "--- os-release ---")
+		if inv.Kernel != running || inv.Packages["kernel"].String() != want {
+			t.Errorf("running %q: got kernel %s", running, inv.Packages["kernel"])
+		}
+	}
+	inv := ParseInventory("n1", strings.Replace(out, "--- os-release ---\n", "--- os-release ---\nRUNNING_KERNEL=5.14.0-284.20.1.el9_2.x86_64\n", 1), "--- os-release ---")
+	for _, f := range Check(testCVE, inv).Findings {
+		if f.Package == "kernel" && f.Status != Affected {
+			t.Fatalf("running vulnerable kernel reported as %+v", f)
+		}
+	}
+}
+
+var testCVE = &redhat.CVE{
+	Name:           "CVE-2024-21626",
+	ThreatSeverity: "Important",
+	Bugzilla:       redhat.CVEBugzilla{Description: "runc: file descriptor leak"},
+	AffectedRelease: []redhat.AffectedRelease{
+		{ProductName: "Red Hat OpenShift Container Platform 4.13", Advisory: "RHSA-2024:0666", CPE: "cpe:/a:redhat:openshift:4.13::el8", Package: "runc-4:1.1.12-1.rhaos4.13.el8"},
+		{ProductName: "Red Hat OpenShift Container Platform 4.14", Advisory: "RHSA-2024:0670", CPE: "cpe:/a:redhat:openshift:4.14::el9", Package: "runc-4:1.1.12-1.rhaos4.14.el9"},
+		{ProductName: "Red Hat OpenShift Container Platform 4.14", Advisory: "RHSA-2024:0671", CPE: "cpe:/a:redhat:openshift:4.14::el9", Package: "rhcos-414.92.202402011424-0"},
+		{ProductName: "Red Hat Enterprise Linux 9.2 Extended Update Support", Advisory: "RHSA-2024:0100", CPE: "cpe:/o:redhat:rhel_eus:9.2::baseos", Package: "kernel-0:5.14.0-284.30.1.el9_2"},
+		{ProductName: "Red Hat Enterprise Linux 9", Advisory: "RHSA-2024:0200", CPE: "cpe:/o:redhat:enterprise_linux:9::baseos", Package: "kernel-0:5.14.0-362.18.1.el9_3"},
+	},
+	PackageState: []redhat.PackageState{
+		{ProductName: "Red Hat OpenShift Container Platform 4", FixState: "Not affected", PackageName: "crun", CPE: "cpe:/a:redhat:openshift:4"},
+		{ProductName: "Red Hat OpenShift Container Platform 4", FixState: "Fix deferred", PackageName: "conmon", CPE: "cpe:/a:redhat:openshift:4"},
+	},
+}
+
+func TestCheck(t *testing.T) {
+	inv := ParseInventory("n1", inventoryOutput, "--- os-release ---")
+	r := Check(testCVE, inv)
+	got := map[string]Finding{}
+	for _, f := range r.Findings {
+		got[f.Package] = f
+	}
+	if f := got["runc"]; f.Status != Affected || f.Advisory != "RHSA-2024:0670" {
+		t.Fatalf("unexpected runc finding %+v", f)
+	}
+	if f := got["rhcos"]; f.Status != Affected || f.Advisory != "RHSA-2024:0671" {
+		t.Fatalf("unexpected rhcos finding %+v", f)
+	}
+	if f := got["kernel"]; f.Status != Fixed || f.Advisory != "RHSA-2024:0100" {
+		t.Fatalf("unexpected kernel finding %+v", f)
+	}
+	if f := got["conmon"]; f.Status != Affected || f.Note != "Fix deferred" {
+		t.Fatalf("unexpected conmon finding %+v", f)
+	}
+	if f := got["cri-o"]; f.Status != NotAffected {
+		t.Fatalf("unexpected cri-o finding %+v", f)
+	}
+	if _, ok := got["crun"]; ok {
+		t.Fatal("crun is not installed and should not be reported")
+	}
+	if !r.Exposed() {
+		t.Fatal("expected node to be exposed")
+	}
+	out := r.Format(testCVE)
+	for _, want := range []string{"CVE-2024-21626 (Important)", "RHCOS 414.92.202310210434-0", "Result: EXPOSED", "runc 4:1.1.10-1.rhaos4.14.el9: affected; fixed in 4:1.1.12-1.rhaos4.14.el9 by RHSA-2024:0670"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestCheckFixed(t *testing.T) {
+	inv := Inventory{
+		Node:      "n2",
+		Packages:  map[string]EVR{"runc": ParseEVR("4:1.1.12-1.rhaos4.14.el9")},
+		OpenShift: "4.14",
+		RHEL:      "9.2",
+	}
+	r := Check(testCVE, inv)
+	if r.Exposed() || len(r.Findings) != 1 || r.Findings[0].Status != Fixed {
+		t.Fatalf("unexpected report %+v", r)
+	}
+}
+
+func TestFormatFleet(t *testing.T) {
+	affected := Check(testCVE, ParseInventory("n1", inventoryOutput, "--- os-release ---"))
+	fixed := Check(testCVE, Inventory{
+		Node:      "n2",
+		Packages:  map[string]EVR{"runc": ParseEVR("4:1.1.12-1.rhaos4.14.el9")},
+		OpenShift: "4.14",
+		RHEL:      "9.2",
+	})
+	failed := Report{Inventory: Inventory{Node: "n3"}, Err: errors.New("boom")}
+	out := FormatFleet(testCVE, []Report{affected, fixed, failed})
+	for _, want := range []string{
+		"| Node | Exposed | conmon | cri-o | kernel | runc | rhcos |",
+		"| n1 | YES |",
+		"| n2 | no | - | - | - | fixed (RHSA-2024:0670) | - |",
+		"| n3 | error: boom |",
+		"1 of 2 nodes exposed, 1 could not be checked",
+		"- RHSA-2024:0670 (1 node)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in\n%s", want, out)
+		}
+	}
+}
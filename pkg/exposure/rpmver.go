@@ -0,0 +1,185 @@
+package exposure
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EVR is an RPM epoch, version and release.
+type EVR struct {
+	Epoch   int
+	Version string
+	Release string
+}
+
+// String formats the EVR the way Security Data and rpm --qf print it.
+func (e EVR) String() string {
+	s := strconv.Itoa(e.Epoch) + ":" + e.Version
+	if e.Release != "" {
+		s += "-" + e.Release
+	}
+	return s
+}
+
+// archRE matches an architecture suffix that some sources append to a NEVRA.
+var archRE = regexp.MustCompile(`\.(x86_64|aarch64|ppc64le|s390x|noarch|src)$`)
+
+// ParseEVR parses "[EPOCH:]VERSION[-RELEASE]". A missing or "(none)" epoch
+// is treated as 0.
+func ParseEVR(s string) EVR {
+	var e EVR
+	if i := strings.Index(s, ":"); i >= 0 {
+		e.Epoch, _ = strconv.Atoi(s[:i])
+		s = s[i+1:]
+	}
+	if i := strings.LastIndex(s, "-"); i >= 0 {
+		e.Version, e.Release = s[:i], s[i+1:]
+	} else {
+		e.Version = s
+	}
+	return e
+}
+
+// ParseNEVRA splits a package string such as
+// "cri-o-0:1.27.1-6.rhaos4.14.git.el9" into its name and EVR.
+func ParseNEVRA(s string) (string, EVR) {
+	s = archRE.ReplaceAllString(s, "")
+	i := strings.LastIndex(s, "-")
+	if i < 0 {
+		return s, EVR{}
+	}
+	release := s[i+1:]
+	rest := s[:i]
+	j := strings.LastIndex(rest, "-")
+	if j < 0 {
+		return rest, EVR{Release: release}
+	}
+	e := ParseEVR(rest[j+1:])
+	e.Release = release
+	return rest[:j], e
+}
+
+// CompareEVR compares two EVRs and returns -1, 0 or 1. An empty release on
+// either side matches any release.
+func CompareEVR(a, b EVR) int {
+	if a.Epoch != b.Epoch {
+		if a.Epoch < b.Epoch {
+			return -1
+		}
+		return 1
+	}
+	if c := rpmvercmp(a.Version, b.Version); c != 0 {
+		return c
+	}
+	if a.Release == "" || b.Release == "" {
+		return 0
+	}
+	return rpmvercmp(a.Release, b.Release)
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// rpmvercmp compares two version or release strings with the same rules as
+// rpm: alternating numeric and alphabetic segments, numeric segments newer
+// than alphabetic ones, '~' sorting before anything and '^' after the base
+// version.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isAlnum(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isAlnum(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+		atA, atB := byte(0), byte(0)
+		if i < len(a) {
+			atA = a[i]
+		}
+		if j < len(b) {
+			atB = b[j]
+		}
+		if atA == '~' || atB == '~' {
+			if atA != '~' {
+				return 1
+			}
+			if atB != '~' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if atA == '^' || atB == '^' {
+			if atA == 0 {
+				return -1
+			}
+			if atB == 0 {
+				return 1
+			}
+			if atA != '^' {
+				return 1
+			}
+			if atB != '^' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if atA == 0 || atB == 0 {
+			break
+		}
+		numeric := isDigit(atA)
+		class := func(c byte) bool { return isAlnum(c) && !isDigit(c) }
+		if numeric {
+			class = isDigit
+		}
+		si, sj := i, j
+		for i < len(a) && class(a[i]) {
+			i++
+		}
+		for j < len(b) && class(b[j]) {
+			j++
+		}
+		segA, segB := a[si:i], b[sj:j]
+		if segB == "" {
+			// Segments of different types: numeric is newer.
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+		if numeric {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				if len(segA) < len(segB) {
+					return -1
+				}
+				return 1
+			}
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i < len(a):
+		return 1
+	default:
+		return -1
+	}
+}
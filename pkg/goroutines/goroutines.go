@@ -0,0 +1,271 @@
+// Package goroutines parses Go goroutine stack dumps such as the ones CRI-O
+// writes to /tmp/crio-goroutine-stacks-*.log or its journal on SIGUSR1. It has no cluster
+// dependencies so dumps taken from artifacts or sosreports can be analyzed
+// offline.
+package goroutines
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Frame is a single function call in a goroutine stack.
+type Frame struct {
+	Func     string
+	Location string
+}
+
+// Goroutine is one parsed goroutine from a stack dump.
+type Goroutine struct {
+	ID      int
+	State   string
+	Minutes int
+	Locked  bool
+	Frames  []Frame
+	Created string
+}
+
+// Group collects goroutines that share the same state and call stack.
+type Group struct {
+	State      string
+	Count      int
+	MinMinutes int
+	MaxMinutes int
+	Frames     []Frame
+	Created    string
+	IDs        []int
+}
+
+// Report is the result of analyzing a stack dump.
+type Report struct {
+	Total      int
+	States     map[string]int
+	Groups     []Group
+	Contention []string
+}
+
+var headerRE = regexp.MustCompile(`^goroutine (\d+) \[([^\]]*)\]:$`)
+
+// Parse reads a goroutine dump and returns the goroutines it contains. Lines
+// that are not part of a goroutine block are ignored so that dumps embedded in
+// other log output can be parsed as well.
+func Parse(r io.Reader) ([]Goroutine, error) {
+	var (
+		result []Goroutine
+		cur    *Goroutine
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if m := headerRE.FindStringSubmatch(line); m != nil {
+			if cur != nil {
+				result = append(result, *cur)
+			}
+			id, _ := strconv.Atoi(m[1])
+			cur = &Goroutine{ID: id}
+			parseState(cur, m[2])
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			result = append(result, *cur)
+			cur = nil
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			if n := len(cur.Frames); n > 0 && cur.Frames[n-1].Location == "" {
+				cur.Frames[n-1].Location = trimOffset(strings.TrimSpace(line))
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "created by ") {
+			cur.Created = strings.TrimPrefix(line, "created by ")
+			if i := strings.Index(cur.Created, " in goroutine "); i >= 0 {
+				cur.Created = cur.Created[:i]
+			}
+			continue
+		}
+		cur.Frames = append(cur.Frames, Frame{Func: trimArgs(line)})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read goroutine dump: %w", err)
+	}
+	if cur != nil {
+		result = append(result, *cur)
+	}
+	return result, nil
+}
+
+// parseState splits a header state such as "semacquire, 5 minutes, locked to
+// thread" into its parts.
+func parseState(g *Goroutine, s string) {
+	parts := strings.Split(s, ", ")
+	g.State = parts[0]
+	for _, p := range parts[1:] {
+		switch {
+		case strings.HasSuffix(p, " minutes"), strings.HasSuffix(p, " minute"):
+			g.Minutes, _ = strconv.Atoi(strings.Fields(p)[0])
+		case p == "locked to thread":
+			g.Locked = true
+		}
+	}
+}
+
+func trimArgs(fn string) string {
+	if i := strings.LastIndex(fn, "("); i > 0 && strings.HasSuffix(fn, ")") {
+		return fn[:i]
+	}
+	return fn
+}
+
+func trimOffset(loc string) string {
+	if i := strings.LastIndex(loc, " +0x"); i >= 0 {
+		return loc[:i]
+	}
+	return loc
+}
+
+// lockStates are wait reasons that indicate a goroutine is blocked on a lock.
+// sync.Cond.Wait is left out: it is how idle workers wait for more work, not
+// a sign that a lock is contended.
+var lockStates = map[string]bool{
+	"semacquire":         true,
+	"sync.Mutex.Lock":    true,
+	"sync.RWMutex.Lock":  true,
+	"sync.RWMutex.RLock": true,
+}
+
+// Analyze groups goroutines by state and stack and flags likely lock
+// contention. Groups are ordered by descending size.
+func Analyze(gs []Goroutine) Report {
+	rep := Report{Total: len(gs), States: map[string]int{}}
+	index := map[string]int{}
+	for _, g := range gs {
+		rep.States[g.State]++
+		key := g.State + "\n" + g.Created
+		for _, f := range g.Frames {
+			key += "\n" + f.Func
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(rep.Groups)
+			index[key] = i
+			rep.Groups = append(rep.Groups, Group{
+				State:      g.State,
+				Frames:     g.Frames,
+				Created:    g.Created,
+				MinMinutes: g.Minutes,
+				MaxMinutes: g.Minutes,
+			})
+		}
+		grp := &rep.Groups[i]
+		grp.Count++
+		grp.IDs = append(grp.IDs, g.ID)
+		if g.Minutes < grp.MinMinutes {
+			grp.MinMinutes = g.Minutes
+		}
+		if g.Minutes > grp.MaxMinutes {
+			grp.MaxMinutes = g.Minutes
+		}
+	}
+	sort.SliceStable(rep.Groups, func(i, j int) bool {
+		if rep.Groups[i].Count != rep.Groups[j].Count {
+			return rep.Groups[i].Count > rep.Groups[j].Count
+		}
+		return rep.Groups[i].MaxMinutes > rep.Groups[j].MaxMinutes
+	})
+	for _, grp := range rep.Groups {
+		if !lockStates[grp.State] {
+			continue
+		}
+		if grp.Count < 2 && grp.MaxMinutes == 0 {
+			continue
+		}
+		fn, loc := callerOf(grp.Frames)
+		msg := fmt.Sprintf("%d goroutine(s) waiting in %s at %s", grp.Count, grp.State, fn)
+		if loc != "" {
+			msg += " (" + loc + ")"
+		}
+		if grp.MaxMinutes > 0 {
+			msg += fmt.Sprintf(", blocked up to %d minutes", grp.MaxMinutes)
+		}
+		rep.Contention = append(rep.Contention, msg)
+	}
+	return rep
+}
+
+// callerOf returns the first frame outside the runtime and sync packages,
+// which is where the contended lock is acquired.
+func callerOf(frames []Frame) (string, string) {
+	for _, f := range frames {
+		if strings.HasPrefix(f.Func, "runtime.") || strings.HasPrefix(f.Func, "sync.") || strings.HasPrefix(f.Func, "internal/sync.") {
+			continue
+		}
+		return f.Func, f.Location
+	}
+	if len(frames) > 0 {
+		return frames[0].Func, frames[0].Location
+	}
+	return "unknown", ""
+}
+
+// Format renders the report as plain text. At most maxGroups groups are
+// listed; zero or a negative value lists all of them.
+func (r Report) Format(maxGroups int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d goroutines in %d unique stacks\n", r.Total, len(r.Groups))
+	states := make([]string, 0, len(r.States))
+	for s := range r.States {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if r.States[states[i]] != r.States[states[j]] {
+			return r.States[states[i]] > r.States[states[j]]
+		}
+		return states[i] < states[j]
+	})
+	b.WriteString("\nStates:\n")
+	for _, s := range states {
+		fmt.Fprintf(&b, "  %6d  %s\n", r.States[s], s)
+	}
+	if len(r.Contention) > 0 {
+		b.WriteString("\nLikely lock contention:\n")
+		for _, c := range r.Contention {
+			fmt.Fprintf(&b, "  - %s\n", c)
+		}
+	}
+	b.WriteString("\nStacks:\n")
+	for i, g := range r.Groups {
+		if maxGroups > 0 && i == maxGroups {
+			fmt.Fprintf(&b, "... %d more stacks omitted\n", len(r.Groups)-maxGroups)
+			break
+		}
+		fmt.Fprintf(&b, "\n[%d x %s", g.Count, g.State)
+		if g.MaxMinutes > 0 {
+			if g.MinMinutes == g.MaxMinutes {
+				fmt.Fprintf(&b, ", %d minutes", g.MaxMinutes)
+			} else {
+				fmt.Fprintf(&b, ", %d-%d minutes", g.MinMinutes, g.MaxMinutes)
+			}
+		}
+		b.WriteString("]\n")
+		for _, f := range g.Frames {
+			fmt.Fprintf(&b, "  %s\n", f.Func)
+			if f.Location != "" {
+				fmt.Fprintf(&b, "      %s\n", f.Location)
+			}
+		}
+		if g.Created != "" {
+			fmt.Fprintf(&b, "  created by %s\n", g.Created)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package goroutines
+
+import (
+	"strings"
+	"testing"
+)
+
+const dump = `goroutine 1 [chan receive, 12 minutes]:
+main.main()
+	/src/cmd/crio/main.go:300 +0x1a5
+
+goroutine 40 [sync.Mutex.Lock, 7 minutes]:
+sync.runtime_SemacquireMutex(0xc000123, 0x0, 0x1)
+	/usr/lib/golang/src/runtime/sema.go:77 +0x25
+sync.(*Mutex).lockSlow(0xc000120)
+	/usr/lib/golang/src/sync/mutex.go:171 +0x165
+sync.(*Mutex).Lock(...)
+	/usr/lib/golang/src/sync/mutex.go:90
+github.com/cri-o/cri-o/internal/oci.(*Container).State(0xc000120)
+	/src/internal/oci/container.go:420 +0x4d
+created by google.golang.org/grpc.(*Server).serveStreams.func1 in goroutine 30
+	/src/vendor/google.golang.org/grpc/server.go:1000 +0x1b9
+
+goroutine 41 [sync.Mutex.Lock, 3 minutes]:
+sync.runtime_SemacquireMutex(0xc000123, 0x0, 0x1)
+	/usr/lib/golang/src/runtime/sema.go:77 +0x25
+sync.(*Mutex).lockSlow(0xc000120)
+	/usr/lib/golang/src/sync/mutex.go:171 +0x165
+sync.(*Mutex).Lock(...)
+	/usr/lib/golang/src/sync/mutex.go:90
+github.com/cri-o/cri-o/internal/oci.(*Container).State(0xc000120)
+	/src/internal/oci/container.go:420 +0x4d
+created by google.golang.org/grpc.(*Server).serveStreams.func1 in goroutine 30
+	/src/vendor/google.golang.org/grpc/server.go:1000 +0x1b9
+
+goroutine 50 [IO wait]:
+internal/poll.runtime_pollWait(0x7f, 0x72)
+	/usr/lib/golang/src/runtime/netpoll.go:343 +0x85
+`
+
+func TestParse(t *testing.T) {
+	gs, err := Parse(strings.NewReader("journal noise\n" + dump))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gs) != 4 {
+		t.Fatalf("expected 4 goroutines, got %d", len(gs))
+	}
+	g := gs[1]
+	if g.ID != 40 || g.State != "sync.Mutex.Lock" || g.Minutes != 7 {
+		t.Fatalf("unexpected goroutine %+v", g)
+	}
+	if g.Frames[3].Func != "github.com/cri-o/cri-o/internal/oci.(*Container).State" {
+		t.Fatalf("unexpected frame %q", g.Frames[3].Func)
+	}
+	if g.Frames[3].Location != "/src/internal/oci/container.go:420" {
+		t.Fatalf("unexpected location %q", g.Frames[3].Location)
+	}
+	if g.Created != "google.golang.org/grpc.(*Server).serveStreams.func1" {
+		t.Fatalf("unexpected creator %q", g.Created)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	gs, err := Parse(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rep := Analyze(gs)
+	if rep.Total != 4 || len(rep.Groups) != 3 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	top := rep.Groups[0]
+	if top.Count != 2 || top.MinMinutes != 3 || top.MaxMinutes != 7 {
+		t.Fatalf("unexpected top group %+v", top)
+	}
+	if len(rep.Contention) != 1 || !strings.Contains(rep.Contention[0], "oci.(*Container).State") {
+		t.Fatalf("unexpected contention %v", rep.Contention)
+	}
+	out := rep.Format(2)
+	if !strings.Contains(out, "[2 x sync.Mutex.Lock, 3-7 minutes]") || !strings.Contains(out, "1 more stacks omitted") {
+		t.Fatalf("unexpected format:\n%s", out)
+	}
+}
+
+func TestAnalyzeIdleWorkers(t *testing.T) {
+	idle := "goroutine 5 [sync.Cond.Wait, 30 minutes]:\nsync.runtime_notifyListWait(0x1)\n\t/go/src/runtime/sema.go:569 +0x1\nsync.(*Cond).Wait(0x2)\n\t/go/src/sync/cond.go:70 +0x1\nmain.worker()\n\t/src/main.go:10 +0x1\n\n" +
+		"goroutine 6 [sync.Cond.Wait, 30 minutes]:\nsync.runtime_notifyListWait(0x1)\n\t/go/src/runtime/sema.go:569 +0x1\nsync.(*Cond).Wait(0x2)\n\t/go/src/sync/cond.go:70 +0x1\nmain.worker()\n\t/src/main.go:10 +0x1\n"
+	journal := "Received signal to dump goroutine stacks\n" + idle + "Stack dump written\n"
+	gs, err := Parse(strings.NewReader(journal))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rep := Analyze(gs)
+	if rep.Total != 2 || len(rep.Contention) != 0 {
+		t.Fatalf("idle workers reported as contention: %+v", rep)
+	}
+}
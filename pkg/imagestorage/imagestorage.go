@@ -0,0 +1,653 @@
+// Package imagestorage analyzes the container storage of a node under
+// /var/lib/containers. It cross-references the images and containers CRI-O
+// reports over CRI with the overlay driver's own bookkeeping in
+// overlay-images, overlay-layers and overlay-containers and with the size of
+// every layer directory, to find unused and dangling images, layers no image
+// or container refers to, containers left in storage that CRI-O does not know
+// and how much space removing them would free.
+package imagestorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harche/crio-mcp-server/pkg/openshift"
+)
+
+// Root is the storage root of CRI-O on OpenShift nodes.
+const Root = "/var/lib/containers/storage"
+
+// Sections of Script's output. Their names are also the keys of
+// Report.Errors.
+const (
+	SectionImages            = "images"
+	SectionContainers        = "containers"
+	SectionPods              = "pods"
+	SectionFilesystem        = "df"
+	SectionStorageImages     = "storage-images"
+	SectionStorageLayers     = "storage-layers"
+	SectionStorageContainers = "storage-containers"
+	SectionContainerDirs     = "container-dirs"
+	SectionOverlay           = "overlay"
+)
+
+// Script collects everything Analyze needs in one debug pod. du does not
+// cross into the overlay mounts of running containers, so each layer
+// directory is counted once.
+var Script = openshift.SectionedScript(
+	openshift.Section{Name: SectionImages, Command: "crictl images -o json"},
+	openshift.Section{Name: SectionContainers, Command: "crictl ps -a -o json"},
+	openshift.Section{Name: SectionPods, Command: "crictl pods -o json"},
+	openshift.Section{Name: SectionFilesystem, Command: "df -P /var/lib/containers"},
+	openshift.Section{Name: SectionStorageImages, Command: "cat " + Root + "/overlay-images/images.json"},
+	openshift.Section{Name: SectionStorageLayers, Command: "cat " + Root + "/overlay-layers/layers.json"},
+	openshift.Section{Name: SectionStorageContainers, Command: "cat " + Root + "/overlay-containers/containers.json"},
+	openshift.Section{Name: SectionContainerDirs, Command: "ls -1 " + Root + "/overlay-containers"},
+	openshift.Section{Name: SectionOverlay, Command: "du -xk -d 1 " + Root + "/overlay"},
+)
+
+// Image is an image known to CRI-O.
+type Image struct {
+	ID      string   `json:"id"`
+	Tags    []string `json:"tags,omitempty"`
+	Digests []string `json:"digests,omitempty"`
+	// Size is the size crictl reports, which counts layers shared with
+	// other images.
+	Size   int64 `json:"size"`
+	Pinned bool  `json:"pinned,omitempty"`
+	// Exclusive is the size of the layers no other image or container uses,
+	// which is what removing only this image frees.
+	Exclusive int64 `json:"exclusive"`
+	// Containers counts the containers in any state created from the image
+	// and Running those that run.
+	Containers int        `json:"containers"`
+	Running    int        `json:"running"`
+	LastUsed   *time.Time `json:"lastUsed,omitempty"`
+	Dangling   bool       `json:"dangling,omitempty"`
+	Unused     bool       `json:"unused,omitempty"`
+}
+
+// Name returns the first tag of the image, or "<none>" for a dangling one.
+func (i Image) Name() string {
+	if len(i.Tags) > 0 {
+		return i.Tags[0]
+	}
+	return "<none>"
+}
+
+// Layer is an overlay layer directory.
+type Layer struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+}
+
+// Leftover is a container in storage that CRI-O does not list.
+type Leftover struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Image string `json:"image,omitempty"`
+	Layer string `json:"layer,omitempty"`
+	Size  int64  `json:"size"`
+	// InStorage is false when only the container's directory is left and
+	// containers.json no longer lists it.
+	InStorage bool `json:"inStorage"`
+}
+
+// Filesystem is the usage of the filesystem holding /var/lib/containers.
+type Filesystem struct {
+	Mount     string `json:"mount"`
+	Size      int64  `json:"size"`
+	Used      int64  `json:"used"`
+	Available int64  `json:"available"`
+	Percent   int    `json:"percent"`
+}
+
+// Reclaimable estimates the space that removing each kind of leftover frees.
+type Reclaimable struct {
+	Images     int64 `json:"images"`
+	Layers     int64 `json:"layers"`
+	LayerDirs  int64 `json:"layerDirs"`
+	Containers int64 `json:"containers"`
+	Total      int64 `json:"total"`
+}
+
+// Report is the analysis of a node's image storage.
+type Report struct {
+	Node       string      `json:"node"`
+	Filesystem *Filesystem `json:"filesystem,omitempty"`
+	// Overlay is the space used by all layer directories.
+	Overlay      int64      `json:"overlay"`
+	LayerDirs    int        `json:"layerDirs"`
+	Images       []Image    `json:"images"`
+	Containers   int        `json:"containers"`
+	Sandboxes    int        `json:"sandboxes"`
+	Leftovers    []Leftover `json:"leftovers,omitempty"`
+	UnusedLayers []Layer    `json:"unusedLayers,omitempty"`
+	// UnknownLayerDirs are directories under overlay that layers.json does
+	// not list, such as the remains of an interrupted pull.
+	UnknownLayerDirs []Layer     `json:"unknownLayerDirs,omitempty"`
+	Reclaimable      Reclaimable `json:"reclaimable"`
+
+	// Errors holds, by section, why its data could not be read.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// number decodes integers that CRI encodes as JSON strings.
+type number int64
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	*n = number(v)
+	return err
+}
+
+type criImage struct {
+	ID          string   `json:"id"`
+	RepoTags    []string `json:"repoTags"`
+	RepoDigests []string `json:"repoDigests"`
+	Size        number   `json:"size"`
+	Pinned      bool     `json:"pinned"`
+}
+
+type criContainer struct {
+	ID    string `json:"id"`
+	Image struct {
+		Image string `json:"image"`
+	} `json:"image"`
+	ImageRef  string `json:"imageRef"`
+	State     string `json:"state"`
+	CreatedAt number `json:"createdAt"`
+}
+
+type storageImage struct {
+	ID           string   `json:"id"`
+	TopLayer     string   `json:"layer"`
+	MappedLayers []string `json:"mapped-layers"`
+}
+
+type storageLayer struct {
+	ID       string `json:"id"`
+	Parent   string `json:"parent"`
+	DiffSize int64  `json:"diff-size"`
+}
+
+type storageContainer struct {
+	ID      string   `json:"id"`
+	Names   []string `json:"names"`
+	ImageID string   `json:"image"`
+	Layer   string   `json:"layer"`
+}
+
+// idRE matches the 64 character IDs containers/storage names directories by.
+var idRE = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// decode unmarshals the JSON document in s, skipping anything printed
+// before or after it. open is '{' or '['.
+func decode(s string, open byte, v any) error {
+	closing := byte('}')
+	if open == '[' {
+		closing = ']'
+	}
+	start, end := strings.IndexByte(s, open), strings.LastIndexByte(s, closing)
+	if start < 0 || end < start {
+		if s = strings.TrimSpace(s); s == "" {
+			return fmt.Errorf("no output")
+		}
+		return fmt.Errorf("%s", s)
+	}
+	return json.Unmarshal([]byte(s[start:end+1]), v)
+}
+
+func parseDF(s string) (*Filesystem, error) {
+	for _, line := range strings.Split(s, "\n") {
+		// Filesystem 1024-blocks Used Available Capacity Mounted on
+		f := strings.Fields(line)
+		if len(f) < 6 || !strings.HasSuffix(f[4], "%") {
+			continue
+		}
+		size, err1 := strconv.ParseInt(f[1], 10, 64)
+		used, err2 := strconv.ParseInt(f[2], 10, 64)
+		avail, err3 := strconv.ParseInt(f[3], 10, 64)
+		pct, err4 := strconv.Atoi(strings.TrimSuffix(f[4], "%"))
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			continue
+		}
+		return &Filesystem{Mount: f[5], Size: size << 10, Used: used << 10, Available: avail << 10, Percent: pct}, nil
+	}
+	return nil, fmt.Errorf("df: %s", strings.TrimSpace(s))
+}
+
+// parseDU reads "du -k -d 1" of the overlay directory and returns the size
+// of each layer directory and of the whole directory.
+func parseDU(s string) (map[string]int64, int64, error) {
+	dirs := map[string]int64{}
+	var total int64
+	found := false
+	for _, line := range strings.Split(s, "\n") {
+		kb, path, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(kb, 10, 64)
+		if err != nil {
+			continue
+		}
+		name := path[strings.LastIndexByte(path, '/')+1:]
+		switch {
+		case strings.TrimSuffix(path, "/") == Root+"/overlay":
+			total, found = n<<10, true
+		case idRE.MatchString(name):
+			dirs[name] = n << 10
+		}
+	}
+	if !found {
+		return nil, 0, fmt.Errorf("du: %s", strings.TrimSpace(s))
+	}
+	return dirs, total, nil
+}
+
+// Analyze reads the output of Script run on node.
+func Analyze(node, out string) *Report {
+	r := &Report{Node: node, Errors: map[string]string{}}
+	secs := openshift.SplitSections(out)
+	read := func(name string, parse func(string) error) bool {
+		s, ok := secs[name]
+		if !ok {
+			r.Errors[name] = "no output from the node"
+			return false
+		}
+		if err := parse(s); err != nil {
+			r.Errors[name] = err.Error()
+			return false
+		}
+		return true
+	}
+
+	var (
+		images      struct{ Images []criImage }
+		containers  struct{ Containers []criContainer }
+		pods        struct{ Items []struct{ ID string } }
+		sImages     []storageImage
+		sLayers     []storageLayer
+		sContainers []storageContainer
+		dirs        []string
+		du          map[string]int64
+	)
+	haveImages := read(SectionImages, func(s string) error { return decode(s, '{', &images) })
+	haveContainers := read(SectionContainers, func(s string) error { return decode(s, '{', &containers) })
+	havePods := read(SectionPods, func(s string) error { return decode(s, '{', &pods) })
+	read(SectionFilesystem, func(s string) (err error) { r.Filesystem, err = parseDF(s); return })
+	haveSImages := read(SectionStorageImages, func(s string) error { return decode(s, '[', &sImages) })
+	haveSLayers := read(SectionStorageLayers, func(s string) error { return decode(s, '[', &sLayers) })
+	haveSContainers := read(SectionStorageContainers, func(s string) error { return decode(s, '[', &sContainers) })
+	read(SectionContainerDirs, func(s string) error {
+		for _, l := range strings.Fields(s) {
+			if idRE.MatchString(l) {
+				dirs = append(dirs, l)
+			}
+		}
+		if len(dirs) == 0 && !strings.Contains(s, "containers.json") {
+			return fmt.Errorf("ls: %s", strings.TrimSpace(s))
+		}
+		return nil
+	})
+	haveDU := read(SectionOverlay, func(s string) (err error) { du, r.Overlay, err = parseDU(s); return })
+	r.LayerDirs = len(du)
+	r.Containers = len(containers.Containers)
+	r.Sandboxes = len(pods.Items)
+
+	// Layers, their parents and sizes. du measures what is on disk; the
+	// diff size recorded at pull time stands in when du is missing.
+	parent := map[string]string{}
+	size := map[string]int64{}
+	for _, l := range sLayers {
+		parent[l.ID] = l.Parent
+		size[l.ID] = l.DiffSize
+		if n, ok := du[l.ID]; ok {
+			size[l.ID] = n
+		}
+	}
+	chain := func(top string, into map[string]bool) {
+		for id := top; id != "" && !into[id]; id = parent[id] {
+			into[id] = true
+		}
+	}
+
+	storageByID := map[string]storageContainer{}
+	for _, c := range sContainers {
+		storageByID[c.ID] = c
+	}
+
+	for _, ci := range images.Images {
+		img := Image{ID: trimID(ci.ID), Digests: ci.RepoDigests, Size: int64(ci.Size), Pinned: ci.Pinned}
+		for _, t := range ci.RepoTags {
+			if t != "" && !strings.HasPrefix(t, "<none>") {
+				img.Tags = append(img.Tags, t)
+			}
+		}
+		img.Dangling = len(img.Tags) == 0
+		r.Images = append(r.Images, img)
+	}
+	byRef := map[string]int{}
+	for i, img := range r.Images {
+		byRef[img.ID] = i
+		for _, ref := range append(append([]string(nil), img.Tags...), img.Digests...) {
+			byRef[ref] = i
+		}
+	}
+	for _, c := range containers.Containers {
+		i, ok := -1, false
+		if sc, found := storageByID[c.ID]; found {
+			i, ok = byRef[sc.ImageID]
+		}
+		for _, ref := range []string{c.ImageRef, c.Image.Image} {
+			if !ok {
+				i, ok = byRef[trimID(ref)]
+			}
+		}
+		if !ok {
+			continue
+		}
+		img := &r.Images[i]
+		img.Containers++
+		if c.State == "CONTAINER_RUNNING" {
+			img.Running++
+		}
+		if t := time.Unix(0, int64(c.CreatedAt)).UTC(); c.CreatedAt > 0 && (img.LastUsed == nil || t.After(*img.LastUsed)) {
+			img.LastUsed = &t
+		}
+	}
+	for i := range r.Images {
+		img := &r.Images[i]
+		img.Unused = haveContainers && img.Containers == 0
+	}
+
+	// Containers left in storage. Infra containers share their sandbox's ID.
+	if haveContainers && havePods && haveSContainers {
+		known := map[string]bool{}
+		for _, c := range containers.Containers {
+			known[c.ID] = true
+		}
+		for _, p := range pods.Items {
+			known[p.ID] = true
+		}
+		seen := map[string]bool{}
+		for _, c := range sContainers {
+			if known[c.ID] {
+				continue
+			}
+			seen[c.ID] = true
+			l := Leftover{ID: c.ID, Image: c.ImageID, Layer: c.Layer, Size: du[c.Layer], InStorage: true}
+			if len(c.Names) > 0 {
+				l.Name = c.Names[0]
+			}
+			if i, ok := byRef[c.ImageID]; ok && !r.Images[i].Dangling {
+				l.Image = r.Images[i].Name()
+			}
+			r.Leftovers = append(r.Leftovers, l)
+		}
+		for _, d := range dirs {
+			if !known[d] && !seen[d] {
+				r.Leftovers = append(r.Leftovers, Leftover{ID: d})
+			}
+		}
+	}
+
+	// Layers referenced by images and containers. Removing images keeps
+	// every container, including leftovers, and the layers below them, and
+	// images only storage knows about, such as those podman pulled.
+	if haveSImages && haveSLayers && haveSContainers {
+		inUse := map[string]bool{}
+		for _, c := range sContainers {
+			chain(c.Layer, inUse)
+		}
+		removable := map[string]bool{}
+		for _, img := range r.Images {
+			removable[img.ID] = img.Unused && !img.Pinned
+		}
+		layersOf := map[string]map[string]bool{}
+		users := map[string]int{}
+		kept, freed := map[string]bool{}, map[string]bool{}
+		for l := range inUse {
+			kept[l] = true
+		}
+		for _, i := range sImages {
+			ls := map[string]bool{}
+			for _, top := range append([]string{i.TopLayer}, i.MappedLayers...) {
+				chain(top, ls)
+			}
+			layersOf[i.ID] = ls
+			for l := range ls {
+				users[l]++
+				if removable[i.ID] {
+					freed[l] = true
+				} else {
+					kept[l] = true
+				}
+			}
+		}
+		for i := range r.Images {
+			for l := range layersOf[r.Images[i].ID] {
+				if users[l] == 1 && !inUse[l] {
+					r.Images[i].Exclusive += size[l]
+				}
+			}
+		}
+		for l := range freed {
+			if !kept[l] {
+				r.Reclaimable.Images += size[l]
+			}
+		}
+		for _, l := range sLayers {
+			if !kept[l.ID] && !freed[l.ID] {
+				r.UnusedLayers = append(r.UnusedLayers, Layer{ID: l.ID, Size: size[l.ID]})
+				r.Reclaimable.Layers += size[l.ID]
+			}
+		}
+	} else if haveImages && haveContainers {
+		// Without the storage metadata shared layers cannot be told apart
+		// and the estimate counts them once per image.
+		for _, img := range r.Images {
+			if img.Unused && !img.Pinned {
+				r.Reclaimable.Images += img.Size
+			}
+		}
+	}
+
+	if haveDU && haveSLayers {
+		for id, n := range du {
+			if _, ok := parent[id]; !ok {
+				r.UnknownLayerDirs = append(r.UnknownLayerDirs, Layer{ID: id, Size: n})
+				r.Reclaimable.LayerDirs += n
+			}
+		}
+	}
+	for _, l := range r.Leftovers {
+		r.Reclaimable.Containers += l.Size
+	}
+	r.Reclaimable.Total = r.Reclaimable.Images + r.Reclaimable.Layers + r.Reclaimable.LayerDirs + r.Reclaimable.Containers
+
+	sort.SliceStable(r.Images, func(i, j int) bool { return r.Images[i].Size > r.Images[j].Size })
+	bySize := func(ls []Layer) func(i, j int) bool {
+		return func(i, j int) bool {
+			if ls[i].Size != ls[j].Size {
+				return ls[i].Size > ls[j].Size
+			}
+			return ls[i].ID < ls[j].ID
+		}
+	}
+	sort.Slice(r.UnusedLayers, bySize(r.UnusedLayers))
+	sort.Slice(r.UnknownLayerDirs, bySize(r.UnknownLayerDirs))
+	sort.SliceStable(r.Leftovers, func(i, j int) bool { return r.Leftovers[i].Size > r.Leftovers[j].Size })
+	return r
+}
+
+// trimID drops the digest algorithm from an image ID.
+func trimID(id string) string {
+	return strings.TrimPrefix(id, "sha256:")
+}
+
+func short(id string) string {
+	if len(id) > 13 {
+		return id[:13]
+	}
+	return id
+}
+
+// FormatSize renders a byte count with a binary unit.
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// maxListed is the number of layers and leftovers Summary lists per kind.
+const maxListed = 10
+
+// Summary renders the analysis for a reader.
+func (r *Report) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "node %s\n", r.Node)
+	if fs := r.Filesystem; fs != nil {
+		fmt.Fprintf(&b, "filesystem: %s %d%% used (%s of %s, %s available)\n", fs.Mount, fs.Percent, FormatSize(fs.Used), FormatSize(fs.Size), FormatSize(fs.Available))
+	}
+	fmt.Fprintf(&b, "overlay: %s in %d layer directories\n", FormatSize(r.Overlay), r.LayerDirs)
+	var unused, dangling int
+	for _, img := range r.Images {
+		if img.Unused {
+			unused++
+		}
+		if img.Dangling {
+			dangling++
+		}
+	}
+	fmt.Fprintf(&b, "images: %d, %d unused, %d dangling\n", len(r.Images), unused, dangling)
+	fmt.Fprintf(&b, "containers: %d known to CRI-O in %d sandboxes, %d left in storage\n", r.Containers, r.Sandboxes, len(r.Leftovers))
+	rc := r.Reclaimable
+	fmt.Fprintf(&b, "reclaimable: about %s\n", FormatSize(rc.Total))
+	fmt.Fprintf(&b, "  unused images: %s\n", FormatSize(rc.Images))
+	fmt.Fprintf(&b, "  unreferenced layers: %s\n", FormatSize(rc.Layers))
+	fmt.Fprintf(&b, "  layer directories unknown to storage: %s\n", FormatSize(rc.LayerDirs))
+	fmt.Fprintf(&b, "  leftover containers: %s\n", FormatSize(rc.Containers))
+
+	if len(r.Images) > 0 {
+		b.WriteString("\nimages, largest first:\n")
+		fmt.Fprintf(&b, "%-13s  %9s  %9s  %-30s  %s\n", "ID", "SIZE", "EXCLUSIVE", "USE", "NAME")
+		for _, img := range r.Images {
+			fmt.Fprintf(&b, "%-13s  %9s  %9s  %-30s  %s\n", short(img.ID), FormatSize(img.Size), FormatSize(img.Exclusive), use(img), img.Name())
+		}
+	}
+	if len(r.Leftovers) > 0 {
+		b.WriteString("\ncontainers in storage that CRI-O does not list (podman containers also show here):\n")
+		for i, l := range r.Leftovers {
+			if i == maxListed {
+				fmt.Fprintf(&b, "  ... %d more\n", len(r.Leftovers)-maxListed)
+				break
+			}
+			switch {
+			case !l.InStorage:
+				fmt.Fprintf(&b, "  %s  directory only\n", short(l.ID))
+			default:
+				image := l.Image
+				if idRE.MatchString(image) {
+					image = short(image)
+				}
+				fmt.Fprintf(&b, "  %s  %s  %s  image %s\n", short(l.ID), FormatSize(l.Size), l.Name, image)
+			}
+		}
+	}
+	for _, list := range []struct {
+		title  string
+		layers []Layer
+	}{
+		{"layers no image or container uses", r.UnusedLayers},
+		{"layer directories layers.json does not list", r.UnknownLayerDirs},
+	} {
+		if len(list.layers) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", list.title)
+		for i, l := range list.layers {
+			if i == maxListed {
+				fmt.Fprintf(&b, "  ... %d more\n", len(list.layers)-maxListed)
+				break
+			}
+			fmt.Fprintf(&b, "  %s  %s\n", short(l.ID), FormatSize(l.Size))
+		}
+	}
+	if len(r.Errors) > 0 {
+		b.WriteString("\nnot collected, the estimate may be incomplete:\n")
+		names := make([]string, 0, len(r.Errors))
+		for n := range r.Errors {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintf(&b, "- %s: %s\n", n, r.Errors[n])
+		}
+	}
+	return b.String()
+}
+
+// use describes how an image is used.
+func use(img Image) string {
+	var s string
+	switch {
+	case img.Running > 0:
+		s = fmt.Sprintf("%d running", img.Running)
+		if n := img.Containers - img.Running; n > 0 {
+			s += fmt.Sprintf(", %d exited", n)
+		}
+	case img.Containers > 0 && img.LastUsed != nil:
+		s = fmt.Sprintf("%d exited, last %s", img.Containers, img.LastUsed.Format("2006-01-02 15:04"))
+	case img.Containers > 0:
+		s = fmt.Sprintf("%d exited", img.Containers)
+	case img.Unused:
+		s = "unused"
+	default:
+		s = "unknown"
+	}
+	if img.Pinned {
+		s += ", pinned"
+	}
+	return s
+}
+
+// JSON returns the report with every collected value.
+func (r *Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// refRE matches the image IDs and references PruneArgs accepts; they are
+// passed to a shell on the node.
+var refRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/:@-]*$`)
+
+// PruneArgs returns the crictl arguments that remove images. Without images
+// every image no container uses is removed.
+func PruneArgs(images []string) ([]string, error) {
+	if len(images) == 0 {
+		return []string{"rmi", "--prune"}, nil
+	}
+	for _, i := range images {
+		if !refRE.MatchString(i) {
+			return nil, fmt.Errorf("invalid image reference %q", i)
+		}
+	}
+	return append([]string{"rmi"}, images...), nil
+}
@@ -0,0 +1,227 @@
+package imagestorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// id returns a storage ID made of c.
+func id(c string) string { return strings.Repeat(c, 64) }
+
+var (
+	layerBase, layerApp, layerOld, layerStray, layerDangling, layerPause = id("1"), id("2"), id("3"), id("4"), id("5"), id("6")
+	layerWeb, layerLeft, layerInfra, layerUnknown                        = id("7"), id("8"), id("9"), id("0")
+	imgApp, imgOld, imgDangling, imgPause                                = id("a"), id("b"), id("c"), id("d")
+	ctrWeb, ctrLeft, ctrDirOnly, sandbox                                 = id("e"), id("f"), strings.Repeat("ef", 32), strings.Repeat("fe", 32)
+)
+
+func fixture() string {
+	kb := map[string]int{
+		layerBase: 100000, layerApp: 200000, layerOld: 300000, layerStray: 4000, layerDangling: 50000,
+		layerPause: 700, layerWeb: 1000, layerLeft: 20000, layerInfra: 10, layerUnknown: 9000,
+	}
+	var du strings.Builder
+	for l, n := range kb {
+		fmt.Fprintf(&du, "%d\t%s/overlay/%s\n", n, Root, l)
+	}
+	fmt.Fprintf(&du, "4\t%s/overlay/l\n", Root)
+	fmt.Fprintf(&du, "1000000\t%s/overlay\n", Root)
+
+	return `Starting pod/worker-0-debug-abcde ...
+### images
+{"images": [
+  {"id": "` + imgApp + `", "repoTags": ["quay.io/acme/app:v2"], "repoDigests": ["quay.io/acme/app@sha256:aaaa"], "size": "307200000", "pinned": false},
+  {"id": "` + imgOld + `", "repoTags": ["quay.io/acme/app:v1"], "repoDigests": [], "size": "409600000", "pinned": false},
+  {"id": "` + imgDangling + `", "repoTags": [], "repoDigests": ["quay.io/acme/tool@sha256:cccc"], "size": "51200000", "pinned": false},
+  {"id": "` + imgPause + `", "repoTags": ["registry.k8s.io/pause:3.9"], "repoDigests": [], "size": "716800", "pinned": true}
+]}
+### containers
+{"containers": [
+  {"id": "` + ctrWeb + `", "podSandboxId": "` + sandbox + `", "metadata": {"name": "web", "attempt": 0}, "image": {"image": "` + imgApp + `"}, "imageRef": "` + imgApp + `", "state": "CONTAINER_RUNNING", "createdAt": "1760000000000000000"}
+]}
+### pods
+{"items": [{"id": "` + sandbox + `", "state": "SANDBOX_READY"}]}
+### df
+Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda4        125829120 107374182  18454938      86% /var
+### storage-images
+[
+  {"id": "` + imgApp + `", "names": ["quay.io/acme/app:v2"], "layer": "` + layerApp + `"},
+  {"id": "` + imgOld + `", "names": ["quay.io/acme/app:v1"], "layer": "` + layerOld + `"},
+  {"id": "` + imgDangling + `", "layer": "` + layerDangling + `"},
+  {"id": "` + imgPause + `", "names": ["registry.k8s.io/pause:3.9"], "layer": "` + layerPause + `"}
+]
+### storage-layers
+[
+  {"id": "` + layerBase + `", "diff-size": 1},
+  {"id": "` + layerApp + `", "parent": "` + layerBase + `"},
+  {"id": "` + layerOld + `", "parent": "` + layerBase + `"},
+  {"id": "` + layerStray + `"},
+  {"id": "` + layerDangling + `"},
+  {"id": "` + layerPause + `"},
+  {"id": "` + layerWeb + `", "parent": "` + layerApp + `"},
+  {"id": "` + layerLeft + `", "parent": "` + layerApp + `"},
+  {"id": "` + layerInfra + `", "parent": "` + layerPause + `"}
+]
+### storage-containers
+[
+  {"id": "` + ctrWeb + `", "names": ["k8s_web_web-1_app_0"], "image": "` + imgApp + `", "layer": "` + layerWeb + `"},
+  {"id": "` + sandbox + `", "names": ["k8s_POD_web-1_app_0"], "image": "` + imgPause + `", "layer": "` + layerInfra + `"},
+  {"id": "` + ctrLeft + `", "names": ["k8s_web_web-0_app_3"], "image": "` + imgApp + `", "layer": "` + layerLeft + `"}
+]
+### container-dirs
+` + ctrWeb + `
+` + sandbox + `
+` + ctrLeft + `
+` + ctrDirOnly + `
+containers.json
+containers.lock
+### overlay
+` + du.String() + `### end
+
+Removing debug pod ...
+`
+}
+
+func TestAnalyze(t *testing.T) {
+	r := Analyze("worker-0", fixture())
+	if len(r.Errors) != 0 {
+		t.Fatalf("unexpected errors %v", r.Errors)
+	}
+	if r.Filesystem.Percent != 86 || r.Filesystem.Mount != "/var" || r.Overlay != 1000000<<10 || r.LayerDirs != 10 {
+		t.Fatalf("unexpected usage %+v %d %d", r.Filesystem, r.Overlay, r.LayerDirs)
+	}
+	var got []string
+	for _, img := range r.Images {
+		got = append(got, fmt.Sprintf("%s unused=%t dangling=%t containers=%d running=%d exclusive=%d", img.Name(), img.Unused, img.Dangling, img.Containers, img.Running, img.Exclusive>>10))
+	}
+	want := []string{
+		"quay.io/acme/app:v1 unused=true dangling=false containers=0 running=0 exclusive=300000",
+		"quay.io/acme/app:v2 unused=false dangling=false containers=1 running=1 exclusive=0",
+		"<none> unused=true dangling=true containers=0 running=0 exclusive=50000",
+		"registry.k8s.io/pause:3.9 unused=true dangling=false containers=0 running=0 exclusive=0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected images\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if r.Images[1].LastUsed == nil || r.Images[1].LastUsed.Year() != 2025 {
+		t.Fatalf("unexpected last use %v", r.Images[1].LastUsed)
+	}
+	if len(r.Leftovers) != 2 || r.Leftovers[0].ID != ctrLeft || r.Leftovers[0].Image != "quay.io/acme/app:v2" || r.Leftovers[0].Size != 20000<<10 ||
+		r.Leftovers[1].ID != ctrDirOnly || r.Leftovers[1].InStorage {
+		t.Fatalf("unexpected leftovers %+v", r.Leftovers)
+	}
+	if len(r.UnusedLayers) != 1 || r.UnusedLayers[0].ID != layerStray {
+		t.Fatalf("unexpected unused layers %+v", r.UnusedLayers)
+	}
+	if len(r.UnknownLayerDirs) != 1 || r.UnknownLayerDirs[0].ID != layerUnknown {
+		t.Fatalf("unexpected unknown layer dirs %+v", r.UnknownLayerDirs)
+	}
+	wantRC := Reclaimable{Images: 350000 << 10, Layers: 4000 << 10, LayerDirs: 9000 << 10, Containers: 20000 << 10, Total: 383000 << 10}
+	if r.Reclaimable != wantRC {
+		t.Fatalf("unexpected reclaimable %+v, want %+v", r.Reclaimable, wantRC)
+	}
+}
+
+func TestAnalyzeWithoutStorageMetadata(t *testing.T) {
+	out := `### images
+{"images": [
+  {"id": "sha256:` + imgApp + `", "repoTags": ["quay.io/acme/app:v2"], "repoDigests": ["quay.io/acme/app@sha256:aaaa"], "size": "1024"},
+  {"id": "sha256:` + imgOld + `", "repoTags": ["<none>:<none>"], "size": "2048"}
+]}
+### containers
+{"containers": [
+  {"id": "c1", "image": {"image": "quay.io/acme/app@sha256:aaaa"}, "imageRef": "quay.io/acme/app@sha256:aaaa", "state": "CONTAINER_EXITED", "createdAt": "1760000000000000000"}
+]}
+### pods
+{"items": []}
+### storage-images
+cat: /var/lib/containers/storage/overlay-images/images.json: Permission denied
+### end
+`
+	r := Analyze("worker-0", out)
+	if r.Images[1].Containers != 1 || r.Images[1].Unused || !r.Images[0].Dangling || !r.Images[0].Unused {
+		t.Fatalf("unexpected images %+v", r.Images)
+	}
+	if r.Reclaimable.Images != 2048 || r.Reclaimable.Total != 2048 || len(r.Leftovers) != 0 {
+		t.Fatalf("unexpected reclaimable %+v %+v", r.Reclaimable, r.Leftovers)
+	}
+	if !strings.Contains(r.Errors[SectionStorageImages], "Permission denied") || r.Errors[SectionOverlay] != "no output from the node" {
+		t.Fatalf("unexpected errors %v", r.Errors)
+	}
+}
+
+func TestAnalyzeWithoutContainers(t *testing.T) {
+	out := strings.Replace(fixture(), "### containers\n", "### containers\nFATA[0000] connect: connection refused\n### ignored\n", 1)
+	r := Analyze("worker-0", out)
+	if !strings.Contains(r.Errors[SectionContainers], "connection refused") {
+		t.Fatalf("unexpected errors %v", r.Errors)
+	}
+	// Without the containers nothing can be called unused or left over.
+	for _, img := range r.Images {
+		if img.Unused {
+			t.Fatalf("image %s reported unused", img.Name())
+		}
+	}
+	if len(r.Leftovers) != 0 || r.Reclaimable.Images != 0 {
+		t.Fatalf("unexpected result %+v %+v", r.Leftovers, r.Reclaimable)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	got := Analyze("worker-0", fixture()).Summary()
+	for _, want := range []string{
+		"node worker-0\nfilesystem: /var 86% used (102.4GiB of 120.0GiB, 17.6GiB available)\noverlay: 976.6MiB in 10 layer directories\n",
+		"images: 4, 3 unused, 1 dangling\ncontainers: 1 known to CRI-O in 1 sandboxes, 2 left in storage\n",
+		"reclaimable: about 374.0MiB\n  unused images: 341.8MiB\n",
+		"bbbbbbbbbbbbb   390.6MiB   293.0MiB  unused                          quay.io/acme/app:v1\n",
+		"aaaaaaaaaaaaa   293.0MiB         0B  1 running                       quay.io/acme/app:v2\n",
+		"ddddddddddddd   700.0KiB         0B  unused, pinned                  registry.k8s.io/pause:3.9\n",
+		"  fffffffffffff  19.5MiB  k8s_web_web-0_app_3  image quay.io/acme/app:v2\n  efefefefefefe  directory only\n",
+		"layers no image or container uses:\n  4444444444444  3.9MiB\n",
+		"layer directories layers.json does not list:\n  0000000000000  8.8MiB\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("summary lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "not collected") {
+		t.Fatalf("unexpected errors in summary:\n%s", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	js, err := Analyze("worker-0", fixture()).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Report
+	if err := json.Unmarshal([]byte(js), &back); err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Images) != 4 || back.Reclaimable.Total != 383000<<10 || len(back.Leftovers) != 2 {
+		t.Fatalf("unexpected JSON %s", js)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{0: "0B", 1023: "1023B", 1536: "1.5KiB", 5 << 30: "5.0GiB"} {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestPruneArgs(t *testing.T) {
+	if args, _ := PruneArgs(nil); strings.Join(args, " ") != "rmi --prune" {
+		t.Fatalf("unexpected args %v", args)
+	}
+	if args, _ := PruneArgs([]string{imgOld, "quay.io/acme/app@sha256:aaaa"}); len(args) != 3 || args[0] != "rmi" {
+		t.Fatalf("unexpected args %v", args)
+	}
+	if _, err := PruneArgs([]string{"app; reboot"}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
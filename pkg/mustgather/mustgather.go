@@ -0,0 +1,628 @@
+// Package mustgather indexes the output of "oc adm must-gather" so it can be
+// queried without access to the cluster it came from. Both extracted
+// directories and tarballs are supported.
+package mustgather
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Condition is a Kubernetes status condition.
+type Condition struct {
+	Type    string `yaml:"type"`
+	Status  string `yaml:"status"`
+	Reason  string `yaml:"reason"`
+	Message string `yaml:"message"`
+}
+
+type metadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
+// ClusterOperator is the status of an OpenShift cluster operator.
+type ClusterOperator struct {
+	Name       string
+	Conditions []Condition
+}
+
+// Healthy reports whether the operator is Available and neither Degraded nor
+// Progressing.
+func (c ClusterOperator) Healthy() bool {
+	return conditionIs(c.Conditions, "Available", "True") &&
+		!conditionIs(c.Conditions, "Degraded", "True") &&
+		!conditionIs(c.Conditions, "Progressing", "True")
+}
+
+// Node is the status of a cluster node.
+type Node struct {
+	Name       string
+	Roles      []string
+	Conditions []Condition
+	Taints     []string
+}
+
+// Ready reports whether the node's Ready condition is True.
+func (n Node) Ready() bool {
+	return conditionIs(n.Conditions, "Ready", "True")
+}
+
+// Pressure returns the pressure or unavailability conditions that are set on
+// the node.
+func (n Node) Pressure() []Condition {
+	var out []Condition
+	for _, c := range n.Conditions {
+		if c.Type != "Ready" && c.Status == "True" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// MachineConfigPool is the status of a MachineConfigPool.
+type MachineConfigPool struct {
+	Name                 string
+	MachineCount         int
+	ReadyMachineCount    int
+	UpdatedMachineCount  int
+	DegradedMachineCount int
+	Conditions           []Condition
+}
+
+// Degraded reports whether the pool is degraded.
+func (p MachineConfigPool) Degraded() bool {
+	return conditionIs(p.Conditions, "Degraded", "True") || p.DegradedMachineCount > 0
+}
+
+// Updating reports whether the pool is rolling out a new configuration.
+func (p MachineConfigPool) Updating() bool {
+	return conditionIs(p.Conditions, "Updating", "True")
+}
+
+// Pod is a summary of a pod's status.
+type Pod struct {
+	Namespace string
+	Name      string
+	Node      string
+	Phase     string
+	Reasons   []string
+	Restarts  int
+}
+
+// Failing reports whether the pod is not running or completed cleanly, or has
+// containers stuck in a waiting or terminated error state.
+func (p Pod) Failing() bool {
+	if p.Phase != "Running" && p.Phase != "Succeeded" {
+		return true
+	}
+	return len(p.Reasons) > 0
+}
+
+// Bundle is an indexed must-gather.
+type Bundle struct {
+	Root             string
+	ClusterOperators []ClusterOperator
+	Nodes            []Node
+	Pools            []MachineConfigPool
+	Pods             []Pod
+	// Logs maps a unit ("crio" or "kubelet") to the journal files for it.
+	Logs map[string][]string
+
+	// entry is the cache entry of a bundle returned by Open for an archive,
+	// and done makes Close release it once.
+	entry *entry
+	done  *sync.Once
+}
+
+// Close releases a bundle returned by Open. The files extracted from an
+// archive stay in place until the bundle has been evicted from the cache and
+// every caller has closed it. Closing a bundle more than once has no effect.
+func (b *Bundle) Close() {
+	if b.entry != nil {
+		b.done.Do(b.entry.release)
+	}
+}
+
+// MaxExtractBytesEnv sets MaxExtractBytes.
+const MaxExtractBytesEnv = "CRIO_MCP_MAX_EXTRACT_BYTES"
+
+// DefaultMaxExtractBytes is the default limit on the data extracted from one
+// archive.
+const DefaultMaxExtractBytes = 4 << 30
+
+// MaxExtractBytes limits the total size of the files extracted from one
+// archive, and MaxExtractFiles their number, so that a malformed or hostile
+// archive cannot fill the disk. MaxExtractBytes defaults to
+// CRIO_MCP_MAX_EXTRACT_BYTES or 4 GiB. Tests may override both.
+var (
+	MaxExtractBytes int64 = MaxExtractBytesFromEnv()
+	MaxExtractFiles       = 500000
+)
+
+// MaxExtractBytesFromEnv returns the extraction limit configured by the
+// environment, falling back to DefaultMaxExtractBytes for unset or invalid
+// values.
+func MaxExtractBytesFromEnv() int64 {
+	if n, err := strconv.ParseInt(os.Getenv(MaxExtractBytesEnv), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return DefaultMaxExtractBytes
+}
+
+// CacheSize is the number of indexed archives kept by Open. When it is
+// exceeded the least recently used one is dropped, and its temporary
+// directory is removed once no caller uses it. Tests may override it.
+var CacheSize = 4
+
+// entry is an archive in the cache. The first caller extracts and indexes it
+// while later callers for the same archive wait for ready. refs counts the
+// callers using it, including the one loading it; an evicted entry's
+// temporary directory is removed when refs drops to zero.
+type entry struct {
+	key   string
+	ready chan struct{}
+	// bundle, err and tmp are set before ready is closed.
+	bundle *Bundle
+	err    error
+	tmp    string
+	// elem, refs and evicted are guarded by cacheMu.
+	elem    *list.Element
+	refs    int
+	evicted bool
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]*entry{}
+	lru     = list.New()
+)
+
+// Open indexes the must-gather at path, which may be a directory or a
+// .tar, .tar.gz or .tgz archive. Directories are indexed on every call, so
+// changes to their files are always seen. Archives are extracted to a
+// temporary directory once and cached by path, size and modification time;
+// see CacheSize. Concurrent calls for the same archive share one extraction,
+// and calls for other archives do not wait for it. Callers must Close the
+// returned bundle when they are done with its files.
+func Open(path string) (*Bundle, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return Index(path)
+	}
+	key := fmt.Sprintf("%s|%d|%d", path, fi.Size(), fi.ModTime().UnixNano())
+	cacheMu.Lock()
+	e, ok := cache[key]
+	if ok {
+		lru.MoveToFront(e.elem)
+	} else {
+		e = &entry{key: key, ready: make(chan struct{})}
+		e.elem = lru.PushFront(e)
+		cache[key] = e
+	}
+	e.refs++
+	stale := evict()
+	cacheMu.Unlock()
+	for _, dir := range stale {
+		os.RemoveAll(dir)
+	}
+
+	if !ok {
+		e.load(path)
+	}
+	<-e.ready
+	if e.err != nil {
+		e.release()
+		return nil, e.err
+	}
+	b := *e.bundle
+	b.entry, b.done = e, new(sync.Once)
+	return &b, nil
+}
+
+// load extracts and indexes the archive at path. A failed entry is dropped
+// from the cache so that the next Open tries again.
+func (e *entry) load(path string) {
+	dir, err := extract(path)
+	var b *Bundle
+	if err == nil {
+		if b, err = Index(dir); err != nil {
+			os.RemoveAll(dir)
+			dir = ""
+		}
+	}
+	cacheMu.Lock()
+	e.bundle, e.err, e.tmp = b, err, dir
+	if err != nil && !e.evicted {
+		e.drop()
+	}
+	cacheMu.Unlock()
+	close(e.ready)
+}
+
+// release drops a caller's reference and removes the extracted files of an
+// evicted entry once nobody uses them.
+func (e *entry) release() {
+	cacheMu.Lock()
+	e.refs--
+	remove := e.refs == 0 && e.evicted && e.tmp != ""
+	cacheMu.Unlock()
+	if remove {
+		os.RemoveAll(e.tmp)
+	}
+}
+
+// drop removes e from the cache. cacheMu must be held.
+func (e *entry) drop() {
+	lru.Remove(e.elem)
+	delete(cache, e.key)
+	e.evicted = true
+}
+
+// evict drops the least recently used entries beyond CacheSize and returns
+// the temporary directories of those nobody uses, for the caller to remove
+// after unlocking. cacheMu must be held.
+func evict() []string {
+	var stale []string
+	for lru.Len() > CacheSize && lru.Len() > 1 {
+		old := lru.Back().Value.(*entry)
+		old.drop()
+		if old.refs == 0 && old.tmp != "" {
+			stale = append(stale, old.tmp)
+		}
+	}
+	return stale
+}
+
+// extract unpacks a tar archive into a new temporary directory.
+func extract(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var r io.Reader = bufio.NewReader(f)
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return "", fmt.Errorf("open %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	dir, err := os.MkdirTemp("", "must-gather-")
+	if err != nil {
+		return "", err
+	}
+	if err := untar(r, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("extract %s: %w", path, err)
+	}
+	return dir, nil
+}
+
+// untar writes regular files and directories from r below dir. Entries that
+// would escape dir, links and special files are skipped. It fails once more
+// than MaxExtractFiles entries or MaxExtractBytes bytes have been written.
+func untar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	budget := MaxExtractBytes
+	files := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if files++; files > MaxExtractFiles {
+			return fmt.Errorf("archive has more than %d entries", MaxExtractFiles)
+		}
+		target := filepath.Join(dir, filepath.Clean("/"+hdr.Name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				return err
+			}
+			n, err := io.Copy(out, io.LimitReader(tr, budget+1))
+			out.Close()
+			if err != nil {
+				return err
+			}
+			if budget -= n; budget < 0 {
+				return fmt.Errorf("archive expands to more than %d bytes", MaxExtractBytes)
+			}
+		}
+	}
+}
+
+// Index walks an extracted must-gather directory and loads the resources it
+// knows about. A must-gather normally contains one subdirectory per gather
+// image; all of them are indexed.
+func Index(dir string) (*Bundle, error) {
+	b := &Bundle{Root: dir, Logs: map[string][]string{}}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel := filepath.ToSlash(path)
+		name := d.Name()
+		switch {
+		case strings.Contains(rel, "/config.openshift.io/clusteroperators/") && isYAML(name):
+			return b.loadClusterOperator(path)
+		case strings.Contains(rel, "/cluster-scoped-resources/core/nodes/") && isYAML(name):
+			return b.loadNode(path)
+		case strings.Contains(rel, "/machineconfiguration.openshift.io/machineconfigpools/") && isYAML(name):
+			return b.loadPool(path)
+		case strings.Contains(rel, "/namespaces/") && strings.HasSuffix(rel, "/core/pods.yaml"):
+			return b.loadPods(path)
+		case strings.Contains(rel, "/host_service_logs/") || strings.Contains(rel, "/nodes/"):
+			for _, unit := range []string{"crio", "kubelet"} {
+				if strings.Contains(name, unit) {
+					b.Logs[unit] = append(b.Logs[unit], path)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(b.ClusterOperators) == 0 && len(b.Nodes) == 0 && len(b.Pods) == 0 {
+		return nil, fmt.Errorf("%s does not look like a must-gather", dir)
+	}
+	sort.Slice(b.ClusterOperators, func(i, j int) bool { return b.ClusterOperators[i].Name < b.ClusterOperators[j].Name })
+	sort.Slice(b.Nodes, func(i, j int) bool { return b.Nodes[i].Name < b.Nodes[j].Name })
+	sort.Slice(b.Pools, func(i, j int) bool { return b.Pools[i].Name < b.Pools[j].Name })
+	sort.Slice(b.Pods, func(i, j int) bool {
+		if b.Pods[i].Namespace != b.Pods[j].Namespace {
+			return b.Pods[i].Namespace < b.Pods[j].Namespace
+		}
+		return b.Pods[i].Name < b.Pods[j].Name
+	})
+	return b, nil
+}
+
+func isYAML(name string) bool {
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
+}
+
+func decodeFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func (b *Bundle) loadClusterOperator(path string) error {
+	var obj struct {
+		Metadata metadata `yaml:"metadata"`
+		Status   struct {
+			Conditions []Condition `yaml:"conditions"`
+		} `yaml:"status"`
+	}
+	if err := decodeFile(path, &obj); err != nil {
+		return err
+	}
+	b.ClusterOperators = append(b.ClusterOperators, ClusterOperator{Name: obj.Metadata.Name, Conditions: obj.Status.Conditions})
+	return nil
+}
+
+func (b *Bundle) loadNode(path string) error {
+	var obj struct {
+		Metadata metadata `yaml:"metadata"`
+		Spec     struct {
+			Taints []struct {
+				Key    string `yaml:"key"`
+				Effect string `yaml:"effect"`
+			} `yaml:"taints"`
+		} `yaml:"spec"`
+		Status struct {
+			Conditions []Condition `yaml:"conditions"`
+		} `yaml:"status"`
+	}
+	if err := decodeFile(path, &obj); err != nil {
+		return err
+	}
+	n := Node{Name: obj.Metadata.Name, Conditions: obj.Status.Conditions}
+	for l := range obj.Metadata.Labels {
+		if role, ok := strings.CutPrefix(l, "node-role.kubernetes.io/"); ok {
+			n.Roles = append(n.Roles, role)
+		}
+	}
+	sort.Strings(n.Roles)
+	for _, t := range obj.Spec.Taints {
+		n.Taints = append(n.Taints, t.Key+":"+t.Effect)
+	}
+	b.Nodes = append(b.Nodes, n)
+	return nil
+}
+
+func (b *Bundle) loadPool(path string) error {
+	var obj struct {
+		Metadata metadata `yaml:"metadata"`
+		Status   struct {
+			MachineCount         int         `yaml:"machineCount"`
+			ReadyMachineCount    int         `yaml:"readyMachineCount"`
+			UpdatedMachineCount  int         `yaml:"updatedMachineCount"`
+			DegradedMachineCount int         `yaml:"degradedMachineCount"`
+			Conditions           []Condition `yaml:"conditions"`
+		} `yaml:"status"`
+	}
+	if err := decodeFile(path, &obj); err != nil {
+		return err
+	}
+	b.Pools = append(b.Pools, MachineConfigPool{
+		Name:                 obj.Metadata.Name,
+		MachineCount:         obj.Status.MachineCount,
+		ReadyMachineCount:    obj.Status.ReadyMachineCount,
+		UpdatedMachineCount:  obj.Status.UpdatedMachineCount,
+		DegradedMachineCount: obj.Status.DegradedMachineCount,
+		Conditions:           obj.Status.Conditions,
+	})
+	return nil
+}
+
+type containerStatus struct {
+	Name         string `yaml:"name"`
+	RestartCount int    `yaml:"restartCount"`
+	State        struct {
+		Waiting *struct {
+			Reason string `yaml:"reason"`
+		} `yaml:"waiting"`
+		Terminated *struct {
+			Reason   string `yaml:"reason"`
+			ExitCode int    `yaml:"exitCode"`
+		} `yaml:"terminated"`
+	} `yaml:"state"`
+}
+
+func (b *Bundle) loadPods(path string) error {
+	var list struct {
+		Items []struct {
+			Metadata metadata `yaml:"metadata"`
+			Spec     struct {
+				NodeName string `yaml:"nodeName"`
+			} `yaml:"spec"`
+			Status struct {
+				Phase                 string            `yaml:"phase"`
+				Reason                string            `yaml:"reason"`
+				InitContainerStatuses []containerStatus `yaml:"initContainerStatuses"`
+				ContainerStatuses     []containerStatus `yaml:"containerStatuses"`
+			} `yaml:"status"`
+		} `yaml:"items"`
+	}
+	if err := decodeFile(path, &list); err != nil {
+		return err
+	}
+	for _, item := range list.Items {
+		p := Pod{
+			Namespace: item.Metadata.Namespace,
+			Name:      item.Metadata.Name,
+			Node:      item.Spec.NodeName,
+			Phase:     item.Status.Phase,
+		}
+		if item.Status.Reason != "" {
+			p.Reasons = append(p.Reasons, item.Status.Reason)
+		}
+		statuses := append(item.Status.InitContainerStatuses, item.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			p.Restarts += cs.RestartCount
+			if w := cs.State.Waiting; w != nil && w.Reason != "" && w.Reason != "PodInitializing" {
+				p.Reasons = append(p.Reasons, cs.Name+": "+w.Reason)
+			}
+			if t := cs.State.Terminated; t != nil && t.ExitCode != 0 && p.Phase != "Succeeded" {
+				p.Reasons = append(p.Reasons, fmt.Sprintf("%s: %s (exit %d)", cs.Name, t.Reason, t.ExitCode))
+			}
+		}
+		b.Pods = append(b.Pods, p)
+	}
+	return nil
+}
+
+// ErrorPattern is the default expression used by SearchLogs to find
+// interesting journal lines.
+var ErrorPattern = regexp.MustCompile(`(?i)\b(error|fail(ed|ure)?|panic|fatal|timed out|oom)`)
+
+// SearchLogs returns journal lines for unit ("crio" or "kubelet") that match
+// pattern. When node is non-empty only lines mentioning that node, or its
+// short hostname, are returned. At most max lines are returned when max is
+// positive.
+func (b *Bundle) SearchLogs(unit, node string, pattern *regexp.Regexp, max int) ([]string, error) {
+	if pattern == nil {
+		pattern = ErrorPattern
+	}
+	short, _, _ := strings.Cut(node, ".")
+	var out []string
+	for _, path := range b.Logs[unit] {
+		// Files under nodes/<node>/ belong to a single node, so the node
+		// filter is applied to the path rather than each line.
+		perNode := strings.Contains(filepath.ToSlash(path), "/nodes/")
+		if node != "" && perNode && !strings.Contains(filepath.ToSlash(path), "/nodes/"+node+"/") {
+			continue
+		}
+		err := scanLines(path, func(line string) bool {
+			if node != "" && !perNode && !strings.Contains(line, short) {
+				return true
+			}
+			if pattern.MatchString(line) {
+				out = append(out, line)
+			}
+			return max <= 0 || len(out) < max
+		})
+		if err != nil {
+			return nil, err
+		}
+		if max > 0 && len(out) >= max {
+			break
+		}
+	}
+	return out, nil
+}
+
+func scanLines(path string, fn func(string) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if !fn(sc.Text()) {
+			return nil
+		}
+	}
+	return sc.Err()
+}
+
+func conditionIs(conds []Condition, typ, status string) bool {
+	for _, c := range conds {
+		if c.Type == typ {
+			return c.Status == status
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package mustgather
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var fixture = map[string]string{
+	"cluster-scoped-resources/config.openshift.io/clusteroperators/dns.yaml": `
+metadata: {name: dns}
+status:
+  conditions:
+  - {type: Available, status: "True"}
+  - {type: Progressing, status: "False"}
+  - {type: Degraded, status: "False"}
+`,
+	"cluster-scoped-resources/config.openshift.io/clusteroperators/network.yaml": `
+metadata: {name: network}
+status:
+  conditions:
+  - {type: Available, status: "True"}
+  - {type: Progressing, status: "False"}
+  - {type: Degraded, status: "True", message: "DaemonSet ovnkube-node is not available"}
+`,
+	"cluster-scoped-resources/core/nodes/worker-0.example.com.yaml": `
+metadata:
+  name: worker-0.example.com
+  labels: {node-role.kubernetes.io/worker: ""}
+spec:
+  taints:
+  - {key: node.kubernetes.io/not-ready, effect: NoSchedule}
+status:
+  conditions:
+  - {type: DiskPressure, status: "True"}
+  - {type: Ready, status: "False", message: "PLEG is not healthy"}
+`,
+	"cluster-scoped-resources/core/nodes/master-0.example.com.yaml": `
+metadata:
+  name: master-0.example.com
+  labels: {node-role.kubernetes.io/master: ""}
+status:
+  conditions:
+  - {type: Ready, status: "True"}
+`,
+	"cluster-scoped-resources/machineconfiguration.openshift.io/machineconfigpools/worker.yaml": `
+metadata: {name: worker}
+status:
+  machineCount: 3
+  readyMachineCount: 2
+  updatedMachineCount: 2
+  degradedMachineCount: 1
+  conditions:
+  - {type: Degraded, status: "True", message: "Node worker-0 is reporting: unexpected on-disk state"}
+`,
+	"namespaces/app/core/pods.yaml": `
+items:
+- metadata: {name: web-1, namespace: app}
+  spec: {nodeName: worker-0.example.com}
+  status:
+    phase: Pending
+    containerStatuses:
+    - name: web
+      restartCount: 0
+      state: {waiting: {reason: ContainerCreating}}
+- metadata: {name: web-2, namespace: app}
+  spec: {nodeName: master-0.example.com}
+  status:
+    phase: Running
+    containerStatuses:
+    - {name: web, restartCount: 1, state: {running: {}}}
+`,
+	"host_service_logs/masters/crio_service.log": "Jan 01 00:00:00 master-0 crio[1]: level=info msg=\"started\"\n" +
+		"Jan 01 00:00:01 master-0 crio[1]: level=error msg=\"Failed to create pod sandbox\"\n",
+	"nodes/worker-0.example.com/worker-0.example.com_crio.log": "Jan 01 00:00:02 worker-0 crio[1]: level=error msg=\"error reserving ctr name\"\n",
+}
+
+func writeFixture(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "must-gather.local.123", "quay-io-ocp-must-gather")
+	for name, content := range fixture {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Dir(dir)
+}
+
+func TestIndex(t *testing.T) {
+	b, err := Index(writeFixture(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.ClusterOperators) != 2 || b.ClusterOperators[1].Healthy() || !b.ClusterOperators[0].Healthy() {
+		t.Fatalf("unexpected operators %+v", b.ClusterOperators)
+	}
+	if len(b.Nodes) != 2 || b.Nodes[1].Ready() || b.Nodes[1].Roles[0] != "worker" {
+		t.Fatalf("unexpected nodes %+v", b.Nodes)
+	}
+	if len(b.Pools) != 1 || !b.Pools[0].Degraded() {
+		t.Fatalf("unexpected pools %+v", b.Pools)
+	}
+	if len(b.Pods) != 2 || !b.Pods[0].Failing() || b.Pods[1].Failing() {
+		t.Fatalf("unexpected pods %+v", b.Pods)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	b, err := Index(writeFixture(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := b.Query(QueryClusterOperators, QueryOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "network") || strings.Contains(out, "dns") {
+		t.Fatalf("unexpected operators output:\n%s", out)
+	}
+	out, _ = b.Query(QueryFailingPods, QueryOptions{})
+	if !strings.Contains(out, "web-1") || strings.Contains(out, "web-2") {
+		t.Fatalf("unexpected pods output:\n%s", out)
+	}
+	out, _ = b.Query(QueryCrioLogs, QueryOptions{Node: "master-0.example.com"})
+	if !strings.Contains(out, "1 matching crio journal lines") || !strings.Contains(out, "Failed to create pod sandbox") {
+		t.Fatalf("unexpected crio output:\n%s", out)
+	}
+	out, _ = b.Query(QueryCrioLogs, QueryOptions{Node: "worker-0.example.com"})
+	if !strings.Contains(out, "error reserving ctr name") || strings.Contains(out, "sandbox") {
+		t.Fatalf("unexpected crio output:\n%s", out)
+	}
+	if _, err := b.Query("bogus", QueryOptions{}); err == nil {
+		t.Fatal("expected error for unknown query")
+	}
+}
+
+// writeTarball archives dir as a .tar.gz in a new temporary directory.
+func writeTarball(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mg.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		data, _ := os.ReadFile(p)
+		if err := tw.WriteHeader(&tar.Header{Name: rel, Mode: 0o600, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gz.Close()
+	f.Close()
+	return path
+}
+
+func TestOpenTarball(t *testing.T) {
+	path := writeTarball(t, writeFixture(t))
+	b, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer b.Close()
+	if len(b.Nodes) != 2 {
+		t.Fatalf("unexpected nodes %+v", b.Nodes)
+	}
+}
+
+func TestOpenShared(t *testing.T) {
+	path := writeTarball(t, writeFixture(t))
+	roots := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range roots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := Open(path)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			defer b.Close()
+			roots[i] = b.Root
+		}()
+	}
+	wg.Wait()
+	for _, r := range roots {
+		if r != roots[0] {
+			t.Fatalf("archive extracted more than once: %v", roots)
+		}
+	}
+}
+
+func TestOpenEvictsExtracted(t *testing.T) {
+	orig := CacheSize
+	CacheSize = 1
+	defer func() { CacheSize = orig }()
+	first, err := Open(writeTarball(t, writeFixture(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := Open(writeTarball(t, writeFixture(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer second.Close()
+	if _, err := os.Stat(first.Root); err != nil {
+		t.Fatalf("extraction removed while in use: %v", err)
+	}
+	if _, err := first.SearchLogs("crio", "", regexp.MustCompile("error"), 10); err != nil {
+		t.Fatalf("evicted bundle unusable before Close: %v", err)
+	}
+	first.Close()
+	first.Close()
+	if _, err := os.Stat(first.Root); !os.IsNotExist(err) {
+		t.Fatalf("evicted extraction %s was not removed: %v", first.Root, err)
+	}
+}
+
+func TestOpenDirectoryNotCached(t *testing.T) {
+	dir := writeFixture(t)
+	b, err := Open(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b.Close()
+	if err := os.Remove(filepath.Join(dir, "quay-io-ocp-must-gather", "cluster-scoped-resources", "core", "nodes", "master-0.example.com.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	b, err = Open(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.Nodes) != 1 {
+		t.Fatalf("change inside the directory not seen: %+v", b.Nodes)
+	}
+}
+
+func TestOpenExtractLimits(t *testing.T) {
+	path := writeTarball(t, writeFixture(t))
+	origBytes, origFiles := MaxExtractBytes, MaxExtractFiles
+	defer func() { MaxExtractBytes, MaxExtractFiles = origBytes, origFiles }()
+
+	MaxExtractBytes = 10
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "expands to more than 10 bytes") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	MaxExtractBytes, MaxExtractFiles = origBytes, 1
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "more than 1 entries") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
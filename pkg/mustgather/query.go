@@ -0,0 +1,207 @@
+package mustgather
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Queries supported by Bundle.Query.
+const (
+	QuerySummary          = "summary"
+	QueryClusterOperators = "cluster_operators"
+	QueryNodes            = "nodes"
+	QueryPools            = "machine_config_pools"
+	QueryFailingPods      = "failing_pods"
+	QueryCrioLogs         = "crio_logs"
+	QueryKubeletLogs      = "kubelet_logs"
+)
+
+// QueryNames lists the supported query names.
+var QueryNames = []string{QuerySummary, QueryClusterOperators, QueryNodes, QueryPools, QueryFailingPods, QueryCrioLogs, QueryKubeletLogs}
+
+// QueryOptions narrows the result of a query.
+type QueryOptions struct {
+	// Node limits node, pod and log queries to a single node.
+	Node string
+	// Namespace limits pod queries to a single namespace.
+	Namespace string
+	// Pattern overrides ErrorPattern for log queries.
+	Pattern string
+	// All includes healthy objects in status queries.
+	All bool
+	// MaxLines caps the number of log lines returned.
+	MaxLines int
+}
+
+// Query answers one of the named queries against the bundle as plain text.
+func (b *Bundle) Query(name string, opts QueryOptions) (string, error) {
+	var sb strings.Builder
+	switch name {
+	case QuerySummary:
+		b.writeSummary(&sb)
+	case QueryClusterOperators:
+		b.writeOperators(&sb, opts.All)
+	case QueryNodes:
+		b.writeNodes(&sb, opts)
+	case QueryPools:
+		b.writePools(&sb, opts.All)
+	case QueryFailingPods:
+		b.writePods(&sb, opts)
+	case QueryCrioLogs, QueryKubeletLogs:
+		unit := strings.TrimSuffix(name, "_logs")
+		var re *regexp.Regexp
+		if opts.Pattern != "" {
+			var err error
+			if re, err = regexp.Compile(opts.Pattern); err != nil {
+				return "", fmt.Errorf("invalid pattern: %w", err)
+			}
+		}
+		if opts.MaxLines <= 0 {
+			opts.MaxLines = 200
+		}
+		lines, err := b.SearchLogs(unit, opts.Node, re, opts.MaxLines)
+		if err != nil {
+			return "", err
+		}
+		if len(b.Logs[unit]) == 0 {
+			fmt.Fprintf(&sb, "no %s journal files in must-gather\n", unit)
+			break
+		}
+		fmt.Fprintf(&sb, "%d matching %s journal lines", len(lines), unit)
+		if len(lines) == opts.MaxLines {
+			sb.WriteString(" (truncated)")
+		}
+		sb.WriteString("\n")
+		for _, l := range lines {
+			sb.WriteString(l + "\n")
+		}
+	default:
+		return "", fmt.Errorf("unknown query %q (valid: %s)", name, strings.Join(QueryNames, ", "))
+	}
+	return sb.String(), nil
+}
+
+func (b *Bundle) writeSummary(sb *strings.Builder) {
+	var badOps, notReady, degradedPools, failingPods int
+	for _, c := range b.ClusterOperators {
+		if !c.Healthy() {
+			badOps++
+		}
+	}
+	for _, n := range b.Nodes {
+		if !n.Ready() || len(n.Pressure()) > 0 {
+			notReady++
+		}
+	}
+	for _, p := range b.Pools {
+		if p.Degraded() {
+			degradedPools++
+		}
+	}
+	for _, p := range b.Pods {
+		if p.Failing() {
+			failingPods++
+		}
+	}
+	fmt.Fprintf(sb, "must-gather: %s\n", b.Root)
+	fmt.Fprintf(sb, "cluster operators: %d total, %d unhealthy\n", len(b.ClusterOperators), badOps)
+	fmt.Fprintf(sb, "nodes: %d total, %d not ready or under pressure\n", len(b.Nodes), notReady)
+	fmt.Fprintf(sb, "machine config pools: %d total, %d degraded\n", len(b.Pools), degradedPools)
+	fmt.Fprintf(sb, "pods: %d total, %d failing\n", len(b.Pods), failingPods)
+	fmt.Fprintf(sb, "journal files: %d crio, %d kubelet\n", len(b.Logs["crio"]), len(b.Logs["kubelet"]))
+	if badOps > 0 {
+		sb.WriteString("\n")
+		b.writeOperators(sb, false)
+	}
+	if degradedPools > 0 {
+		sb.WriteString("\n")
+		b.writePools(sb, false)
+	}
+}
+
+func (b *Bundle) writeOperators(sb *strings.Builder, all bool) {
+	sb.WriteString("NAME\tAVAILABLE\tPROGRESSING\tDEGRADED\tMESSAGE\n")
+	for _, c := range b.ClusterOperators {
+		if !all && c.Healthy() {
+			continue
+		}
+		fmt.Fprintf(sb, "%s\t%s\t%s\t%s\t%s\n", c.Name,
+			status(c.Conditions, "Available"), status(c.Conditions, "Progressing"), status(c.Conditions, "Degraded"),
+			problemMessage(c.Conditions))
+	}
+}
+
+func (b *Bundle) writeNodes(sb *strings.Builder, opts QueryOptions) {
+	sb.WriteString("NAME\tROLES\tREADY\tCONDITIONS\tTAINTS\n")
+	for _, n := range b.Nodes {
+		if opts.Node != "" && n.Name != opts.Node {
+			continue
+		}
+		pressure := n.Pressure()
+		if !opts.All && opts.Node == "" && n.Ready() && len(pressure) == 0 {
+			continue
+		}
+		var conds []string
+		for _, c := range pressure {
+			conds = append(conds, c.Type)
+		}
+		if !n.Ready() {
+			for _, c := range n.Conditions {
+				if c.Type == "Ready" {
+					conds = append(conds, fmt.Sprintf("Ready=%s: %s", c.Status, c.Message))
+				}
+			}
+		}
+		fmt.Fprintf(sb, "%s\t%s\t%s\t%s\t%s\n", n.Name, strings.Join(n.Roles, ","), status(n.Conditions, "Ready"),
+			strings.Join(conds, "; "), strings.Join(n.Taints, ","))
+	}
+}
+
+func (b *Bundle) writePools(sb *strings.Builder, all bool) {
+	sb.WriteString("NAME\tUPDATING\tDEGRADED\tMACHINES\tREADY\tUPDATED\tDEGRADED-MACHINES\tMESSAGE\n")
+	for _, p := range b.Pools {
+		if !all && !p.Degraded() && !p.Updating() {
+			continue
+		}
+		fmt.Fprintf(sb, "%s\t%t\t%t\t%d\t%d\t%d\t%d\t%s\n", p.Name, p.Updating(), p.Degraded(),
+			p.MachineCount, p.ReadyMachineCount, p.UpdatedMachineCount, p.DegradedMachineCount, problemMessage(p.Conditions))
+	}
+}
+
+func (b *Bundle) writePods(sb *strings.Builder, opts QueryOptions) {
+	sb.WriteString("NAMESPACE\tNAME\tNODE\tPHASE\tRESTARTS\tREASONS\n")
+	for _, p := range b.Pods {
+		if opts.Node != "" && p.Node != opts.Node {
+			continue
+		}
+		if opts.Namespace != "" && p.Namespace != opts.Namespace {
+			continue
+		}
+		if !opts.All && !p.Failing() {
+			continue
+		}
+		fmt.Fprintf(sb, "%s\t%s\t%s\t%s\t%d\t%s\n", p.Namespace, p.Name, p.Node, p.Phase, p.Restarts, strings.Join(p.Reasons, "; "))
+	}
+}
+
+func status(conds []Condition, typ string) string {
+	for _, c := range conds {
+		if c.Type == typ {
+			return c.Status
+		}
+	}
+	return "Unknown"
+}
+
+// problemMessage returns the message of the first condition that indicates a
+// problem.
+func problemMessage(conds []Condition) string {
+	for _, c := range conds {
+		bad := (c.Type == "Degraded" && c.Status == "True") || (c.Type == "Available" && c.Status != "True")
+		if bad && c.Message != "" {
+			return strings.ReplaceAll(c.Message, "\n", " ")
+		}
+	}
+	return ""
+}
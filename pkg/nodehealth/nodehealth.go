@@ -0,0 +1,492 @@
+// Package nodehealth rates how healthy a node is from the container
+// runtime's point of view. A Report is filled from the node object, the
+// output of Script run on the node and "oc adm top nodes", and Evaluate
+// scores each area: node conditions and taints, the runtime status reported
+// over CRI, the crio and kubelet units, disk and inode usage, pressure stall
+// information, resource usage and kernel errors.
+package nodehealth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/harche/crio-mcp-server/pkg/openshift"
+)
+
+// Status is the score of an area, from best to worst.
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusWarning  Status = "warning"
+	StatusCritical Status = "critical"
+	// StatusUnknown means the data for the area could not be collected.
+	StatusUnknown Status = "unknown"
+)
+
+// rank orders statuses so that the worst one of a node can be picked.
+// Unknown ranks between ok and warning: it hides nothing that is known to be
+// wrong, but a node with missing data is not reported healthy.
+var rank = map[Status]int{StatusOK: 0, StatusUnknown: 1, StatusWarning: 2, StatusCritical: 3}
+
+// Areas in the order they are reported.
+const (
+	AreaConditions = "conditions"
+	AreaRuntime    = "runtime"
+	AreaServices   = "services"
+	AreaDisk       = "disk"
+	AreaInodes     = "inodes"
+	AreaPressure   = "pressure"
+	AreaResources  = "resources"
+	AreaKernel     = "kernel"
+)
+
+var areas = []string{AreaConditions, AreaRuntime, AreaServices, AreaDisk, AreaInodes, AreaPressure, AreaResources, AreaKernel}
+
+// Thresholds used by Evaluate. Usage is in percent; pressure is the share of
+// the last 60 seconds in which tasks stalled, in percent.
+const (
+	UsageWarning         = 80
+	UsageCritical        = 90
+	MetricsWarning       = 90
+	PressureSomeWarning  = 10
+	PressureCPUWarning   = 50
+	PressureFullWarning  = 5
+	PressureFullCritical = 20
+)
+
+// Condition is a node condition.
+type Condition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Taint is a node taint.
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+func (t Taint) String() string {
+	if t.Value != "" {
+		return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
+	}
+	return t.Key + ":" + t.Effect
+}
+
+// RuntimeCondition is a condition reported by "crictl info".
+type RuntimeCondition struct {
+	Type    string `json:"type"`
+	Status  bool   `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Unit is the state of a systemd unit.
+type Unit struct {
+	Name     string `json:"name"`
+	Active   string `json:"active"`
+	Sub      string `json:"sub"`
+	Restarts int    `json:"restarts"`
+}
+
+// Usage is the block or inode usage of a filesystem.
+type Usage struct {
+	Mount   string `json:"mount"`
+	Percent int    `json:"percent"`
+}
+
+// Pressure is the pressure stall information of a resource.
+type Pressure struct {
+	Resource string  `json:"resource"`
+	Some60   float64 `json:"someAvg60"`
+	Full60   float64 `json:"fullAvg60"`
+}
+
+// Metrics is the node's row of "oc adm top nodes".
+type Metrics struct {
+	CPUPercent    int `json:"cpuPercent"`
+	MemoryPercent int `json:"memoryPercent"`
+}
+
+// Area is the score of one area with the reasons for it.
+type Area struct {
+	Name     string   `json:"name"`
+	Status   Status   `json:"status"`
+	Findings []string `json:"findings,omitempty"`
+}
+
+// Report is everything known about a node's health.
+type Report struct {
+	Node   string `json:"node"`
+	Status Status `json:"status"`
+	Areas  []Area `json:"areas"`
+
+	Conditions    []Condition        `json:"conditions,omitempty"`
+	Unschedulable bool               `json:"unschedulable,omitempty"`
+	Taints        []Taint            `json:"taints,omitempty"`
+	Runtime       []RuntimeCondition `json:"runtime,omitempty"`
+	Units         []Unit             `json:"units,omitempty"`
+	Disks         []Usage            `json:"disks,omitempty"`
+	Inodes        []Usage            `json:"inodes,omitempty"`
+	Pressure      []Pressure         `json:"pressure,omitempty"`
+	Metrics       *Metrics           `json:"metrics,omitempty"`
+	KernelErrors  []string           `json:"kernelErrors,omitempty"`
+
+	// Errors holds, by area, why its data could not be collected.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// New returns an empty report for node.
+func New(node string) *Report {
+	return &Report{Node: node, Errors: map[string]string{}}
+}
+
+// Fail records that the data of the given areas could not be collected.
+func (r *Report) Fail(err error, areas ...string) {
+	for _, a := range areas {
+		r.Errors[a] = err.Error()
+	}
+}
+
+// ParseNode reads the conditions and taints from "oc get node -o json".
+func (r *Report) ParseNode(data []byte) error {
+	var node struct {
+		Spec struct {
+			Unschedulable bool    `json:"unschedulable"`
+			Taints        []Taint `json:"taints"`
+		} `json:"spec"`
+		Status struct {
+			Conditions []Condition `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("decoding node %s: %w", r.Node, err)
+	}
+	r.Conditions = node.Status.Conditions
+	r.Unschedulable = node.Spec.Unschedulable
+	r.Taints = node.Spec.Taints
+	return nil
+}
+
+// Script collects the on-node data in one debug pod, one section per area.
+var Script = openshift.SectionedScript(
+	openshift.Section{Name: AreaRuntime, Command: "crictl info"},
+	openshift.Section{Name: AreaServices, Command: "systemctl show crio kubelet -p Id,ActiveState,SubState,NRestarts"},
+	openshift.Section{Name: AreaDisk, Command: "df -P /var/lib/containers /var/lib/kubelet /"},
+	openshift.Section{Name: AreaInodes, Command: "df -Pi /var/lib/containers /var/lib/kubelet /"},
+	openshift.Section{Name: AreaPressure, Command: `for r in cpu memory io; do echo "resource $r"; cat /proc/pressure/$r; done`},
+	openshift.Section{Name: AreaKernel, Command: "journalctl -k --since -1h --no-pager | grep -iE 'out of memory|oom-kill|killed process|hung task|blocked for more than|i/o error|fs error|soft lockup|hard lockup|BUG:|call trace' | tail -n 50"},
+)
+
+// ParseDebug reads the output of Script. Areas whose section is missing, for
+// example because the debug pod ended early, are marked as failed.
+func (r *Report) ParseDebug(out string) {
+	sections := openshift.SplitSections(out)
+
+	parsers := []struct {
+		area  string
+		parse func(string) error
+	}{
+		{AreaRuntime, r.parseRuntime},
+		{AreaServices, r.parseUnits},
+		{AreaDisk, func(s string) (err error) { r.Disks, err = parseDF(s); return }},
+		{AreaInodes, func(s string) (err error) { r.Inodes, err = parseDF(s); return }},
+		{AreaPressure, r.parsePressure},
+		{AreaKernel, func(s string) error { r.KernelErrors = nonEmpty(s); return nil }},
+	}
+	for _, p := range parsers {
+		s, ok := sections[p.area]
+		if !ok {
+			r.Errors[p.area] = "no output from the node"
+			continue
+		}
+		if err := p.parse(s); err != nil {
+			r.Errors[p.area] = err.Error()
+		}
+	}
+}
+
+func (r *Report) parseRuntime(s string) error {
+	data := []byte(s)
+	start, end := bytes.IndexByte(data, '{'), bytes.LastIndexByte(data, '}')
+	if start < 0 || end < start {
+		return fmt.Errorf("crictl info: %s", strings.TrimSpace(s))
+	}
+	var info struct {
+		Status struct {
+			Conditions []RuntimeCondition `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(data[start:end+1], &info); err != nil {
+		return fmt.Errorf("decoding crictl info: %w", err)
+	}
+	r.Runtime = info.Status.Conditions
+	return nil
+}
+
+func (r *Report) parseUnits(s string) error {
+	var u Unit
+	flush := func() {
+		if u.Name != "" {
+			r.Units = append(r.Units, u)
+		}
+		u = Unit{}
+	}
+	for _, line := range strings.Split(s, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			flush()
+			continue
+		}
+		switch k {
+		case "Id":
+			u.Name = strings.TrimSuffix(v, ".service")
+		case "ActiveState":
+			u.Active = v
+		case "SubState":
+			u.Sub = v
+		case "NRestarts":
+			u.Restarts, _ = strconv.Atoi(v)
+		}
+	}
+	flush()
+	if len(r.Units) == 0 {
+		return fmt.Errorf("systemctl show: %s", strings.TrimSpace(s))
+	}
+	return nil
+}
+
+// parseDF reads "df -P" or "df -Pi" output, once per mount point.
+func parseDF(s string) ([]Usage, error) {
+	var out []Usage
+	seen := map[string]bool{}
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 6 || !strings.HasSuffix(f[4], "%") {
+			continue
+		}
+		pct, err := strconv.Atoi(strings.TrimSuffix(f[4], "%"))
+		if err != nil || seen[f[5]] {
+			continue
+		}
+		seen[f[5]] = true
+		out = append(out, Usage{Mount: f[5], Percent: pct})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("df: %s", strings.TrimSpace(s))
+	}
+	return out, nil
+}
+
+// avg60RE matches a line of /proc/pressure/*.
+var avg60RE = regexp.MustCompile(`^(some|full) .*\bavg60=([0-9.]+)`)
+
+func (r *Report) parsePressure(s string) error {
+	var cur *Pressure
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if name, ok := strings.CutPrefix(line, "resource "); ok {
+			r.Pressure = append(r.Pressure, Pressure{Resource: name})
+			cur = &r.Pressure[len(r.Pressure)-1]
+			continue
+		}
+		m := avg60RE.FindStringSubmatch(line)
+		if m == nil || cur == nil {
+			continue
+		}
+		v, _ := strconv.ParseFloat(m[2], 64)
+		if m[1] == "some" {
+			cur.Some60 = v
+		} else {
+			cur.Full60 = v
+		}
+	}
+	if len(r.Pressure) == 0 {
+		return fmt.Errorf("no pressure stall information; the kernel may run without psi=1")
+	}
+	return nil
+}
+
+func nonEmpty(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// ParseMetrics reads the node's row from "oc adm top nodes".
+func (r *Report) ParseMetrics(out string) error {
+	for _, line := range strings.Split(out, "\n") {
+		// NAME CPU(cores) CPU% MEMORY(bytes) MEMORY%
+		f := strings.Fields(line)
+		if len(f) < 5 || f[0] != r.Node {
+			continue
+		}
+		cpu, err1 := strconv.Atoi(strings.TrimSuffix(f[2], "%"))
+		mem, err2 := strconv.Atoi(strings.TrimSuffix(f[4], "%"))
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("node %s reports no metrics: %s", r.Node, strings.Join(f[1:], " "))
+		}
+		r.Metrics = &Metrics{CPUPercent: cpu, MemoryPercent: mem}
+		return nil
+	}
+	return fmt.Errorf("node %s is not listed by oc adm top nodes", r.Node)
+}
+
+// kernelCritical matches kernel messages that point to a failing node rather
+// than a workload exceeding its limits.
+var kernelCritical = regexp.MustCompile(`(?i)hung task|blocked for more than|i/o error|fs error|soft lockup|hard lockup|BUG:`)
+
+// kernelKilled matches the line the OOM killer logs for every process it
+// kills.
+var kernelKilled = regexp.MustCompile(`(?i)killed process`)
+
+// Evaluate scores every area and the node as a whole.
+func (r *Report) Evaluate() {
+	r.Areas = nil
+	r.Status = StatusOK
+	for _, name := range areas {
+		a := Area{Name: name, Status: StatusOK}
+		if err, ok := r.Errors[name]; ok {
+			a.Status = StatusUnknown
+			a.Findings = []string{"not collected: " + err}
+		} else {
+			r.evaluate(&a)
+		}
+		if rank[a.Status] > rank[r.Status] {
+			r.Status = a.Status
+		}
+		r.Areas = append(r.Areas, a)
+	}
+}
+
+// flag raises a's status to s and records why.
+func (a *Area) flag(s Status, format string, args ...any) {
+	if rank[s] > rank[a.Status] {
+		a.Status = s
+	}
+	a.Findings = append(a.Findings, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) evaluate(a *Area) {
+	switch a.Name {
+	case AreaConditions:
+		for _, c := range r.Conditions {
+			switch {
+			case c.Type == "Ready" && c.Status != "True":
+				a.flag(StatusCritical, "Ready is %s: %s", c.Status, c.Message)
+			case c.Type != "Ready" && c.Status == "True":
+				a.flag(StatusCritical, "%s: %s", c.Type, c.Message)
+			}
+		}
+		if r.Unschedulable {
+			a.flag(StatusWarning, "node is cordoned")
+		}
+		for _, t := range r.Taints {
+			if t.Effect == "NoSchedule" && t.Key == "node.kubernetes.io/unschedulable" {
+				continue
+			}
+			if t.Effect == "NoExecute" || strings.HasPrefix(t.Key, "node.kubernetes.io/") {
+				a.flag(StatusWarning, "taint %s", t)
+			}
+		}
+	case AreaRuntime:
+		for _, c := range r.Runtime {
+			if !c.Status {
+				a.flag(StatusCritical, "%s is false: %s %s", c.Type, c.Reason, c.Message)
+			}
+		}
+	case AreaServices:
+		for _, u := range r.Units {
+			if u.Active != "active" {
+				a.flag(StatusCritical, "%s is %s (%s)", u.Name, u.Active, u.Sub)
+			}
+			if u.Restarts > 0 {
+				a.flag(StatusWarning, "%s was restarted %d times", u.Name, u.Restarts)
+			}
+		}
+	case AreaDisk, AreaInodes:
+		usage, what := r.Disks, "space"
+		if a.Name == AreaInodes {
+			usage, what = r.Inodes, "inodes"
+		}
+		for _, u := range usage {
+			switch {
+			case u.Percent >= UsageCritical:
+				a.flag(StatusCritical, "%s %d%% of %s used", u.Mount, u.Percent, what)
+			case u.Percent >= UsageWarning:
+				a.flag(StatusWarning, "%s %d%% of %s used", u.Mount, u.Percent, what)
+			}
+		}
+	case AreaPressure:
+		for _, p := range r.Pressure {
+			switch {
+			case p.Full60 >= PressureFullCritical:
+				a.flag(StatusCritical, "%s: all tasks stalled %.1f%% of the last minute", p.Resource, p.Full60)
+			case p.Full60 >= PressureFullWarning:
+				a.flag(StatusWarning, "%s: all tasks stalled %.1f%% of the last minute", p.Resource, p.Full60)
+			case p.Resource == "cpu" && p.Some60 >= PressureCPUWarning,
+				p.Resource != "cpu" && p.Some60 >= PressureSomeWarning:
+				a.flag(StatusWarning, "%s: some tasks stalled %.1f%% of the last minute", p.Resource, p.Some60)
+			}
+		}
+	case AreaResources:
+		if m := r.Metrics; m != nil {
+			if m.CPUPercent >= MetricsWarning {
+				a.flag(StatusWarning, "CPU at %d%%", m.CPUPercent)
+			}
+			if m.MemoryPercent >= MetricsWarning {
+				a.flag(StatusWarning, "memory at %d%%", m.MemoryPercent)
+			}
+		}
+	case AreaKernel:
+		ooms := 0
+		for _, l := range r.KernelErrors {
+			switch {
+			case kernelCritical.MatchString(l):
+				a.flag(StatusCritical, "%s", l)
+			case kernelKilled.MatchString(l):
+				ooms++
+			}
+		}
+		if ooms > 0 {
+			a.flag(StatusWarning, "%d processes OOM killed in the last hour", ooms)
+		}
+	}
+}
+
+// Summary renders the scores, worst areas first, for a reader.
+func (r *Report) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "node %s: %s\n", r.Node, r.Status)
+	sorted := append([]Area(nil), r.Areas...)
+	sort.SliceStable(sorted, func(i, j int) bool { return rank[sorted[i].Status] > rank[sorted[j].Status] })
+	for _, a := range sorted {
+		fmt.Fprintf(&b, "%-10s %s\n", a.Name, a.Status)
+		for _, f := range a.Findings {
+			fmt.Fprintf(&b, "  - %s\n", f)
+		}
+	}
+	return b.String()
+}
+
+// JSON returns the report with every collected value.
+func (r *Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
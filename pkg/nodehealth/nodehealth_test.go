@@ -0,0 +1,241 @@
+package nodehealth
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const nodeJSON = `{
+  "kind": "Node",
+  "metadata": {"name": "worker-0"},
+  "spec": {
+    "unschedulable": true,
+    "taints": [
+      {"key": "node.kubernetes.io/unschedulable", "effect": "NoSchedule"},
+      {"key": "node.kubernetes.io/disk-pressure", "effect": "NoSchedule"},
+      {"key": "dedicated", "value": "infra", "effect": "NoSchedule"}
+    ]
+  },
+  "status": {
+    "conditions": [
+      {"type": "MemoryPressure", "status": "False", "reason": "KubeletHasSufficientMemory"},
+      {"type": "DiskPressure", "status": "True", "reason": "KubeletHasDiskPressure", "message": "kubelet has disk pressure"},
+      {"type": "Ready", "status": "True", "reason": "KubeletReady"}
+    ]
+  }
+}`
+
+const debugOut = `Starting pod/worker-0-debug-abcde ...
+To use host binaries, run ` + "`chroot /host`" + `
+### runtime
+{
+  "status": {
+    "conditions": [
+      {"type": "RuntimeReady", "status": true, "reason": "", "message": ""},
+      {"type": "NetworkReady", "status": false, "reason": "NetworkPluginNotReady", "message": "no CNI configuration file"}
+    ]
+  },
+  "config": {}
+}
+### services
+Id=crio.service
+ActiveState=active
+SubState=running
+NRestarts=3
+
+Id=kubelet.service
+ActiveState=active
+SubState=running
+NRestarts=0
+### disk
+Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda4        125293548 117775935   7517613      94% /var
+/dev/sda4        125293548 117775935   7517613      94% /var
+/dev/sda4        125293548 117775935   7517613      94% /
+### inodes
+Filesystem       Inodes  IUsed    IFree IUse% Mounted on
+/dev/sda4      62651712 50121369 12530343   80% /var
+/dev/sda4      62651712 50121369 12530343   80% /var
+/dev/sda4      62651712 50121369 12530343   80% /
+### pressure
+resource cpu
+some avg10=61.00 avg60=55.20 avg300=40.00 total=1
+full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+resource memory
+some avg10=0.00 avg60=1.00 avg300=0.00 total=0
+full avg10=0.00 avg60=0.50 avg300=0.00 total=0
+resource io
+some avg10=30.00 avg60=25.00 avg300=10.00 total=1
+full avg10=22.00 avg60=21.30 avg300=8.00 total=1
+### kernel
+Jan 01 worker-0 kernel: Memory cgroup out of memory: Killed process 4242 (java) total-vm:1kB
+Jan 01 worker-0 kernel: Memory cgroup out of memory: Killed process 4243 (java) total-vm:1kB
+### end
+
+Removing debug pod ...
+`
+
+const topOut = `NAME       CPU(cores)   CPU%   MEMORY(bytes)   MEMORY%
+worker-0   3800m        95%    12000Mi         70%
+worker-1   <unknown>    <unknown>   <unknown>   <unknown>
+`
+
+func TestParse(t *testing.T) {
+	r := New("worker-0")
+	if err := r.ParseNode([]byte(nodeJSON)); err != nil {
+		t.Fatal(err)
+	}
+	r.ParseDebug(debugOut)
+	if err := r.ParseMetrics(topOut); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Errors) != 0 {
+		t.Fatalf("unexpected errors %v", r.Errors)
+	}
+	if len(r.Conditions) != 3 || !r.Unschedulable || len(r.Taints) != 3 {
+		t.Fatalf("unexpected node %+v", r)
+	}
+	if len(r.Runtime) != 2 || r.Runtime[1].Status || r.Runtime[1].Reason != "NetworkPluginNotReady" {
+		t.Fatalf("unexpected runtime %+v", r.Runtime)
+	}
+	if len(r.Units) != 2 || r.Units[0] != (Unit{Name: "crio", Active: "active", Sub: "running", Restarts: 3}) {
+		t.Fatalf("unexpected units %+v", r.Units)
+	}
+	if len(r.Disks) != 2 || r.Disks[0] != (Usage{Mount: "/var", Percent: 94}) || len(r.Inodes) != 2 {
+		t.Fatalf("unexpected usage %+v %+v", r.Disks, r.Inodes)
+	}
+	if len(r.Pressure) != 3 || r.Pressure[2] != (Pressure{Resource: "io", Some60: 25, Full60: 21.3}) {
+		t.Fatalf("unexpected pressure %+v", r.Pressure)
+	}
+	if len(r.KernelErrors) != 2 {
+		t.Fatalf("unexpected kernel errors %q", r.KernelErrors)
+	}
+	if *r.Metrics != (Metrics{CPUPercent: 95, MemoryPercent: 70}) {
+		t.Fatalf("unexpected metrics %+v", r.Metrics)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	r := New("worker-1")
+	if err := r.ParseNode([]byte("error: nodes \"worker-1\" not found")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := r.ParseMetrics(topOut); err == nil || !strings.Contains(err.Error(), "reports no metrics") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := New("worker-2").ParseMetrics(topOut); err == nil || !strings.Contains(err.Error(), "not listed") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The debug pod was killed while df ran.
+	r.ParseDebug("### runtime\ncrictl: command not found\n### services\nId=crio.service\nActiveState=active\n### disk\n")
+	want := map[string]string{
+		AreaRuntime:  "crictl info: crictl: command not found",
+		AreaDisk:     "df: ",
+		AreaInodes:   "no output from the node",
+		AreaPressure: "no output from the node",
+		AreaKernel:   "no output from the node",
+	}
+	for area, msg := range want {
+		if r.Errors[area] != msg {
+			t.Errorf("%s: got %q, want %q", area, r.Errors[area], msg)
+		}
+	}
+	if _, ok := r.Errors[AreaServices]; ok || len(r.Units) != 1 {
+		t.Fatalf("services not parsed: %v %+v", r.Errors, r.Units)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	r := New("worker-0")
+	r.ParseNode([]byte(nodeJSON))
+	r.ParseDebug(debugOut)
+	r.ParseMetrics(topOut)
+	r.Evaluate()
+	if r.Status != StatusCritical {
+		t.Fatalf("unexpected status %s", r.Status)
+	}
+	want := map[string]Status{
+		AreaConditions: StatusCritical,
+		AreaRuntime:    StatusCritical,
+		AreaServices:   StatusWarning,
+		AreaDisk:       StatusCritical,
+		AreaInodes:     StatusWarning,
+		AreaPressure:   StatusCritical,
+		AreaResources:  StatusWarning,
+		AreaKernel:     StatusWarning,
+	}
+	for _, a := range r.Areas {
+		if a.Status != want[a.Name] {
+			t.Errorf("%s: got %s %q, want %s", a.Name, a.Status, a.Findings, want[a.Name])
+		}
+	}
+	if len(r.Areas) != len(want) {
+		t.Fatalf("unexpected areas %+v", r.Areas)
+	}
+
+	healthy := New("worker-1")
+	healthy.Conditions = []Condition{{Type: "Ready", Status: "True"}, {Type: "PIDPressure", Status: "False"}}
+	healthy.Units = []Unit{{Name: "crio", Active: "active", Sub: "running"}}
+	healthy.Fail(errors.New("metrics API not available"), AreaResources)
+	healthy.Evaluate()
+	if healthy.Status != StatusUnknown {
+		t.Fatalf("unexpected status %s %+v", healthy.Status, healthy.Areas)
+	}
+	delete(healthy.Errors, AreaResources)
+	healthy.Evaluate()
+	if healthy.Status != StatusOK {
+		t.Fatalf("unexpected status %s %+v", healthy.Status, healthy.Areas)
+	}
+}
+
+func TestKernelCritical(t *testing.T) {
+	r := New("n")
+	r.KernelErrors = []string{
+		"kernel: INFO: task crio:1234 blocked for more than 120 seconds.",
+		"kernel: Out of memory: Killed process 1 (x)",
+	}
+	r.Evaluate()
+	a := r.Areas[len(r.Areas)-1]
+	if a.Name != AreaKernel || a.Status != StatusCritical || len(a.Findings) != 2 || !strings.HasPrefix(a.Findings[1], "1 processes OOM killed") {
+		t.Fatalf("unexpected area %+v", a)
+	}
+}
+
+func TestSummaryAndJSON(t *testing.T) {
+	r := New("worker-0")
+	r.Units = []Unit{{Name: "crio", Active: "failed", Sub: "failed", Restarts: 5}}
+	r.Disks = []Usage{{Mount: "/var", Percent: 85}}
+	r.Fail(errors.New("oc adm top nodes failed"), AreaResources)
+	r.Evaluate()
+	want := `node worker-0: critical
+services   critical
+  - crio is failed (failed)
+  - crio was restarted 5 times
+disk       warning
+  - /var 85% of space used
+resources  unknown
+  - not collected: oc adm top nodes failed
+conditions ok
+runtime    ok
+inodes     ok
+pressure   ok
+kernel     ok
+`
+	if got := r.Summary(); got != want {
+		t.Fatalf("unexpected summary\n%s\nwant\n%s", got, want)
+	}
+	js, err := r.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Report
+	if err := json.Unmarshal([]byte(js), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Status != StatusCritical || len(back.Areas) != 8 || back.Errors[AreaResources] == "" || back.Units[0].Restarts != 5 {
+		t.Fatalf("unexpected JSON %s", js)
+	}
+}
@@ -0,0 +1,306 @@
+package openshift
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HostPathPolicy limits the host paths CopyFilesFromNode may archive. A path
+// is allowed if it lies under an Allow entry and not under a Deny entry; Deny
+// entries below a requested directory are excluded from its archive.
+type HostPathPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// DefaultAllowedHostPaths covers the configuration, state and logs useful for
+// debugging the container runtime and kubelet. /etc/machine-config-daemon and
+// /run/containers are left out: they hold the rendered machine config, with
+// the cluster's pull secret, and registry credentials.
+var DefaultAllowedHostPaths = []string{
+	"/etc/containers",
+	"/etc/crio",
+	"/etc/kubernetes",
+	"/etc/os-release",
+	"/etc/sysconfig",
+	"/etc/systemd",
+	"/run/crio",
+	"/sys/fs/cgroup",
+	"/var/lib/crio",
+	"/var/lib/kubelet",
+	"/var/log",
+}
+
+// DefaultDeniedHostPaths are credentials, private keys and pod data that must
+// never leave the node, plus container storage, which is too large to copy.
+var DefaultDeniedHostPaths = []string{
+	"/etc/gshadow",
+	"/etc/kubernetes/kubeconfig",
+	"/etc/kubernetes/static-pod-resources",
+	"/etc/pki/tls/private",
+	"/etc/shadow",
+	"/etc/ssh",
+	"/etc/sudoers",
+	"/etc/sudoers.d",
+	"/home",
+	"/root",
+	"/var/lib/containers/storage",
+	"/var/lib/kubelet/config.json",
+	"/var/lib/kubelet/kubeconfig",
+	"/var/lib/kubelet/pki",
+	"/var/lib/kubelet/pods",
+}
+
+// Environment variables read by HostPathPolicyFromEnv and
+// MaxArchiveBytesFromEnv. Path lists use the OS path list separator (":").
+const (
+	AllowedHostPathsEnv = "CRIO_MCP_HOST_PATHS_ALLOW"
+	DeniedHostPathsEnv  = "CRIO_MCP_HOST_PATHS_DENY"
+	MaxArchiveBytesEnv  = "CRIO_MCP_MAX_ARCHIVE_BYTES"
+)
+
+// DefaultMaxArchiveBytes is the default limit on a compressed archive.
+const DefaultMaxArchiveBytes = 256 << 20
+
+// HostPaths is the policy applied by CopyFilesFromNode. It defaults to the
+// built-in lists, replaced by CRIO_MCP_HOST_PATHS_ALLOW and extended by
+// CRIO_MCP_HOST_PATHS_DENY. Tests may override it.
+var HostPaths = HostPathPolicyFromEnv()
+
+// MaxArchiveBytes limits the size of the compressed archive CopyFilesFromNode
+// receives. It defaults to CRIO_MCP_MAX_ARCHIVE_BYTES or 256 MiB. Tests may
+// override it.
+var MaxArchiveBytes = MaxArchiveBytesFromEnv()
+
+// HostPathPolicyFromEnv returns the policy configured by the environment.
+// Setting CRIO_MCP_HOST_PATHS_ALLOW to "/" allows every path that is not
+// denied.
+func HostPathPolicyFromEnv() HostPathPolicy {
+	p := HostPathPolicy{
+		Allow: DefaultAllowedHostPaths,
+		Deny:  DefaultDeniedHostPaths,
+	}
+	if v := os.Getenv(AllowedHostPathsEnv); v != "" {
+		p.Allow = filepath.SplitList(v)
+	}
+	if v := os.Getenv(DeniedHostPathsEnv); v != "" {
+		p.Deny = append(append([]string{}, p.Deny...), filepath.SplitList(v)...)
+	}
+	return p
+}
+
+// MaxArchiveBytesFromEnv returns the archive size limit configured by the
+// environment, falling back to DefaultMaxArchiveBytes for unset or invalid
+// values.
+func MaxArchiveBytesFromEnv() int64 {
+	if n, err := strconv.ParseInt(os.Getenv(MaxArchiveBytesEnv), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return DefaultMaxArchiveBytes
+}
+
+// under reports whether p is dir or lies below it.
+func under(p, dir string) bool {
+	dir = path.Clean(dir)
+	return dir == "/" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// Check validates a requested path and returns it cleaned, with the denied
+// paths below it that must be excluded from the archive.
+func (hp HostPathPolicy) Check(p string) (string, []string, error) {
+	if !path.IsAbs(p) {
+		return "", nil, fmt.Errorf("path %q must be absolute", p)
+	}
+	p = path.Clean(p)
+	for _, d := range hp.Deny {
+		if under(p, d) {
+			return "", nil, fmt.Errorf("path %s is denied by the host path policy (%s)", p, path.Clean(d))
+		}
+	}
+	allowed := false
+	for _, a := range hp.Allow {
+		if under(p, a) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", nil, fmt.Errorf("path %s is not in the allowed host paths; set %s to allow it", p, AllowedHostPathsEnv)
+	}
+	var excludes []string
+	for _, d := range hp.Deny {
+		if d = path.Clean(d); under(d, p) {
+			excludes = append(excludes, d)
+		}
+	}
+	return p, excludes, nil
+}
+
+// ErrArchiveTooLarge is returned when an archive exceeds MaxArchiveBytes.
+var ErrArchiveTooLarge = errors.New("archive too large")
+
+// ManifestEntry describes one member of a node archive.
+type ManifestEntry struct {
+	Path string
+	Type string
+	Size int64
+}
+
+// Archive describes a gzip-compressed tar archive copied from a node.
+type Archive struct {
+	// Size is the compressed size of the archive.
+	Size     int64
+	Manifest []ManifestEntry
+}
+
+// FileBytes returns the uncompressed size of the regular files.
+func (a *Archive) FileBytes() int64 {
+	var n int64
+	for _, e := range a.Manifest {
+		n += e.Size
+	}
+	return n
+}
+
+// maxManifestLines limits the entries FormatManifest prints.
+const maxManifestLines = 200
+
+// FormatManifest lists the archive members with their sizes.
+func (a *Archive) FormatManifest() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d entries, %d bytes uncompressed, %d bytes compressed\n", len(a.Manifest), a.FileBytes(), a.Size)
+	for i, e := range a.Manifest {
+		if i == maxManifestLines {
+			fmt.Fprintf(&b, "... and %d more\n", len(a.Manifest)-i)
+			break
+		}
+		switch e.Type {
+		case "file":
+			fmt.Fprintf(&b, "%s %d\n", e.Path, e.Size)
+		default:
+			fmt.Fprintf(&b, "%s (%s)\n", e.Path, e.Type)
+		}
+	}
+	return b.String()
+}
+
+// capWriter passes up to max bytes on to w. Once the limit is exceeded it
+// discards further output, so the writer never blocks, and calls abort once.
+type capWriter struct {
+	w        io.Writer
+	n        int64
+	max      int64
+	exceeded bool
+	abort    func()
+}
+
+func (c *capWriter) Write(p []byte) (int, error) {
+	if c.exceeded {
+		return len(p), nil
+	}
+	if c.n+int64(len(p)) > c.max {
+		c.exceeded = true
+		c.abort()
+		return len(p), nil
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readManifest lists the members of a gzip-compressed tar stream. It always
+// consumes r to the end so the writer feeding it never blocks.
+func readManifest(r io.Reader) ([]ManifestEntry, error) {
+	defer io.Copy(io.Discard, r)
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(zr)
+	var entries []ManifestEntry
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		e := ManifestEntry{Path: "/" + strings.TrimPrefix(hdr.Name, "/")}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			e.Type, e.Size = "file", hdr.Size
+		case tar.TypeDir:
+			e.Type = "dir"
+		case tar.TypeSymlink:
+			e.Type = "symlink -> " + hdr.Linkname
+		default:
+			e.Type = "special"
+		}
+		entries = append(entries, e)
+	}
+}
+
+// CopyFilesFromNode streams the specified files or directories from the node
+// to w as a gzip-compressed tar archive and returns its manifest, which is
+// read from the stream as it passes, so memory use does not grow with the
+// archive. Paths are checked against HostPaths, and the archive is limited to
+// MaxArchiveBytes: when it is exceeded the debug session is interrupted,
+// which removes the debug pod, and ErrArchiveTooLarge is returned. w holds a
+// partial archive after an error.
+func CopyFilesFromNode(ctx context.Context, nodeName string, paths []string, w io.Writer) (*Archive, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no paths specified")
+	}
+	var cleaned, excludes []string
+	for _, p := range paths {
+		c, ex, err := HostPaths.Check(p)
+		if err != nil {
+			return nil, err
+		}
+		cleaned = append(cleaned, c)
+		excludes = append(excludes, ex...)
+	}
+	args := []string{"debug", fmt.Sprintf("node/%s", nodeName), "--", "chroot", "/host", "tar", "czf", "-", "--ignore-failed-read"}
+	for _, ex := range excludes {
+		args = append(args, "--exclude="+ex)
+	}
+	args = append(args, cleaned...)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pr, pw := io.Pipe()
+	type result struct {
+		entries []ManifestEntry
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		entries, err := readManifest(pr)
+		done <- result{entries, err}
+	}()
+	cw := &capWriter{w: io.MultiWriter(w, pw), max: MaxArchiveBytes, abort: cancel}
+	err := Stream(ctx, cw, args...)
+	pw.Close()
+	m := <-done
+	if cw.exceeded {
+		return nil, fmt.Errorf("%w: the archive from node %s exceeded %d bytes and the copy was aborted; request fewer or smaller paths or raise %s",
+			ErrArchiveTooLarge, nodeName, MaxArchiveBytes, MaxArchiveBytesEnv)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("oc debug failed: %w", err)
+	}
+	if m.err != nil {
+		return nil, fmt.Errorf("read archive from node %s: %w", nodeName, m.err)
+	}
+	return &Archive{Size: cw.n, Manifest: m.entries}, nil
+}
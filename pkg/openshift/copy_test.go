@@ -0,0 +1,206 @@
+package openshift
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHostPathPolicyCheck(t *testing.T) {
+	hp := HostPathPolicy{Allow: DefaultAllowedHostPaths, Deny: DefaultDeniedHostPaths}
+	for _, c := range []struct {
+		path, clean, excludes, err string
+	}{
+		{path: "/etc/crio/crio.conf", clean: "/etc/crio/crio.conf"},
+		{path: "/var/lib/kubelet", clean: "/var/lib/kubelet", excludes: "/var/lib/kubelet/config.json /var/lib/kubelet/kubeconfig /var/lib/kubelet/pki /var/lib/kubelet/pods"},
+		{path: "/etc/shadow", err: "denied"},
+		{path: "/etc/crio/../shadow", err: "/etc/shadow is denied"},
+		{path: "/var/lib/kubelet/pki/kubelet-client-current.pem", err: "denied"},
+		{path: "/var/lib/containers", err: "not in the allowed host paths"},
+		{path: "/etc/machine-config-daemon/currentconfig", err: "not in the allowed host paths"},
+		{path: "/etc/machine-config-daemon/node-annotations.json", err: "not in the allowed host paths"},
+		{path: "/run/containers/0/auth.json", err: "not in the allowed host paths"},
+		{path: "/run/containers", err: "not in the allowed host paths"},
+		{path: "/usr/bin", err: "not in the allowed host paths"},
+		{path: "etc/crio", err: "must be absolute"},
+	} {
+		clean, ex, err := hp.Check(c.path)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("%s: expected error containing %q, got %v", c.path, c.err, err)
+			}
+			continue
+		}
+		if err != nil || clean != c.clean || strings.Join(ex, " ") != c.excludes {
+			t.Fatalf("%s: got %q %v %v", c.path, clean, ex, err)
+		}
+	}
+
+	everything := HostPathPolicy{Allow: []string{"/"}, Deny: []string{"/etc/shadow"}}
+	if _, ex, err := everything.Check("/"); err != nil || len(ex) != 1 {
+		t.Fatalf("unexpected result %v %v", ex, err)
+	}
+}
+
+func TestHostPathPolicyFromEnv(t *testing.T) {
+	t.Setenv(AllowedHostPathsEnv, "/opt:/srv")
+	t.Setenv(DeniedHostPathsEnv, "/srv/secrets")
+	hp := HostPathPolicyFromEnv()
+	if strings.Join(hp.Allow, ",") != "/opt,/srv" || hp.Deny[len(hp.Deny)-1] != "/srv/secrets" || len(hp.Deny) != len(DefaultDeniedHostPaths)+1 {
+		t.Fatalf("unexpected policy %+v", hp)
+	}
+	t.Setenv(MaxArchiveBytesEnv, "1024")
+	if MaxArchiveBytesFromEnv() != 1024 {
+		t.Fatal("limit not read from environment")
+	}
+	t.Setenv(MaxArchiveBytesEnv, "lots")
+	if MaxArchiveBytesFromEnv() != DefaultMaxArchiveBytes {
+		t.Fatal("invalid limit not ignored")
+	}
+}
+
+func testArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	tw.WriteHeader(&tar.Header{Name: "etc/crio/", Typeflag: tar.TypeDir, Mode: 0o755})
+	tw.WriteHeader(&tar.Header{Name: "etc/crio/crio.conf", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5})
+	tw.Write([]byte("[crio"))
+	tw.WriteHeader(&tar.Header{Name: "etc/crio/current", Typeflag: tar.TypeSymlink, Linkname: "crio.conf"})
+	tw.Close()
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestCopyFilesFromNode(t *testing.T) {
+	orig := Stream
+	defer func() { Stream = orig }()
+	data := testArchive(t)
+	var got []string
+	Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+		got = args
+		_, err := w.Write(data)
+		return err
+	}
+	var buf bytes.Buffer
+	a, err := CopyFilesFromNode(context.Background(), "n1", []string{"/etc/crio", "/etc/kubernetes/"}, &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "debug node/n1 -- chroot /host tar czf - --ignore-failed-read --exclude=/etc/kubernetes/kubeconfig --exclude=/etc/kubernetes/static-pod-resources /etc/crio /etc/kubernetes"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("unexpected args %v", got)
+	}
+	if !bytes.Equal(buf.Bytes(), data) || a.Size != int64(len(data)) || len(a.Manifest) != 3 || a.FileBytes() != 5 {
+		t.Fatalf("unexpected archive %+v", a.Manifest)
+	}
+	m := a.FormatManifest()
+	for _, line := range []string{"3 entries, 5 bytes uncompressed", "/etc/crio/ (dir)", "/etc/crio/crio.conf 5", "/etc/crio/current (symlink -> crio.conf)"} {
+		if !strings.Contains(m, line) {
+			t.Fatalf("manifest missing %q:\n%s", line, m)
+		}
+	}
+
+	Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+		t.Fatal("denied path reached the node")
+		return nil
+	}
+	if _, err := CopyFilesFromNode(context.Background(), "n1", []string{"/etc/crio", "/etc/shadow"}, io.Discard); err == nil {
+		t.Fatal("expected denied path error")
+	}
+}
+
+func TestCopyFilesFromNodeTooLarge(t *testing.T) {
+	orig, origMax := Stream, MaxArchiveBytes
+	defer func() { Stream, MaxArchiveBytes = orig, origMax }()
+	MaxArchiveBytes = 1024
+	Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+		chunk := make([]byte, 512)
+		for i := 0; i < 4; i++ {
+			w.Write(chunk)
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	_, err := CopyFilesFromNode(context.Background(), "n1", []string{"/var/log"}, io.Discard)
+	if !errors.Is(err, ErrArchiveTooLarge) || !strings.Contains(err.Error(), "exceeded 1024 bytes") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCopyFilesFromNodeStreamsLargeArchive(t *testing.T) {
+	const size = 64 << 20
+	orig := Stream
+	defer func() { Stream = orig }()
+	Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+		// Uncompressed gzip keeps the stream as large as the payload.
+		zw, _ := gzip.NewWriterLevel(w, gzip.NoCompression)
+		tw := tar.NewWriter(zw)
+		if err := tw.WriteHeader(&tar.Header{Name: "var/log/big.log", Mode: 0o644, Size: size, Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		chunk := bytes.Repeat([]byte("x"), 32<<10)
+		for n := 0; n < size; n += len(chunk) {
+			if _, err := tw.Write(chunk); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	var n countingWriter
+	a, err := CopyFilesFromNode(context.Background(), "n1", []string{"/var/log"}, &n)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Size != int64(n) || a.Size < size || a.FileBytes() != size {
+		t.Fatalf("unexpected archive size %d, written %d, files %d", a.Size, n, a.FileBytes())
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > size/4 {
+		t.Fatalf("allocated %d bytes streaming a %d byte archive", alloc, a.Size)
+	}
+}
+
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+func TestStreamInterruptsOnCancel(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "interrupted")
+	script := `#!/bin/sh
+trap 'echo yes > ` + marker + `; exit 130' INT
+while :; do printf '0123456789abcdef'; done
+`
+	os.WriteFile(filepath.Join(dir, "oc"), []byte(script), 0o755)
+	t.Setenv("PATH", dir+":/bin:/usr/bin")
+
+	orig := MaxArchiveBytes
+	defer func() { MaxArchiveBytes = orig }()
+	MaxArchiveBytes = 4096
+	_, err := CopyFilesFromNode(context.Background(), "n1", []string{"/var/log"}, io.Discard)
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("oc was not interrupted: %v", err)
+	}
+}
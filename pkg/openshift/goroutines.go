@@ -0,0 +1,37 @@
+package openshift
+
+import (
+	"context"
+	"fmt"
+)
+
+// crioGoroutineDumpScript signals CRI-O with SIGUSR1 and prints the stack dump
+// once it appears, either as a new file under /tmp or, for CRI-O versions that
+// log the stacks instead, in the crio unit's journal since the signal.
+const crioGoroutineDumpScript = `start=$(date +%s)
+before=$(ls -t /tmp/crio-goroutine-stacks-*.log 2>/dev/null | head -n1)
+pkill -USR1 -x crio || { echo "crio process not found" >&2; exit 1; }
+for i in 1 2 3 4 5 6 7 8 9 10; do
+  sleep 1
+  f=$(ls -t /tmp/crio-goroutine-stacks-*.log 2>/dev/null | head -n1)
+  if [ -n "$f" ] && [ "$f" != "$before" ]; then cat "$f"; exit 0; fi
+  if journalctl -u crio --since "@$start" -o cat --no-pager 2>/dev/null | grep -q '^goroutine [0-9]* \['; then
+    sleep 1
+    journalctl -u crio --since "@$start" -o cat --no-pager
+    exit 0
+  fi
+done
+echo "no goroutine dump written by crio to /tmp or the journal" >&2
+exit 1`
+
+// CrioGoroutines triggers a goroutine stack dump in CRI-O on the node and
+// returns the contents of the dump file, or the crio journal since the signal
+// when the stacks were logged there. goroutines.Parse skips the log lines
+// around the dump.
+func CrioGoroutines(ctx context.Context, nodeName string) ([]byte, error) {
+	out, err := debugNodeBinary(ctx, nodeName, crioGoroutineDumpScript)
+	if err != nil {
+		return nil, fmt.Errorf("crio goroutine dump failed: %w", err)
+	}
+	return out, nil
+}
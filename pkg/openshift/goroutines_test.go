@@ -0,0 +1,38 @@
+package openshift
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestCrioGoroutines(t *testing.T) {
+	dump := "goroutine 1 [running]:\nmain.main()\n"
+	withRunMock(func(ctx context.Context, args ...string) ([]byte, error) {
+		script := args[len(args)-1]
+		if !strings.Contains(script, "pkill -USR1 -x crio") || !strings.Contains(script, `journalctl -u crio --since "@$start"`) {
+			t.Fatalf("unexpected script %q", script)
+		}
+		return []byte(binaryBegin + "\n" + base64.StdEncoding.EncodeToString([]byte(dump)) + "\n" + binaryEnd + " 0\n"), nil
+	}, func() {
+		out, err := CrioGoroutines(context.Background(), "n1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(out) != dump {
+			t.Fatalf("unexpected output %q", out)
+		}
+	})
+}
+
+func TestCrioGoroutinesEmpty(t *testing.T) {
+	withRunMock(func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte(binaryBegin + "\n\n" + binaryEnd + " 1\nno goroutine dump written by crio to /tmp or the journal\n"), nil
+	}, func() {
+		_, err := CrioGoroutines(context.Background(), "n1")
+		if err == nil || !strings.Contains(err.Error(), "no goroutine dump written by crio") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package openshift
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Impersonation names the user and groups oc acts as, so that the cluster's
+// own RBAC decides what a caller may do instead of the server's credentials.
+type Impersonation struct {
+	User   string
+	Groups []string
+}
+
+type impersonationKey struct{}
+
+// WithImpersonation returns a context under which every oc invocation runs
+// with --as and --as-group set from imp.
+func WithImpersonation(ctx context.Context, imp Impersonation) context.Context {
+	return context.WithValue(ctx, impersonationKey{}, imp)
+}
+
+// ImpersonationFrom returns the impersonation set on ctx, if any.
+func ImpersonationFrom(ctx context.Context) (Impersonation, bool) {
+	imp, ok := ctx.Value(impersonationKey{}).(Impersonation)
+	return imp, ok && imp.User != ""
+}
+
+// globalArgs returns the flags placed before every oc subcommand.
+func globalArgs(ctx context.Context) []string {
+	imp, ok := ImpersonationFrom(ctx)
+	if !ok {
+		return nil
+	}
+	args := []string{"--as=" + imp.User}
+	for _, g := range imp.Groups {
+		args = append(args, "--as-group="+g)
+	}
+	return args
+}
+
+// ErrForbidden matches errors for operations the cluster's RBAC rejected.
+var ErrForbidden = errors.New("forbidden")
+
+// ForbiddenError reports an oc invocation the API server refused.
+type ForbiddenError struct {
+	// As is the impersonated user, empty when oc used its own credentials.
+	As string
+	// Message is the API server's explanation.
+	Message string
+	// Output is everything oc printed, which includes the line Message was
+	// taken from.
+	Output string
+	Err    error
+
+	line string
+}
+
+func (e *ForbiddenError) Error() string {
+	who := "the server's credentials"
+	if e.As != "" {
+		who = e.As
+	}
+	msg := "forbidden by cluster RBAC for " + who + ": " + e.Message
+	// The output is added when it holds more than the refusal itself, so
+	// that nothing else oc printed is lost.
+	if out := strings.TrimSpace(e.Output); out != "" && out != e.line {
+		msg += "\n\n" + out
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrForbidden) true for a ForbiddenError.
+func (e *ForbiddenError) Is(target error) bool { return target == ErrForbidden }
+
+func (e *ForbiddenError) Unwrap() error { return e.Err }
+
+// forbiddenRE matches the lines oc itself prints when the API server answers
+// 403: "Error from server (Forbidden): ..." for refused requests, including
+// failed impersonation, and "error: ... cannot impersonate ..." when oc
+// refuses to impersonate. Text such as "is forbidden:" elsewhere in the
+// output, for example in logs read from a node, does not match.
+var forbiddenRE = regexp.MustCompile(`(?m)^(?:Error from server \(Forbidden\): (.+)|error: (.*cannot impersonate.*))$`)
+
+// classify turns a failed oc invocation whose output shows a 403 into a
+// ForbiddenError and returns other errors unchanged.
+func classify(ctx context.Context, out []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	m := forbiddenRE.FindSubmatch(out)
+	if m == nil {
+		return err
+	}
+	msg := m[1]
+	if msg == nil {
+		msg = m[2]
+	}
+	imp, _ := ImpersonationFrom(ctx)
+	return &ForbiddenError{As: imp.User, Message: strings.TrimSpace(string(msg)), Output: string(out), Err: err, line: strings.TrimSpace(string(m[0]))}
+}
@@ -0,0 +1,92 @@
+package openshift
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeOC puts an oc script on PATH that records its arguments and fails with
+// a Forbidden error when asked to debug a node.
+func fakeOC(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := `#!/bin/sh
+echo "$@" > ` + argsFile + `
+case "$*" in
+*debug*) echo 'Error from server (Forbidden): pods "n1-debug" is forbidden: User "alice" cannot create resource "pods" in API group "" in the namespace "default"' >&2; exit 1;;
+esac
+echo ok
+`
+	if err := os.WriteFile(filepath.Join(dir, "oc"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	return argsFile
+}
+
+func TestImpersonation(t *testing.T) {
+	argsFile := fakeOC(t)
+	ctx := WithImpersonation(context.Background(), Impersonation{User: "alice", Groups: []string{"sre", "dev"}})
+
+	out, err := Output(ctx, "get", "nodes")
+	if err != nil || strings.TrimSpace(string(out)) != "ok" {
+		t.Fatalf("unexpected result %q, %v", out, err)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if got := strings.TrimSpace(string(args)); got != "--as=alice --as-group=sre --as-group=dev get nodes" {
+		t.Fatalf("unexpected args %q", got)
+	}
+
+	if _, err := Run(context.Background(), "get", "nodes"); err != nil {
+		t.Fatal(err)
+	}
+	args, _ = os.ReadFile(argsFile)
+	if got := strings.TrimSpace(string(args)); got != "get nodes" {
+		t.Fatalf("impersonated without a caller: %q", got)
+	}
+}
+
+func TestForbiddenError(t *testing.T) {
+	fakeOC(t)
+	ctx := WithImpersonation(context.Background(), Impersonation{User: "alice"})
+	for name, call := range map[string]func() error{
+		"run":    func() error { _, err := DebugNode(ctx, "n1", "true"); return err },
+		"output": func() error { _, err := FetchNodeFile(ctx, "n1", "/etc/crio/crio.conf"); return err },
+	} {
+		err := call()
+		var fe *ForbiddenError
+		if !errors.Is(err, ErrForbidden) || !errors.As(err, &fe) {
+			t.Fatalf("%s: expected forbidden error, got %v", name, err)
+		}
+		want := `forbidden by cluster RBAC for alice: pods "n1-debug" is forbidden: User "alice" cannot create resource "pods" in API group "" in the namespace "default"`
+		if fe.Error() != want {
+			t.Fatalf("%s: unexpected message %q", name, fe.Error())
+		}
+	}
+
+	for _, out := range []string{
+		"error: no such node",
+		// Refusals that show up in what a command printed on the node.
+		"Oct 18 10:00:00 n1 kubelet[1]: pods \"web-0\" is forbidden: exceeded quota\nerror: non-zero exit code from debug container",
+		"  Error from server (Forbidden): quoted in a log line",
+	} {
+		if err := classify(ctx, []byte(out), errors.New("exit status 1")); errors.Is(err, ErrForbidden) {
+			t.Fatalf("%q classified as forbidden: %v", out, err)
+		}
+	}
+
+	out := "Starting pod/n1-debug ...\nerror: User \"sa\" cannot impersonate resource \"users\" in API group \"\" at the cluster scope\n"
+	err := classify(ctx, []byte(out), errors.New("exit status 1"))
+	var fe *ForbiddenError
+	if !errors.As(err, &fe) || fe.Message != `User "sa" cannot impersonate resource "users" in API group "" at the cluster scope` {
+		t.Fatalf("impersonation refusal not classified: %v", err)
+	}
+	if !strings.HasSuffix(fe.Error(), "\n\n"+strings.TrimSpace(out)) {
+		t.Fatalf("oc output dropped: %q", fe.Error())
+	}
+}
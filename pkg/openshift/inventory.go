@@ -0,0 +1,67 @@
+package openshift
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InventoryPackages are the RPMs whose versions decide a node's exposure to
+// container runtime CVEs.
+var InventoryPackages = []string{"cri-o", "runc", "crun", "conmon", "kernel"}
+
+// InventorySeparator separates the rpm query from /etc/os-release in the
+// output of NodeInventory.
+const InventorySeparator = "--- os-release ---"
+
+// NodeInventory lists the installed versions of InventoryPackages followed by
+// the running kernel, as a RUNNING_KERNEL=<uname -r> line, and the node's
+// /etc/os-release. Each package is printed as "NAME EPOCH:VERSION-RELEASE";
+// packages that are not installed are reported by rpm on their own line and
+// can be ignored. Several lines for one package mean several versions are
+// installed, as is usual for the kernel.
+func NodeInventory(ctx context.Context, nodeName string) (string, error) {
+	cmd := fmt.Sprintf("rpm -q --qf '%%{NAME} %%{EPOCH}:%%{VERSION}-%%{RELEASE}\\n' %s; echo %s; echo \"RUNNING_KERNEL=$(uname -r)\"; cat /etc/os-release",
+		strings.Join(InventoryPackages, " "), shellQuote(InventorySeparator))
+	return DebugNode(ctx, nodeName, cmd)
+}
+
+// PoolNodes returns the names of the nodes selected by a MachineConfigPool.
+func PoolNodes(ctx context.Context, pool string) ([]string, error) {
+	out, err := Output(ctx, "get", "machineconfigpool", pool, "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("oc get machineconfigpool failed: %w", err)
+	}
+	var mcp struct {
+		Spec struct {
+			NodeSelector struct {
+				MatchLabels map[string]string `json:"matchLabels"`
+			} `json:"nodeSelector"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(out, &mcp); err != nil {
+		return nil, fmt.Errorf("decoding machineconfigpool %s: %w", pool, err)
+	}
+	labels := mcp.Spec.NodeSelector.MatchLabels
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("machineconfigpool %s has no node selector", pool)
+	}
+	var selector []string
+	for k, v := range labels {
+		selector = append(selector, k+"="+v)
+	}
+	sort.Strings(selector)
+	out, err = Output(ctx, "get", "nodes", "-l", strings.Join(selector, ","), "-o", "name")
+	if err != nil {
+		return nil, fmt.Errorf("oc get nodes failed: %w", err)
+	}
+	var nodes []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if name := strings.TrimPrefix(strings.TrimSpace(line), "node/"); name != "" {
+			nodes = append(nodes, name)
+		}
+	}
+	return nodes, nil
+}
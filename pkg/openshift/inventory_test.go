@@ -0,0 +1,48 @@
+package openshift
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNodeInventory(t *testing.T) {
+	withRunMock(func(ctx context.Context, args ...string) ([]byte, error) {
+		cmd := args[len(args)-1]
+		if args[1] != "node/n1" || !strings.Contains(cmd, "cri-o runc crun conmon kernel") || !strings.Contains(cmd, "/etc/os-release") || !strings.Contains(cmd, `echo "RUNNING_KERNEL=$(uname -r)"`) {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte("cri-o 0:1.27.1-6.el9\n"), nil
+	}, func() {
+		out, err := NodeInventory(context.Background(), "n1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out != "cri-o 0:1.27.1-6.el9\n" {
+			t.Fatalf("unexpected output %q", out)
+		}
+	})
+}
+
+func TestPoolNodes(t *testing.T) {
+	orig := Output
+	defer func() { Output = orig }()
+	Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		switch fmt.Sprint(args) {
+		case "[get machineconfigpool worker -o json]":
+			return []byte(`{"spec":{"nodeSelector":{"matchLabels":{"node-role.kubernetes.io/worker":""}}}}`), nil
+		case "[get nodes -l node-role.kubernetes.io/worker= -o name]":
+			return []byte("node/w1\nnode/w2\n"), nil
+		}
+		t.Fatalf("unexpected args %v", args)
+		return nil, nil
+	}
+	nodes, err := PoolNodes(context.Background(), "worker")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(nodes) != "[w1 w2]" {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+}
@@ -1,11 +1,20 @@
 package openshift
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
 )
 
 // run executes the oc command with given arguments and returns combined output.
@@ -13,10 +22,63 @@ import (
 // It is defined as a variable to allow tests to substitute a fake implementation
 // without spawning external processes.
 // Run is used by helper functions to execute the oc command. Tests may override
-// this variable to avoid running external commands.
+// this variable to avoid running external commands. When ctx carries an
+// Impersonation the command runs as that user, and a refusal by the API
+// server is returned as a *ForbiddenError.
 var Run = func(ctx context.Context, args ...string) ([]byte, error) {
-	cmd := exec.CommandContext(ctx, "oc", args...)
-	return cmd.CombinedOutput()
+	if p := dryrun.FromContext(ctx); p != nil {
+		return nil, p.Record(dryrun.Command("oc", append(globalArgs(ctx), args...)...))
+	}
+	cmd := exec.CommandContext(ctx, "oc", append(globalArgs(ctx), args...)...)
+	out, err := cmd.CombinedOutput()
+	return out, classify(ctx, out, err)
+}
+
+// Output executes the oc command with given arguments and returns its standard
+// output only, which keeps binary payloads free of the messages oc writes to
+// stderr. Tests may override this variable. Impersonation and forbidden
+// errors are handled as for Run.
+var Output = func(ctx context.Context, args ...string) ([]byte, error) {
+	if p := dryrun.FromContext(ctx); p != nil {
+		return nil, p.Record(dryrun.Command("oc", append(globalArgs(ctx), args...)...))
+	}
+	cmd := exec.CommandContext(ctx, "oc", append(globalArgs(ctx), args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, classify(ctx, ee.Stderr, fmt.Errorf("%w: %s", err, string(ee.Stderr)))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// streamWaitDelay is how long Stream waits for oc to exit after being
+// interrupted before killing it.
+const streamWaitDelay = 10 * time.Second
+
+// Stream executes the oc command with given arguments and copies its standard
+// output to w as it is produced, so large payloads need not be buffered.
+// Cancelling ctx interrupts oc rather than killing it, which gives oc debug
+// the chance to delete its debug pod. Tests may override this variable.
+//
+// Run, Output and Stream are the executor every oc invocation in this package
+// goes through; substituting them mocks the cluster. During a dry run they
+// record the command in the context's dryrun.Plan instead of running it.
+var Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+	if p := dryrun.FromContext(ctx); p != nil {
+		return p.Record(dryrun.Command("oc", append(globalArgs(ctx), args...)...))
+	}
+	cmd := exec.CommandContext(ctx, "oc", append(globalArgs(ctx), args...)...)
+	cmd.Stdout = w
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = streamWaitDelay
+	if err := cmd.Run(); err != nil {
+		return classify(ctx, stderr.Bytes(), fmt.Errorf("%w: %s", err, stderr.String()))
+	}
+	return nil
 }
 
 // DebugNode runs `oc debug` for the given node and command.
@@ -28,19 +90,33 @@ func DebugNode(ctx context.Context, nodeName, command string) (string, error) {
 	return string(out), nil
 }
 
-// NodeLogs runs `oc adm node-logs` for the given node and since parameter.
-func NodeLogs(ctx context.Context, nodeName, since string) (string, error) {
+// nodeLogsArgs returns the oc arguments for reading a node's journal.
+func nodeLogsArgs(nodeName, since string) []string {
 	args := []string{"adm", "node-logs", nodeName}
 	if since != "" {
 		args = append(args, "--since", since)
 	}
-	out, err := Run(ctx, args...)
+	return args
+}
+
+// NodeLogs runs `oc adm node-logs` for the given node and since parameter.
+func NodeLogs(ctx context.Context, nodeName, since string) (string, error) {
+	out, err := Run(ctx, nodeLogsArgs(nodeName, since)...)
 	if err != nil {
 		return "", fmt.Errorf("oc adm node-logs failed: %w: %s", err, out)
 	}
 	return string(out), nil
 }
 
+// StreamNodeLogs copies the output of `oc adm node-logs` to w as it is
+// produced, for logs too large to hold in memory.
+func StreamNodeLogs(ctx context.Context, nodeName, since string, w io.Writer) error {
+	if err := Stream(ctx, w, nodeLogsArgs(nodeName, since)...); err != nil {
+		return fmt.Errorf("oc adm node-logs failed: %w", err)
+	}
+	return nil
+}
+
 // MustGather runs `oc adm must-gather` with optional destination directory and
 // additional arguments.
 func MustGather(ctx context.Context, destDir string, extra []string) (string, error) {
@@ -70,11 +146,112 @@ func SosReport(ctx context.Context, nodeName, caseID string) (string, error) {
 	return string(out), nil
 }
 
+var sha256RE = regexp.MustCompile(`\b[0-9a-f]{64}\b`)
+
+// NodeFileChecksum returns the hex encoded SHA-256 of a file on the node's
+// host filesystem.
+func NodeFileChecksum(ctx context.Context, nodeName, path string) (string, error) {
+	out, err := DebugNode(ctx, nodeName, "sha256sum "+shellQuote(path))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256RE.FindString(out)
+	if sum == "" {
+		return "", fmt.Errorf("sha256sum output not understood: %s", out)
+	}
+	return sum, nil
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// sosArchiveRE matches the archive path sosreport prints when it finishes.
+// Inside toolbox the host filesystem is mounted at /host.
+var sosArchiveRE = regexp.MustCompile(`(?:/host)?(/var/tmp/(?:sosreport|sos)-[^\s]+\.tar\.(?:xz|gz|bz2))`)
+
+// SosReportArchive returns the host path of the archive named in sosreport
+// output, or an empty string if none is found.
+func SosReportArchive(output string) string {
+	m := sosArchiveRE.FindStringSubmatch(output)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// shellWordRE matches arguments that the shell passes through unchanged.
+var shellWordRE = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellJoin joins args into a shell command line in which each of them is a
+// single word. Arguments containing anything but plain characters are quoted,
+// so metacharacters such as ";" or "$(...)" reach the program literally.
+func ShellJoin(args []string) string {
+	words := make([]string, len(args))
+	for i, a := range args {
+		if shellWordRE.MatchString(a) {
+			words[i] = a
+		} else {
+			words[i] = shellQuote(a)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
 // Crictl runs `crictl` inside a debug pod on the specified node with the given arguments.
-// The args slice corresponds to command-line arguments after "crictl".
+// The args slice corresponds to command-line arguments after "crictl"; each
+// is passed to crictl as one argument and never interpreted by the shell.
 func Crictl(ctx context.Context, nodeName string, args []string) (string, error) {
-	cmd := fmt.Sprintf("crictl %s", strings.Join(args, " "))
-	return DebugNode(ctx, nodeName, cmd)
+	return DebugNode(ctx, nodeName, "crictl "+ShellJoin(args))
+}
+
+// crictlValueFlags are the crictl global flags that take a value.
+var crictlValueFlags = map[string]bool{
+	"-c": true, "--config": true,
+	"-i": true, "--image-endpoint": true,
+	"-r": true, "--runtime-endpoint": true,
+	"-t": true, "--timeout": true,
+	"--tracing-endpoint":                  true,
+	"--tracing-sampling-rate-per-million": true,
+}
+
+// crictlBoolFlags are the crictl global flags that take no value.
+var crictlBoolFlags = map[string]bool{
+	"-D": true, "--debug": true,
+	"-h": true, "--help": true,
+	"-v": true, "--version": true,
+	"--enable-tracing": true,
+}
+
+// CrictlSubcommand returns the subcommand crictl runs for args, skipping the
+// global flags and their values. It returns an empty string when there is no
+// subcommand or a flag before it is not a known global flag, since its value
+// could then be mistaken for the subcommand.
+func CrictlSubcommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		case !strings.HasPrefix(a, "-"):
+			return a
+		}
+		name, _, hasValue := strings.Cut(a, "=")
+		switch {
+		case crictlValueFlags[name]:
+			if !hasValue {
+				i++
+			}
+		case crictlBoolFlags[name]:
+		default:
+			return ""
+		}
+	}
+	return ""
 }
 
 // NetworkLogs runs the gather_network_logs must-gather addon.
@@ -106,6 +283,53 @@ func ProfilingNode(ctx context.Context, destDir string) (string, error) {
 	return string(out), nil
 }
 
+var (
+	serverMu  sync.Mutex
+	serverURL string
+)
+
+// CurrentServer returns the API server URL of the cluster oc is logged in
+// to, or an empty string if it cannot be determined. A successful lookup is
+// cached for the lifetime of the process.
+func CurrentServer(ctx context.Context) string {
+	serverMu.Lock()
+	defer serverMu.Unlock()
+	if serverURL != "" {
+		return serverURL
+	}
+	out, err := Output(ctx, "whoami", "--show-server")
+	if err != nil {
+		return ""
+	}
+	serverURL = strings.TrimSpace(string(out))
+	return serverURL
+}
+
+// NodeLabels returns the labels of a node.
+func NodeLabels(ctx context.Context, nodeName string) (map[string]string, error) {
+	out, err := Output(ctx, "get", "node", nodeName, "-o", "jsonpath={.metadata.labels}")
+	if err != nil {
+		return nil, fmt.Errorf("oc get node failed: %w", err)
+	}
+	labels := map[string]string{}
+	if strings.TrimSpace(string(out)) == "" {
+		return labels, nil
+	}
+	if err := json.Unmarshal(out, &labels); err != nil {
+		return nil, fmt.Errorf("decoding labels of node %s: %w", nodeName, err)
+	}
+	return labels, nil
+}
+
+// NodeJSON returns a node object as JSON.
+func NodeJSON(ctx context.Context, nodeName string) ([]byte, error) {
+	out, err := Output(ctx, "get", "node", nodeName, "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("oc get node failed: %w", err)
+	}
+	return out, nil
+}
+
 // Events retrieves recent cluster events across all namespaces.
 func Events(ctx context.Context) (string, error) {
 	out, err := Run(ctx, "get", "events", "-A")
@@ -132,31 +356,50 @@ func PodLogs(ctx context.Context, namespace, pod, container, since string) (stri
 	return string(out), nil
 }
 
+// PodNode returns the node a pod is scheduled to, or "" when it is not
+// scheduled yet.
+func PodNode(ctx context.Context, namespace, pod string) (string, error) {
+	out, err := Output(ctx, "get", "pod", "-n", namespace, pod, "-o", "jsonpath={.spec.nodeName}")
+	if err != nil {
+		return "", fmt.Errorf("oc get pod failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// NodePods returns the pods the API server places on a node as JSON.
+func NodePods(ctx context.Context, nodeName string) ([]byte, error) {
+	out, err := Output(ctx, "get", "pods", "-A", "--field-selector", "spec.nodeName="+nodeName, "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("oc get pods failed: %w", err)
+	}
+	return out, nil
+}
+
 // NodeConfig gathers basic node configuration like kubelet and CRI-O settings.
 func NodeConfig(ctx context.Context, nodeName string) (string, error) {
 	cmd := "cat /etc/kubernetes/kubelet.conf && echo --- && cat /etc/crio/crio.conf"
 	return DebugNode(ctx, nodeName, cmd)
 }
 
-// CopyFilesFromNode retrieves the specified files or directories from the node
-// and returns them as a gzip-compressed tar archive.
-func CopyFilesFromNode(ctx context.Context, nodeName string, paths []string) ([]byte, error) {
-	if len(paths) == 0 {
-		return nil, fmt.Errorf("no paths specified")
-	}
-	args := []string{"debug", fmt.Sprintf("node/%s", nodeName), "--", "chroot", "/host", "tar", "czf", "-", "--ignore-failed-read"}
-	args = append(args, paths...)
-	cmd := exec.CommandContext(ctx, "oc", args...)
-	out, err := cmd.Output()
+// FetchNodeFile returns the contents of a single file on the node's host
+// filesystem.
+func FetchNodeFile(ctx context.Context, nodeName, path string) ([]byte, error) {
+	out, err := Output(ctx, "debug", fmt.Sprintf("node/%s", nodeName), "--", "chroot", "/host", "cat", path)
 	if err != nil {
-		if ee, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("oc debug failed: %w: %s", err, string(ee.Stderr))
-		}
 		return nil, fmt.Errorf("oc debug failed: %w", err)
 	}
 	return out, nil
 }
 
+// StreamNodeFile copies a single file from the node's host filesystem to w
+// without holding it in memory, for archives such as sosreports.
+func StreamNodeFile(ctx context.Context, nodeName, path string, w io.Writer) error {
+	if err := Stream(ctx, w, "debug", fmt.Sprintf("node/%s", nodeName), "--", "chroot", "/host", "cat", path); err != nil {
+		return fmt.Errorf("oc debug failed: %w", err)
+	}
+	return nil
+}
+
 // NodeMetrics retrieves CPU and memory usage for all nodes using
 // `oc adm top nodes`.
 func NodeMetrics(ctx context.Context) (string, error) {
@@ -1,10 +1,14 @@
 package openshift
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"testing"
+
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
 )
 
 // helper to replace run during tests
@@ -154,3 +158,231 @@ func TestPrometheusQuery(t *testing.T) {
 		}
 	})
 }
+
+func TestSosReportArchive(t *testing.T) {
+	out := "Your sosreport has been generated and saved in:\n  /host/var/tmp/sosreport-worker-0-01234567-2025-01-01-abcdef.tar.xz\n\n Size\t12.3MiB\n"
+	if got := SosReportArchive(out); got != "/var/tmp/sosreport-worker-0-01234567-2025-01-01-abcdef.tar.xz" {
+		t.Fatalf("unexpected archive %q", got)
+	}
+	if got := SosReportArchive("sosreport failed"); got != "" {
+		t.Fatalf("unexpected archive %q", got)
+	}
+}
+
+func TestCrictlQuotesArgs(t *testing.T) {
+	withRunMock(func(ctx context.Context, args ...string) ([]byte, error) {
+		want := []string{"debug", "node/n1", "--", "chroot", "/host", "sh", "-c", `crictl ps ';' crictl rmp -a 'ps;' '$(reboot)' 'it'\''s' --label=app=web`}
+		if fmt.Sprint(args) != fmt.Sprint(want) {
+			t.Fatalf("unexpected args %q", args)
+		}
+		return []byte("ok"), nil
+	}, func() {
+		if _, err := Crictl(context.Background(), "n1", []string{"ps", ";", "crictl", "rmp", "-a", "ps;", "$(reboot)", "it's", "--label=app=web"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestCrictlSubcommand(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"ps", "-a"}, "ps"},
+		{[]string{"-r", "unix:///var/run/crio/crio.sock", "rmp", "-a"}, "rmp"},
+		{[]string{"--timeout", "5s", "stopp", "abc"}, "stopp"},
+		{[]string{"--timeout=5s", "-D", "inspect", "abc"}, "inspect"},
+		{[]string{"-r", "inspect", "rm", "abc"}, "rm"},
+		{[]string{"--", "rmi", "abc"}, "rmi"},
+		{[]string{"--unknown", "ps"}, ""},
+		{[]string{"--debug"}, ""},
+		{nil, ""},
+	} {
+		if got := CrictlSubcommand(tt.args); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFetchNodeFile(t *testing.T) {
+	orig := Output
+	defer func() { Output = orig }()
+	expected := []string{"debug", "node/n1", "--", "chroot", "/host", "cat", "/var/tmp/sos.tar.xz"}
+	Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != fmt.Sprint(expected) {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte("data"), nil
+	}
+	out, err := FetchNodeFile(context.Background(), "n1", "/var/tmp/sos.tar.xz")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "data" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestStreamNodeLogs(t *testing.T) {
+	orig := Stream
+	defer func() { Stream = orig }()
+	Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+		if fmt.Sprint(args) != "[adm node-logs n1 --since 2h]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		io.WriteString(w, "journal")
+		return errors.New("exit status 1")
+	}
+	var buf bytes.Buffer
+	err := StreamNodeLogs(context.Background(), "n1", "2h", &buf)
+	if err == nil || buf.String() != "journal" {
+		t.Fatalf("unexpected result %q, %v", buf.String(), err)
+	}
+}
+
+func TestStreamNodeFile(t *testing.T) {
+	orig := Stream
+	defer func() { Stream = orig }()
+	expected := []string{"debug", "node/n1", "--", "chroot", "/host", "cat", "/var/tmp/sos.tar.xz"}
+	Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+		if fmt.Sprint(args) != fmt.Sprint(expected) {
+			t.Fatalf("unexpected args %v", args)
+		}
+		_, err := io.WriteString(w, "data")
+		return err
+	}
+	var buf bytes.Buffer
+	if err := StreamNodeFile(context.Background(), "n1", "/var/tmp/sos.tar.xz", &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != "data" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestNodeFileChecksum(t *testing.T) {
+	sum := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	expected := []string{"debug", "node/n1", "--", "chroot", "/host", "sh", "-c", "sha256sum '/var/tmp/it'\\''s.tar.xz'"}
+	withRunMock(func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != fmt.Sprint(expected) {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte("Starting pod/n1-debug ...\n" + sum + "  /var/tmp/it's.tar.xz\n"), nil
+	}, func() {
+		out, err := NodeFileChecksum(context.Background(), "n1", "/var/tmp/it's.tar.xz")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out != sum {
+			t.Fatalf("unexpected checksum %q", out)
+		}
+	})
+}
+
+func TestCurrentServer(t *testing.T) {
+	orig := Output
+	defer func() { Output = orig }()
+	serverURL = ""
+	defer func() { serverURL = "" }()
+	calls := 0
+	Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		calls++
+		if fmt.Sprint(args) != "[whoami --show-server]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte("https://api.example.com:6443\n"), nil
+	}
+	for i := 0; i < 2; i++ {
+		if got := CurrentServer(context.Background()); got != "https://api.example.com:6443" {
+			t.Fatalf("unexpected server %q", got)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected a cached lookup, got %d calls", calls)
+	}
+}
+
+func TestNodeLabels(t *testing.T) {
+	orig := Output
+	defer func() { Output = orig }()
+	Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != "[get node n1 -o jsonpath={.metadata.labels}]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte(`{"kubernetes.io/hostname":"n1","node-role.kubernetes.io/worker":""}`), nil
+	}
+	labels, err := NodeLabels(context.Background(), "n1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, ok := labels["node-role.kubernetes.io/worker"]; !ok || v != "" || labels["kubernetes.io/hostname"] != "n1" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+}
+
+func TestNodeJSON(t *testing.T) {
+	orig := Output
+	defer func() { Output = orig }()
+	Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != "[get node n1 -o json]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte(`{"kind":"Node"}`), nil
+	}
+	out, err := NodeJSON(context.Background(), "n1")
+	if err != nil || string(out) != `{"kind":"Node"}` {
+		t.Fatalf("got %s, %v", out, err)
+	}
+}
+
+func TestNodePods(t *testing.T) {
+	orig := Output
+	defer func() { Output = orig }()
+	Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != "[get pods -A --field-selector spec.nodeName=n1 -o json]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte(`{"items":[]}`), nil
+	}
+	out, err := NodePods(context.Background(), "n1")
+	if err != nil || string(out) != `{"items":[]}` {
+		t.Fatalf("got %s, %v", out, err)
+	}
+}
+
+func TestPodNode(t *testing.T) {
+	orig := Output
+	defer func() { Output = orig }()
+	Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != "[get pod -n ns web-1 -o jsonpath={.spec.nodeName}]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte("worker-0\n"), nil
+	}
+	node, err := PodNode(context.Background(), "ns", "web-1")
+	if err != nil || node != "worker-0" {
+		t.Fatalf("got %q, %v", node, err)
+	}
+}
+
+func TestExecutorDryRun(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	ctx, plan := dryrun.With(WithImpersonation(context.Background(), Impersonation{User: "alice", Groups: []string{"sre"}}))
+	if _, err := Crictl(ctx, "n1", []string{"rmp", "abc"}); !errors.Is(err, dryrun.ErrSkipped) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := Output(ctx, "get", "nodes"); !errors.Is(err, dryrun.ErrSkipped) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := StreamNodeLogs(ctx, "n1", "", io.Discard); !errors.Is(err, dryrun.ErrSkipped) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []string{
+		"oc --as=alice --as-group=sre debug node/n1 -- chroot /host sh -c 'crictl rmp abc'",
+		"oc --as=alice --as-group=sre get nodes",
+		"oc --as=alice --as-group=sre adm node-logs n1",
+	}
+	if got := plan.Steps(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("unexpected steps %q", got)
+	}
+}
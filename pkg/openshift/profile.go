@@ -0,0 +1,123 @@
+package openshift
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// CrioSocket is the CRI-O unix socket. When CRI-O runs with
+// enable_profile_unix_socket the pprof handlers are served on it.
+const CrioSocket = "/var/run/crio/crio.sock"
+
+// Profile components accepted by NodeProfile.
+const (
+	ComponentCrio    = "crio"
+	ComponentKubelet = "kubelet"
+)
+
+// profilePaths maps the supported profile types to their pprof endpoints.
+var profilePaths = map[string]string{
+	"cpu":       "profile",
+	"heap":      "heap",
+	"goroutine": "goroutine",
+	"mutex":     "mutex",
+	"block":     "block",
+}
+
+// ProfileTypes lists the profile types accepted by NodeProfile.
+var ProfileTypes = []string{"cpu", "heap", "goroutine", "mutex", "block"}
+
+const (
+	binaryBegin = "----CRIO-MCP-BEGIN----"
+	binaryEnd   = "----CRIO-MCP-END----"
+)
+
+// pprofQuery returns the /debug/pprof path and query string for a profile.
+// CPU profiles always take a duration; the other types produce a delta
+// profile over seconds when it is positive and a snapshot otherwise.
+func pprofQuery(profile string, seconds int) (string, error) {
+	p, ok := profilePaths[profile]
+	if !ok {
+		return "", fmt.Errorf("unsupported profile type %q", profile)
+	}
+	if profile == "cpu" && seconds <= 0 {
+		seconds = 30
+	}
+	path := "/debug/pprof/" + p
+	if seconds > 0 {
+		path += fmt.Sprintf("?seconds=%d", seconds)
+	}
+	return path, nil
+}
+
+// debugNodeBinary runs command on the node and returns its stdout as raw
+// bytes. The output is base64 encoded on the node and framed by markers so it
+// survives the informational messages oc debug mixes into the stream. The
+// command's exit status follows the end marker, then its stderr, which is
+// reported when the command fails. Output of a failed command is discarded,
+// since a transfer that broke off would otherwise be returned truncated.
+func debugNodeBinary(ctx context.Context, nodeName, command string) ([]byte, error) {
+	script := fmt.Sprintf(`o=$(mktemp); e=$(mktemp); (%s) >"$o" 2>"$e"; s=$?; echo %s; base64 -w0 "$o"; echo; echo %s $s; cat "$e"; rm -f "$o" "$e"`, command, binaryBegin, binaryEnd)
+	out, err := DebugNode(ctx, nodeName, script)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFramed(out)
+}
+
+func decodeFramed(out string) ([]byte, error) {
+	start := strings.Index(out, binaryBegin)
+	end := strings.LastIndex(out, binaryEnd)
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("unexpected debug pod output: %s", out)
+	}
+	status, stderr, _ := strings.Cut(out[end+len(binaryEnd):], "\n")
+	stderr = strings.TrimSpace(stderr)
+	switch status = strings.TrimSpace(status); status {
+	case "0":
+	case "":
+		return nil, fmt.Errorf("exit status missing from debug pod output: %s", stderr)
+	default:
+		return nil, fmt.Errorf("exit status %s: %s", status, stderr)
+	}
+	payload := strings.TrimSpace(out[start+len(binaryBegin) : end])
+	if payload == "" {
+		return nil, fmt.Errorf("no output: %s", stderr)
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("decode debug pod output: %w", err)
+	}
+	return data, nil
+}
+
+// NodeProfile captures a pprof profile from a single component on the node.
+// CRI-O is queried over its unix socket, which requires profiling to be
+// enabled in crio.conf. The kubelet is queried through the apiserver node
+// proxy; only its standard output is kept so that warnings oc prints do not
+// corrupt the profile. Seconds controls the sampling duration.
+func NodeProfile(ctx context.Context, nodeName, component, profile string, seconds int) ([]byte, error) {
+	path, err := pprofQuery(profile, seconds)
+	if err != nil {
+		return nil, err
+	}
+	switch component {
+	case ComponentCrio:
+		cmd := fmt.Sprintf("curl -sSf --unix-socket %s 'http://localhost%s'", CrioSocket, path)
+		data, err := debugNodeBinary(ctx, nodeName, cmd)
+		if err != nil {
+			return nil, fmt.Errorf("crio profile failed (is enable_profile_unix_socket set?): %w", err)
+		}
+		return data, nil
+	case ComponentKubelet:
+		out, err := Output(ctx, "get", "--raw", fmt.Sprintf("/api/v1/nodes/%s/proxy%s", nodeName, path))
+		if err != nil {
+			return nil, fmt.Errorf("kubelet profile failed: %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported component %q", component)
+	}
+}
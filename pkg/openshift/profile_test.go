@@ -0,0 +1,81 @@
+package openshift
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNodeProfileKubelet(t *testing.T) {
+	orig := Output
+	defer func() { Output = orig }()
+	expected := []string{"get", "--raw", "/api/v1/nodes/n1/proxy/debug/pprof/profile?seconds=10"}
+	Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != fmt.Sprint(expected) {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte("pprof"), nil
+	}
+	withRunMock(func(ctx context.Context, args ...string) ([]byte, error) {
+		t.Fatalf("kubelet profile must not mix stderr into the payload: %v", args)
+		return nil, nil
+	}, func() {
+		out, err := NodeProfile(context.Background(), "n1", ComponentKubelet, "cpu", 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(out) != "pprof" {
+			t.Fatalf("unexpected output %q", out)
+		}
+	})
+}
+
+func TestNodeProfileCrio(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString([]byte{0x1f, 0x8b, 0x00})
+	withRunMock(func(ctx context.Context, args ...string) ([]byte, error) {
+		script := args[len(args)-1]
+		if !strings.Contains(script, "--unix-socket /var/run/crio/crio.sock 'http://localhost/debug/pprof/heap'") {
+			t.Fatalf("unexpected script %q", script)
+		}
+		return []byte("Starting pod/n1-debug ...\n" + binaryBegin + "\n" + payload + "\n" + binaryEnd + " 0\nRemoving debug pod ...\n"), nil
+	}, func() {
+		out, err := NodeProfile(context.Background(), "n1", ComponentCrio, "heap", 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(out) != "\x1f\x8b\x00" {
+			t.Fatalf("unexpected output %q", out)
+		}
+	})
+}
+
+func TestNodeProfileCrioFailed(t *testing.T) {
+	// curl failed after part of the profile was transferred.
+	payload := base64.StdEncoding.EncodeToString([]byte{0x1f, 0x8b})
+	withRunMock(func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte(binaryBegin + "\n" + payload + "\n" + binaryEnd + " 18\ncurl: (18) transfer closed with outstanding read data remaining\n"), nil
+	}, func() {
+		_, err := NodeProfile(context.Background(), "n1", ComponentCrio, "heap", 0)
+		if err == nil || !strings.Contains(err.Error(), "exit status 18: curl: (18) transfer closed") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	withRunMock(func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte(binaryBegin + "\n" + payload + "\n"), nil
+	}, func() {
+		if _, err := NodeProfile(context.Background(), "n1", ComponentCrio, "heap", 0); err == nil {
+			t.Fatal("expected error for output without an end marker")
+		}
+	})
+}
+
+func TestNodeProfileInvalid(t *testing.T) {
+	if _, err := NodeProfile(context.Background(), "n1", ComponentCrio, "trace", 0); err == nil {
+		t.Fatal("expected error for unsupported profile")
+	}
+	if _, err := NodeProfile(context.Background(), "n1", "etcd", "cpu", 0); err == nil {
+		t.Fatal("expected error for unsupported component")
+	}
+}
@@ -0,0 +1,54 @@
+package openshift
+
+import "strings"
+
+// sectionMark starts each section of a SectionedScript's output.
+const sectionMark = "### "
+
+// sectionEnd names the mark printed after the last section.
+const sectionEnd = "end"
+
+// Section is one named command of a SectionedScript.
+type Section struct {
+	Name    string
+	Command string
+}
+
+// SectionedScript joins the commands of sections into one script for
+// DebugNode, so that several commands share a single debug pod. Each command
+// is preceded by a mark line naming its section, and a final mark ends the
+// last section so that oc's own messages are not read as part of it.
+func SectionedScript(sections ...Section) string {
+	var b strings.Builder
+	for _, s := range sections {
+		b.WriteString("echo " + shellQuote(sectionMark+s.Name) + "; " + s.Command + "\n")
+	}
+	b.WriteString("echo " + shellQuote(sectionMark+sectionEnd))
+	return b.String()
+}
+
+// SplitSections splits the output of a SectionedScript by its marks and
+// returns the output of each section by name. A section that is missing, for
+// example because the debug pod ended early, has no entry.
+func SplitSections(out string) map[string]string {
+	m := map[string]string{}
+	var name string
+	var b strings.Builder
+	flush := func() {
+		if name != "" && name != sectionEnd {
+			m[name] = b.String()
+		}
+		b.Reset()
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, sectionMark) {
+			flush()
+			name = strings.TrimSpace(strings.TrimPrefix(line, sectionMark))
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	flush()
+	return m
+}
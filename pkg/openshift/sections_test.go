@@ -0,0 +1,26 @@
+package openshift
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSectionedScript(t *testing.T) {
+	script := SectionedScript(
+		Section{Name: "runtime", Command: "crictl info"},
+		Section{Name: "disk", Command: "df -P /"},
+	)
+	want := "echo '### runtime'; crictl info\necho '### disk'; df -P /\necho '### end'"
+	if script != want {
+		t.Fatalf("unexpected script %q", script)
+	}
+
+	out := "Starting pod/n1-debug ...\n### runtime\n{}\n### disk\nFilesystem\n/dev/sda4\n### end\nRemoving debug pod ...\n"
+	got := SplitSections(out)
+	if fmt.Sprint(got) != "map[disk:Filesystem\n/dev/sda4\n runtime:{}\n]" {
+		t.Fatalf("unexpected sections %q", got)
+	}
+	if got := SplitSections("### runtime\nFATA[0000] connect: connection refused\n"); len(got) != 1 || got["disk"] != "" {
+		t.Fatalf("unexpected sections for truncated output %q", got)
+	}
+}
@@ -0,0 +1,606 @@
+// Package orphans finds runtime state on a node that has lost its owner. A
+// Report is filled from the pods the API server places on the node, the
+// sandboxes and containers CRI-O lists, the conmon and OCI runtime processes
+// running on the node and its network namespaces, and Evaluate reports each
+// class of inconsistency between them, such as sandboxes with no pod or
+// conmon processes with no container.
+package orphans
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harche/crio-mcp-server/pkg/openshift"
+)
+
+// Sources of a report. They are also the keys of Report.Errors.
+const (
+	SourcePods       = "api pods"
+	SourceSandboxes  = "crictl pods"
+	SourceContainers = "crictl ps"
+	SourceInspect    = "crictl inspectp"
+	SourceProcesses  = "processes"
+	SourceNetns      = "network namespaces"
+)
+
+// Classes of inconsistency, in the order they are reported.
+const (
+	ClassPodWithoutSandbox       = "pods without sandbox"
+	ClassSandboxWithoutPod       = "sandboxes without pod"
+	ClassContainerWithoutSandbox = "containers without sandbox"
+	ClassConmonWithoutContainer  = "conmon without container"
+	ClassConmonOfExited          = "conmon of exited container"
+	ClassRunningWithoutConmon    = "running containers without conmon"
+	ClassStuckRuntime            = "long-running runtime processes"
+	ClassNetnsWithoutSandbox     = "network namespaces without sandbox"
+	ClassSandboxWithoutNetns     = "sandboxes without network namespace"
+)
+
+// class describes a class of inconsistency and the sources it needs.
+type class struct {
+	name    string
+	hint    string
+	sources []string
+}
+
+var classes = []class{
+	{ClassPodWithoutSandbox, "The kubelet expects these pods to run but CRI-O has no sandbox for them; check the kubelet journal for sandbox creation errors.", []string{SourcePods, SourceSandboxes}},
+	{ClassSandboxWithoutPod, "CRI-O keeps sandboxes for pods the API server no longer places on the node; the kubelet normally removes them, so a growing list points to a kubelet that lost track after a restart.", []string{SourcePods, SourceSandboxes}},
+	{ClassContainerWithoutSandbox, "CRI-O lists containers whose sandbox it does not know; they are usually left from a crash during pod removal.", []string{SourceSandboxes, SourceContainers}},
+	{ClassConmonWithoutContainer, "conmon still monitors containers CRI-O no longer lists; they usually survive a CRI-O crash or restart and hold on to memory and mounts.", []string{SourceSandboxes, SourceContainers, SourceProcesses}},
+	{ClassConmonOfExited, "conmon is still running for containers CRI-O reports as exited; the exit may not have been recorded.", []string{SourceContainers, SourceProcesses}},
+	{ClassRunningWithoutConmon, "CRI-O reports these containers as running but no conmon monitors them; their exit will not be noticed.", []string{SourceContainers, SourceProcesses}},
+	{ClassStuckRuntime, "runc and crun calls normally finish within seconds; long-running ones are usually blocked on a frozen cgroup, a hung mount or the kernel.", []string{SourceProcesses}},
+	{ClassNetnsWithoutSandbox, "Network namespaces no sandbox uses keep their interfaces and IP addresses; they are left when CNI teardown fails.", []string{SourceSandboxes, SourceInspect, SourceNetns}},
+	{ClassSandboxWithoutNetns, "Ready sandboxes whose network namespace is gone have no network; the pod must be recreated.", []string{SourceSandboxes, SourceInspect, SourceNetns}},
+}
+
+// Grace is how old a pod, sandbox or conmon process must be before it is
+// reported, so that pods being started or stopped while the data is
+// collected are not mistaken for orphans.
+const Grace = 2 * time.Minute
+
+// StuckAfter is how long a runc or crun process may run before it is
+// reported.
+const StuckAfter = 5 * time.Minute
+
+// now returns the current time. Tests may override it.
+var now = time.Now
+
+// Pod is a pod the API server places on the node.
+type Pod struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	UID       string    `json:"uid"`
+	Phase     string    `json:"phase"`
+	Created   time.Time `json:"created"`
+}
+
+// Sandbox is a pod sandbox listed by CRI-O.
+type Sandbox struct {
+	ID        string    `json:"id"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	UID       string    `json:"uid"`
+	State     string    `json:"state"`
+	Created   time.Time `json:"created"`
+	// Netns is the sandbox's network namespace, empty for host network pods.
+	Netns string `json:"netns,omitempty"`
+}
+
+// Container is a container listed by CRI-O.
+type Container struct {
+	ID        string `json:"id"`
+	SandboxID string `json:"sandboxId"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+}
+
+// Process is a conmon or OCI runtime process.
+type Process struct {
+	PID     int    `json:"pid"`
+	Seconds int    `json:"seconds"`
+	Command string `json:"command"`
+	Args    string `json:"args"`
+	// Container is the container a conmon process monitors.
+	Container string `json:"container,omitempty"`
+}
+
+// Item is one inconsistency.
+type Item struct {
+	ID     string `json:"id"`
+	Pod    string `json:"pod,omitempty"`
+	State  string `json:"state,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (it Item) String() string {
+	s := short(it.ID)
+	for _, f := range []string{it.Pod, it.State} {
+		if f != "" {
+			s += " " + f
+		}
+	}
+	if it.Detail != "" {
+		s += ": " + it.Detail
+	}
+	return s
+}
+
+// Class is a class of inconsistency with the items found.
+type Class struct {
+	Name  string `json:"name"`
+	Items []Item `json:"items,omitempty"`
+	// Skipped is set when a source the class needs could not be collected.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Report is everything found on a node.
+type Report struct {
+	Node       string      `json:"node"`
+	Found      int         `json:"found"`
+	Classes    []Class     `json:"classes"`
+	Pods       []Pod       `json:"pods,omitempty"`
+	Sandboxes  []Sandbox   `json:"sandboxes,omitempty"`
+	Containers []Container `json:"containers,omitempty"`
+	Processes  []Process   `json:"processes,omitempty"`
+	Netns      []string    `json:"netns,omitempty"`
+
+	// Errors holds, by source, why it could not be collected.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// New returns an empty report for node.
+func New(node string) *Report {
+	return &Report{Node: node, Errors: map[string]string{}}
+}
+
+// Fail records that the given sources could not be collected.
+func (r *Report) Fail(err error, sources ...string) {
+	for _, s := range sources {
+		r.Errors[s] = err.Error()
+	}
+}
+
+// jsonPart returns the JSON document in out, skipping the messages oc debug
+// prints around it.
+func jsonPart(out []byte) []byte {
+	s := string(out)
+	start, end := strings.IndexByte(s, '{'), strings.LastIndexByte(s, '}')
+	if start < 0 || end < start {
+		return out
+	}
+	return out[start : end+1]
+}
+
+// nanos parses the nanosecond timestamps crictl prints as strings.
+func nanos(s string) time.Time {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// mirrorAnnotation holds the UID the kubelet gives a static pod, which its
+// sandbox carries instead of the mirror pod's UID.
+const mirrorAnnotation = "kubernetes.io/config.mirror"
+
+// ParsePods reads "oc get pods -A --field-selector spec.nodeName=... -o json".
+func (r *Report) ParsePods(data []byte) error {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Namespace         string            `json:"namespace"`
+				Name              string            `json:"name"`
+				UID               string            `json:"uid"`
+				CreationTimestamp time.Time         `json:"creationTimestamp"`
+				Annotations       map[string]string `json:"annotations"`
+			} `json:"metadata"`
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(jsonPart(data), &list); err != nil {
+		return fmt.Errorf("decoding pods: %w", err)
+	}
+	for _, it := range list.Items {
+		uid := it.Metadata.UID
+		if m := it.Metadata.Annotations[mirrorAnnotation]; m != "" {
+			uid = m
+		}
+		r.Pods = append(r.Pods, Pod{Namespace: it.Metadata.Namespace, Name: it.Metadata.Name, UID: uid, Phase: it.Status.Phase, Created: it.Metadata.CreationTimestamp})
+	}
+	return nil
+}
+
+// ParseSandboxes reads "crictl pods -o json".
+func (r *Report) ParseSandboxes(out []byte) error {
+	var doc struct {
+		Items []struct {
+			ID       string `json:"id"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+				UID       string `json:"uid"`
+			} `json:"metadata"`
+			State     string `json:"state"`
+			CreatedAt string `json:"createdAt"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(jsonPart(out), &doc); err != nil {
+		return fmt.Errorf("decoding crictl pods: %w", err)
+	}
+	for _, it := range doc.Items {
+		r.Sandboxes = append(r.Sandboxes, Sandbox{ID: it.ID, Namespace: it.Metadata.Namespace, Name: it.Metadata.Name, UID: it.Metadata.UID, State: it.State, Created: nanos(it.CreatedAt)})
+	}
+	return nil
+}
+
+// ParseContainers reads "crictl ps -a -o json".
+func (r *Report) ParseContainers(out []byte) error {
+	var doc struct {
+		Containers []struct {
+			ID           string `json:"id"`
+			PodSandboxID string `json:"podSandboxId"`
+			Metadata     struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			State string `json:"state"`
+		} `json:"containers"`
+	}
+	if err := json.Unmarshal(jsonPart(out), &doc); err != nil {
+		return fmt.Errorf("decoding crictl ps: %w", err)
+	}
+	for _, c := range doc.Containers {
+		r.Containers = append(r.Containers, Container{ID: c.ID, SandboxID: c.PodSandboxID, Name: c.Metadata.Name, State: c.State})
+	}
+	return nil
+}
+
+// Script collects the on-node data in one debug pod: the network namespace
+// of every sandbox, the conmon and OCI runtime processes with their age in
+// seconds and the network namespaces CRI-O created.
+var Script = openshift.SectionedScript(
+	openshift.Section{Name: "inspect", Command: `ids=$(crictl pods -q); [ -z "$ids" ] || crictl inspectp $ids`},
+	openshift.Section{Name: "processes", Command: `ps -eo pid=,etimes=,comm=,args= | awk '$3 ~ /^(conmon|conmonrs|runc|crun)$/'`},
+	openshift.Section{Name: "netns", Command: "ls -1 /var/run/netns"},
+)
+
+// ParseDebug reads the output of Script. Sources whose section is missing
+// are marked as failed.
+func (r *Report) ParseDebug(out string) {
+	sections := openshift.SplitSections(out)
+
+	parsers := []struct {
+		section, source string
+		parse           func(string) error
+	}{
+		{"inspect", SourceInspect, r.parseInspect},
+		{"processes", SourceProcesses, r.parseProcesses},
+		{"netns", SourceNetns, r.parseNetns},
+	}
+	for _, p := range parsers {
+		s, ok := sections[p.section]
+		if !ok {
+			r.Errors[p.source] = "no output from the node"
+			continue
+		}
+		if err := p.parse(s); err != nil {
+			r.Errors[p.source] = err.Error()
+		}
+	}
+}
+
+// parseInspect reads the network namespace of each sandbox from the JSON
+// documents "crictl inspectp" prints, one per sandbox.
+func (r *Report) parseInspect(s string) error {
+	netns := map[string]string{}
+	if i := strings.IndexByte(s, '{'); i >= 0 {
+		dec := json.NewDecoder(strings.NewReader(s[i:]))
+		for {
+			var doc struct {
+				Status struct {
+					ID string `json:"id"`
+				} `json:"status"`
+				Info struct {
+					RuntimeSpec struct {
+						Linux struct {
+							Namespaces []struct {
+								Type string `json:"type"`
+								Path string `json:"path"`
+							} `json:"namespaces"`
+						} `json:"linux"`
+					} `json:"runtimeSpec"`
+				} `json:"info"`
+			}
+			err := dec.Decode(&doc)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("decoding crictl inspectp: %w", err)
+			}
+			for _, ns := range doc.Info.RuntimeSpec.Linux.Namespaces {
+				if ns.Type == "network" {
+					netns[doc.Status.ID] = ns.Path
+				}
+			}
+		}
+	} else if s = strings.TrimSpace(s); s != "" {
+		return fmt.Errorf("crictl inspectp: %s", s)
+	}
+	for i := range r.Sandboxes {
+		r.Sandboxes[i].Netns = netns[r.Sandboxes[i].ID]
+	}
+	return nil
+}
+
+func (r *Report) parseProcesses(s string) error {
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 4 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(f[0])
+		secs, err2 := strconv.Atoi(f[1])
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("ps: %s", strings.TrimSpace(line))
+		}
+		p := Process{PID: pid, Seconds: secs, Command: f[2], Args: strings.Join(f[3:], " ")}
+		if p.Command == "conmon" {
+			p.Container = conmonContainer(f[4:])
+		}
+		r.Processes = append(r.Processes, p)
+	}
+	return nil
+}
+
+// Age is how long the process has been running.
+func (p Process) Age() time.Duration {
+	return time.Duration(p.Seconds) * time.Second
+}
+
+// conmonContainer returns the container ID from conmon's arguments.
+func conmonContainer(args []string) string {
+	for i, a := range args {
+		switch {
+		case (a == "-c" || a == "--cid") && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(a, "--cid="):
+			return strings.TrimPrefix(a, "--cid=")
+		}
+	}
+	return ""
+}
+
+func (r *Report) parseNetns(s string) error {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "ls: "):
+			// The directory does not exist until the first pod with its
+			// own network starts.
+			if !strings.Contains(line, "No such file") {
+				return fmt.Errorf("%s", line)
+			}
+		default:
+			r.Netns = append(r.Netns, line)
+		}
+	}
+	return nil
+}
+
+// Evaluate cross-references the sources and fills in the classes.
+func (r *Report) Evaluate() {
+	t := now()
+	old := func(created time.Time) bool { return created.IsZero() || t.Sub(created) >= Grace }
+
+	podByUID := map[string]Pod{}
+	podByName := map[string]Pod{}
+	for _, p := range r.Pods {
+		podByUID[p.UID] = p
+		podByName[p.Namespace+"/"+p.Name] = p
+	}
+	sandboxByID := map[string]Sandbox{}
+	sandboxUIDs := map[string]bool{}
+	for _, s := range r.Sandboxes {
+		sandboxByID[s.ID] = s
+		sandboxUIDs[s.UID] = true
+	}
+	containerByID := map[string]Container{}
+	for _, c := range r.Containers {
+		containerByID[c.ID] = c
+	}
+	conmonOf := map[string]Process{}
+	conmonrs := false
+	for _, p := range r.Processes {
+		switch p.Command {
+		case "conmon":
+			conmonOf[p.Container] = p
+		case "conmonrs":
+			conmonrs = true
+		}
+	}
+	netns := map[string]bool{}
+	for _, n := range r.Netns {
+		netns[n] = true
+	}
+
+	find := map[string]func() []Item{
+		ClassPodWithoutSandbox: func() (items []Item) {
+			for _, p := range r.Pods {
+				if p.Phase == "Succeeded" || p.Phase == "Failed" || sandboxUIDs[p.UID] || !old(p.Created) {
+					continue
+				}
+				items = append(items, Item{ID: p.UID, Pod: p.Namespace + "/" + p.Name, State: p.Phase})
+			}
+			return
+		},
+		ClassSandboxWithoutPod: func() (items []Item) {
+			for _, s := range r.Sandboxes {
+				if _, ok := podByUID[s.UID]; ok || !old(s.Created) {
+					continue
+				}
+				it := Item{ID: s.ID, Pod: s.Namespace + "/" + s.Name, State: s.State}
+				if p, ok := podByName[it.Pod]; ok {
+					it.Detail = fmt.Sprintf("left from an earlier pod of that name; the current one has uid %s", p.UID)
+				}
+				items = append(items, it)
+			}
+			return
+		},
+		ClassContainerWithoutSandbox: func() (items []Item) {
+			for _, c := range r.Containers {
+				if _, ok := sandboxByID[c.SandboxID]; !ok {
+					items = append(items, Item{ID: c.ID, State: c.State, Detail: fmt.Sprintf("container %s of sandbox %s", c.Name, short(c.SandboxID))})
+				}
+			}
+			return
+		},
+		ClassConmonWithoutContainer: func() (items []Item) {
+			for _, p := range r.Processes {
+				if p.Command != "conmon" || p.Age() < Grace {
+					continue
+				}
+				_, isContainer := containerByID[p.Container]
+				// Infra containers share their sandbox's ID.
+				_, isSandbox := sandboxByID[p.Container]
+				if !isContainer && !isSandbox {
+					items = append(items, Item{ID: p.Container, Detail: fmt.Sprintf("conmon pid %d running for %s", p.PID, p.Age())})
+				}
+			}
+			return
+		},
+		ClassConmonOfExited: func() (items []Item) {
+			for _, c := range r.Containers {
+				if p, ok := conmonOf[c.ID]; ok && c.State == "CONTAINER_EXITED" && p.Age() >= Grace {
+					items = append(items, Item{ID: c.ID, State: c.State, Detail: fmt.Sprintf("container %s, conmon pid %d", c.Name, p.PID)})
+				}
+			}
+			return
+		},
+		ClassRunningWithoutConmon: func() (items []Item) {
+			// conmon-rs monitors all containers of a pod from one process
+			// that does not name them.
+			if conmonrs {
+				return nil
+			}
+			for _, c := range r.Containers {
+				if _, ok := conmonOf[c.ID]; !ok && c.State == "CONTAINER_RUNNING" {
+					items = append(items, Item{ID: c.ID, State: c.State, Detail: "container " + c.Name})
+				}
+			}
+			return
+		},
+		ClassStuckRuntime: func() (items []Item) {
+			for _, p := range r.Processes {
+				if (p.Command == "runc" || p.Command == "crun") && p.Age() >= StuckAfter {
+					items = append(items, Item{ID: strconv.Itoa(p.PID), Detail: fmt.Sprintf("running for %s: %s", p.Age(), p.Args)})
+				}
+			}
+			return
+		},
+		ClassNetnsWithoutSandbox: func() (items []Item) {
+			used := map[string]bool{}
+			for _, s := range r.Sandboxes {
+				used[path.Base(s.Netns)] = true
+			}
+			for _, n := range r.Netns {
+				if !used[n] {
+					items = append(items, Item{ID: n, Detail: "/var/run/netns/" + n})
+				}
+			}
+			return
+		},
+		ClassSandboxWithoutNetns: func() (items []Item) {
+			for _, s := range r.Sandboxes {
+				if s.State == "SANDBOX_READY" && s.Netns != "" && !netns[path.Base(s.Netns)] {
+					items = append(items, Item{ID: s.ID, Pod: s.Namespace + "/" + s.Name, State: s.State, Detail: s.Netns + " is missing"})
+				}
+			}
+			return
+		},
+	}
+
+	r.Classes = nil
+	r.Found = 0
+	for _, c := range classes {
+		cl := Class{Name: c.name}
+		for _, src := range c.sources {
+			if _, failed := r.Errors[src]; failed {
+				cl.Skipped = true
+			}
+		}
+		if !cl.Skipped {
+			cl.Items = find[c.name]()
+			r.Found += len(cl.Items)
+		}
+		r.Classes = append(r.Classes, cl)
+	}
+}
+
+func short(id string) string {
+	if len(id) > 13 {
+		return id[:13]
+	}
+	return id
+}
+
+// Summary renders the classes that have items, with a hint on each, for a
+// reader.
+func (r *Report) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "node %s: %d inconsistencies\n", r.Node, r.Found)
+	fmt.Fprintf(&b, "api pods: %d, sandboxes: %d, containers: %d, runtime processes: %d, network namespaces: %d\n",
+		len(r.Pods), len(r.Sandboxes), len(r.Containers), len(r.Processes), len(r.Netns))
+	var clean, skipped []string
+	for i, cl := range r.Classes {
+		switch {
+		case cl.Skipped:
+			skipped = append(skipped, cl.Name)
+		case len(cl.Items) == 0:
+			clean = append(clean, cl.Name)
+		default:
+			fmt.Fprintf(&b, "\n%s (%d):\n%s\n", cl.Name, len(cl.Items), classes[i].hint)
+			for _, it := range cl.Items {
+				fmt.Fprintf(&b, "- %s\n", it)
+			}
+		}
+	}
+	if len(clean) > 0 {
+		fmt.Fprintf(&b, "\nnone found: %s\n", strings.Join(clean, ", "))
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&b, "\nnot checked: %s\n", strings.Join(skipped, ", "))
+		srcs := make([]string, 0, len(r.Errors))
+		for s := range r.Errors {
+			srcs = append(srcs, s)
+		}
+		sort.Strings(srcs)
+		for _, s := range srcs {
+			fmt.Fprintf(&b, "- %s: %s\n", s, r.Errors[s])
+		}
+	}
+	if r.Found > 0 {
+		b.WriteString("\nRun the tool again to rule out pods that were starting or stopping while it ran.\n")
+	}
+	return b.String()
+}
+
+// JSON returns the report with every collected value.
+func (r *Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,240 @@
+package orphans
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var fixedNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func ago(d time.Duration) string {
+	return fmt.Sprint(fixedNow.Add(-d).UnixNano())
+}
+
+const apiPods = `{"items": [
+  {"metadata": {"namespace": "app", "name": "web-1", "uid": "u1", "creationTimestamp": "2026-10-18T11:00:00Z"}, "status": {"phase": "Running"}},
+  {"metadata": {"namespace": "app", "name": "db-0", "uid": "u2-new", "creationTimestamp": "2026-10-18T11:00:00Z"}, "status": {"phase": "Running"}},
+  {"metadata": {"namespace": "app", "name": "starting", "uid": "u3", "creationTimestamp": "2026-10-18T11:59:30Z"}, "status": {"phase": "Pending"}},
+  {"metadata": {"namespace": "app", "name": "stuck", "uid": "u4", "creationTimestamp": "2026-10-18T11:00:00Z"}, "status": {"phase": "Pending"}},
+  {"metadata": {"namespace": "app", "name": "job-1", "uid": "u5", "creationTimestamp": "2026-10-18T11:00:00Z"}, "status": {"phase": "Succeeded"}},
+  {"metadata": {"namespace": "openshift-etcd", "name": "etcd-worker-0", "uid": "mirror", "creationTimestamp": "2026-10-18T11:00:00Z",
+    "annotations": {"kubernetes.io/config.mirror": "hash1"}}, "status": {"phase": "Running"}}
+]}`
+
+func sandboxes() string {
+	item := func(id, ns, name, uid, state string, age time.Duration) string {
+		return fmt.Sprintf(`{"id": %q, "metadata": {"name": %q, "namespace": %q, "uid": %q}, "state": %q, "createdAt": %q}`, id, name, ns, uid, state, ago(age))
+	}
+	return "Starting pod/worker-0-debug ...\n{\"items\": [\n" + strings.Join([]string{
+		item("s1", "app", "web-1", "u1", "SANDBOX_READY", time.Hour),
+		item("s2", "app", "db-0", "u2-old", "SANDBOX_NOTREADY", time.Hour),
+		item("s3", "openshift-etcd", "etcd-worker-0", "hash1", "SANDBOX_READY", time.Hour),
+		item("s4", "app", "gone", "u9", "SANDBOX_READY", time.Hour),
+		item("s5", "app", "new", "u10", "SANDBOX_READY", 10*time.Second),
+	}, ",\n") + "\n]}\nRemoving debug pod ...\n"
+}
+
+const containers = `{"containers": [
+  {"id": "c1", "podSandboxId": "s1", "metadata": {"name": "web"}, "state": "CONTAINER_RUNNING"},
+  {"id": "c2", "podSandboxId": "s2", "metadata": {"name": "db"}, "state": "CONTAINER_EXITED"},
+  {"id": "c3", "podSandboxId": "sX", "metadata": {"name": "lost"}, "state": "CONTAINER_RUNNING"},
+  {"id": "c4", "podSandboxId": "s3", "metadata": {"name": "etcd"}, "state": "CONTAINER_RUNNING"}
+]}`
+
+func inspect(id, netns string) string {
+	ns := `{"type": "pid"}`
+	if netns != "" {
+		ns += fmt.Sprintf(`, {"type": "network", "path": %q}`, netns)
+	}
+	return fmt.Sprintf(`{"status": {"id": %q}, "info": {"runtimeSpec": {"linux": {"namespaces": [%s]}}}}`, id, ns)
+}
+
+func debugOut() string {
+	conmon := func(pid, secs int, id string) string {
+		return fmt.Sprintf("%d %d conmon /usr/bin/conmon -b /run/containers/storage/overlay-containers/%s/userdata -c %s --exit-dir /var/run/crio/exits", pid, secs, id, id)
+	}
+	return strings.Join([]string{
+		"Starting pod/worker-0-debug ...",
+		"### inspect",
+		inspect("s1", "/var/run/netns/ns1"),
+		inspect("s2", "/var/run/netns/ns2"),
+		inspect("s3", ""),
+		inspect("s4", "/var/run/netns/ns4"),
+		inspect("s5", "/var/run/netns/ns5"),
+		"### processes",
+		conmon(100, 3600, "s1"),
+		conmon(101, 3600, "c1"),
+		conmon(102, 3600, "c2"),
+		conmon(103, 3600, "c3"),
+		conmon(104, 3600, "cZ"),
+		conmon(105, 30, "cY"),
+		"900 600 runc /usr/bin/runc --root /run/runc kill c3 KILL",
+		"901 3 runc /usr/bin/runc --root /run/runc state c1",
+		"### netns",
+		"ns1", "ns2", "ns3", "ns5",
+		"### end",
+		"",
+		"Removing debug pod ...",
+	}, "\n")
+}
+
+func fixture(t *testing.T) *Report {
+	t.Helper()
+	orig := now
+	t.Cleanup(func() { now = orig })
+	now = func() time.Time { return fixedNow }
+	r := New("worker-0")
+	if err := r.ParsePods([]byte(apiPods)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ParseSandboxes([]byte(sandboxes())); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ParseContainers([]byte(containers)); err != nil {
+		t.Fatal(err)
+	}
+	r.ParseDebug(debugOut())
+	return r
+}
+
+func TestParse(t *testing.T) {
+	r := fixture(t)
+	if len(r.Errors) != 0 {
+		t.Fatalf("unexpected errors %v", r.Errors)
+	}
+	if len(r.Pods) != 6 || r.Pods[5].UID != "hash1" {
+		t.Fatalf("unexpected pods %+v", r.Pods)
+	}
+	if len(r.Sandboxes) != 5 || r.Sandboxes[0].Netns != "/var/run/netns/ns1" || r.Sandboxes[2].Netns != "" || !r.Sandboxes[4].Created.Equal(fixedNow.Add(-10*time.Second)) {
+		t.Fatalf("unexpected sandboxes %+v", r.Sandboxes)
+	}
+	if len(r.Containers) != 4 || r.Containers[2].SandboxID != "sX" {
+		t.Fatalf("unexpected containers %+v", r.Containers)
+	}
+	if len(r.Processes) != 8 || r.Processes[1].Container != "c1" || r.Processes[6].Command != "runc" || r.Processes[6].Age() != 10*time.Minute {
+		t.Fatalf("unexpected processes %+v", r.Processes)
+	}
+	if strings.Join(r.Netns, ",") != "ns1,ns2,ns3,ns5" {
+		t.Fatalf("unexpected netns %v", r.Netns)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	r := New("worker-0")
+	if err := r.ParsePods([]byte("error: the server doesn't have a resource type")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := r.ParseSandboxes([]byte("FATA[0000] connect: connection refused")); err == nil {
+		t.Fatal("expected an error")
+	}
+	r.ParseDebug("### inspect\nFATA[0000] connect: connection refused\n### processes\n")
+	want := map[string]string{
+		SourceInspect: "crictl inspectp: FATA[0000] connect: connection refused",
+		SourceNetns:   "no output from the node",
+	}
+	for src, msg := range want {
+		if r.Errors[src] != msg {
+			t.Errorf("%s: got %q, want %q", src, r.Errors[src], msg)
+		}
+	}
+	if _, ok := r.Errors[SourceProcesses]; ok {
+		t.Fatalf("empty process list reported as an error: %v", r.Errors)
+	}
+
+	r = New("worker-0")
+	r.ParseDebug("### inspect\n### processes\n### netns\nls: cannot access '/var/run/netns': No such file or directory\n### end\n")
+	if len(r.Errors) != 0 || len(r.Netns) != 0 {
+		t.Fatalf("unexpected result %v %v", r.Errors, r.Netns)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	r := fixture(t)
+	r.Evaluate()
+	got := map[string][]string{}
+	for _, cl := range r.Classes {
+		if cl.Skipped {
+			t.Fatalf("class %s skipped", cl.Name)
+		}
+		for _, it := range cl.Items {
+			got[cl.Name] = append(got[cl.Name], it.String())
+		}
+	}
+	want := map[string][]string{
+		ClassPodWithoutSandbox:       {"u2-new app/db-0 Running", "u4 app/stuck Pending"},
+		ClassSandboxWithoutPod:       {"s2 app/db-0 SANDBOX_NOTREADY: left from an earlier pod of that name; the current one has uid u2-new", "s4 app/gone SANDBOX_READY"},
+		ClassContainerWithoutSandbox: {"c3 CONTAINER_RUNNING: container lost of sandbox sX"},
+		ClassConmonWithoutContainer:  {"cZ: conmon pid 104 running for 1h0m0s"},
+		ClassConmonOfExited:          {"c2 CONTAINER_EXITED: container db, conmon pid 102"},
+		ClassRunningWithoutConmon:    {"c4 CONTAINER_RUNNING: container etcd"},
+		ClassStuckRuntime:            {"900: running for 10m0s: /usr/bin/runc --root /run/runc kill c3 KILL"},
+		ClassNetnsWithoutSandbox:     {"ns3: /var/run/netns/ns3"},
+		ClassSandboxWithoutNetns:     {"s4 app/gone SANDBOX_READY: /var/run/netns/ns4 is missing"},
+	}
+	for name, items := range want {
+		if fmt.Sprint(got[name]) != fmt.Sprint(items) {
+			t.Errorf("%s: got %q, want %q", name, got[name], items)
+		}
+	}
+	if r.Found != 11 || len(r.Classes) != len(classes) {
+		t.Fatalf("found %d in %d classes", r.Found, len(r.Classes))
+	}
+}
+
+func TestEvaluateConmonrs(t *testing.T) {
+	r := New("worker-0")
+	r.Containers = []Container{{ID: "c1", SandboxID: "s1", Name: "web", State: "CONTAINER_RUNNING"}}
+	r.Processes = []Process{{PID: 1, Seconds: 600, Command: "conmonrs", Args: "/usr/bin/conmonrs --runtime /usr/bin/crun"}}
+	r.Evaluate()
+	for _, cl := range r.Classes {
+		if cl.Name == ClassRunningWithoutConmon && len(cl.Items) != 0 {
+			t.Fatalf("containers monitored by conmon-rs reported: %+v", cl.Items)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	r := New("worker-0")
+	r.Pods = []Pod{{Namespace: "app", Name: "web-1", UID: "u1", Phase: "Running"}}
+	r.Sandboxes = []Sandbox{{ID: "0123456789abcdef", Namespace: "app", Name: "old", UID: "u0", State: "SANDBOX_NOTREADY"}}
+	r.Fail(errors.New("oc debug failed: timeout"), SourceInspect, SourceProcesses, SourceNetns)
+	r.Evaluate()
+	want := `node worker-0: 2 inconsistencies
+api pods: 1, sandboxes: 1, containers: 0, runtime processes: 0, network namespaces: 0
+
+pods without sandbox (1):
+The kubelet expects these pods to run but CRI-O has no sandbox for them; check the kubelet journal for sandbox creation errors.
+- u1 app/web-1 Running
+
+sandboxes without pod (1):
+CRI-O keeps sandboxes for pods the API server no longer places on the node; the kubelet normally removes them, so a growing list points to a kubelet that lost track after a restart.
+- 0123456789abc app/old SANDBOX_NOTREADY
+
+none found: containers without sandbox
+
+not checked: conmon without container, conmon of exited container, running containers without conmon, long-running runtime processes, network namespaces without sandbox, sandboxes without network namespace
+- crictl inspectp: oc debug failed: timeout
+- network namespaces: oc debug failed: timeout
+- processes: oc debug failed: timeout
+
+Run the tool again to rule out pods that were starting or stopping while it ran.
+`
+	if got := r.Summary(); got != want {
+		t.Fatalf("unexpected summary\n%s\nwant\n%s", got, want)
+	}
+	js, err := r.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Report
+	if err := json.Unmarshal([]byte(js), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Found != 2 || len(back.Classes) != len(classes) || !back.Classes[3].Skipped || len(back.Errors) != 3 {
+		t.Fatalf("unexpected JSON %s", js)
+	}
+}
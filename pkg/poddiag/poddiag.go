@@ -0,0 +1,499 @@
+// Package poddiag works out at which stage a pod fails to start. It reads the
+// pod's events, the sandbox and containers CRI-O holds for it on its node,
+// the node journal and the logs of a failed container, and returns the
+// earliest stage that failed together with the lines that show it. Parsing
+// and classification are kept free of cluster access so they can be tested
+// with captured output.
+package poddiag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Stage is a step of starting a pod, in the order the kubelet and CRI-O
+// perform them.
+type Stage string
+
+const (
+	StageScheduling Stage = "scheduling"
+	StageVolumes    Stage = "volume mount"
+	StageSandbox    Stage = "sandbox creation"
+	StageCNI        Stage = "CNI"
+	StageImagePull  Stage = "image pull"
+	StageCreate     Stage = "container create"
+	StageStart      Stage = "container start"
+	StageExit       Stage = "runtime exit"
+	// StageNone means no failure was found.
+	StageNone Stage = "none"
+)
+
+// hints tell the reader where to look next for each stage.
+var hints = map[Stage]string{
+	StageScheduling: "Check the FailedScheduling message for taints, node selectors, affinity and resource requests.",
+	StageVolumes:    "Check the PVC, its storage class and the CSI driver pods on the node, and that referenced secrets and config maps exist.",
+	StageSandbox:    "Read the CRI-O journal on the node around the time of the failure, and run the runtime-health runbook there.",
+	StageCNI:        "Check the network operator and the multus and OVN-Kubernetes pods on the node; the CNI error names the failing plugin.",
+	StageImagePull:  "Check the image reference, the pull secret of the pod's service account, registry mirrors and network access from the node.",
+	StageCreate:     "Check the container spec: referenced config maps and secrets, the entrypoint, security context and mounts.",
+	StageStart:      "Check the entrypoint and the container's security context; the runtime error names the failing call.",
+	StageExit:       "Read the container's logs and exit code; 137 with OOMKilled means the memory limit was reached.",
+	StageNone:       "The sandbox is ready and the containers are running. If the pod is still not Ready, check its readiness probe.",
+}
+
+// Event is a Kubernetes event about the pod.
+type Event struct {
+	Type    string
+	Reason  string
+	Message string
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s %s: %s", e.Type, e.Reason, e.Message)
+}
+
+// ParseEvents returns the events about pod in namespace from the output of
+// "oc get events -A", oldest first.
+func ParseEvents(out, namespace, pod string) []Event {
+	var events []Event
+	object := "pod/" + pod
+	for _, line := range strings.Split(out, "\n") {
+		// NAMESPACE LAST-SEEN TYPE REASON OBJECT MESSAGE
+		f := strings.Fields(line)
+		if len(f) < 6 || f[0] != namespace || f[4] != object {
+			continue
+		}
+		events = append(events, Event{Type: f[2], Reason: f[3], Message: strings.Join(f[5:], " ")})
+	}
+	return events
+}
+
+// assignedRE matches the message of a Scheduled event.
+var assignedRE = regexp.MustCompile(`Successfully assigned \S+ to (\S+)`)
+
+// ScheduledNode returns the node named by the pod's Scheduled event.
+func ScheduledNode(events []Event) string {
+	node := ""
+	for _, e := range events {
+		if m := assignedRE.FindStringSubmatch(e.Message); e.Reason == "Scheduled" && m != nil {
+			node = m[1]
+		}
+	}
+	return node
+}
+
+// Sandbox is a pod sandbox as listed by "crictl pods -o json".
+type Sandbox struct {
+	ID        string
+	Name      string
+	Namespace string
+	State     string
+	CreatedAt int64
+}
+
+// ParseSandboxes parses "crictl pods -o json" and returns the sandboxes of
+// pod in namespace, newest first. crictl matches --name as a regular
+// expression, so other pods are dropped here.
+func ParseSandboxes(out []byte, namespace, pod string) ([]Sandbox, error) {
+	var doc struct {
+		Items []struct {
+			ID       string `json:"id"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			State     string `json:"state"`
+			CreatedAt string `json:"createdAt"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(jsonPart(out), &doc); err != nil {
+		return nil, fmt.Errorf("decoding crictl pods: %w", err)
+	}
+	var sbs []Sandbox
+	for _, it := range doc.Items {
+		if it.Metadata.Name != pod || it.Metadata.Namespace != namespace {
+			continue
+		}
+		created, _ := strconv.ParseInt(it.CreatedAt, 10, 64)
+		sbs = append(sbs, Sandbox{ID: it.ID, Name: it.Metadata.Name, Namespace: it.Metadata.Namespace, State: it.State, CreatedAt: created})
+	}
+	sort.SliceStable(sbs, func(i, j int) bool { return sbs[i].CreatedAt > sbs[j].CreatedAt })
+	return sbs, nil
+}
+
+// Container is a container of the sandbox with the status reported by
+// "crictl inspect".
+type Container struct {
+	ID       string
+	Name     string
+	Attempt  int
+	State    string
+	ExitCode int
+	Reason   string
+	Message  string
+}
+
+func (c Container) String() string {
+	s := fmt.Sprintf("container %s (attempt %d) %s", c.Name, c.Attempt, c.State)
+	if c.State == "CONTAINER_EXITED" {
+		s += fmt.Sprintf(" exit code %d", c.ExitCode)
+	}
+	if c.Reason != "" {
+		s += " reason " + c.Reason
+	}
+	if c.Message != "" {
+		s += ": " + c.Message
+	}
+	return s
+}
+
+// ParseContainers parses "crictl ps -a -o json". When a container was
+// restarted only its latest attempt is kept.
+func ParseContainers(out []byte) ([]Container, error) {
+	var doc struct {
+		Containers []struct {
+			ID       string `json:"id"`
+			Metadata struct {
+				Name    string `json:"name"`
+				Attempt int    `json:"attempt"`
+			} `json:"metadata"`
+			State string `json:"state"`
+		} `json:"containers"`
+	}
+	if err := json.Unmarshal(jsonPart(out), &doc); err != nil {
+		return nil, fmt.Errorf("decoding crictl ps: %w", err)
+	}
+	latest := map[string]int{}
+	var cs []Container
+	for _, c := range doc.Containers {
+		ctr := Container{ID: c.ID, Name: c.Metadata.Name, Attempt: c.Metadata.Attempt, State: c.State}
+		if i, ok := latest[ctr.Name]; ok {
+			if cs[i].Attempt < ctr.Attempt {
+				cs[i] = ctr
+			}
+			continue
+		}
+		latest[ctr.Name] = len(cs)
+		cs = append(cs, ctr)
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+	return cs, nil
+}
+
+// ParseInspect fills c's exit code, reason and message from the output of
+// "crictl inspect".
+func ParseInspect(out []byte, c *Container) error {
+	var doc struct {
+		Status struct {
+			State    string `json:"state"`
+			ExitCode int    `json:"exitCode"`
+			Reason   string `json:"reason"`
+			Message  string `json:"message"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(jsonPart(out), &doc); err != nil {
+		return fmt.Errorf("decoding crictl inspect: %w", err)
+	}
+	if doc.Status.State != "" {
+		c.State = doc.Status.State
+	}
+	c.ExitCode = doc.Status.ExitCode
+	c.Reason = doc.Status.Reason
+	c.Message = strings.TrimSpace(doc.Status.Message)
+	return nil
+}
+
+// jsonPart drops what oc debug prints around the command's output, such as
+// "Starting pod/..." and "Removing debug pod ...".
+func jsonPart(out []byte) []byte {
+	start := bytes.IndexByte(out, '{')
+	end := bytes.LastIndexByte(out, '}')
+	if start < 0 || end < start {
+		return out
+	}
+	return out[start : end+1]
+}
+
+// journalRE matches CRI-O and kubelet log lines worth reporting.
+var journalRE = regexp.MustCompile(`level=(?:error|warning)|\b[EW]\d{4} |(?i)\b(?:error|failed)\b`)
+
+// maxJournalLines caps the journal lines reported as evidence.
+const maxJournalLines = 10
+
+// JournalLines returns the last error and warning lines of the node journal
+// that mention any of keys, such as the sandbox ID or "namespace/pod".
+func JournalLines(out string, keys ...string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if !journalRE.MatchString(line) {
+			continue
+		}
+		for _, k := range keys {
+			if k != "" && strings.Contains(line, k) {
+				lines = append(lines, strings.TrimSpace(line))
+				break
+			}
+		}
+	}
+	if len(lines) > maxJournalLines {
+		lines = lines[len(lines)-maxJournalLines:]
+	}
+	return lines
+}
+
+// Input is what was collected about the pod.
+type Input struct {
+	Namespace string
+	Pod       string
+	Node      string
+	Events    []Event
+	// Sandbox is the newest sandbox of the pod, or nil when CRI-O has none.
+	Sandbox    *Sandbox
+	Containers []Container
+	Journal    []string
+	// Logs are the last lines of the failed container's logs.
+	Logs []string
+	// Errors lists what could not be collected.
+	Errors []string
+}
+
+// Evidence is a line supporting the verdict.
+type Evidence struct {
+	// Source is where the line came from: event, crictl, journal or logs.
+	Source string
+	Text   string
+}
+
+// Verdict is the result of a diagnosis.
+type Verdict struct {
+	Input
+	Stage   Stage
+	Summary string
+	// Evidence is ordered from the most to the least specific.
+	Evidence []Evidence
+}
+
+// Patterns recognizing each stage in event messages and runtime errors.
+var (
+	cniRE      = regexp.MustCompile(`(?i)\bCNI\b|network plugin|multus|ovn|add(?:ing)? pod .* to (?:CNI )?network|failed to (?:setup|set up) network|NetworkPlugin`)
+	pullRE     = regexp.MustCompile(`(?i)failed to pull image|back-off pulling image|ErrImagePull|ImagePullBackOff|InvalidImageName|ErrImageNeverPull`)
+	createRE   = regexp.MustCompile(`(?i)CreateContainerError|CreateContainerConfigError|container create failed|failed to create container|couldn't find key|not found`)
+	startRE    = regexp.MustCompile(`(?i)StartError|ContainerCannotRun|failed to start container|starting container process caused`)
+	backOffRE  = regexp.MustCompile(`(?i)back-off restarting failed container`)
+	mountRE    = regexp.MustCompile(`^Failed(?:Mount|AttachVolume|Map)$`)
+	sandboxRE  = regexp.MustCompile(`^FailedCreatePodSandBox$|^FailedKillPod$`)
+	scheduleRE = regexp.MustCompile(`^FailedScheduling$`)
+)
+
+// Diagnose returns the earliest stage at which the pod failed.
+func Diagnose(in Input) Verdict {
+	v := Verdict{Input: in}
+	warnings := func(match func(Event) bool) []Event {
+		var out []Event
+		for _, e := range in.Events {
+			if e.Type != "Normal" && match(e) {
+				out = append(out, e)
+			}
+		}
+		return out
+	}
+	addEvents := func(es []Event) {
+		for _, e := range es {
+			v.Evidence = append(v.Evidence, Evidence{"event", e.String()})
+		}
+	}
+	addJournal := func() {
+		for _, l := range in.Journal {
+			v.Evidence = append(v.Evidence, Evidence{"journal", l})
+		}
+	}
+	addContainers := func(match func(Container) bool) {
+		for _, c := range in.Containers {
+			if match(c) {
+				v.Evidence = append(v.Evidence, Evidence{"crictl", c.String()})
+			}
+		}
+	}
+	ready := in.Sandbox != nil && in.Sandbox.State == "SANDBOX_READY"
+
+	if in.Node == "" {
+		v.Stage = StageScheduling
+		es := warnings(func(e Event) bool { return scheduleRE.MatchString(e.Reason) })
+		v.Summary = "The pod has not been scheduled to a node."
+		if len(es) > 0 {
+			v.Summary = "The scheduler cannot place the pod: " + es[len(es)-1].Message
+		} else if len(in.Events) == 0 {
+			v.Summary += " No events were found for it; they may have expired."
+		}
+		addEvents(es)
+		return v.done()
+	}
+
+	if !ready {
+		if es := warnings(func(e Event) bool { return mountRE.MatchString(e.Reason) }); len(es) > 0 {
+			v.Stage = StageVolumes
+			v.Summary = "The kubelet cannot mount the pod's volumes, so it does not create the sandbox: " + es[len(es)-1].Message
+			addEvents(es)
+			return v.done()
+		}
+		es := warnings(func(e Event) bool { return sandboxRE.MatchString(e.Reason) })
+		cni := false
+		for _, e := range es {
+			cni = cni || cniRE.MatchString(e.Message)
+		}
+		for _, l := range in.Journal {
+			cni = cni || cniRE.MatchString(l)
+		}
+		v.Stage = StageSandbox
+		if cni {
+			v.Stage = StageCNI
+		}
+		switch {
+		case len(es) > 0 && cni:
+			v.Summary = "The pod network could not be set up for the sandbox: " + es[len(es)-1].Message
+		case len(es) > 0:
+			v.Summary = "CRI-O could not create the pod sandbox: " + es[len(es)-1].Message
+		case cni:
+			v.Summary = "The CRI-O journal shows CNI errors for the pod's sandbox."
+		case in.Sandbox != nil:
+			v.Summary = fmt.Sprintf("The pod's sandbox %s is %s.", short(in.Sandbox.ID), in.Sandbox.State)
+		default:
+			v.Summary = fmt.Sprintf("CRI-O on %s has no sandbox for the pod and no sandbox errors were reported; the kubelet may not have asked for one yet.", in.Node)
+		}
+		addEvents(es)
+		addJournal()
+		return v.done()
+	}
+
+	if es := warnings(func(e Event) bool { return pullRE.MatchString(e.Message) || pullRE.MatchString(e.Reason) }); len(es) > 0 {
+		v.Stage = StageImagePull
+		v.Summary = "An image could not be pulled: " + es[0].Message
+		addEvents(es)
+		addJournal()
+		return v.done()
+	}
+
+	if es := warnings(func(e Event) bool {
+		return e.Reason == "Failed" && createRE.MatchString(e.Message) && !startRE.MatchString(e.Message)
+	}); len(es) > 0 {
+		v.Stage = StageCreate
+		v.Summary = "A container could not be created: " + es[len(es)-1].Message
+		addEvents(es)
+		addJournal()
+		return v.done()
+	}
+
+	startFailed := func(c Container) bool {
+		return startRE.MatchString(c.Reason) || startRE.MatchString(c.Message) || c.State == "CONTAINER_CREATED"
+	}
+	es := warnings(func(e Event) bool { return startRE.MatchString(e.Message) || startRE.MatchString(e.Reason) })
+	if len(es) > 0 || anyContainer(in.Containers, startFailed) {
+		v.Stage = StageStart
+		v.Summary = "A container was created but could not be started."
+		if len(es) > 0 {
+			v.Summary = "A container could not be started: " + es[len(es)-1].Message
+		}
+		addContainers(startFailed)
+		addEvents(es)
+		addJournal()
+		return v.done()
+	}
+
+	exited := func(c Container) bool {
+		return c.State == "CONTAINER_EXITED" && (c.ExitCode != 0 || c.Reason == "OOMKilled")
+	}
+	backOff := warnings(func(e Event) bool { return backOffRE.MatchString(e.Message) })
+	for _, c := range in.Containers {
+		if !exited(c) {
+			continue
+		}
+		v.Stage = StageExit
+		v.Summary = fmt.Sprintf("Container %s exited with code %d", c.Name, c.ExitCode)
+		if c.Reason != "" {
+			v.Summary += " (" + c.Reason + ")"
+		}
+		v.Summary += "."
+		addContainers(exited)
+		addEvents(backOff)
+		for _, l := range in.Logs {
+			v.Evidence = append(v.Evidence, Evidence{"logs", l})
+		}
+		return v.done()
+	}
+	if len(backOff) > 0 {
+		v.Stage = StageExit
+		v.Summary = "The kubelet is backing off restarting a failed container."
+		addEvents(backOff)
+		return v.done()
+	}
+
+	v.Stage = StageNone
+	v.Summary = fmt.Sprintf("No failure found: sandbox %s is ready", short(in.Sandbox.ID))
+	if len(in.Containers) > 0 {
+		v.Summary += " and its containers are running or completed"
+	}
+	v.Summary += "."
+	addContainers(func(Container) bool { return true })
+	return v.done()
+}
+
+// done adds the state of the sandbox to the evidence of a failure.
+func (v Verdict) done() Verdict {
+	if v.Sandbox != nil && v.Stage != StageNone {
+		v.Evidence = append(v.Evidence, Evidence{"crictl", fmt.Sprintf("sandbox %s %s", short(v.Sandbox.ID), v.Sandbox.State)})
+	}
+	return v
+}
+
+// anyContainer reports whether match holds for any of cs.
+func anyContainer(cs []Container, match func(Container) bool) bool {
+	for _, c := range cs {
+		if match(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// short returns the 13 character prefix crictl prints for IDs.
+func short(id string) string {
+	if len(id) > 13 {
+		return id[:13]
+	}
+	return id
+}
+
+// Format renders the verdict for a tool result.
+func (v Verdict) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pod: %s/%s\n", v.Namespace, v.Pod)
+	node := v.Node
+	if node == "" {
+		node = "(not scheduled)"
+	}
+	fmt.Fprintf(&b, "node: %s\n", node)
+	if v.Sandbox != nil {
+		fmt.Fprintf(&b, "sandbox: %s %s\n", short(v.Sandbox.ID), v.Sandbox.State)
+	}
+	fmt.Fprintf(&b, "stage: %s\n", v.Stage)
+	fmt.Fprintf(&b, "verdict: %s\n", v.Summary)
+	if len(v.Evidence) > 0 {
+		b.WriteString("evidence:\n")
+		for _, e := range v.Evidence {
+			fmt.Fprintf(&b, "- [%s] %s\n", e.Source, e.Text)
+		}
+	}
+	fmt.Fprintf(&b, "next: %s\n", hints[v.Stage])
+	if len(v.Errors) > 0 {
+		b.WriteString("not collected, the verdict may be incomplete:\n")
+		for _, e := range v.Errors {
+			fmt.Fprintf(&b, "- %s\n", e)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,207 @@
+package poddiag
+
+import (
+	"strings"
+	"testing"
+)
+
+const events = `NAMESPACE   LAST SEEN   TYPE      REASON                   OBJECT        MESSAGE
+app         5m          Normal    Scheduled                pod/web-1     Successfully assigned app/web-1 to worker-0
+app         4m          Warning   FailedCreatePodSandBox   pod/web-1     Failed to create pod sandbox: rpc error: code = Unknown desc = failed to create pod network sandbox k8s_web-1_app_1234_0(abc): error adding pod app_web-1 to CNI network "multus-cni-network": plugin type="multus" failed (add): timed out
+app         4m          Warning   BackOff                  pod/web-10    Back-off restarting failed container
+other       1m          Warning   FailedMount              pod/web-1     MountVolume.SetUp failed
+`
+
+func TestParseEvents(t *testing.T) {
+	es := ParseEvents(events, "app", "web-1")
+	if len(es) != 2 || es[1].Reason != "FailedCreatePodSandBox" || !strings.HasSuffix(es[1].Message, "failed (add): timed out") {
+		t.Fatalf("unexpected events %+v", es)
+	}
+	if n := ScheduledNode(es); n != "worker-0" {
+		t.Fatalf("unexpected node %q", n)
+	}
+}
+
+func TestParseSandboxes(t *testing.T) {
+	out := `Starting pod/worker-0-debug ...
+{
+  "items": [
+    {"id": "old0000000000000", "metadata": {"name": "web-1", "namespace": "app"}, "state": "SANDBOX_NOTREADY", "createdAt": "1700000000000000000"},
+    {"id": "other00000000000", "metadata": {"name": "web-10", "namespace": "app"}, "state": "SANDBOX_READY", "createdAt": "1800000000000000000"},
+    {"id": "new0000000000000", "metadata": {"name": "web-1", "namespace": "app"}, "state": "SANDBOX_READY", "createdAt": "1700000001000000000"}
+  ]
+}
+Removing debug pod ...`
+	sbs, err := ParseSandboxes([]byte(out), "app", "web-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbs) != 2 || sbs[0].ID != "new0000000000000" || sbs[0].State != "SANDBOX_READY" {
+		t.Fatalf("unexpected sandboxes %+v", sbs)
+	}
+	if _, err := ParseSandboxes([]byte("error: no pods"), "app", "web-1"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestParseContainersAndInspect(t *testing.T) {
+	out := `{"containers": [
+  {"id": "c1", "metadata": {"name": "web", "attempt": 1}, "state": "CONTAINER_EXITED"},
+  {"id": "c2", "metadata": {"name": "web", "attempt": 2}, "state": "CONTAINER_EXITED"},
+  {"id": "c3", "metadata": {"name": "sidecar", "attempt": 0}, "state": "CONTAINER_RUNNING"}
+]}`
+	cs, err := ParseContainers([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 2 || cs[0].Name != "sidecar" || cs[1].ID != "c2" {
+		t.Fatalf("unexpected containers %+v", cs)
+	}
+	err = ParseInspect([]byte(`{"status": {"state": "CONTAINER_EXITED", "exitCode": 137, "reason": "OOMKilled", "message": ""}}`), &cs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cs[1].String(); got != "container web (attempt 2) CONTAINER_EXITED exit code 137 reason OOMKilled" {
+		t.Fatalf("unexpected container %q", got)
+	}
+}
+
+func TestJournalLines(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`Jan 01 crio[1]: time="x" level=info msg="Running pod sandbox: app/web-1/POD"` + "\n")
+	b.WriteString(`Jan 01 crio[1]: time="x" level=error msg="Error adding network for app/web-1"` + "\n")
+	b.WriteString(`Jan 01 kubelet[2]: E0101 pod_workers.go:1 "Error syncing pod" pod="other/db-0"` + "\n")
+	for i := 0; i < 12; i++ {
+		b.WriteString(`Jan 01 kubelet[2]: E0101 kuberuntime_manager.go:1 "CreatePodSandbox failed" podSandboxID="abc123"` + "\n")
+	}
+	lines := JournalLines(b.String(), "app/web-1", "abc123")
+	if len(lines) != maxJournalLines || !strings.Contains(lines[0], "CreatePodSandbox") {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	lines = JournalLines(b.String(), "app/web-1")
+	if len(lines) != 1 || !strings.Contains(lines[0], "Error adding network") {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	ready := &Sandbox{ID: "0123456789abcdef", State: "SANDBOX_READY"}
+	notReady := &Sandbox{ID: "0123456789abcdef", State: "SANDBOX_NOTREADY"}
+	warn := func(reason, msg string) Event { return Event{Type: "Warning", Reason: reason, Message: msg} }
+	for _, tc := range []struct {
+		name    string
+		in      Input
+		stage   Stage
+		summary string
+	}{
+		{
+			name:    "unscheduled",
+			in:      Input{Events: []Event{warn("FailedScheduling", "0/3 nodes are available: 3 Insufficient memory.")}},
+			stage:   StageScheduling,
+			summary: "The scheduler cannot place the pod: 0/3 nodes are available: 3 Insufficient memory.",
+		},
+		{
+			name:    "no events",
+			in:      Input{},
+			stage:   StageScheduling,
+			summary: "they may have expired",
+		},
+		{
+			name:    "volume",
+			in:      Input{Node: "n1", Events: []Event{warn("FailedMount", `MountVolume.SetUp failed for volume "cfg" : configmap "cfg" not found`)}},
+			stage:   StageVolumes,
+			summary: `configmap "cfg" not found`,
+		},
+		{
+			name:    "cni",
+			in:      Input{Node: "n1", Events: ParseEvents(events, "app", "web-1")},
+			stage:   StageCNI,
+			summary: "The pod network could not be set up for the sandbox: Failed to create pod sandbox",
+		},
+		{
+			name:    "sandbox",
+			in:      Input{Node: "n1", Events: []Event{warn("FailedCreatePodSandBox", "Failed to create pod sandbox: rpc error: code = Unknown desc = error reserving pod name")}},
+			stage:   StageSandbox,
+			summary: "CRI-O could not create the pod sandbox: Failed to create pod sandbox: rpc error",
+		},
+		{
+			name:    "cni from journal",
+			in:      Input{Node: "n1", Sandbox: notReady, Journal: []string{`level=error msg="Error adding network: failed to send CNI request"`}},
+			stage:   StageCNI,
+			summary: "The CRI-O journal shows CNI errors",
+		},
+		{
+			name:    "no sandbox",
+			in:      Input{Node: "n1"},
+			stage:   StageSandbox,
+			summary: "CRI-O on n1 has no sandbox for the pod",
+		},
+		{
+			name:    "image pull",
+			in:      Input{Node: "n1", Sandbox: ready, Events: []Event{warn("Failed", `Failed to pull image "quay.io/app:v2": manifest unknown`), warn("Failed", "Error: ErrImagePull")}},
+			stage:   StageImagePull,
+			summary: `An image could not be pulled: Failed to pull image "quay.io/app:v2": manifest unknown`,
+		},
+		{
+			name:    "create",
+			in:      Input{Node: "n1", Sandbox: ready, Events: []Event{warn("Failed", `Error: container create failed: exec: "/app": stat /app: no such file or directory`)}},
+			stage:   StageCreate,
+			summary: "A container could not be created: Error: container create failed",
+		},
+		{
+			name:    "start",
+			in:      Input{Node: "n1", Sandbox: ready, Containers: []Container{{Name: "web", State: "CONTAINER_CREATED"}}},
+			stage:   StageStart,
+			summary: "A container was created but could not be started.",
+		},
+		{
+			name:    "exit",
+			in:      Input{Node: "n1", Sandbox: ready, Containers: []Container{{Name: "init", State: "CONTAINER_EXITED"}, {Name: "web", State: "CONTAINER_EXITED", ExitCode: 137, Reason: "OOMKilled"}}, Logs: []string{"web: killed"}},
+			stage:   StageExit,
+			summary: "Container web exited with code 137 (OOMKilled).",
+		},
+		{
+			name:    "running",
+			in:      Input{Node: "n1", Sandbox: ready, Containers: []Container{{Name: "web", State: "CONTAINER_RUNNING"}}},
+			stage:   StageNone,
+			summary: "No failure found: sandbox 0123456789abc is ready and its containers are running or completed.",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := Diagnose(tc.in)
+			if v.Stage != tc.stage || !strings.Contains(v.Summary, tc.summary) {
+				t.Fatalf("got %s %q, want %s %q", v.Stage, v.Summary, tc.stage, tc.summary)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	v := Diagnose(Input{
+		Namespace:  "app",
+		Pod:        "web-1",
+		Node:       "n1",
+		Sandbox:    &Sandbox{ID: "0123456789abcdef", State: "SANDBOX_READY"},
+		Containers: []Container{{Name: "web", Attempt: 3, State: "CONTAINER_EXITED", ExitCode: 1, Reason: "Error"}},
+		Events:     []Event{{Type: "Warning", Reason: "BackOff", Message: "Back-off restarting failed container web in pod web-1"}},
+		Logs:       []string{"web: panic: boom"},
+		Errors:     []string{"node journal: timeout"},
+	})
+	want := `pod: app/web-1
+node: n1
+sandbox: 0123456789abc SANDBOX_READY
+stage: runtime exit
+verdict: Container web exited with code 1 (Error).
+evidence:
+- [crictl] container web (attempt 3) CONTAINER_EXITED exit code 1 reason Error
+- [event] Warning BackOff: Back-off restarting failed container web in pod web-1
+- [logs] web: panic: boom
+- [crictl] sandbox 0123456789abc SANDBOX_READY
+next: Read the container's logs and exit code; 137 with OOMKilled means the memory limit was reached.
+not collected, the verdict may be incomplete:
+- node journal: timeout
+`
+	if got := v.Format(); got != want {
+		t.Fatalf("unexpected format\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,37 @@
+package prompts
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ServerPrompt returns p in the form registered with an MCP server. The
+// prompt renders to a single user message.
+func (p *Prompt) ServerPrompt() server.ServerPrompt {
+	opts := []mcp.PromptOption{mcp.WithPromptDescription(p.Description)}
+	for _, a := range p.Arguments {
+		argOpts := []mcp.ArgumentOption{mcp.ArgumentDescription(a.Description)}
+		if a.Required {
+			argOpts = append(argOpts, mcp.RequiredArgument())
+		}
+		opts = append(opts, mcp.WithArgument(a.Name, argOpts...))
+	}
+	return server.ServerPrompt{
+		Prompt: mcp.NewPrompt(p.Name, opts...),
+		Handler: func(_ context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			text, err := p.Render(req.Params.Arguments)
+			if err != nil {
+				return nil, err
+			}
+			description := p.Title
+			if description == "" {
+				description = p.Description
+			}
+			return mcp.NewGetPromptResult(description, []mcp.PromptMessage{
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+			}), nil
+		},
+	}
+}
@@ -0,0 +1,120 @@
+// Package prompts provides MCP prompts that walk a model through common
+// container runtime investigations with this server's tools. Each prompt is a
+// Markdown file with a YAML front matter block naming the prompt and its
+// arguments; the body is a text/template rendered with the arguments. The
+// built-in prompts are embedded, and teams can add their own from a directory.
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/harche/crio-mcp-server/pkg/catalog"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed builtin/*.md
+var builtin embed.FS
+
+// Argument is a parameter of a prompt.
+type Argument struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
+}
+
+// Prompt is a parsed prompt file.
+type Prompt struct {
+	Name        string     `yaml:"name"`
+	Title       string     `yaml:"title"`
+	Description string     `yaml:"description"`
+	Arguments   []Argument `yaml:"arguments"`
+
+	body *template.Template
+}
+
+// frontMatter separates the YAML header from the template body.
+const frontMatter = "---\n"
+
+// Parse reads a prompt file. file is only used in error messages.
+func Parse(file string, data []byte) (*Prompt, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if !strings.HasPrefix(text, frontMatter) {
+		return nil, fmt.Errorf("%s: missing front matter", file)
+	}
+	header, body, ok := strings.Cut(text[len(frontMatter):], "\n"+frontMatter)
+	if !ok {
+		return nil, fmt.Errorf("%s: unterminated front matter", file)
+	}
+	dec := yaml.NewDecoder(strings.NewReader(header))
+	dec.KnownFields(true)
+	var p Prompt
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if p.Name == "" {
+		return nil, fmt.Errorf("%s: name required", file)
+	}
+	seen := map[string]bool{}
+	for _, a := range p.Arguments {
+		if a.Name == "" || seen[a.Name] {
+			return nil, fmt.Errorf("%s: argument names must be unique and non-empty", file)
+		}
+		seen[a.Name] = true
+	}
+	// Optional arguments that were not given render as empty strings.
+	t, err := template.New(p.Name).Option("missingkey=zero").Parse(strings.TrimSpace(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	p.body = t
+	return &p, nil
+}
+
+// Render returns the prompt text for args. Required arguments must be set.
+func (p *Prompt) Render(args map[string]string) (string, error) {
+	for _, a := range p.Arguments {
+		if a.Required && args[a.Name] == "" {
+			return "", fmt.Errorf("prompt %s: argument %s is required", p.Name, a.Name)
+		}
+	}
+	if args == nil {
+		args = map[string]string{}
+	}
+	var b bytes.Buffer
+	if err := p.body.Execute(&b, args); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	return b.String(), nil
+}
+
+// loader reads prompt files.
+var loader = catalog.Loader[*Prompt]{
+	Kind:  "prompt",
+	Exts:  []string{".md"},
+	Parse: Parse,
+	Name:  func(p *Prompt) string { return p.Name },
+}
+
+// Builtin returns the embedded prompts.
+func Builtin() []*Prompt {
+	return loader.Builtin(builtin, "builtin")
+}
+
+// Load reads the prompts in dir.
+func Load(dir string) ([]*Prompt, error) {
+	return loader.Load(dir)
+}
+
+// DirEnv names a directory of additional prompt files.
+const DirEnv = "CRIO_MCP_PROMPTS_DIR"
+
+// FromEnv returns the built-in prompts together with those in the directory
+// named by CRIO_MCP_PROMPTS_DIR, sorted by name. A file with the name of a
+// built-in prompt replaces it.
+func FromEnv() ([]*Prompt, error) {
+	return loader.FromEnv(Builtin(), DirEnv)
+}
@@ -0,0 +1,97 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBuiltin(t *testing.T) {
+	want := []string{"crashloop-no-logs", "crio-high-cpu", "image-pull-failure", "node-notready-pleg", "pod-stuck-container-creating"}
+	ps := Builtin()
+	if len(ps) != len(want) {
+		t.Fatalf("got %d prompts, want %d", len(ps), len(want))
+	}
+	tool := regexp.MustCompile("`([a-z_]+)`")
+	for i, p := range ps {
+		if p.Name != want[i] || p.Title == "" || p.Description == "" {
+			t.Errorf("unexpected prompt %+v", p)
+		}
+		args := map[string]string{}
+		for _, a := range p.Arguments {
+			args[a.Name] = "ARG-" + a.Name
+		}
+		text, err := p.Render(args)
+		if err != nil {
+			t.Fatalf("%s: %v", p.Name, err)
+		}
+		for name := range args {
+			if !strings.Contains(text, "ARG-"+name) {
+				t.Errorf("%s: argument %s not used", p.Name, name)
+			}
+		}
+		if strings.Contains(text, "<no value>") || len(tool.FindAllString(text, -1)) < 3 {
+			t.Errorf("%s: unexpected text:\n%s", p.Name, text)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	p, err := Parse("test.md", []byte("---\nname: t\narguments:\n  - name: pod\n    required: true\n  - name: node\n---\nCheck {{.pod}}{{if .node}} on {{.node}}{{end}}.\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.Render(nil); err == nil || !strings.Contains(err.Error(), "argument pod is required") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, _ := p.Render(map[string]string{"pod": "web-0"}); got != "Check web-0." {
+		t.Fatalf("unexpected text %q", got)
+	}
+	if got, _ := p.Render(map[string]string{"pod": "web-0", "node": "n1"}); got != "Check web-0 on n1." {
+		t.Fatalf("unexpected text %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, data := range []string{
+		"no front matter",
+		"---\nname: t\n",
+		"---\ntitle: missing name\n---\nbody",
+		"---\nname: t\nargs: []\n---\nbody",
+		"---\nname: t\narguments:\n  - name: a\n  - name: a\n---\nbody",
+		"---\nname: t\n---\n{{.unclosed",
+	} {
+		if _, err := Parse("bad.md", []byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "etcd.md"), []byte("---\nname: etcd-slow\ntitle: Slow etcd\n---\nCheck etcd."), 0o600)
+	os.WriteFile(filepath.Join(dir, "pleg.md"), []byte("---\nname: node-notready-pleg\n---\nOur own PLEG runbook."), 0o600)
+	os.WriteFile(filepath.Join(dir, "README.txt"), []byte("ignored"), 0o600)
+	t.Setenv(DirEnv, dir)
+	ps, err := FromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	byName := map[string]*Prompt{}
+	for _, p := range ps {
+		byName[p.Name] = p
+	}
+	if len(ps) != 6 || byName["etcd-slow"] == nil {
+		t.Fatalf("unexpected prompts %d", len(ps))
+	}
+	if text, _ := byName["node-notready-pleg"].Render(nil); text != "Our own PLEG runbook." {
+		t.Fatalf("built-in not replaced: %q", text)
+	}
+
+	os.WriteFile(filepath.Join(dir, "dup.md"), []byte("---\nname: etcd-slow\n---\nagain"), 0o600)
+	if _, err := FromEnv(); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+package redact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// sniffLen is how much of a file is inspected to decide whether it is text.
+const sniffLen = 8192
+
+// isText reports whether data looks like text rather than a binary file,
+// which is left alone because rewriting it could corrupt it. Text with
+// invalid UTF-8, common in journal output, is still treated as text.
+func isText(data []byte) bool {
+	head := data
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	return bytes.IndexByte(head, 0) < 0
+}
+
+// Bytes redacts data if it is text and returns it unchanged otherwise.
+func (r *Redactor) Bytes(data []byte) ([]byte, Report) {
+	rep := Report{}
+	if !isText(data) {
+		return data, rep
+	}
+	// Data is only copied into a string once a rule matches, so that large
+	// output without secrets is scanned without being copied.
+	var s string
+	matched := false
+	for _, rule := range r.rules {
+		if !matched {
+			if !rule.RE.Match(data) {
+				continue
+			}
+			s, matched = string(data), true
+		}
+		s = apply(rule, s, rep)
+	}
+	if rep.Total() == 0 {
+		return data, rep
+	}
+	return []byte(s), rep
+}
+
+// blockSize is how much text a Writer gathers before redacting it.
+const blockSize = 1 << 20
+
+// maxHold limits how much text a Writer keeps back while it waits for the
+// end of a private key.
+const maxHold = 4 * blockSize
+
+// Writer redacts text written to it and passes it on to the underlying
+// writer, so that output too large to hold in memory can be redacted as it
+// is streamed. Text is redacted in blocks of whole lines, and a private key
+// is kept in one block, so only a custom pattern spanning more than a block
+// can be missed. Output whose start contains a NUL byte is binary and passed
+// on unchanged.
+type Writer struct {
+	r       *Redactor
+	w       io.Writer
+	buf     []byte
+	sniffed bool
+	binary  bool
+	rep     Report
+}
+
+// NewWriter returns a Writer that redacts text on its way to w. Close must be
+// called to write the text still held back.
+func (r *Redactor) NewWriter(w io.Writer) *Writer {
+	return &Writer{r: r, w: w, rep: Report{}}
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.binary {
+		return w.w.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if !w.sniffed && len(w.buf) >= sniffLen {
+		if err := w.sniff(); err != nil || w.binary {
+			return len(p), err
+		}
+	}
+	for len(w.buf) >= blockSize {
+		n := w.cut()
+		if n == 0 {
+			break
+		}
+		if err := w.emit(n); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// sniff decides from the start of the output whether it is binary, and if so
+// passes on what was gathered so far.
+func (w *Writer) sniff() error {
+	w.sniffed = true
+	if isText(w.buf) {
+		return nil
+	}
+	w.binary = true
+	_, err := w.w.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// cut returns how much of the buffer can be redacted now: everything up to
+// the last complete line, but not the start of a private key whose end has
+// not been written yet. It returns 0 when the buffer should grow first.
+func (w *Writer) cut() int {
+	n := bytes.LastIndexByte(w.buf, '\n') + 1
+	if i := bytes.LastIndex(w.buf[:n], []byte("-----BEGIN ")); i >= 0 && !bytes.Contains(w.buf[i:], []byte("-----END ")) {
+		n = i
+	}
+	if n == 0 && len(w.buf) >= maxHold {
+		n = len(w.buf)
+	}
+	return n
+}
+
+// emit redacts the first n bytes of the buffer and writes them out.
+func (w *Writer) emit(n int) error {
+	out, rep := w.r.Bytes(w.buf[:n])
+	w.rep.Add(rep)
+	// out may share the buffer, so it is written before the buffer shifts.
+	_, err := w.w.Write(out)
+	w.buf = append(w.buf[:0], w.buf[n:]...)
+	return err
+}
+
+// Close redacts and writes the remaining text. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if !w.sniffed {
+		if err := w.sniff(); err != nil || w.binary {
+			return err
+		}
+	}
+	if w.binary || len(w.buf) == 0 {
+		return nil
+	}
+	return w.emit(len(w.buf))
+}
+
+// Report returns what has been redacted so far.
+func (w *Writer) Report() Report {
+	return w.rep
+}
+
+// memberBuffer is the largest archive member redacted in memory; larger
+// members are redacted into a temporary file, since the tar header that
+// precedes them must give their redacted size.
+const memberBuffer = 8 << 20
+
+// TarGz copies the gzip-compressed tar archive read from src to dst with
+// every text file redacted. Binary files, directories and links are copied
+// unchanged.
+func (r *Redactor) TarGz(dst io.Writer, src io.Reader) (Report, error) {
+	zr, err := gzip.NewReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	tr := tar.NewReader(zr)
+	zw := gzip.NewWriter(dst)
+	tw := tar.NewWriter(zw)
+	rep := Report{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			if err := tw.WriteHeader(hdr); err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		body, fileRep, err := r.member(tr, hdr.Size)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", hdr.Name, err)
+		}
+		rep.Add(fileRep)
+		hdr.Size = body.Size()
+		if err := tw.WriteHeader(hdr); err != nil {
+			body.Close()
+			return nil, err
+		}
+		_, err = io.Copy(tw, body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// redactedMember is the redacted content of an archive member.
+type redactedMember interface {
+	io.ReadCloser
+	Size() int64
+}
+
+type memberBytes struct{ *bytes.Reader }
+
+func (memberBytes) Close() error { return nil }
+
+type memberFile struct {
+	*os.File
+	size int64
+}
+
+func (f memberFile) Size() int64 { return f.size }
+
+func (f memberFile) Close() error {
+	f.File.Close()
+	return os.Remove(f.Name())
+}
+
+// member redacts an archive member of the given size read from src.
+func (r *Redactor) member(src io.Reader, size int64) (redactedMember, Report, error) {
+	if size <= memberBuffer {
+		data, err := io.ReadAll(src)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, rep := r.Bytes(data)
+		return memberBytes{bytes.NewReader(data)}, rep, nil
+	}
+	f, err := os.CreateTemp("", "crio-mcp-redact-")
+	if err != nil {
+		return nil, nil, err
+	}
+	m := memberFile{File: f}
+	w := r.NewWriter(f)
+	if _, err := io.Copy(w, src); err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	if m.size, err = f.Seek(0, io.SeekCurrent); err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	return m, w.Report(), nil
+}
+
+// ArchiveWriter redacts a gzip-compressed tar archive with TarGz as it is
+// written, for archives streamed from a node to an artifact.
+type ArchiveWriter struct {
+	pw   *io.PipeWriter
+	done chan archiveResult
+}
+
+type archiveResult struct {
+	rep Report
+	err error
+}
+
+// NewArchiveWriter returns an ArchiveWriter that writes the redacted archive
+// to dst. Close must be called to finish it.
+func (r *Redactor) NewArchiveWriter(dst io.Writer) *ArchiveWriter {
+	pr, pw := io.Pipe()
+	aw := &ArchiveWriter{pw: pw, done: make(chan archiveResult, 1)}
+	go func() {
+		rep, err := r.TarGz(dst, pr)
+		if err == nil {
+			// The padding after the end of the archive is not needed.
+			io.Copy(io.Discard, pr)
+		}
+		// A failed rewrite fails further writes instead of blocking them.
+		pr.CloseWithError(err)
+		aw.done <- archiveResult{rep, err}
+	}()
+	return aw
+}
+
+// Write implements io.Writer.
+func (aw *ArchiveWriter) Write(p []byte) (int, error) {
+	return aw.pw.Write(p)
+}
+
+// Close ends the archive, waits until it has been redacted and returns what
+// was removed.
+func (aw *ArchiveWriter) Close() (Report, error) {
+	aw.pw.Close()
+	res := <-aw.done
+	return res.rep, res.err
+}
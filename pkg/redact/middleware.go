@@ -0,0 +1,91 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Result redacts the text and embedded resources of res in place and returns
+// what was removed. Blob resources are dropped rather than returned
+// unredacted.
+func (r *Redactor) Result(res *mcp.CallToolResult) Report {
+	rep := Report{}
+	if res == nil {
+		return rep
+	}
+	for i, c := range res.Content {
+		switch c := c.(type) {
+		case mcp.TextContent:
+			var n Report
+			c.Text, n = r.String(c.Text)
+			rep.Add(n)
+			res.Content[i] = c
+		case mcp.EmbeddedResource:
+			var n Report
+			c.Resource, n = r.resource(c.Resource)
+			rep.Add(n)
+			res.Content[i] = c
+		}
+	}
+	return rep
+}
+
+// resource redacts a single embedded resource.
+func (r *Redactor) resource(rc mcp.ResourceContents) (mcp.ResourceContents, Report) {
+	switch rc := rc.(type) {
+	case mcp.TextResourceContents:
+		var rep Report
+		rc.Text, rep = r.String(rc.Text)
+		return rc, rep
+	case *mcp.TextResourceContents:
+		out, rep := r.resource(*rc)
+		return out, rep
+	case mcp.BlobResourceContents:
+		return withheld(rc.URI), Report{}
+	case *mcp.BlobResourceContents:
+		return withheld(rc.URI), Report{}
+	}
+	return rc, Report{}
+}
+
+// withheld replaces a blob resource, which is not scanned for secrets, with a
+// note. Tools save binary output as artifacts instead of returning it.
+func withheld(uri string) mcp.ResourceContents {
+	return mcp.TextResourceContents{
+		URI:      uri,
+		MIMEType: "text/plain",
+		Text:     "resource withheld: binary resources are not scanned for secrets",
+	}
+}
+
+// Middleware returns a tool handler middleware that redacts every result and,
+// when something was removed, appends a note saying how many items were
+// redacted so the model knows the output is incomplete.
+func Middleware(r *Redactor) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			res, err := next(ctx, req)
+			if res == nil {
+				return res, err
+			}
+			if rep := r.Result(res); rep.Total() > 0 {
+				res.Content = append(res.Content, mcp.NewTextContent("["+rep.String()+"]"))
+			}
+			return res, err
+		}
+	}
+}
+
+// Unavailable returns a middleware that rejects every call with err. It is
+// used when custom redaction patterns cannot be loaded, so output is never
+// returned with less redaction than the operator configured.
+func Unavailable(err error) server.ToolHandlerMiddleware {
+	return func(server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultError(fmt.Sprintf("redaction unavailable: %v", err)), nil
+		}
+	}
+}
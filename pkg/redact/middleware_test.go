@@ -0,0 +1,64 @@
+package redact
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestMiddleware(t *testing.T) {
+	h := Middleware(New())(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		res := mcp.NewToolResultResource("collected files", mcp.TextResourceContents{
+			URI:      "kubeconfig",
+			MIMEType: "text/plain",
+			Text:     "token: " + jwt,
+		})
+		res.Content = append(res.Content, mcp.NewTextContent("password=hunter2"))
+		return res, nil
+	})
+	res, err := h(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Content) != 4 {
+		t.Fatalf("expected redaction note, got %+v", res.Content)
+	}
+	if text := res.Content[2].(mcp.TextContent).Text; text != "password=[REDACTED:password]" {
+		t.Fatalf("text not redacted: %q", text)
+	}
+	note := res.Content[3].(mcp.TextContent).Text
+	if note != "[redacted 2 secrets (jwt: 1, password: 1)]" {
+		t.Fatalf("unexpected note %q", note)
+	}
+	if text := res.Content[1].(mcp.EmbeddedResource).Resource.(mcp.TextResourceContents).Text; strings.Contains(text, jwt) {
+		t.Fatalf("resource not redacted: %q", text)
+	}
+}
+
+func TestMiddlewareWithholdsBlobs(t *testing.T) {
+	res := mcp.NewToolResultResource("files", mcp.BlobResourceContents{
+		URI:      "files.tar.gz",
+		MIMEType: "application/tar+gzip",
+		Blob:     base64.StdEncoding.EncodeToString([]byte("password=hunter2")),
+	})
+	New().Result(res)
+	rc, ok := res.Content[1].(mcp.EmbeddedResource).Resource.(mcp.TextResourceContents)
+	if !ok || !strings.HasPrefix(rc.Text, "resource withheld") {
+		t.Fatalf("blob returned: %+v", res.Content[1])
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	h := Unavailable(errors.New("bad pattern"))(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		t.Fatal("handler called")
+		return nil, nil
+	})
+	res, _ := h(context.Background(), mcp.CallToolRequest{})
+	if !res.IsError {
+		t.Fatalf("expected error result, got %+v", res)
+	}
+}
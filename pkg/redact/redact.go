@@ -0,0 +1,174 @@
+// Package redact removes credentials from tool output before it is returned
+// to the model. Node configuration, debug shells, pod logs and must-gather
+// data routinely contain bearer tokens, kubeconfig keys, pull-secret auths and
+// passwords, none of which should end up in a transcript.
+package redact
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule is a named secret detector. If RE has a group named "secret" only
+// that part of a match is replaced, so surrounding keys such as "password="
+// stay readable; otherwise the whole match is replaced.
+type Rule struct {
+	Name string
+	RE   *regexp.Regexp
+}
+
+// Builtin are the detectors every Redactor starts with. The order matters:
+// private keys and JWTs are removed before the broader key/value rules see
+// them.
+var Builtin = []Rule{
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{"kubeconfig-key", regexp.MustCompile(`(?i)\bclient-key(?:-data)?"?\s*:\s*"?(?P<secret>[A-Za-z0-9+/=._-]{16,})`)},
+	{"openshift-token", regexp.MustCompile(`\bsha256~[A-Za-z0-9_-]{40,}`)},
+	{"bearer-token", regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{16,}=*)`)},
+	{"dockerconfig", regexp.MustCompile(`\.dockerconfigjson"?\s*:\s*"?(?P<secret>[A-Za-z0-9+/]{16,}={0,2})`)},
+	{"dockerconfig-auth", regexp.MustCompile(`"(?:auth|identitytoken|registrytoken)"\s*:\s*"(?P<secret>[^"]+)"`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws_?secret_?access_?key"?\s*[=:]\s*"?(?P<secret>[A-Za-z0-9/+=]{40})`)},
+	{"password", regexp.MustCompile(`(?i)(?:password|passwd)"?\s*[=:]\s*(?P<secret>"[^"\n]+"|'[^'\n]+'|[^\s"',;&]+)`)},
+}
+
+// mask returns the replacement for a secret found by rule name.
+func mask(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// Redactor applies a list of rules to text.
+type Redactor struct {
+	rules []Rule
+}
+
+// New returns a Redactor using the built-in rules followed by extra.
+func New(extra ...Rule) *Redactor {
+	rules := append(append([]Rule{}, Builtin...), extra...)
+	return &Redactor{rules: rules}
+}
+
+// PatternsFileEnv names a file of additional redaction regexes.
+const PatternsFileEnv = "CRIO_MCP_REDACT_PATTERNS_FILE"
+
+// FromEnv returns a Redactor with the built-in rules plus one rule per line of
+// the file named by CRIO_MCP_REDACT_PATTERNS_FILE. Blank lines and lines
+// starting with # are ignored.
+func FromEnv() (*Redactor, error) {
+	path := os.Getenv(PatternsFileEnv)
+	if path == "" {
+		return New(), nil
+	}
+	extra, err := LoadPatterns(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(extra...), nil
+}
+
+// LoadPatterns reads a file of regexes, one per line, into rules named
+// "custom".
+func LoadPatterns(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read redaction patterns: %w", err)
+	}
+	defer f.Close()
+	var rules []Rule
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rules = append(rules, Rule{Name: "custom", RE: re})
+	}
+	return rules, sc.Err()
+}
+
+// Report counts redactions per rule.
+type Report map[string]int
+
+// Total returns the number of redacted items.
+func (r Report) Total() int {
+	n := 0
+	for _, c := range r {
+		n += c
+	}
+	return n
+}
+
+// Add merges o into r.
+func (r Report) Add(o Report) {
+	for k, v := range o {
+		r[k] += v
+	}
+}
+
+// String summarizes the report, e.g. "redacted 3 secrets (jwt: 2, password: 1)".
+func (r Report) String() string {
+	total := r.Total()
+	if total == 0 {
+		return "no secrets redacted"
+	}
+	names := make([]string, 0, len(r))
+	for k := range r {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, k := range names {
+		parts[i] = fmt.Sprintf("%s: %d", k, r[k])
+	}
+	noun := "secrets"
+	if total == 1 {
+		noun = "secret"
+	}
+	return fmt.Sprintf("redacted %d %s (%s)", total, noun, strings.Join(parts, ", "))
+}
+
+// String returns s with every detected secret replaced and reports what was
+// removed.
+func (r *Redactor) String(s string) (string, Report) {
+	rep := Report{}
+	for _, rule := range r.rules {
+		s = apply(rule, s, rep)
+	}
+	return s, rep
+}
+
+// apply replaces the matches of rule in s, counting them in rep.
+func apply(rule Rule, s string, rep Report) string {
+	matches := rule.RE.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	group := rule.RE.SubexpIndex("secret")
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if group > 0 && m[2*group] >= 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		// A value that an earlier rule already replaced is not counted twice.
+		if start == end || strings.HasPrefix(strings.Trim(s[start:end], `"'`), "[REDACTED") {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(mask(rule.Name))
+		last = end
+		rep[rule.Name]++
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
@@ -0,0 +1,329 @@
+package redhat
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultChunkSize is the upload chunk size used when the server does not
+// request one.
+const DefaultChunkSize = 64 << 20
+
+// UploadResult describes a completed attachment upload.
+type UploadResult struct {
+	CaseNumber string
+	FileName   string
+	Size       int64
+	SHA256     string
+	// Resumed is true when part of the file had already been uploaded by an
+	// earlier attempt.
+	Resumed bool
+}
+
+// uploadState is persisted next to the file being uploaded so an interrupted
+// transfer can continue where it stopped.
+type uploadState struct {
+	CaseNumber string `json:"caseNumber"`
+	UploadURL  string `json:"uploadUrl"`
+	ChunkSize  int64  `json:"chunkSize"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+}
+
+func statePath(path string) string {
+	return path + ".upload.json"
+}
+
+// UploadAttachment attaches the file at path to a support case using the
+// default client.
+func UploadAttachment(ctx context.Context, offlineToken, caseNumber, path string) (*UploadResult, error) {
+	return Default().UploadAttachment(ctx, offlineToken, caseNumber, path)
+}
+
+// UploadAttachment attaches the file at path to a support case. An empty
+// offlineToken uses the configured offline token.
+//
+// By default the file is streamed from disk as multipart/form-data to
+// {CaseAPIBase}/cases/{case}/attachments, the attachment endpoint documented
+// for the Case Management API. The upload is a single request, so
+// Config.Timeout must cover the whole transfer.
+//
+// When Config.ResumableUploads is set, the file is sent with a resumable
+// protocol instead: a POST to {CaseAPIBase}/cases/{case}/attachments/uploads
+// opens an upload session and returns its URL, then the file is sent in
+// chunks using PUT requests with a Content-Range header. The server answers
+// 308 with a Range header while the upload is incomplete and 200 or 201 once
+// the last chunk is stored. The session is recorded in <path>.upload.json; if
+// a previous attempt was interrupted, the committed offset is queried with an
+// empty PUT carrying "Content-Range: bytes */<size>" and the upload continues
+// from there. This protocol is not part of the documented Case Management
+// API; enable it only for an endpoint, such as an upload gateway, known to
+// implement it.
+func (c *Client) UploadAttachment(ctx context.Context, offlineToken, caseNumber, path string) (*UploadResult, error) {
+	if caseNumber == "" {
+		return nil, fmt.Errorf("case number required")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	sum, err := fileSHA256(f)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.tokenFor(ctx, offlineToken)
+	if err != nil {
+		return nil, err
+	}
+	res := &UploadResult{CaseNumber: caseNumber, FileName: filepath.Base(path), Size: fi.Size(), SHA256: sum}
+	if !c.cfg.ResumableUploads {
+		if err := c.postAttachment(ctx, token, caseNumber, f, res); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	st, offset := c.resumeState(ctx, token, path, caseNumber, fi.Size(), sum)
+	if st == nil {
+		st, err = c.startUpload(ctx, token, caseNumber, res)
+		if err != nil {
+			return nil, err
+		}
+		if err := saveState(path, st); err != nil {
+			return nil, err
+		}
+	} else {
+		res.Resumed = offset > 0
+	}
+
+	for offset < fi.Size() {
+		end := offset + st.ChunkSize
+		if end > fi.Size() {
+			end = fi.Size()
+		}
+		next, done, err := c.putChunk(ctx, token, st.UploadURL, io.NewSectionReader(f, offset, end-offset), offset, end, fi.Size())
+		if err != nil {
+			return nil, fmt.Errorf("upload failed at byte %d (rerun to resume): %w", offset, err)
+		}
+		if done {
+			break
+		}
+		if next <= offset {
+			return nil, fmt.Errorf("upload made no progress at byte %d: the server committed up to byte %d", offset, next)
+		}
+		offset = next
+	}
+	os.Remove(statePath(path))
+	return res, nil
+}
+
+// postAttachment streams f as the "file" field of a multipart/form-data POST
+// to the case's attachments endpoint. The body is produced on the fly, and
+// again from the start if the request is retried.
+func (c *Client) postAttachment(ctx context.Context, token, caseNumber string, f *os.File, res *UploadResult) error {
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	body := func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			mw := multipart.NewWriter(pw)
+			err := mw.SetBoundary(boundary)
+			var part io.Writer
+			if err == nil {
+				part, err = mw.CreateFormFile("file", res.FileName)
+			}
+			if err == nil {
+				_, err = io.Copy(part, io.NewSectionReader(f, 0, res.Size))
+			}
+			if err == nil {
+				err = mw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+	endpoint := c.cfg.CaseAPIBase + "/cases/" + url.PathEscape(caseNumber) + "/attachments"
+	rc, _ := body()
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, rc)
+	if err != nil {
+		rc.Close()
+		return err
+	}
+	req.GetBody = body
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("attachment upload failed: %s: %s", resp.Status, data)
+	}
+	return nil
+}
+
+func fileSHA256(f *os.File) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resumeState loads a saved upload session for path and asks the server how
+// much of it has been committed. It returns nil if there is nothing to resume.
+func (c *Client) resumeState(ctx context.Context, token, path, caseNumber string, size int64, sum string) (*uploadState, int64) {
+	data, err := os.ReadFile(statePath(path))
+	if err != nil {
+		return nil, 0
+	}
+	var st uploadState
+	if json.Unmarshal(data, &st) != nil || st.CaseNumber != caseNumber || st.Size != size || st.SHA256 != sum || st.UploadURL == "" {
+		return nil, 0
+	}
+	next, done, err := c.putChunk(ctx, token, st.UploadURL, nil, 0, 0, size)
+	if err != nil {
+		return nil, 0
+	}
+	if done {
+		return &st, size
+	}
+	return &st, next
+}
+
+func saveState(path string, st *uploadState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath(path), data, 0o600)
+}
+
+func (c *Client) startUpload(ctx context.Context, token, caseNumber string, res *UploadResult) (*uploadState, error) {
+	body, err := json.Marshal(map[string]any{
+		"fileName": res.FileName,
+		"size":     res.Size,
+		"checksum": "sha256:" + res.SHA256,
+	})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.cfg.CaseAPIBase + "/cases/" + url.PathEscape(caseNumber) + "/attachments/uploads"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("upload request failed: %s: %s", resp.Status, data)
+	}
+	var session struct {
+		UploadURL string `json:"uploadUrl"`
+		ChunkSize int64  `json:"chunkSize"`
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	if session.UploadURL == "" {
+		session.UploadURL = resp.Header.Get("Location")
+	}
+	if session.UploadURL == "" {
+		return nil, fmt.Errorf("upload session URL not found")
+	}
+	if session.ChunkSize <= 0 {
+		session.ChunkSize = DefaultChunkSize
+	}
+	return &uploadState{
+		CaseNumber: caseNumber,
+		UploadURL:  session.UploadURL,
+		ChunkSize:  session.ChunkSize,
+		Size:       res.Size,
+		SHA256:     res.SHA256,
+	}, nil
+}
+
+// putChunk sends bytes [start, end) of a file of the given size. A nil body
+// queries the committed offset instead. It returns the next offset to send
+// and whether the upload is complete.
+func (c *Client) putChunk(ctx context.Context, token, uploadURL string, body io.Reader, start, end, size int64) (int64, bool, error) {
+	contentRange := fmt.Sprintf("bytes */%d", size)
+	if body != nil {
+		contentRange = fmt.Sprintf("bytes %d-%d/%d", start, end-1, size)
+	} else {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, body)
+	if err != nil {
+		return 0, false, err
+	}
+	req.ContentLength = end - start
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", contentRange)
+	resp, err := c.send(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return size, true, nil
+	case http.StatusPermanentRedirect:
+		next, err := committedOffset(resp.Header.Get("Range"))
+		if err != nil || next > size {
+			return 0, false, fmt.Errorf("chunk upload failed: invalid Range %q in %s response", resp.Header.Get("Range"), resp.Status)
+		}
+		return next, false, nil
+	default:
+		return 0, false, fmt.Errorf("chunk upload failed: %s: %s", resp.Status, data)
+	}
+}
+
+// committedOffset parses a "bytes=0-N" Range header into the next offset. A
+// missing header means nothing has been committed yet.
+func committedOffset(r string) (int64, error) {
+	if r == "" {
+		return 0, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimPrefix(r, "bytes="), "-")
+	if !ok || first != "0" {
+		return 0, fmt.Errorf("invalid range %q", r)
+	}
+	n, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid range %q", r)
+	}
+	return n + 1, nil
+}
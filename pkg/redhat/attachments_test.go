@@ -0,0 +1,193 @@
+package redhat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// caseStandIn emulates the attachment endpoints of the Case Management API
+// and the resumable upload session protocol.
+type caseStandIn struct {
+	mu       sync.Mutex
+	received []byte
+	failAt   int64
+	puts     int
+	name     string
+	// stall makes the session answer 308 without committing anything.
+	stall bool
+}
+
+func (c *caseStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case r.URL.Path == "/token":
+		fmt.Fprint(w, `{"access_token":"tok"}`)
+	case r.Method == "POST" && r.URL.Path == "/cases/0123/attachments":
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f, hdr, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c.received, _ = io.ReadAll(f)
+		c.name = hdr.Filename
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "POST" && r.URL.Path == "/cases/0123/attachments/uploads":
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"uploadUrl":"http://%s/session/1","chunkSize":4}`, r.Host)
+	case r.Method == "PUT" && r.URL.Path == "/session/1":
+		c.puts++
+		var start, end, size int64
+		cr := r.Header.Get("Content-Range")
+		if _, err := fmt.Sscanf(cr, "bytes */%d", &size); err == nil {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(c.received)-1))
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &start, &end, &size); err != nil || start != int64(len(c.received)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if c.stall {
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		if c.failAt > 0 && start >= c.failAt {
+			c.failAt = 0
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		c.received = append(c.received, data...)
+		if int64(len(c.received)) == size {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(c.received)-1))
+		w.WriteHeader(http.StatusPermanentRedirect)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newStandInClient returns a client whose endpoints all point at h.
+func newStandInClient(t *testing.T, h http.Handler) *Client {
+	return newStandInClientConfig(t, h, Config{})
+}
+
+// newStandInClientConfig is newStandInClient with further settings in cfg.
+func newStandInClientConfig(t *testing.T, h http.Handler, cfg Config) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	cfg.APIBase = srv.URL
+	cfg.CaseAPIBase = srv.URL
+	cfg.SSOTokenURL = srv.URL + "/token"
+	cfg.MaxRetries = -1
+	c, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestUploadAttachmentResume(t *testing.T) {
+	standIn := &caseStandIn{failAt: 8}
+	c := newStandInClientConfig(t, standIn, Config{ResumableUploads: true})
+	path := filepath.Join(t.TempDir(), "sosreport.tar.xz")
+	content := "0123456789abcdefXYZ"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := c.UploadAttachment(context.Background(), "off", "0123", path)
+	if err == nil || !strings.Contains(err.Error(), "rerun to resume") {
+		t.Fatalf("expected interrupted upload, got %v", err)
+	}
+	if _, err := os.Stat(statePath(path)); err != nil {
+		t.Fatalf("upload state not saved: %v", err)
+	}
+
+	res, err := c.UploadAttachment(context.Background(), "off", "0123", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.Resumed || res.Size != int64(len(content)) || res.FileName != "sosreport.tar.xz" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if string(standIn.received) != content {
+		t.Fatalf("unexpected upload %q", standIn.received)
+	}
+	if _, err := os.Stat(statePath(path)); !os.IsNotExist(err) {
+		t.Fatalf("upload state not removed: %v", err)
+	}
+}
+
+func TestUploadAttachment(t *testing.T) {
+	standIn := &caseStandIn{}
+	c := newStandInClient(t, standIn)
+	path := filepath.Join(t.TempDir(), "must-gather.tar.gz")
+	content := "0123456789abcdefXYZ"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.UploadAttachment(context.Background(), "off", "0123", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Resumed || res.Size != int64(len(content)) || standIn.name != "must-gather.tar.gz" || string(standIn.received) != content {
+		t.Fatalf("unexpected result %+v, received %q as %q", res, standIn.received, standIn.name)
+	}
+	if _, err := os.Stat(statePath(path)); !os.IsNotExist(err) {
+		t.Fatalf("unexpected upload state: %v", err)
+	}
+}
+
+func TestUploadAttachmentNoProgress(t *testing.T) {
+	standIn := &caseStandIn{stall: true}
+	c := newStandInClientConfig(t, standIn, Config{ResumableUploads: true})
+	path := filepath.Join(t.TempDir(), "sosreport.tar.xz")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.UploadAttachment(context.Background(), "off", "0123", path)
+	if err == nil || !strings.Contains(err.Error(), "no progress at byte 0") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if standIn.puts != 1 {
+		t.Fatalf("stalled session retried %d times", standIn.puts)
+	}
+}
+
+func TestCommittedOffset(t *testing.T) {
+	for r, want := range map[string]int64{"": 0, "bytes=0-0": 1, "bytes=0-99": 100} {
+		if got, err := committedOffset(r); err != nil || got != want {
+			t.Errorf("%q: got %d, %v", r, got, err)
+		}
+	}
+	for _, r := range []string{"bytes=5-9", "bytes=0-x", "garbage", "bytes=0--2"} {
+		if _, err := committedOffset(r); err == nil {
+			t.Errorf("%q accepted", r)
+		}
+	}
+}
+
+func TestUploadAttachmentRequiresCase(t *testing.T) {
+	if _, err := UploadAttachment(context.Background(), "off", "", "/nonexistent"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,322 @@
+package redhat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrCaseCommentsDisabled is returned by AddCaseComment unless the client was
+// configured with AllowCaseComments.
+var ErrCaseCommentsDisabled = errors.New("adding case comments is disabled; set REDHAT_ALLOW_CASE_COMMENTS=true to enable it")
+
+// CaseSeverities are the severities accepted by the Case Management API.
+var CaseSeverities = []string{"1 (Urgent)", "2 (High)", "3 (Normal)", "4 (Low)"}
+
+// Case is a Red Hat support case.
+type Case struct {
+	CaseNumber       string        `json:"caseNumber"`
+	Summary          string        `json:"summary"`
+	Description      string        `json:"description"`
+	Status           string        `json:"status"`
+	Severity         string        `json:"severity"`
+	Product          string        `json:"product"`
+	Version          string        `json:"version"`
+	ContactName      string        `json:"contactName"`
+	CreatedDate      string        `json:"createdDate"`
+	LastModifiedDate string        `json:"lastModifiedDate"`
+	Comments         []CaseComment `json:"-"`
+}
+
+// CaseComment is a comment on a support case.
+type CaseComment struct {
+	ID          string `json:"id"`
+	CreatedBy   string `json:"createdBy"`
+	CreatedDate string `json:"createdDate"`
+	Body        string `json:"commentBody"`
+	Public      bool   `json:"isPublic"`
+}
+
+// CaseFilter selects the cases returned by ListCases.
+type CaseFilter struct {
+	// Status limits results to cases in this status, e.g. "Waiting on Red Hat".
+	Status string
+	// Keyword matches the case summary and description.
+	Keyword       string
+	IncludeClosed bool
+	MaxResults    int
+}
+
+// NewCase describes a case to open.
+type NewCase struct {
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Product     string `json:"product"`
+	Version     string `json:"version,omitempty"`
+	Severity    string `json:"severity,omitempty"`
+}
+
+// ListCases returns the cases visible to the account using the default client.
+func ListCases(ctx context.Context, filter CaseFilter, offlineToken string) ([]Case, error) {
+	return Default().ListCases(ctx, filter, offlineToken)
+}
+
+// GetCase returns a case and its comments using the default client.
+func GetCase(ctx context.Context, caseNumber, offlineToken string) (*Case, error) {
+	return Default().GetCase(ctx, caseNumber, offlineToken)
+}
+
+// AddCaseComment posts a comment to a case using the default client.
+func AddCaseComment(ctx context.Context, caseNumber, body, offlineToken string) (*CaseComment, error) {
+	return Default().AddCaseComment(ctx, caseNumber, body, offlineToken)
+}
+
+// CreateCase opens a new support case using the default client.
+func CreateCase(ctx context.Context, nc NewCase, offlineToken string) (*Case, error) {
+	return Default().CreateCase(ctx, nc, offlineToken)
+}
+
+// ListCases returns the cases visible to the account, most recently modified
+// first as ordered by the API.
+func (c *Client) ListCases(ctx context.Context, filter CaseFilter, offlineToken string) ([]Case, error) {
+	token, err := c.tokenFor(ctx, offlineToken)
+	if err != nil {
+		return nil, err
+	}
+	if filter.MaxResults <= 0 {
+		filter.MaxResults = 20
+	}
+	query := map[string]any{
+		"maxResults":    filter.MaxResults,
+		"includeClosed": filter.IncludeClosed,
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Keyword != "" {
+		query["keyword"] = filter.Keyword
+	}
+	// The filter endpoint only reads, so it is safe to retry.
+	data, _, err := c.sendJSON(ctx, "POST", c.cfg.CaseAPIBase+"/cases/filter", token, "case list", query, true)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Cases []Case `json:"cases"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("decode case list: %w", err)
+	}
+	return result.Cases, nil
+}
+
+// GetCase returns a case and its comments.
+func (c *Client) GetCase(ctx context.Context, caseNumber, offlineToken string) (*Case, error) {
+	if caseNumber == "" {
+		return nil, fmt.Errorf("case number required")
+	}
+	token, err := c.tokenFor(ctx, offlineToken)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.cfg.CaseAPIBase + "/cases/" + url.PathEscape(caseNumber)
+	data, err := c.get(ctx, endpoint, token, "case")
+	if err != nil {
+		return nil, err
+	}
+	var cs Case
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return nil, fmt.Errorf("decode case: %w", err)
+	}
+	data, err = c.get(ctx, endpoint+"/comments", token, "case comments")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cs.Comments); err != nil {
+		return nil, fmt.Errorf("decode case comments: %w", err)
+	}
+	sort.SliceStable(cs.Comments, func(i, j int) bool {
+		return cs.Comments[i].CreatedDate < cs.Comments[j].CreatedDate
+	})
+	if cs.CaseNumber == "" {
+		cs.CaseNumber = caseNumber
+	}
+	return &cs, nil
+}
+
+// AddCaseComment posts a public comment to a case. It fails with
+// ErrCaseCommentsDisabled unless the client was configured with
+// AllowCaseComments, so that an agent cannot write to a customer-visible case
+// without the operator opting in.
+func (c *Client) AddCaseComment(ctx context.Context, caseNumber, body, offlineToken string) (*CaseComment, error) {
+	if !c.cfg.AllowCaseComments {
+		return nil, ErrCaseCommentsDisabled
+	}
+	if caseNumber == "" {
+		return nil, fmt.Errorf("case number required")
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("comment body required")
+	}
+	token, err := c.tokenFor(ctx, offlineToken)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.cfg.CaseAPIBase + "/cases/" + url.PathEscape(caseNumber) + "/comments"
+	data, hdr, err := c.sendJSON(ctx, "POST", endpoint, token, "case comment", map[string]any{
+		"commentBody": body,
+		"isPublic":    true,
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	comment := CaseComment{Body: body, Public: true}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &comment); err != nil {
+			return nil, fmt.Errorf("decode case comment: %w", err)
+		}
+	}
+	if comment.ID == "" {
+		comment.ID = locationID(hdr)
+	}
+	return &comment, nil
+}
+
+// CreateCase opens a new support case and returns it with the assigned case
+// number.
+func (c *Client) CreateCase(ctx context.Context, nc NewCase, offlineToken string) (*Case, error) {
+	if nc.Summary == "" || nc.Description == "" || nc.Product == "" {
+		return nil, fmt.Errorf("summary, description and product are required")
+	}
+	token, err := c.tokenFor(ctx, offlineToken)
+	if err != nil {
+		return nil, err
+	}
+	data, hdr, err := c.sendJSON(ctx, "POST", c.cfg.CaseAPIBase+"/cases", token, "case creation", nc, false)
+	if err != nil {
+		return nil, err
+	}
+	cs := Case{
+		Summary:     nc.Summary,
+		Description: nc.Description,
+		Product:     nc.Product,
+		Version:     nc.Version,
+		Severity:    nc.Severity,
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &cs); err != nil {
+			return nil, fmt.Errorf("decode case: %w", err)
+		}
+	}
+	if cs.CaseNumber == "" {
+		cs.CaseNumber = locationID(hdr)
+	}
+	if cs.CaseNumber == "" {
+		return nil, fmt.Errorf("case created but no case number was returned")
+	}
+	return &cs, nil
+}
+
+// locationID returns the last path element of a Location header, which the
+// Case Management API uses to identify created resources.
+func locationID(hdr http.Header) string {
+	loc := hdr.Get("Location")
+	if loc == "" {
+		return ""
+	}
+	if u, err := url.Parse(loc); err == nil {
+		loc = u.Path
+	}
+	return path.Base(strings.TrimSuffix(loc, "/"))
+}
+
+// sendJSON sends in as a JSON body and returns the body and headers of a 200
+// or 201 response. Requests that create resources pass retry=false: a retried
+// POST after a lost response could otherwise create the resource twice.
+func (c *Client) sendJSON(ctx context.Context, method, endpoint, token, what string, in any, retry bool) ([]byte, http.Header, error) {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !retry {
+		req.GetBody = nil
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, nil, fmt.Errorf("%s request failed: %s: %s", what, resp.Status, bytes.TrimSpace(data))
+	}
+	return data, resp.Header, nil
+}
+
+// FormatCases renders a case list one line per case.
+func FormatCases(cases []Case) string {
+	if len(cases) == 0 {
+		return "no cases found"
+	}
+	var b strings.Builder
+	for _, cs := range cases {
+		fmt.Fprintf(&b, "%s [%s] %s: %s", cs.CaseNumber, cs.Severity, cs.Status, cs.Summary)
+		if cs.LastModifiedDate != "" {
+			fmt.Fprintf(&b, " (updated %s)", cs.LastModifiedDate)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// FormatCase renders a case with its description and comments, oldest
+// comment first.
+func FormatCase(cs *Case) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Case %s: %s\n", cs.CaseNumber, cs.Summary)
+	for _, f := range []struct{ name, value string }{
+		{"Status", cs.Status},
+		{"Severity", cs.Severity},
+		{"Product", strings.TrimSpace(cs.Product + " " + cs.Version)},
+		{"Contact", cs.ContactName},
+		{"Created", cs.CreatedDate},
+		{"Updated", cs.LastModifiedDate},
+	} {
+		if f.value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", f.name, f.value)
+		}
+	}
+	fmt.Fprintf(&b, "URL: https://access.redhat.com/support/cases/#/case/%s\n", cs.CaseNumber)
+	if d := strings.TrimSpace(cs.Description); d != "" {
+		fmt.Fprintf(&b, "\nDescription:\n%s\n", d)
+	}
+	if len(cs.Comments) > 0 {
+		fmt.Fprintf(&b, "\nComments (%d):\n", len(cs.Comments))
+		for _, cm := range cs.Comments {
+			visibility := ""
+			if !cm.Public {
+				visibility = " (private)"
+			}
+			fmt.Fprintf(&b, "\n--- %s, %s%s\n%s\n", cm.CreatedBy, cm.CreatedDate, visibility, strings.TrimSpace(cm.Body))
+		}
+	}
+	return b.String()
+}
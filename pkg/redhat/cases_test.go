@@ -0,0 +1,178 @@
+package redhat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// caseAPIStandIn emulates the case endpoints of the Case Management API.
+type caseAPIStandIn struct {
+	mu        sync.Mutex
+	comments  []map[string]any
+	created   []map[string]any
+	filter    map[string]any
+	failPosts int
+	posts     int
+}
+
+func (s *caseAPIStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path == "/token" {
+		fmt.Fprint(w, `{"access_token":"tok","expires_in":900}`)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var body map[string]any
+	if r.Method == "POST" {
+		s.posts++
+		if s.failPosts > 0 {
+			s.failPosts--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/cases/filter":
+		s.filter = body
+		fmt.Fprint(w, `{"cases":[{"caseNumber":"0123","summary":"crio hangs","status":"Waiting on Red Hat","severity":"2 (High)","lastModifiedDate":"2024-05-02T10:00:00Z"}]}`)
+	case r.Method == "GET" && r.URL.Path == "/cases/0123":
+		fmt.Fprint(w, `{"caseNumber":"0123","summary":"crio hangs","description":"pods stuck in ContainerCreating","status":"Waiting on Red Hat","product":"OpenShift Container Platform","version":"4.14"}`)
+	case r.Method == "GET" && r.URL.Path == "/cases/0123/comments":
+		fmt.Fprint(w, `[{"id":"c2","createdBy":"Support","createdDate":"2024-05-02T10:00:00Z","commentBody":"please attach a sosreport","isPublic":true},{"id":"c1","createdBy":"Customer","createdDate":"2024-05-01T10:00:00Z","commentBody":"opened","isPublic":true}]`)
+	case r.Method == "POST" && r.URL.Path == "/cases/0123/comments":
+		s.comments = append(s.comments, body)
+		w.Header().Set("Location", fmt.Sprintf("http://%s/cases/0123/comments/c3", r.Host))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "POST" && r.URL.Path == "/cases":
+		s.created = append(s.created, body)
+		w.Header().Set("Location", fmt.Sprintf("http://%s/cases/0456", r.Host))
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newCaseClient(t *testing.T, h http.Handler, allowComments bool) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := NewClient(Config{
+		CaseAPIBase:       srv.URL,
+		SSOTokenURL:       srv.URL + "/token",
+		Backoff:           time.Millisecond,
+		AllowCaseComments: allowComments,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestListCases(t *testing.T) {
+	s := &caseAPIStandIn{}
+	c := newCaseClient(t, s, false)
+	cases, err := c.ListCases(context.Background(), CaseFilter{Keyword: "crio", MaxResults: 5}, "off")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cases) != 1 || cases[0].CaseNumber != "0123" {
+		t.Fatalf("unexpected cases %+v", cases)
+	}
+	if s.filter["keyword"] != "crio" || s.filter["maxResults"] != float64(5) || s.filter["includeClosed"] != false {
+		t.Fatalf("unexpected filter %v", s.filter)
+	}
+	if out := FormatCases(cases); out != "0123 [2 (High)] Waiting on Red Hat: crio hangs (updated 2024-05-02T10:00:00Z)\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestGetCase(t *testing.T) {
+	c := newCaseClient(t, &caseAPIStandIn{}, false)
+	cs, err := c.GetCase(context.Background(), "0123", "off")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cs.Comments) != 2 || cs.Comments[0].ID != "c1" {
+		t.Fatalf("comments not ordered oldest first: %+v", cs.Comments)
+	}
+	out := FormatCase(cs)
+	for _, want := range []string{"Case 0123: crio hangs", "Product: OpenShift Container Platform 4.14", "pods stuck in ContainerCreating", "--- Support, 2024-05-02T10:00:00Z\nplease attach a sosreport"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestAddCaseCommentDisabled(t *testing.T) {
+	s := &caseAPIStandIn{}
+	c := newCaseClient(t, s, false)
+	_, err := c.AddCaseComment(context.Background(), "0123", "hello", "off")
+	if !errors.Is(err, ErrCaseCommentsDisabled) {
+		t.Fatalf("expected ErrCaseCommentsDisabled, got %v", err)
+	}
+	if s.posts != 0 {
+		t.Fatalf("disabled comment reached the server")
+	}
+}
+
+func TestAddCaseComment(t *testing.T) {
+	s := &caseAPIStandIn{}
+	c := newCaseClient(t, s, true)
+	cm, err := c.AddCaseComment(context.Background(), "0123", "sosreport attached", "off")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cm.ID != "c3" || len(s.comments) != 1 || s.comments[0]["commentBody"] != "sosreport attached" || s.comments[0]["isPublic"] != true {
+		t.Fatalf("unexpected comment %+v, server saw %v", cm, s.comments)
+	}
+}
+
+func TestAddCaseCommentNotRetried(t *testing.T) {
+	s := &caseAPIStandIn{failPosts: 1}
+	c := newCaseClient(t, s, true)
+	if _, err := c.AddCaseComment(context.Background(), "0123", "hello", "off"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected 503 error, got %v", err)
+	}
+	if s.posts != 1 {
+		t.Fatalf("comment was posted %d times", s.posts)
+	}
+}
+
+func TestCreateCase(t *testing.T) {
+	s := &caseAPIStandIn{}
+	c := newCaseClient(t, s, false)
+	if _, err := c.CreateCase(context.Background(), NewCase{Summary: "x"}, "off"); err == nil {
+		t.Fatal("expected validation error")
+	}
+	cs, err := c.CreateCase(context.Background(), NewCase{
+		Summary:     "crio hangs",
+		Description: "pods stuck",
+		Product:     "OpenShift Container Platform",
+		Version:     "4.14",
+		Severity:    "3 (Normal)",
+	}, "off")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cs.CaseNumber != "0456" || cs.Summary != "crio hangs" {
+		t.Fatalf("unexpected case %+v", cs)
+	}
+	if len(s.created) != 1 || s.created[0]["version"] != "4.14" || s.created[0]["severity"] != "3 (Normal)" {
+		t.Fatalf("unexpected request %v", s.created)
+	}
+}
@@ -0,0 +1,282 @@
+package redhat
+
+import (
+	"container/list"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
+)
+
+// Default endpoints of the public Red Hat APIs.
+const (
+	DefaultAPIBase       = "https://access.redhat.com"
+	DefaultCaseAPIBase   = "https://api.access.redhat.com/support/v1"
+	DefaultErrataAPIBase = "https://api.access.redhat.com/management/v1"
+	DefaultSSOTokenURL   = "https://sso.redhat.com/auth/realms/redhat-external/protocol/openid-connect/token"
+)
+
+// Config configures a Client. Zero values select the defaults, so
+// disconnected sites only need to set the fields that point at their mirror.
+type Config struct {
+	// APIBase is the Customer Portal base URL serving the Hydra search and
+	// Security Data APIs.
+	APIBase string
+	// CaseAPIBase is the base URL of the Case Management API.
+	CaseAPIBase string
+	// ErrataAPIBase is the base URL of the Subscription Management API
+	// used to look up errata.
+	ErrataAPIBase string
+	// SSOTokenURL is the endpoint used to exchange offline tokens.
+	SSOTokenURL string
+	// ProxyURL is an HTTP proxy for all requests. When empty the standard
+	// HTTPS_PROXY/NO_PROXY environment variables apply.
+	ProxyURL string
+	// CABundle is a PEM file with additional trusted certificate authorities.
+	CABundle string
+	// Timeout bounds each HTTP request. Defaults to two minutes.
+	Timeout time.Duration
+	// MaxRetries is how often a request failing with 429 or 5xx is retried.
+	// Negative disables retries; zero selects the default of 3.
+	MaxRetries int
+	// Backoff is the delay before the first retry; it doubles on each
+	// attempt. Defaults to one second.
+	Backoff time.Duration
+	// AllowCaseComments enables AddCaseComment. It is off by default so
+	// nothing is written to a customer-visible case without opting in.
+	AllowCaseComments bool
+	// ResumableUploads makes UploadAttachment use the chunked, resumable
+	// upload session protocol instead of the documented multipart endpoint.
+	ResumableUploads bool
+}
+
+// ConfigFromEnv builds a Config from REDHAT_API_URL, REDHAT_CASE_API_URL,
+// REDHAT_ERRATA_API_URL, REDHAT_SSO_TOKEN_URL, REDHAT_PROXY, REDHAT_CA_BUNDLE,
+// REDHAT_TIMEOUT, REDHAT_MAX_RETRIES, REDHAT_ALLOW_CASE_COMMENTS and
+// REDHAT_RESUMABLE_UPLOADS.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		APIBase:       os.Getenv("REDHAT_API_URL"),
+		CaseAPIBase:   os.Getenv("REDHAT_CASE_API_URL"),
+		ErrataAPIBase: os.Getenv("REDHAT_ERRATA_API_URL"),
+		SSOTokenURL:   os.Getenv("REDHAT_SSO_TOKEN_URL"),
+		ProxyURL:      os.Getenv("REDHAT_PROXY"),
+		CABundle:      os.Getenv("REDHAT_CA_BUNDLE"),
+	}
+	if d, err := time.ParseDuration(os.Getenv("REDHAT_TIMEOUT")); err == nil {
+		cfg.Timeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("REDHAT_MAX_RETRIES")); err == nil {
+		cfg.MaxRetries = n
+	}
+	cfg.AllowCaseComments, _ = strconv.ParseBool(os.Getenv("REDHAT_ALLOW_CASE_COMMENTS"))
+	cfg.ResumableUploads, _ = strconv.ParseBool(os.Getenv("REDHAT_RESUMABLE_UPLOADS"))
+	return cfg
+}
+
+// Client talks to the Red Hat Customer Portal, Security Data and Case
+// Management APIs. Access tokens are cached per client.
+type Client struct {
+	cfg Config
+	do  func(*http.Request) (*http.Response, error)
+
+	tokens      *TokenProvider
+	mu          sync.Mutex
+	explicit    map[string]*list.Element
+	explicitLRU *list.List
+}
+
+// NewClient returns a Client for cfg.
+func NewClient(cfg Config) (*Client, error) {
+	hc, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(cfg, hc.Do), nil
+}
+
+func newClient(cfg Config, do func(*http.Request) (*http.Response, error)) *Client {
+	if cfg.APIBase == "" {
+		cfg.APIBase = DefaultAPIBase
+	}
+	if cfg.CaseAPIBase == "" {
+		cfg.CaseAPIBase = DefaultCaseAPIBase
+	}
+	if cfg.ErrataAPIBase == "" {
+		cfg.ErrataAPIBase = DefaultErrataAPIBase
+	}
+	if cfg.SSOTokenURL == "" {
+		cfg.SSOTokenURL = DefaultSSOTokenURL
+	}
+	cfg.APIBase = strings.TrimSuffix(cfg.APIBase, "/")
+	cfg.CaseAPIBase = strings.TrimSuffix(cfg.CaseAPIBase, "/")
+	cfg.ErrataAPIBase = strings.TrimSuffix(cfg.ErrataAPIBase, "/")
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	c := &Client{cfg: cfg, do: do, explicit: map[string]*list.Element{}, explicitLRU: list.New()}
+	c.tokens = c.NewTokenProvider(OfflineToken)
+	return c
+}
+
+func newHTTPClient(cfg Config) (*http.Client, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CABundle)
+		}
+		tr.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	return &http.Client{Transport: tr, Timeout: timeout}, nil
+}
+
+// Do sends the requests made by the package-level functions. It defaults to
+// an HTTP client configured from the environment. Tests may override this
+// variable.
+var Do = func(req *http.Request) (*http.Response, error) {
+	hc, err := envHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	return hc.Do(req)
+}
+
+var (
+	envHTTPOnce sync.Once
+	envHTTP     *http.Client
+	envHTTPErr  error
+
+	defaultMu     sync.Mutex
+	defaultClient *Client
+)
+
+func envHTTPClient() (*http.Client, error) {
+	envHTTPOnce.Do(func() {
+		envHTTP, envHTTPErr = newHTTPClient(ConfigFromEnv())
+	})
+	return envHTTP, envHTTPErr
+}
+
+// Default returns the client used by the package-level functions. It is
+// configured from the environment and sends requests through Do.
+func Default() *Client {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultClient == nil {
+		defaultClient = newClient(ConfigFromEnv(), func(req *http.Request) (*http.Response, error) { return Do(req) })
+	}
+	return defaultClient
+}
+
+// SetDefault replaces the client used by the package-level functions.
+func SetDefault(c *Client) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultClient = c
+}
+
+// send issues req, retrying with exponential backoff when the server answers
+// 429 or 5xx or the connection fails. Requests whose body cannot be replayed
+// are sent once. A Retry-After header overrides the computed delay. During a
+// dry run the request is recorded instead of sent.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if p := dryrun.FromContext(req.Context()); p != nil {
+		return nil, p.Record(req.Method + " " + req.URL.Redacted())
+	}
+	delay := c.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(req)
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		canReplay := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if !retryable || attempt >= c.cfg.MaxRetries || !canReplay || req.Context().Err() != nil {
+			return resp, err
+		}
+		wait := delay
+		if resp != nil {
+			if s, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+				wait = time.Duration(s) * time.Second
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		delay *= 2
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// get performs an authenticated or anonymous GET and returns the body of a
+// 200 response. An empty token sends no Authorization header.
+func (c *Client) get(ctx context.Context, endpoint, token, what string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s request failed: %s", what, resp.Status)
+	}
+	return body, nil
+}
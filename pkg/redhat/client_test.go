@@ -0,0 +1,111 @@
+package redhat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			if r.URL.Path != "/hydra/rest/securitydata/cve/CVE-1.json" {
+				t.Errorf("unexpected path %s", r.URL.Path)
+			}
+			fmt.Fprint(w, "cve")
+		}
+	}))
+	defer srv.Close()
+	c, err := NewClient(Config{APIBase: srv.URL + "/", Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.CVEInfo(context.Background(), "CVE-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "cve" || calls != 3 {
+		t.Fatalf("unexpected output %q after %d calls", out, calls)
+	}
+}
+
+func TestClientGivesUp(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	c, err := NewClient(Config{APIBase: srv.URL, MaxRetries: 2, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CVEInfo(context.Background(), "CVE-1"); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("expected 502 error, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestClientContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	c, err := NewClient(Config{APIBase: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.CVEInfo(ctx, "CVE-1"); err == nil {
+		t.Fatal("expected error")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("request was not aborted by context")
+	}
+}
+
+func TestNewClientInvalidConfig(t *testing.T) {
+	if _, err := NewClient(Config{CABundle: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Fatal("expected error for missing CA bundle")
+	}
+	bad := filepath.Join(t.TempDir(), "bad.pem")
+	if err := os.WriteFile(bad, []byte("not a cert"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(Config{CABundle: bad}); err == nil {
+		t.Fatal("expected error for invalid CA bundle")
+	}
+	if _, err := NewClient(Config{ProxyURL: "://bad"}); err == nil {
+		t.Fatal("expected error for invalid proxy")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("REDHAT_API_URL", "https://mirror.example.com")
+	t.Setenv("REDHAT_TIMEOUT", "10s")
+	t.Setenv("REDHAT_MAX_RETRIES", "5")
+	t.Setenv("REDHAT_ALLOW_CASE_COMMENTS", "true")
+	cfg := ConfigFromEnv()
+	if cfg.APIBase != "https://mirror.example.com" || cfg.Timeout != 10*time.Second || cfg.MaxRetries != 5 || !cfg.AllowCaseComments {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
@@ -0,0 +1,240 @@
+package redhat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CVE is the subset of a Security Data API CVE document needed to decide
+// whether a system is exposed.
+type CVE struct {
+	Name            string            `json:"name"`
+	ThreatSeverity  string            `json:"threat_severity"`
+	PublicDate      string            `json:"public_date"`
+	Bugzilla        CVEBugzilla       `json:"bugzilla"`
+	CVSS3           CVECVSS3          `json:"cvss3"`
+	Statement       string            `json:"statement"`
+	AffectedRelease []AffectedRelease `json:"affected_release"`
+	PackageState    []PackageState    `json:"package_state"`
+}
+
+// CVEBugzilla references the Red Hat Bugzilla entry tracking a CVE.
+type CVEBugzilla struct {
+	Description string `json:"description"`
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+}
+
+// CVECVSS3 holds the CVSS v3 score assigned by Red Hat.
+type CVECVSS3 struct {
+	BaseScore string `json:"cvss3_base_score"`
+	Vector    string `json:"cvss3_scoring_vector"`
+}
+
+// AffectedRelease describes an erratum that ships a fix for a product.
+// Package is the fixed NEVRA, e.g. "cri-o-0:1.27.1-6.rhaos4.14.git.el9".
+type AffectedRelease struct {
+	ProductName string `json:"product_name"`
+	ReleaseDate string `json:"release_date"`
+	Advisory    string `json:"advisory"`
+	CPE         string `json:"cpe"`
+	Package     string `json:"package"`
+}
+
+// PackageState describes a product and package that has no fix, either
+// because it is not affected or because a fix is pending or declined.
+type PackageState struct {
+	ProductName string `json:"product_name"`
+	FixState    string `json:"fix_state"`
+	PackageName string `json:"package_name"`
+	CPE         string `json:"cpe"`
+}
+
+// CVEDetails fetches and decodes a CVE from the Security Data API.
+func CVEDetails(ctx context.Context, cveID string) (*CVE, error) {
+	return Default().CVEDetails(ctx, cveID)
+}
+
+// CVEDetails fetches and decodes a CVE from the Security Data API.
+func (c *Client) CVEDetails(ctx context.Context, cveID string) (*CVE, error) {
+	endpoint := c.cfg.APIBase + "/hydra/rest/securitydata/cve/" + url.QueryEscape(cveID) + ".json"
+	body, err := c.get(ctx, endpoint, "", "cve")
+	if err != nil {
+		return nil, err
+	}
+	var cve CVE
+	if err := json.Unmarshal(body, &cve); err != nil {
+		return nil, err
+	}
+	if cve.Name == "" {
+		cve.Name = cveID
+	}
+	return &cve, nil
+}
+
+// RuntimePackages are the container runtime packages searched by default.
+var RuntimePackages = []string{"cri-o", "runc", "conmon", "crun", "containers-common"}
+
+// CVESeverities are the severities accepted by the Security Data API, from
+// least to most severe.
+var CVESeverities = []string{"low", "moderate", "important", "critical"}
+
+// CVEQuery describes a search of the Security Data CVE list. Empty fields
+// are not filtered on.
+type CVEQuery struct {
+	Packages []string
+	Severity string
+	// After and Before bound the public date and use the YYYY-MM-DD format.
+	After  string
+	Before string
+	// PerPage limits the number of CVEs returned for each package.
+	PerPage int
+}
+
+// CVESummary is an entry of the Security Data CVE list.
+type CVESummary struct {
+	CVE              string   `json:"CVE"`
+	Severity         string   `json:"severity"`
+	PublicDate       string   `json:"public_date"`
+	Advisories       []string `json:"advisories"`
+	Bugzilla         string   `json:"bugzilla"`
+	Description      string   `json:"bugzilla_description"`
+	CVSS3Score       string   `json:"cvss3_score"`
+	CWE              string   `json:"CWE"`
+	AffectedPackages []string `json:"affected_packages"`
+	ResourceURL      string   `json:"resource_url"`
+}
+
+// validate checks the query the same way the API would, so mistakes are
+// reported before any request is made.
+func (q CVEQuery) validate() error {
+	if q.Severity != "" {
+		ok := false
+		for _, s := range CVESeverities {
+			ok = ok || s == q.Severity
+		}
+		if !ok {
+			return fmt.Errorf("invalid severity %q: must be one of %s", q.Severity, strings.Join(CVESeverities, ", "))
+		}
+	}
+	for _, d := range []struct{ name, value string }{{"after", q.After}, {"before", q.Before}} {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d.value); err != nil {
+			return fmt.Errorf("invalid %s date %q: use YYYY-MM-DD", d.name, d.value)
+		}
+	}
+	return nil
+}
+
+// SearchCVEs lists CVEs from the Security Data API.
+func SearchCVEs(ctx context.Context, q CVEQuery) ([]CVESummary, error) {
+	return Default().SearchCVEs(ctx, q)
+}
+
+// SearchCVEs lists CVEs from the Security Data API. The API filters on a
+// single package per request, so each package is queried separately and the
+// results are merged, newest first.
+func (c *Client) SearchCVEs(ctx context.Context, q CVEQuery) ([]CVESummary, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	packages := q.Packages
+	if len(packages) == 0 {
+		packages = []string{""}
+	}
+	seen := map[string]bool{}
+	var results []CVESummary
+	for _, pkg := range packages {
+		params := url.Values{}
+		if pkg != "" {
+			params.Set("package", pkg)
+		}
+		if q.Severity != "" {
+			params.Set("severity", q.Severity)
+		}
+		if q.After != "" {
+			params.Set("after", q.After)
+		}
+		if q.Before != "" {
+			params.Set("before", q.Before)
+		}
+		if q.PerPage > 0 {
+			params.Set("per_page", strconv.Itoa(q.PerPage))
+		}
+		body, err := c.get(ctx, c.cfg.APIBase+"/hydra/rest/securitydata/cve.json?"+params.Encode(), "", "cve search")
+		if err != nil {
+			return nil, err
+		}
+		var list []CVESummary
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("decode cve list: %w", err)
+		}
+		for _, s := range list {
+			if !seen[s.CVE] {
+				seen[s.CVE] = true
+				results = append(results, s)
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].PublicDate > results[j].PublicDate
+	})
+	return results, nil
+}
+
+// FormatCVEList renders CVE summaries one per paragraph. When packages is
+// non-empty only the affected packages with those names are listed, since a
+// CVE may name hundreds of unrelated builds.
+func FormatCVEList(list []CVESummary, packages []string) string {
+	if len(list) == 0 {
+		return "no CVEs found"
+	}
+	var b strings.Builder
+	for i, s := range list {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		date, _, _ := strings.Cut(s.PublicDate, "T")
+		fmt.Fprintf(&b, "%s [%s] published %s", s.CVE, s.Severity, date)
+		if s.CVSS3Score != "" {
+			fmt.Fprintf(&b, ", CVSS %s", s.CVSS3Score)
+		}
+		b.WriteString("\n")
+		if s.Description != "" {
+			fmt.Fprintf(&b, "  %s\n", strings.TrimSpace(s.Description))
+		}
+		if len(s.Advisories) > 0 {
+			fmt.Fprintf(&b, "  advisories: %s\n", strings.Join(s.Advisories, ", "))
+		}
+		if fixed := filterPackages(s.AffectedPackages, packages); len(fixed) > 0 {
+			fmt.Fprintf(&b, "  fixed builds: %s\n", strings.Join(fixed, ", "))
+		}
+	}
+	return b.String()
+}
+
+// filterPackages keeps the NEVRAs whose name is one of names.
+func filterPackages(nevras, names []string) []string {
+	if len(names) == 0 {
+		return nevras
+	}
+	var out []string
+	for _, p := range nevras {
+		for _, n := range names {
+			rest, ok := strings.CutPrefix(p, n+"-")
+			if ok && rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package redhat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const cveResponse = `{
+  "threat_severity": "Important",
+  "public_date": "2024-02-01T00:00:00Z",
+  "bugzilla": {"description": "runc: file descriptor leak", "id": "2258725"},
+  "cvss3": {"cvss3_base_score": "8.6"},
+  "affected_release": [
+    {"product_name": "Red Hat OpenShift Container Platform 4.14", "advisory": "RHSA-2024:0670", "cpe": "cpe:/a:redhat:openshift:4.14::el9", "package": "runc-4:1.1.12-1.rhaos4.14.el9"}
+  ],
+  "package_state": [
+    {"product_name": "Red Hat OpenShift Container Platform 4", "fix_state": "Not affected", "package_name": "crun", "cpe": "cpe:/a:redhat:openshift:4"}
+  ],
+  "name": "CVE-2024-21626"
+}`
+
+func TestCVEDetails(t *testing.T) {
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		if !strings.HasSuffix(req.URL.Path, "/cve/CVE-2024-21626.json") {
+			t.Fatalf("unexpected path %s", req.URL.Path)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(cveResponse))}, nil
+	}, func() {
+		cve, err := CVEDetails(context.Background(), "CVE-2024-21626")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cve.Name != "CVE-2024-21626" || cve.ThreatSeverity != "Important" || cve.CVSS3.BaseScore != "8.6" {
+			t.Fatalf("unexpected cve %+v", cve)
+		}
+		if len(cve.AffectedRelease) != 1 || cve.AffectedRelease[0].Advisory != "RHSA-2024:0670" {
+			t.Fatalf("unexpected affected releases %+v", cve.AffectedRelease)
+		}
+		if len(cve.PackageState) != 1 || cve.PackageState[0].FixState != "Not affected" {
+			t.Fatalf("unexpected package state %+v", cve.PackageState)
+		}
+	})
+}
+
+func TestCVEDetailsNotFound(t *testing.T) {
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(strings.NewReader(""))}, nil
+	}, func() {
+		if _, err := CVEDetails(context.Background(), "CVE-0000-0000"); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestSearchCVEs(t *testing.T) {
+	var queries []string
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/hydra/rest/securitydata/cve.json" {
+			t.Fatalf("unexpected path %s", req.URL.Path)
+		}
+		q := req.URL.Query()
+		queries = append(queries, q.Get("package"))
+		if q.Get("severity") != "important" || q.Get("after") != "2024-01-01" {
+			t.Fatalf("unexpected query %s", req.URL.RawQuery)
+		}
+		body := `[{"CVE":"CVE-2024-21626","severity":"important","public_date":"2024-01-31T00:00:00Z","advisories":["RHSA-2024:0670"],"bugzilla_description":"runc: file descriptor leak","affected_packages":["runc-4:1.1.12-1.rhaos4.14.el9","kernel-0:5.14.0-1.el9"]}]`
+		if q.Get("package") == "cri-o" {
+			body = `[{"CVE":"CVE-2024-3154","severity":"important","public_date":"2024-04-26T00:00:00Z","advisories":["RHSA-2024:2669"],"affected_packages":["cri-o-0:1.29.4-3.rhaos4.16.git.el9"]},{"CVE":"CVE-2024-21626","severity":"important","public_date":"2024-01-31T00:00:00Z"}]`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}, func() {
+		list, err := SearchCVEs(context.Background(), CVEQuery{Packages: []string{"runc", "cri-o"}, Severity: "important", After: "2024-01-01"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Join(queries, ",") != "runc,cri-o" {
+			t.Fatalf("unexpected queries %v", queries)
+		}
+		if len(list) != 2 || list[0].CVE != "CVE-2024-3154" || list[1].CVE != "CVE-2024-21626" {
+			t.Fatalf("unexpected list %+v", list)
+		}
+		out := FormatCVEList(list, []string{"runc", "cri-o"})
+		for _, want := range []string{
+			"CVE-2024-3154 [important] published 2024-04-26",
+			"  runc: file descriptor leak",
+			"  advisories: RHSA-2024:0670",
+			"  fixed builds: runc-4:1.1.12-1.rhaos4.14.el9\n",
+		} {
+			if !strings.Contains(out, want) {
+				t.Fatalf("missing %q in\n%s", want, out)
+			}
+		}
+	})
+}
+
+func TestSearchCVEsInvalid(t *testing.T) {
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s", req.URL)
+		return nil, nil
+	}, func() {
+		if _, err := SearchCVEs(context.Background(), CVEQuery{Severity: "urgent"}); err == nil {
+			t.Fatal("expected severity error")
+		}
+		if _, err := SearchCVEs(context.Background(), CVEQuery{After: "01/02/2024"}); err == nil {
+			t.Fatal("expected date error")
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package redhat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Erratum is a Red Hat advisory (RHSA, RHBA or RHEA).
+type Erratum struct {
+	ID          string
+	Type        string
+	Severity    string
+	Synopsis    string
+	Issued      string
+	Updated     string
+	Description string
+	Solution    string
+	CVEs        []string
+	// Packages are the fixed builds as NEVRAs without architecture,
+	// e.g. "runc-4:1.1.12-1.rhaos4.14.el9".
+	Packages []string
+}
+
+// erratumIDRE matches advisory identifiers such as RHSA-2024:0670.
+var erratumIDRE = regexp.MustCompile(`^RH[SBE]A-\d{4}:\d{4,}$`)
+
+// errataPageSize is the number of packages requested per page.
+const errataPageSize = 100
+
+// GetErratum fetches an advisory and the packages it ships.
+func GetErratum(ctx context.Context, id, offlineToken string) (*Erratum, error) {
+	return Default().GetErratum(ctx, id, offlineToken)
+}
+
+// GetErratum fetches an advisory and the packages it ships from the
+// Subscription Management API.
+func (c *Client) GetErratum(ctx context.Context, id, offlineToken string) (*Erratum, error) {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	if !erratumIDRE.MatchString(id) {
+		return nil, fmt.Errorf("invalid advisory %q: expected an ID like RHSA-2024:0670", id)
+	}
+	token, err := c.tokenFor(ctx, offlineToken)
+	if err != nil {
+		return nil, err
+	}
+	base := c.cfg.ErrataAPIBase + "/errata/" + url.PathEscape(id)
+	body, err := c.get(ctx, base, token, "erratum")
+	if err != nil {
+		return nil, err
+	}
+	var detail struct {
+		Body struct {
+			ID          string    `json:"id"`
+			Type        string    `json:"type"`
+			Severity    string    `json:"severity"`
+			Synopsis    string    `json:"synopsis"`
+			Issued      string    `json:"issued"`
+			Updated     string    `json:"lastUpdated"`
+			Description string    `json:"description"`
+			Solution    string    `json:"solution"`
+			CVEs        textField `json:"cves"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal(body, &detail); err != nil {
+		return nil, fmt.Errorf("decode erratum: %w", err)
+	}
+	e := &Erratum{
+		ID:          detail.Body.ID,
+		Type:        detail.Body.Type,
+		Severity:    detail.Body.Severity,
+		Synopsis:    detail.Body.Synopsis,
+		Issued:      detail.Body.Issued,
+		Updated:     detail.Body.Updated,
+		Description: detail.Body.Description,
+		Solution:    detail.Body.Solution,
+		CVEs:        strings.Fields(string(detail.Body.CVEs)),
+	}
+	if e.ID == "" {
+		e.ID = id
+	}
+
+	seen := map[string]bool{}
+	for offset := 0; ; offset += errataPageSize {
+		params := url.Values{}
+		params.Set("limit", strconv.Itoa(errataPageSize))
+		params.Set("offset", strconv.Itoa(offset))
+		body, err := c.get(ctx, base+"/packages?"+params.Encode(), token, "erratum packages")
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Body []struct {
+				Name    string `json:"name"`
+				Epoch   string `json:"epoch"`
+				Version string `json:"version"`
+				Release string `json:"release"`
+			} `json:"body"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("decode erratum packages: %w", err)
+		}
+		for _, p := range page.Body {
+			epoch := p.Epoch
+			if epoch == "" {
+				epoch = "0"
+			}
+			nevra := fmt.Sprintf("%s-%s:%s-%s", p.Name, epoch, p.Version, p.Release)
+			if !seen[nevra] {
+				seen[nevra] = true
+				e.Packages = append(e.Packages, nevra)
+			}
+		}
+		if len(page.Body) < errataPageSize {
+			break
+		}
+	}
+	sort.Strings(e.Packages)
+	return e, nil
+}
+
+// FormatErratum renders an advisory for display. When packages is non-empty
+// only builds with those names are listed.
+func FormatErratum(e *Erratum, packages []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n", e.ID, e.Synopsis)
+	if e.Type != "" {
+		fmt.Fprintf(&b, "Type: %s\n", e.Type)
+	}
+	if e.Severity != "" {
+		fmt.Fprintf(&b, "Severity: %s\n", e.Severity)
+	}
+	if e.Issued != "" {
+		fmt.Fprintf(&b, "Issued: %s\n", e.Issued)
+	}
+	if e.Updated != "" && e.Updated != e.Issued {
+		fmt.Fprintf(&b, "Updated: %s\n", e.Updated)
+	}
+	fmt.Fprintf(&b, "URL: https://access.redhat.com/errata/%s\n", e.ID)
+	if len(e.CVEs) > 0 {
+		fmt.Fprintf(&b, "CVEs: %s\n", strings.Join(e.CVEs, ", "))
+	}
+	pkgs := filterPackages(e.Packages, packages)
+	if len(pkgs) > 0 {
+		b.WriteString("\nFixed packages:\n")
+		for _, p := range pkgs {
+			fmt.Fprintf(&b, "- %s\n", p)
+		}
+	} else if len(e.Packages) > 0 {
+		fmt.Fprintf(&b, "\nNone of the %d fixed packages match the requested names.\n", len(e.Packages))
+	}
+	if d := strings.TrimSpace(e.Description); d != "" {
+		fmt.Fprintf(&b, "\nDescription:\n%s\n", d)
+	}
+	if s := strings.TrimSpace(e.Solution); s != "" {
+		fmt.Fprintf(&b, "\nSolution:\n%s\n", s)
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package redhat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetErratum(t *testing.T) {
+	pages := 0
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "sso.redhat.com" {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"access_token":"tok"}`))}, nil
+		}
+		if req.Header.Get("Authorization") != "Bearer tok" {
+			t.Fatalf("missing auth header")
+		}
+		var body string
+		switch req.URL.Path {
+		case "/management/v1/errata/RHSA-2024:0670":
+			body = `{"body":{"id":"RHSA-2024:0670","type":"Security Advisory","severity":"Important","synopsis":"Important: runc security update","issued":"2024-02-01","cves":"CVE-2024-21626 CVE-2023-0001","description":"An update for runc is now available."}}`
+		case "/management/v1/errata/RHSA-2024:0670/packages":
+			pages++
+			if req.URL.Query().Get("offset") == "0" {
+				var pkgs []string
+				for i := 0; i < errataPageSize; i++ {
+					arch := []string{"x86_64", "aarch64"}[i%2]
+					pkgs = append(pkgs, fmt.Sprintf(`{"name":"runc","epoch":"4","version":"1.1.12","release":"1.rhaos4.14.el9","arch":%q}`, arch))
+				}
+				body = `{"body":[` + strings.Join(pkgs, ",") + `]}`
+			} else {
+				body = `{"body":[{"name":"runc-debuginfo","epoch":"","version":"1.1.12","release":"1.rhaos4.14.el9","arch":"x86_64"}]}`
+			}
+		default:
+			t.Fatalf("unexpected path %s", req.URL.Path)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}, func() {
+		e, err := GetErratum(context.Background(), "rhsa-2024:0670", "off")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pages != 2 {
+			t.Fatalf("expected 2 package pages, got %d", pages)
+		}
+		if fmt.Sprint(e.CVEs) != "[CVE-2024-21626 CVE-2023-0001]" {
+			t.Fatalf("unexpected cves %v", e.CVEs)
+		}
+		if fmt.Sprint(e.Packages) != "[runc-4:1.1.12-1.rhaos4.14.el9 runc-debuginfo-0:1.1.12-1.rhaos4.14.el9]" {
+			t.Fatalf("unexpected packages %v", e.Packages)
+		}
+		out := FormatErratum(e, []string{"runc"})
+		for _, want := range []string{
+			"RHSA-2024:0670: Important: runc security update",
+			"URL: https://access.redhat.com/errata/RHSA-2024:0670",
+			"Fixed packages:\n- runc-4:1.1.12-1.rhaos4.14.el9\n\n",
+		} {
+			if !strings.Contains(out, want) {
+				t.Fatalf("missing %q in\n%s", want, out)
+			}
+		}
+	})
+}
+
+func TestGetErratumInvalidID(t *testing.T) {
+	if _, err := GetErratum(context.Background(), "CVE-2024-21626", "off"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,278 @@
+package redhat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// KCSQuery describes a Knowledge Base search.
+type KCSQuery struct {
+	Query string
+	Rows  int
+	// Product restricts results to a product such as
+	// "OpenShift Container Platform" or "Red Hat Enterprise Linux".
+	Product string
+	// Version restricts results to a product version such as "4.14" or "9".
+	Version string
+}
+
+// KCSResult is a single Knowledge Base document.
+type KCSResult struct {
+	ID           string
+	Kind         string
+	Title        string
+	URL          string
+	Products     []string
+	Versions     []string
+	LastModified string
+	Abstract     string
+	Environment  string
+	Issue        string
+	RootCause    string
+	Resolution   string
+	Diagnostics  string
+	Score        float64
+}
+
+// kcsDoc mirrors the fields of a Hydra search document that are used here.
+// Text fields are returned either as strings or as single-element arrays.
+type kcsDoc struct {
+	ID             string    `json:"id"`
+	DocumentKind   string    `json:"documentKind"`
+	AllTitle       string    `json:"allTitle"`
+	PublishedTitle string    `json:"publishedTitle"`
+	ViewURI        string    `json:"view_uri"`
+	Product        []string  `json:"product"`
+	Version        []string  `json:"documentation_version"`
+	LastModified   string    `json:"lastModifiedDate"`
+	Abstract       textField `json:"abstract"`
+	Environment    textField `json:"solution_environment"`
+	Issue          textField `json:"issue"`
+	SolutionIssue  textField `json:"solution_issue"`
+	RootCause      textField `json:"solution_rootcause"`
+	Resolution     textField `json:"solution_resolution"`
+	Diagnostics    textField `json:"solution_diagnosticsteps"`
+	Score          float64   `json:"score"`
+}
+
+type textField string
+
+func (t *textField) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = textField(s)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*t = textField(strings.Join(list, "\n"))
+	return nil
+}
+
+func (d kcsDoc) result() KCSResult {
+	r := KCSResult{
+		ID:           d.ID,
+		Kind:         d.DocumentKind,
+		Title:        d.PublishedTitle,
+		URL:          d.ViewURI,
+		Products:     d.Product,
+		Versions:     d.Version,
+		LastModified: d.LastModified,
+		Abstract:     string(d.Abstract),
+		Environment:  string(d.Environment),
+		Issue:        string(d.SolutionIssue),
+		RootCause:    string(d.RootCause),
+		Resolution:   string(d.Resolution),
+		Diagnostics:  string(d.Diagnostics),
+		Score:        d.Score,
+	}
+	if r.Title == "" {
+		r.Title = d.AllTitle
+	}
+	if r.Issue == "" {
+		r.Issue = string(d.Issue)
+	}
+	if r.URL == "" && d.ID != "" {
+		r.URL = "https://access.redhat.com/solutions/" + d.ID
+	}
+	return r
+}
+
+// SearchKCS queries the Red Hat Knowledge Base using the default client.
+func SearchKCS(ctx context.Context, q KCSQuery, offlineToken string) ([]KCSResult, error) {
+	return Default().SearchKCS(ctx, q, offlineToken)
+}
+
+// KCSArticle fetches a single Knowledge Base document using the default
+// client.
+func KCSArticle(ctx context.Context, id, offlineToken string) (*KCSResult, error) {
+	return Default().KCSArticle(ctx, id, offlineToken)
+}
+
+// SearchKCS queries the Red Hat Knowledge Base for documents matching q and
+// returns them ranked by relevance. An empty offlineToken uses the configured
+// offline token.
+func (c *Client) SearchKCS(ctx context.Context, q KCSQuery, offlineToken string) ([]KCSResult, error) {
+	if q.Rows <= 0 {
+		q.Rows = 20
+	}
+	params := url.Values{}
+	params.Set("format", "json")
+	params.Set("q", q.Query)
+	params.Set("rows", fmt.Sprint(q.Rows))
+	params.Set("fl", "*,score")
+	if q.Product != "" {
+		params.Add("fq", fmt.Sprintf("product:%q", q.Product))
+	}
+	if q.Version != "" {
+		params.Add("fq", fmt.Sprintf("documentation_version:%q", q.Version))
+	}
+	docs, err := c.searchDocs(ctx, params, offlineToken)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]KCSResult, len(docs))
+	for i, d := range docs {
+		results[i] = d.result()
+	}
+	rankKCS(results)
+	return results, nil
+}
+
+// KCSArticle fetches the full text of a single Knowledge Base document by
+// its ID, for example a solution number.
+func (c *Client) KCSArticle(ctx context.Context, id, offlineToken string) (*KCSResult, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil, fmt.Errorf("article id required")
+	}
+	params := url.Values{}
+	params.Set("format", "json")
+	params.Set("q", fmt.Sprintf("id:%q", id))
+	params.Set("fl", "*")
+	docs, err := c.searchDocs(ctx, params, offlineToken)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		if d.ID == id {
+			r := d.result()
+			return &r, nil
+		}
+	}
+	return nil, fmt.Errorf("article %s not found", id)
+}
+
+func (c *Client) searchDocs(ctx context.Context, params url.Values, offlineToken string) ([]kcsDoc, error) {
+	token, err := c.tokenFor(ctx, offlineToken)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.get(ctx, c.cfg.APIBase+"/hydra/rest/search/kcs?"+params.Encode(), token, "search")
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Response struct {
+			Docs []kcsDoc `json:"docs"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("decode search response: %w", err)
+	}
+	return result.Response.Docs, nil
+}
+
+// rankKCS orders results by search score, preferring solutions over other
+// document kinds when scores tie. The search order is kept otherwise.
+func rankKCS(results []KCSResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Kind == "Solution" && results[j].Kind != "Solution"
+	})
+}
+
+// snippetLen is the maximum length of each text snippet in search output.
+const snippetLen = 300
+
+// FormatKCSResults renders results as text, trimming snippets and dropping
+// trailing results so the output stays within budget characters. A
+// non-positive budget disables trimming.
+func FormatKCSResults(results []KCSResult, budget int) string {
+	if len(results) == 0 {
+		return "no results"
+	}
+	var b strings.Builder
+	for i, r := range results {
+		var e strings.Builder
+		fmt.Fprintf(&e, "%d. %s\n   %s", i+1, r.Title, r.URL)
+		if r.Kind != "" {
+			fmt.Fprintf(&e, " [%s]", r.Kind)
+		}
+		e.WriteString("\n")
+		if len(r.Products) > 0 {
+			fmt.Fprintf(&e, "   products: %s", strings.Join(r.Products, ", "))
+			if len(r.Versions) > 0 {
+				fmt.Fprintf(&e, " (%s)", strings.Join(r.Versions, ", "))
+			}
+			e.WriteString("\n")
+		}
+		if r.LastModified != "" {
+			fmt.Fprintf(&e, "   last modified: %s\n", r.LastModified)
+		}
+		for _, f := range []struct{ name, text string }{
+			{"abstract", r.Abstract}, {"issue", r.Issue}, {"resolution", r.Resolution},
+		} {
+			if s := snippet(f.text, snippetLen); s != "" {
+				fmt.Fprintf(&e, "   %s: %s\n", f.name, s)
+			}
+		}
+		if budget > 0 && b.Len()+e.Len() > budget && i > 0 {
+			fmt.Fprintf(&b, "... %d more results omitted to fit the output budget\n", len(results)-i)
+			break
+		}
+		b.WriteString(e.String())
+	}
+	return b.String()
+}
+
+// FormatKCSArticle renders the full text of a document.
+func FormatKCSArticle(r *KCSResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n", r.Title, r.URL)
+	if len(r.Products) > 0 {
+		fmt.Fprintf(&b, "Products: %s\n", strings.Join(r.Products, ", "))
+	}
+	if r.LastModified != "" {
+		fmt.Fprintf(&b, "Last modified: %s\n", r.LastModified)
+	}
+	for _, f := range []struct{ name, text string }{
+		{"Environment", r.Environment}, {"Issue", r.Issue}, {"Root Cause", r.RootCause},
+		{"Resolution", r.Resolution}, {"Diagnostic Steps", r.Diagnostics},
+	} {
+		if t := strings.TrimSpace(f.text); t != "" {
+			fmt.Fprintf(&b, "\n## %s\n%s\n", f.name, t)
+		}
+	}
+	if r.Resolution == "" && r.Abstract != "" {
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimSpace(r.Abstract))
+	}
+	return b.String()
+}
+
+// snippet collapses whitespace and truncates s to n characters.
+func snippet(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
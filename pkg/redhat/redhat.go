@@ -1,99 +1,82 @@
 package redhat
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
-var Do = http.DefaultClient.Do
+// AccessToken exchanges an offline token for a short-lived access token.
+// Callers that make repeated requests should prefer the cached tokens used by
+// the other functions in this package.
+func AccessToken(ctx context.Context, offlineToken string) (string, error) {
+	return Default().AccessToken(ctx, offlineToken)
+}
+
+// CVEInfo fetches details for a given CVE ID using the Security Data API.
+func CVEInfo(ctx context.Context, cveID string) (string, error) {
+	return Default().CVEInfo(ctx, cveID)
+}
 
 // AccessToken exchanges an offline token for a short-lived access token.
-func AccessToken(offlineToken string) (string, error) {
+func (c *Client) AccessToken(ctx context.Context, offlineToken string) (string, error) {
+	tok, _, err := c.exchangeToken(ctx, offlineToken)
+	return tok, err
+}
+
+// exchangeToken performs the SSO token exchange and returns the access token
+// with its lifetime.
+func (c *Client) exchangeToken(ctx context.Context, offlineToken string) (string, time.Duration, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
 	data.Set("client_id", "rhsm-api")
 	data.Set("refresh_token", offlineToken)
-	req, err := http.NewRequest("POST", "https://sso.redhat.com/auth/realms/redhat-external/protocol/openid-connect/token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.cfg.SSOTokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := Do(req)
+	resp, err := c.send(req)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("token request failed: %s", resp.Status)
+		return "", 0, fmt.Errorf("token request failed: %s", resp.Status)
 	}
 	var result struct {
 		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
+		return "", 0, err
 	}
 	if result.AccessToken == "" {
-		return "", fmt.Errorf("access_token not found")
-	}
-	return result.AccessToken, nil
-}
-
-// SearchKCS queries the Red Hat Knowledge Base for articles matching the query.
-func SearchKCS(query string, rows int, offlineToken string) (string, error) {
-	token, err := AccessToken(offlineToken)
-	if err != nil {
-		return "", err
-	}
-	if rows <= 0 {
-		rows = 20
-	}
-	endpoint := "https://access.redhat.com/hydra/rest/search/kcs?format=json&q=" + url.QueryEscape(query) + "&rows=" + fmt.Sprint(rows)
-	req, err := http.NewRequest("GET", endpoint, nil)
-	if err != nil {
-		return "", err
+		return "", 0, fmt.Errorf("access_token not found")
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	resp, err := Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	ttl := time.Duration(result.ExpiresIn) * time.Second
+	if ttl <= 0 {
+		// SSO access tokens live for 15 minutes unless stated otherwise.
+		ttl = 15 * time.Minute
 	}
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("search request failed: %s", resp.Status)
-	}
-	return string(body), nil
+	return result.AccessToken, ttl, nil
 }
 
 // CVEInfo fetches details for a given CVE ID using the Security Data API.
-func CVEInfo(cveID string) (string, error) {
-	endpoint := "https://access.redhat.com/hydra/rest/securitydata/cve/" + url.QueryEscape(cveID) + ".json"
-	req, err := http.NewRequest("GET", endpoint, nil)
+func (c *Client) CVEInfo(ctx context.Context, cveID string) (string, error) {
+	endpoint := c.cfg.APIBase + "/hydra/rest/securitydata/cve/" + url.QueryEscape(cveID) + ".json"
+	body, err := c.get(ctx, endpoint, "", "cve")
 	if err != nil {
 		return "", err
 	}
-	resp, err := Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("cve request failed: %s", resp.Status)
-	}
 	return string(body), nil
 }
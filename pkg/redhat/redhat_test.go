@@ -1,6 +1,7 @@
 package redhat
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 func withDoMock(f func(req *http.Request) (*http.Response, error), test func()) {
 	orig := Do
 	Do = f
+	ClearTokenCache()
 	defer func() { Do = orig }()
 	test()
 }
@@ -32,20 +34,78 @@ func TestSearchKCS(t *testing.T) {
 			if req.Header.Get("Authorization") != "Bearer tok" {
 				t.Fatalf("missing auth header")
 			}
-			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("result"))}, nil
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(kcsResponse))}, nil
 		}
 		return nil, fmt.Errorf("extra call")
 	}, func() {
-		out, err := SearchKCS("bug", 10, "off")
+		out, err := SearchKCS(context.Background(), KCSQuery{Query: "bug", Rows: 10}, "off")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		if out != "result" {
-			t.Fatalf("unexpected output %q", out)
+		if len(out) != 2 || out[0].ID != "222" {
+			t.Fatalf("unexpected output %+v", out)
+		}
+	})
+}
+
+const kcsResponse = `{"response":{"numFound":2,"docs":[
+{"id":"111","documentKind":"Article","allTitle":"Tuning CRI-O","view_uri":"https://access.redhat.com/articles/111","score":3.5,"abstract":"How to tune"},
+{"id":"222","documentKind":"Solution","publishedTitle":"Pods stuck in ContainerCreating","product":["OpenShift Container Platform"],"documentation_version":["4.14"],"lastModifiedDate":"2025-01-01T00:00:00Z","score":7.2,"issue":["Pods hang"],"solution_resolution":"Upgrade to 4.14.10","solution_rootcause":"A deadlock in CRI-O"}
+]}}`
+
+func TestSearchKCSFilters(t *testing.T) {
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "sso.redhat.com" {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"access_token":"tok"}`))}, nil
+		}
+		fq := req.URL.Query()["fq"]
+		if len(fq) != 2 || fq[0] != `product:"OpenShift Container Platform"` || fq[1] != `documentation_version:"4.14"` {
+			t.Fatalf("unexpected filters %v", fq)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(kcsResponse))}, nil
+	}, func() {
+		_, err := SearchKCS(context.Background(), KCSQuery{Query: "crio", Product: "OpenShift Container Platform", Version: "4.14"}, "off")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
 		}
 	})
 }
 
+func TestKCSArticle(t *testing.T) {
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "sso.redhat.com" {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"access_token":"tok"}`))}, nil
+		}
+		if req.URL.Query().Get("q") != `id:"222"` {
+			t.Fatalf("unexpected query %s", req.URL.RawQuery)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(kcsResponse))}, nil
+	}, func() {
+		art, err := KCSArticle(context.Background(), "222", "off")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out := FormatKCSArticle(art)
+		if !strings.Contains(out, "## Root Cause\nA deadlock in CRI-O") {
+			t.Fatalf("unexpected article:\n%s", out)
+		}
+	})
+}
+
+func TestFormatKCSResultsBudget(t *testing.T) {
+	results := []KCSResult{
+		{Title: "first", URL: "u1", Resolution: strings.Repeat("x", 1000)},
+		{Title: "second", URL: "u2"},
+	}
+	out := FormatKCSResults(results, 200)
+	if !strings.Contains(out, "first") || strings.Contains(out, "second") || !strings.Contains(out, "1 more results omitted") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if !strings.Contains(out, strings.Repeat("x", 300)+"...") {
+		t.Fatalf("snippet not trimmed:\n%s", out)
+	}
+}
+
 func TestCVEInfo(t *testing.T) {
 	withDoMock(func(req *http.Request) (*http.Response, error) {
 		if !strings.Contains(req.URL.Path, "/CVE-1234.json") {
@@ -53,7 +113,7 @@ func TestCVEInfo(t *testing.T) {
 		}
 		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("cve"))}, nil
 	}, func() {
-		out, err := CVEInfo("CVE-1234")
+		out, err := CVEInfo(context.Background(), "CVE-1234")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
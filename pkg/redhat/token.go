@@ -0,0 +1,181 @@
+package redhat
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
+)
+
+// Environment variables consulted for the offline token when a tool call
+// does not provide one.
+const (
+	OfflineTokenEnv     = "REDHAT_OFFLINE_TOKEN"
+	OfflineTokenFileEnv = "REDHAT_OFFLINE_TOKEN_FILE"
+)
+
+// dryRunToken stands in for the access token during a dry run.
+const dryRunToken = "dry-run-access-token"
+
+// refreshMargin is how long before expiry a cached access token is renewed.
+const refreshMargin = time.Minute
+
+// KeyringLookup returns the offline token stored in the desktop keyring. It
+// uses secret-tool with the attributes service=crio-mcp-server and
+// account=offline-token. Tests may override this variable.
+var KeyringLookup = func() (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "secret-tool", "lookup", "service", "crio-mcp-server", "account", "offline-token").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// OfflineToken resolves the configured offline token. It checks the
+// REDHAT_OFFLINE_TOKEN environment variable, then the file named by
+// REDHAT_OFFLINE_TOKEN_FILE (default ~/.config/crio-mcp-server/offline-token),
+// then the keyring. A file named by REDHAT_OFFLINE_TOKEN_FILE must exist and
+// hold a token; only the default file may be absent.
+func OfflineToken() (string, error) {
+	if tok := strings.TrimSpace(os.Getenv(OfflineTokenEnv)); tok != "" {
+		return tok, nil
+	}
+	if path := os.Getenv(OfflineTokenFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read %s: %w", OfflineTokenFileEnv, err)
+		}
+		tok := strings.TrimSpace(string(data))
+		if tok == "" {
+			return "", fmt.Errorf("%s: %s is empty", OfflineTokenFileEnv, path)
+		}
+		return tok, nil
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(dir, "crio-mcp-server", "offline-token")); err == nil {
+			if tok := strings.TrimSpace(string(data)); tok != "" {
+				return tok, nil
+			}
+		}
+	}
+	if tok, err := KeyringLookup(); err == nil && tok != "" {
+		return tok, nil
+	}
+	return "", fmt.Errorf("no offline token configured: set %s or %s, store it in the keyring, or pass offline_token", OfflineTokenEnv, OfflineTokenFileEnv)
+}
+
+// TokenProvider exchanges an offline token for access tokens and caches the
+// result until shortly before it expires. It is safe for concurrent use;
+// concurrent callers share a single refresh.
+type TokenProvider struct {
+	client  *Client
+	offline func() (string, error)
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewTokenProvider returns a provider that obtains its offline token from
+// offline on every refresh, so rotated credentials are picked up.
+func (c *Client) NewTokenProvider(offline func() (string, error)) *TokenProvider {
+	return &TokenProvider{client: c, offline: offline}
+}
+
+// Token returns a valid access token, refreshing it if needed.
+func (p *TokenProvider) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && time.Now().Before(p.expires.Add(-refreshMargin)) {
+		return p.token, nil
+	}
+	offline, err := p.offline()
+	if err != nil {
+		return "", err
+	}
+	tok, ttl, err := p.client.exchangeToken(ctx, offline)
+	if errors.Is(err, dryrun.ErrSkipped) {
+		// The exchange was only recorded. A placeholder, which is not cached,
+		// lets the dry run go on to record the API request as well.
+		return dryRunToken, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	p.token, p.expires = tok, time.Now().Add(ttl)
+	return tok, nil
+}
+
+// Invalidate drops the cached access token so the next call refreshes it.
+func (p *TokenProvider) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.token = ""
+}
+
+// maxExplicitTokens is how many offline tokens passed in tool calls have
+// their access tokens cached. The least recently used is dropped first.
+const maxExplicitTokens = 16
+
+// explicitToken is an entry of Client.explicitLRU.
+type explicitToken struct {
+	key      string
+	provider *TokenProvider
+}
+
+// tokenFor returns an access token for offlineToken, or for the configured
+// offline token when it is empty. Access tokens are cached per offline token,
+// keyed by its hash, for up to maxExplicitTokens offline tokens.
+func (c *Client) tokenFor(ctx context.Context, offlineToken string) (string, error) {
+	if offlineToken == "" {
+		return c.tokens.Token(ctx)
+	}
+	sum := sha256.Sum256([]byte(offlineToken))
+	key := hex.EncodeToString(sum[:])
+	c.mu.Lock()
+	e, ok := c.explicit[key]
+	if ok {
+		c.explicitLRU.MoveToFront(e)
+	} else {
+		e = c.explicitLRU.PushFront(&explicitToken{
+			key:      key,
+			provider: c.NewTokenProvider(func() (string, error) { return offlineToken, nil }),
+		})
+		c.explicit[key] = e
+		for c.explicitLRU.Len() > maxExplicitTokens {
+			delete(c.explicit, c.explicitLRU.Remove(c.explicitLRU.Back()).(*explicitToken).key)
+		}
+	}
+	p := e.Value.(*explicitToken).provider
+	c.mu.Unlock()
+	return p.Token(ctx)
+}
+
+// ClearTokenCache discards all access tokens cached by the client.
+func (c *Client) ClearTokenCache() {
+	c.mu.Lock()
+	c.explicit = map[string]*list.Element{}
+	c.explicitLRU.Init()
+	c.mu.Unlock()
+	c.tokens.Invalidate()
+}
+
+// ClearTokenCache discards all access tokens cached by the default client.
+func ClearTokenCache() {
+	Default().ClearTokenCache()
+}
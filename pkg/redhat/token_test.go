@@ -0,0 +1,151 @@
+package redhat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
+)
+
+func TestTokenProviderCaches(t *testing.T) {
+	var calls int32
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"access_token":"tok","expires_in":900}`))}, nil
+	}, func() {
+		p := Default().NewTokenProvider(func() (string, error) { return "off", nil })
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if tok, err := p.Token(context.Background()); err != nil || tok != "tok" {
+					t.Errorf("unexpected token %q: %v", tok, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if calls != 1 {
+			t.Fatalf("expected a single exchange, got %d", calls)
+		}
+
+		p.expires = time.Now().Add(30 * time.Second)
+		if _, err := p.Token(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 2 {
+			t.Fatalf("expected refresh near expiry, got %d calls", calls)
+		}
+	})
+}
+
+func TestOfflineTokenSources(t *testing.T) {
+	origKeyring := KeyringLookup
+	defer func() { KeyringLookup = origKeyring }()
+	KeyringLookup = func() (string, error) { return "from-keyring", nil }
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(OfflineTokenFileEnv, path)
+	t.Setenv(OfflineTokenEnv, "from-env")
+	if tok, _ := OfflineToken(); tok != "from-env" {
+		t.Fatalf("expected env token, got %q", tok)
+	}
+	t.Setenv(OfflineTokenEnv, "")
+	if tok, _ := OfflineToken(); tok != "from-file" {
+		t.Fatalf("expected file token, got %q", tok)
+	}
+	t.Setenv(OfflineTokenFileEnv, filepath.Join(t.TempDir(), "missing"))
+	if tok, err := OfflineToken(); err == nil || !strings.Contains(err.Error(), "read "+OfflineTokenFileEnv) {
+		t.Fatalf("expected an error for the configured file, got %q, %v", tok, err)
+	}
+	t.Setenv(OfflineTokenFileEnv, "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	if tok, _ := OfflineToken(); tok != "from-keyring" {
+		t.Fatalf("expected keyring token, got %q", tok)
+	}
+}
+
+func TestExplicitTokenCacheBounded(t *testing.T) {
+	exchanges := 0
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		exchanges++
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"access_token":"tok","expires_in":900}`))}, nil
+	}, func() {
+		c := Default()
+		for i := 0; i < 3*maxExplicitTokens; i++ {
+			if _, err := c.tokenFor(context.Background(), fmt.Sprint("off-", i)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if len(c.explicit) != maxExplicitTokens || c.explicitLRU.Len() != maxExplicitTokens {
+			t.Fatalf("cache holds %d tokens", len(c.explicit))
+		}
+		before := exchanges
+		if _, err := c.tokenFor(context.Background(), fmt.Sprint("off-", 3*maxExplicitTokens-1)); err != nil || exchanges != before {
+			t.Fatalf("recent token not cached: %d exchanges, %v", exchanges-before, err)
+		}
+		if _, err := c.tokenFor(context.Background(), "off-0"); err != nil || exchanges != before+1 {
+			t.Fatalf("evicted token still cached: %d exchanges, %v", exchanges-before, err)
+		}
+	})
+}
+
+func TestSearchKCSUsesConfiguredToken(t *testing.T) {
+	t.Setenv(OfflineTokenEnv, "configured")
+	calls := 0
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if req.URL.Host == "sso.redhat.com" {
+			body, _ := io.ReadAll(req.Body)
+			if !strings.Contains(string(body), "refresh_token=configured") {
+				t.Fatalf("unexpected token request %s", body)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"access_token":"tok"}`))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"response":{"docs":[]}}`))}, nil
+	}, func() {
+		for i := 0; i < 2; i++ {
+			if _, err := SearchKCS(context.Background(), KCSQuery{Query: "bug", Rows: 1}, ""); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	})
+	if calls != 3 {
+		t.Fatalf("expected one token exchange and two searches, got %d calls", calls)
+	}
+}
+
+func TestDryRunRecordsAPIRequest(t *testing.T) {
+	t.Setenv(OfflineTokenEnv, "configured")
+	withDoMock(func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("request sent during a dry run: %s", req.URL)
+		return nil, nil
+	}, func() {
+		ctx, plan := dryrun.With(context.Background())
+		if _, err := SearchKCS(ctx, KCSQuery{Query: "bug", Rows: 1}, ""); !errors.Is(err, dryrun.ErrSkipped) {
+			t.Fatalf("expected the search to be skipped, got %v", err)
+		}
+		steps := plan.Steps()
+		if len(steps) != 2 || !strings.HasPrefix(steps[0], "POST https://sso.redhat.com/") || !strings.HasPrefix(steps[1], "GET https://access.redhat.com/hydra/rest/search/kcs?") {
+			t.Fatalf("unexpected plan %q", steps)
+		}
+		if Default().tokens.token != "" {
+			t.Fatal("placeholder token was cached")
+		}
+	})
+}
@@ -0,0 +1,374 @@
+package runbook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Tools maps tool names to the handlers that run them. The server passes its
+// registered handlers, so every step goes through the same authorization,
+// confirmation, redaction and auditing as a direct call.
+type Tools map[string]server.ToolHandlerFunc
+
+// MaxCalls bounds the tool calls of one run, so that a loop over a large
+// cluster cannot run away.
+const MaxCalls = 200
+
+// StepResult is the outcome of one call of a step.
+type StepResult struct {
+	ID    string
+	Title string
+	Tool  string
+	// Item is the loop item the call was made for, if any.
+	Item string
+	// Skipped is set when the step's condition did not hold.
+	Skipped bool
+	// Failed is set when the call returned an error.
+	Failed bool
+	Output string
+
+	maxLines int
+}
+
+// Report is the consolidated result of a run.
+type Report struct {
+	Runbook  *Runbook
+	Params   map[string]string
+	Steps    []StepResult
+	Findings []string
+}
+
+// Run executes rb with params, calling tools for every step. Steps that fail
+// are reported and the run continues, since later steps and findings often
+// explain the failure. An error is returned when a parameter is missing, a
+// step names an unknown tool, the call budget is exhausted or ctx is done.
+func (rb *Runbook) Run(ctx context.Context, tools Tools, params map[string]string) (*Report, error) {
+	for _, s := range rb.Steps {
+		if tools[s.Tool] == nil {
+			return nil, fmt.Errorf("runbook %s: step %s: unknown tool %s", rb.Name, s.ID, s.Tool)
+		}
+	}
+	vars := map[string]any{}
+	used := map[string]string{}
+	for _, p := range rb.Params {
+		v := params[p.Name]
+		if v == "" {
+			v = p.Default
+		}
+		if p.Required && v == "" {
+			return nil, fmt.Errorf("runbook %s: param %s is required", rb.Name, p.Name)
+		}
+		vars[p.Name] = v
+		used[p.Name] = v
+	}
+	for _, s := range rb.Steps {
+		if s.As != "" {
+			vars[s.As] = ""
+		}
+		for name, c := range s.Capture {
+			if c.All {
+				vars[name] = []string{}
+			} else {
+				vars[name] = ""
+			}
+		}
+	}
+
+	r := &Report{Runbook: rb, Params: used}
+	calls := 0
+	for i := range rb.Steps {
+		s := &rb.Steps[i]
+		items := []string{""}
+		if s.foreach != nil {
+			out, err := execute(s.foreach, vars)
+			if err != nil {
+				r.Steps = append(r.Steps, s.failure("", fmt.Sprintf("foreach: %v", err)))
+				continue
+			}
+			items = strings.Fields(out)
+		}
+		for _, item := range items {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if s.foreach != nil {
+				vars[s.As] = item
+			}
+			if s.when != nil {
+				ok, err := execute(s.when, vars)
+				if err != nil {
+					r.Steps = append(r.Steps, s.failure(item, fmt.Sprintf("when: %v", err)))
+					continue
+				}
+				if ok == "" {
+					res := s.result(item, "", false)
+					res.Skipped = true
+					r.Steps = append(r.Steps, res)
+					continue
+				}
+			}
+			if calls++; calls > MaxCalls {
+				return nil, fmt.Errorf("runbook %s: more than %d tool calls", rb.Name, MaxCalls)
+			}
+			r.Steps = append(r.Steps, s.run(ctx, tools[s.Tool], item, vars))
+		}
+	}
+	for _, f := range rb.Findings {
+		ok, err := execute(f.when, vars)
+		if err != nil {
+			r.Findings = append(r.Findings, fmt.Sprintf("finding could not be evaluated: %v", err))
+			continue
+		}
+		if ok == "" {
+			continue
+		}
+		msg, err := execute(f.message, vars)
+		if err != nil {
+			msg = fmt.Sprintf("finding could not be rendered: %v", err)
+		}
+		r.Findings = append(r.Findings, msg)
+	}
+	return r, nil
+}
+
+// result returns the result of calling s for item.
+func (s *Step) result(item, out string, failed bool) StepResult {
+	return StepResult{ID: s.ID, Title: s.Title, Tool: s.Tool, Item: item, Failed: failed, Output: out, maxLines: s.MaxLines}
+}
+
+// failure returns the result of a call of s that failed with msg.
+func (s *Step) failure(item, msg string) StepResult {
+	return s.result(item, msg, true)
+}
+
+// run calls the step's tool once and captures from its output.
+func (s *Step) run(ctx context.Context, h server.ToolHandlerFunc, item string, vars map[string]any) StepResult {
+	args, err := renderArgs(s.args, vars)
+	if err != nil {
+		return s.failure(item, err.Error())
+	}
+	req := mcp.CallToolRequest{}
+	req.Params.Name = s.Tool
+	req.Params.Arguments = args
+	res, err := h(ctx, req)
+	if err != nil {
+		return s.failure(item, err.Error())
+	}
+	out := text(res)
+	if res.IsError {
+		return s.failure(item, out)
+	}
+	if s.filter != nil {
+		if out, err = filterLines(s.filter, vars, out); err != nil {
+			return s.failure(item, err.Error())
+		}
+	}
+	names := make([]string, 0, len(s.Capture))
+	for name := range s.Capture {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := capture(s.Capture[name], name, vars, out); err != nil {
+			return s.failure(item, err.Error())
+		}
+	}
+	return s.result(item, out, false)
+}
+
+// capture stores the values c matches in out.
+func capture(c Capture, name string, vars map[string]any, out string) error {
+	expr, err := execute(c.regex, vars)
+	if err != nil {
+		return fmt.Errorf("capture %s: %w", name, err)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("capture %s: %w", name, err)
+	}
+	var found []string
+	for _, m := range re.FindAllStringSubmatch(out, -1) {
+		if len(m) > 1 {
+			found = append(found, m[1])
+		} else {
+			found = append(found, m[0])
+		}
+	}
+	switch {
+	case len(found) == 0:
+	case c.All:
+		vars[name] = append(vars[name].([]string), found...)
+	default:
+		vars[name] = found[len(found)-1]
+	}
+	return nil
+}
+
+// filterLines keeps the lines of out that match the regular expression t
+// renders to.
+func filterLines(t *template.Template, vars map[string]any, out string) (string, error) {
+	expr, err := execute(t, vars)
+	if err != nil {
+		return "", fmt.Errorf("filter: %w", err)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", fmt.Errorf("filter: %w", err)
+	}
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if re.MatchString(strings.TrimSuffix(line, "\n")) {
+			b.WriteString(line)
+		}
+	}
+	return b.String(), nil
+}
+
+// renderArgs renders the templates in args.
+func renderArgs(args map[string]any, vars map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		switch v := v.(type) {
+		case *template.Template:
+			s, err := execute(v, vars)
+			if err != nil {
+				return nil, fmt.Errorf("args.%s: %w", k, err)
+			}
+			out[k] = s
+		case []any:
+			list := make([]any, len(v))
+			for i, item := range v {
+				t, ok := item.(*template.Template)
+				if !ok {
+					list[i] = item
+					continue
+				}
+				s, err := execute(t, vars)
+				if err != nil {
+					return nil, fmt.Errorf("args.%s[%d]: %w", k, i, err)
+				}
+				list[i] = s
+			}
+			out[k] = list
+		default:
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+func execute(t *template.Template, vars map[string]any) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// text returns the text content of res.
+func text(res *mcp.CallToolResult) string {
+	if res == nil {
+		return ""
+	}
+	var parts []string
+	for _, c := range res.Content {
+		if t, ok := c.(mcp.TextContent); ok {
+			parts = append(parts, t.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// String formats the report: the findings first, then every step with its
+// output.
+func (r *Report) String() string {
+	var b strings.Builder
+	rb := r.Runbook
+	title := rb.Title
+	if title == "" {
+		title = rb.Description
+	}
+	fmt.Fprintf(&b, "runbook %s: %s\n", rb.Name, title)
+	if len(r.Params) > 0 {
+		keys := make([]string, 0, len(r.Params))
+		for k := range r.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = fmt.Sprintf("%s=%s", k, r.Params[k])
+		}
+		fmt.Fprintf(&b, "params: %s\n", strings.Join(pairs, " "))
+	}
+	b.WriteString("\nfindings:\n")
+	if len(r.Findings) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "  - %s\n", strings.TrimSpace(f))
+	}
+	b.WriteString("\nsteps:\n")
+	for i, s := range r.Steps {
+		name := s.ID
+		if s.Item != "" {
+			name += "[" + s.Item + "]"
+		}
+		status := "ok"
+		switch {
+		case s.Skipped:
+			status = "skipped, condition not met"
+		case s.Failed:
+			status = "failed"
+		}
+		fmt.Fprintf(&b, "%d. %s (%s): %s\n", i+1, name, s.Tool, status)
+		if s.Title != "" {
+			fmt.Fprintf(&b, "   %s\n", s.Title)
+		}
+		if !s.Skipped {
+			writeOutput(&b, s.Output, s.maxLines, s.Failed)
+		}
+	}
+	return b.String()
+}
+
+// writeOutput writes out indented and cut to max lines. Errors are always
+// shown in full.
+func writeOutput(b *strings.Builder, out string, max int, failed bool) {
+	if max == 0 {
+		max = DefaultMaxLines
+	}
+	out = strings.TrimRight(out, "\n")
+	if failed {
+		if out == "" {
+			return
+		}
+		for _, line := range strings.Split(out, "\n") {
+			fmt.Fprintf(b, "   | %s\n", line)
+		}
+		return
+	}
+	if max < 0 {
+		return
+	}
+	if out == "" {
+		b.WriteString("   (no output)\n")
+		return
+	}
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		if i == max {
+			fmt.Fprintf(b, "   | ... %d more lines\n", len(lines)-max)
+			break
+		}
+		fmt.Fprintf(b, "   | %s\n", line)
+	}
+}
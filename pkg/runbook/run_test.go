@@ -0,0 +1,226 @@
+package runbook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// fakeTools answers every tool call from outputs, keyed by the tool name and
+// its node_name argument, and records the calls.
+func fakeTools(outputs map[string]string, calls *[]string) Tools {
+	h := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := req.GetArguments()
+		key := req.Params.Name
+		if n, ok := args["node_name"].(string); ok {
+			key += " " + n
+		}
+		*calls = append(*calls, fmt.Sprintf("%s %v", req.Params.Name, args))
+		out, ok := outputs[key]
+		if !ok {
+			return mcp.NewToolResultError("no output for " + key), nil
+		}
+		return mcp.NewToolResultText(out), nil
+	}
+	return Tools{"list": h, "inspect": h, "logs": h}
+}
+
+func mustParse(t *testing.T, data string) *Runbook {
+	t.Helper()
+	rb, err := Parse("test.yaml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rb
+}
+
+const loopRunbook = `name: loop
+title: Loop over nodes
+params:
+  - name: node
+steps:
+  - id: list
+    tool: list
+    capture:
+      nodes:
+        regex: '(?m)^node (\S+)$'
+        all: true
+  - id: inspect
+    foreach: list (or .node .nodes)
+    as: target
+    tool: inspect
+    args:
+      node_name: '{{.target}}'
+      commands: ['check {{shq .target}}']
+    capture:
+      bad:
+        regex: '(?m)^(\S+) bad$'
+        all: true
+  - id: logs
+    when: .bad
+    tool: logs
+    args:
+      node_name: '{{index .bad 0}}'
+findings:
+  - when: .bad
+    message: 'bad nodes: {{join .bad ", "}}'
+  - when: not .nodes
+    message: no nodes
+`
+
+func TestRunLoopsAndCaptures(t *testing.T) {
+	var calls []string
+	tools := fakeTools(map[string]string{
+		"list":      "node a\nnode b\n",
+		"inspect a": "a good\n",
+		"inspect b": "b bad\n",
+		"logs b":    "line1\nline2\n",
+	}, &calls)
+	r, err := mustParse(t, loopRunbook).Run(context.Background(), tools, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"list map[]",
+		"inspect map[commands:[check 'a'] node_name:a]",
+		"inspect map[commands:[check 'b'] node_name:b]",
+		"logs map[node_name:b]",
+	}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Fatalf("unexpected calls\n%v\nwant\n%v", calls, want)
+	}
+	if fmt.Sprint(r.Findings) != "[bad nodes: b]" {
+		t.Fatalf("unexpected findings %v", r.Findings)
+	}
+	out := r.String()
+	for _, s := range []string{
+		"runbook loop: Loop over nodes\nparams: node=\n",
+		"findings:\n  - bad nodes: b\n",
+		"2. inspect[a] (inspect): ok\n   | a good\n",
+		"4. logs (logs): ok\n   | line1\n   | line2\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("report lacks %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestRunParamOverridesLoopAndSkips(t *testing.T) {
+	var calls []string
+	tools := fakeTools(map[string]string{"list": "", "inspect c": "c good\n"}, &calls)
+	r, err := mustParse(t, loopRunbook).Run(context.Background(), tools, map[string]string{"node": "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || !strings.Contains(calls[1], "node_name:c") {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	out := r.String()
+	if !strings.Contains(out, "3. logs (logs): skipped, condition not met\n") {
+		t.Fatalf("logs step not skipped:\n%s", out)
+	}
+	if !strings.Contains(out, "  - no nodes\n") || !strings.Contains(out, "1. list (list): ok\n   (no output)\n") {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+}
+
+func TestRunFailedStepContinues(t *testing.T) {
+	var calls []string
+	tools := fakeTools(map[string]string{"list": "node a\n"}, &calls)
+	r, err := mustParse(t, loopRunbook).Run(context.Background(), tools, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := r.String()
+	if !strings.Contains(out, "2. inspect[a] (inspect): failed\n   | no output for inspect a\n") {
+		t.Fatalf("failure not reported:\n%s", out)
+	}
+	if !strings.Contains(out, "findings:\n  none\n") {
+		t.Fatalf("unexpected findings:\n%s", out)
+	}
+}
+
+func TestRunFilterAndMaxLines(t *testing.T) {
+	rb := mustParse(t, `name: f
+params: [{name: pod, required: true}]
+steps:
+  - id: list
+    tool: list
+    filter: 'pod/{{quote .pod}}\b'
+    max_lines: 2
+    capture:
+      reason:
+        regex: 'Warning (\S+)'
+  - id: logs
+    tool: logs
+    max_lines: -1
+`)
+	var calls []string
+	events := "Normal Scheduled pod/web.1\nWarning Failed pod/webx1\nWarning BackOff pod/web.1\nWarning Pulled pod/web.1\nWarning Killing pod/web.1\n"
+	r, err := rb.Run(context.Background(), fakeTools(map[string]string{"list": events, "logs": "secret"}, &calls), map[string]string{"pod": "web.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := r.String()
+	want := "1. list (list): ok\n   | Normal Scheduled pod/web.1\n   | Warning BackOff pod/web.1\n   | ... 2 more lines\n2. logs (logs): ok\n"
+	if !strings.HasSuffix(out, want) {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+	if _, err := rb.Run(context.Background(), Tools{}, map[string]string{"pod": "x"}); err == nil || !strings.Contains(err.Error(), "unknown tool list") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := rb.Run(context.Background(), fakeTools(nil, &calls), nil); err == nil || !strings.Contains(err.Error(), "param pod is required") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRunTemplateErrorFailsStep(t *testing.T) {
+	rb := mustParse(t, `name: e
+steps:
+  - id: list
+    tool: list
+    args: {node_name: '{{.missing}}'}
+`)
+	var calls []string
+	r, err := rb.Run(context.Background(), fakeTools(nil, &calls), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 0 || !r.Steps[0].Failed || !strings.Contains(r.Steps[0].Output, `map has no entry for key "missing"`) {
+		t.Fatalf("unexpected result %+v", r.Steps)
+	}
+}
+
+func TestRunMaxCalls(t *testing.T) {
+	rb := mustParse(t, `name: m
+steps:
+  - id: list
+    tool: list
+    capture: {items: {regex: '\d+', all: true}}
+  - id: logs
+    foreach: .items
+    tool: logs
+`)
+	var b strings.Builder
+	for i := 0; i <= MaxCalls; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	var calls []string
+	_, err := rb.Run(context.Background(), fakeTools(map[string]string{"list": b.String(), "logs": ""}, &calls), nil)
+	if err == nil || !strings.Contains(err.Error(), "more than 200 tool calls") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls []string
+	_, err := mustParse(t, loopRunbook).Run(ctx, fakeTools(nil, &calls), nil)
+	if err != context.Canceled || len(calls) != 0 {
+		t.Fatalf("unexpected error %v after calls %v", err, calls)
+	}
+}
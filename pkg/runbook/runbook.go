@@ -0,0 +1,330 @@
+// Package runbook runs declarative diagnostic runbooks. A runbook is a YAML
+// file listing steps, each of which calls one of the server's tools with
+// templated arguments. Steps can capture values from a tool's output with
+// regular expressions, run only when a condition holds, and repeat for every
+// item of a list, such as the nodes found by an earlier step. After the last
+// step the runbook's findings are evaluated against the captured values and
+// everything is returned as one report.
+//
+// The built-in runbooks are embedded, and teams can add their own from a
+// directory.
+package runbook
+
+import (
+	"embed"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/harche/crio-mcp-server/pkg/catalog"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed builtin/*.yaml
+var builtin embed.FS
+
+// Param is a parameter of a runbook.
+type Param struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
+	Default     string `yaml:"default"`
+}
+
+// Capture extracts a value from a step's output. Regex is a template
+// rendered with the runbook's values; the first group of each match, or the
+// whole match when there is no group, is captured. With All every match is
+// appended to a list, otherwise the last match is kept.
+type Capture struct {
+	Regex string `yaml:"regex"`
+	All   bool   `yaml:"all"`
+
+	regex *template.Template
+}
+
+// Step calls one tool.
+type Step struct {
+	// ID names the step in the report.
+	ID    string `yaml:"id"`
+	Title string `yaml:"title"`
+	Tool  string `yaml:"tool"`
+	// Args are the tool arguments. Strings, including those in lists, are
+	// templates rendered with the runbook's values.
+	Args map[string]any `yaml:"args"`
+	// When is a template pipeline, such as ".node" or "ne .state \"ok\"";
+	// the step is skipped unless it evaluates to a non-empty value.
+	When string `yaml:"when"`
+	// Foreach is a template pipeline yielding a string or list of strings.
+	// The step runs once per item, with the item available under As.
+	Foreach string `yaml:"foreach"`
+	As      string `yaml:"as"`
+	// Filter is a template for a regular expression; only the output lines
+	// that match it are captured from and reported.
+	Filter  string             `yaml:"filter"`
+	Capture map[string]Capture `yaml:"capture"`
+	// MaxLines limits the output shown in the report. Zero means
+	// DefaultMaxLines; a negative value hides the output.
+	MaxLines int `yaml:"max_lines"`
+
+	args    map[string]any
+	when    *template.Template
+	foreach *template.Template
+	filter  *template.Template
+}
+
+// Finding is reported when its condition holds after the last step.
+type Finding struct {
+	When    string `yaml:"when"`
+	Message string `yaml:"message"`
+
+	when    *template.Template
+	message *template.Template
+}
+
+// Runbook is a parsed runbook file.
+type Runbook struct {
+	Name        string    `yaml:"name"`
+	Title       string    `yaml:"title"`
+	Description string    `yaml:"description"`
+	Params      []Param   `yaml:"params"`
+	Steps       []Step    `yaml:"steps"`
+	Findings    []Finding `yaml:"findings"`
+}
+
+// DefaultMaxLines is the number of output lines reported for a step that
+// does not set max_lines.
+const DefaultMaxLines = 30
+
+// funcs are the functions available to runbook templates.
+var funcs = template.FuncMap{
+	// join concatenates a list with a separator.
+	"join": func(list []string, sep string) string { return strings.Join(list, sep) },
+	// list turns a string into its whitespace-separated fields and returns
+	// lists unchanged, so that "list (or .node .nodes)" loops over either.
+	"list": toList,
+	// uniq removes repeated items, keeping the first.
+	"uniq": func(list []string) []string {
+		seen := map[string]bool{}
+		var out []string
+		for _, s := range list {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+		return out
+	},
+	// has reports whether a list contains s.
+	"has": func(list []string, s string) bool {
+		for _, v := range list {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	},
+	// last returns the last item of a list, or "" when it is empty.
+	"last": func(list []string) string {
+		if len(list) == 0 {
+			return ""
+		}
+		return list[len(list)-1]
+	},
+	// int parses a captured number, returning 0 when it is not one.
+	"int": func(s string) int {
+		n, _ := strconv.Atoi(strings.TrimSpace(s))
+		return n
+	},
+	// quote escapes a value for use inside a regular expression.
+	"quote": regexp.QuoteMeta,
+	// shq quotes a value for a POSIX shell.
+	"shq": func(s string) string { return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'" },
+}
+
+func toList(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(v), nil
+	case []string:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("list: cannot use %T", v)
+	}
+}
+
+// newTemplate parses text with the runbook functions. Referring to a value
+// that does not exist is an error when the template runs.
+func newTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+// Parse reads a runbook file. file is only used in error messages.
+func Parse(file string, data []byte) (*Runbook, error) {
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	var rb Runbook
+	if err := dec.Decode(&rb); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if err := rb.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return &rb, nil
+}
+
+// compile validates rb and parses its templates.
+func (rb *Runbook) compile() error {
+	if rb.Name == "" {
+		return fmt.Errorf("name required")
+	}
+	if len(rb.Steps) == 0 {
+		return fmt.Errorf("runbook %s has no steps", rb.Name)
+	}
+	// Parameters, captures and loop variables share one namespace.
+	names := map[string]string{}
+	define := func(name, what string) error {
+		if name == "" {
+			return fmt.Errorf("%s name required", what)
+		}
+		if prev, ok := names[name]; ok {
+			return fmt.Errorf("%s %s is already defined as a %s", what, name, prev)
+		}
+		names[name] = what
+		return nil
+	}
+	for _, p := range rb.Params {
+		if err := define(p.Name, "param"); err != nil {
+			return err
+		}
+	}
+	ids := map[string]bool{}
+	for i := range rb.Steps {
+		s := &rb.Steps[i]
+		if s.ID == "" || ids[s.ID] {
+			return fmt.Errorf("step ids must be unique and non-empty")
+		}
+		ids[s.ID] = true
+		if s.Tool == "" {
+			return fmt.Errorf("step %s: tool required", s.ID)
+		}
+		var err error
+		if s.When != "" {
+			if s.when, err = newTemplate(s.ID, "{{if "+s.When+"}}true{{end}}"); err != nil {
+				return fmt.Errorf("step %s: when: %w", s.ID, err)
+			}
+		}
+		if s.Foreach != "" {
+			if s.As == "" {
+				s.As = "item"
+			}
+			if err := define(s.As, "loop variable"); err != nil {
+				return fmt.Errorf("step %s: %w", s.ID, err)
+			}
+			if s.foreach, err = newTemplate(s.ID, "{{range list ("+s.Foreach+")}}{{.}}\n{{end}}"); err != nil {
+				return fmt.Errorf("step %s: foreach: %w", s.ID, err)
+			}
+		} else if s.As != "" {
+			return fmt.Errorf("step %s: as requires foreach", s.ID)
+		}
+		if s.Filter != "" {
+			if s.filter, err = newTemplate(s.ID, s.Filter); err != nil {
+				return fmt.Errorf("step %s: filter: %w", s.ID, err)
+			}
+		}
+		if s.args, err = compileArgs(s.ID, s.Args); err != nil {
+			return fmt.Errorf("step %s: %w", s.ID, err)
+		}
+		for name, c := range s.Capture {
+			if err := define(name, "capture"); err != nil {
+				return fmt.Errorf("step %s: %w", s.ID, err)
+			}
+			if c.Regex == "" {
+				return fmt.Errorf("step %s: capture %s: regex required", s.ID, name)
+			}
+			if c.regex, err = newTemplate(name, c.Regex); err != nil {
+				return fmt.Errorf("step %s: capture %s: %w", s.ID, name, err)
+			}
+			s.Capture[name] = c
+		}
+	}
+	for i := range rb.Findings {
+		f := &rb.Findings[i]
+		if f.When == "" || f.Message == "" {
+			return fmt.Errorf("finding %d: when and message required", i+1)
+		}
+		var err error
+		if f.when, err = newTemplate("finding", "{{if "+f.When+"}}true{{end}}"); err != nil {
+			return fmt.Errorf("finding %d: when: %w", i+1, err)
+		}
+		if f.message, err = newTemplate("finding", f.Message); err != nil {
+			return fmt.Errorf("finding %d: message: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// compileArgs replaces every string in args, including those in lists, with
+// its parsed template.
+func compileArgs(step string, args map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		switch v := v.(type) {
+		case string:
+			t, err := newTemplate(step, v)
+			if err != nil {
+				return nil, fmt.Errorf("args.%s: %w", k, err)
+			}
+			out[k] = t
+		case []any:
+			list := make([]any, len(v))
+			for i, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					list[i] = item
+					continue
+				}
+				t, err := newTemplate(step, s)
+				if err != nil {
+					return nil, fmt.Errorf("args.%s[%d]: %w", k, i, err)
+				}
+				list[i] = t
+			}
+			out[k] = list
+		default:
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// loader reads runbook files.
+var loader = catalog.Loader[*Runbook]{
+	Kind:  "runbook",
+	Exts:  []string{".yaml", ".yml"},
+	Parse: Parse,
+	Name:  func(rb *Runbook) string { return rb.Name },
+}
+
+// Builtin returns the embedded runbooks.
+func Builtin() []*Runbook {
+	return loader.Builtin(builtin, "builtin")
+}
+
+// Load reads the runbooks in dir.
+func Load(dir string) ([]*Runbook, error) {
+	return loader.Load(dir)
+}
+
+// DirEnv names a directory of additional runbook files.
+const DirEnv = "CRIO_MCP_RUNBOOKS_DIR"
+
+// FromEnv returns the built-in runbooks together with those in the directory
+// named by CRIO_MCP_RUNBOOKS_DIR, sorted by name. A file with the name of a
+// built-in runbook replaces it.
+func FromEnv() ([]*Runbook, error) {
+	return loader.FromEnv(Builtin(), DirEnv)
+}
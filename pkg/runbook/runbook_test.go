@@ -0,0 +1,95 @@
+package runbook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	rb, err := Parse("x.yaml", []byte(`name: x
+params:
+  - name: node
+    required: true
+steps:
+  - id: a
+    tool: t
+    args:
+      node_name: '{{.node}}'
+      commands: ['echo {{.node}}', 3]
+    capture:
+      pods:
+        regex: 'pod (\S+)'
+        all: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rb.Name != "x" || len(rb.Steps) != 1 || rb.Steps[0].Capture["pods"].regex == nil {
+		t.Fatalf("unexpected runbook %+v", rb)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct{ name, data, want string }{
+		{"no name", "steps: [{id: a, tool: t}]", "name required"},
+		{"no steps", "name: x", "has no steps"},
+		{"unknown field", "name: x\nstep: []", "field step not found"},
+		{"duplicate id", "name: x\nsteps: [{id: a, tool: t}, {id: a, tool: t}]", "unique"},
+		{"no tool", "name: x\nsteps: [{id: a}]", "tool required"},
+		{"bad when", "name: x\nsteps: [{id: a, tool: t, when: '(.a'}]", "when"},
+		{"as without foreach", "name: x\nsteps: [{id: a, tool: t, as: n}]", "as requires foreach"},
+		{"capture shadows param", "name: x\nparams: [{name: n}]\nsteps: [{id: a, tool: t, capture: {n: {regex: x}}}]", "capture n is already defined as a param"},
+		{"loop variable shadows capture", "name: x\nsteps: [{id: a, tool: t, capture: {n: {regex: x}}}, {id: b, tool: t, foreach: .n, as: n}]", "loop variable n is already defined"},
+		{"capture without regex", "name: x\nsteps: [{id: a, tool: t, capture: {n: {all: true}}}]", "regex required"},
+		{"finding without message", "name: x\nsteps: [{id: a, tool: t}]\nfindings: [{when: .x}]", "when and message required"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse("x.yaml", []byte(tc.data))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	want := map[string]bool{"node-health": true, "runtime-health": true, "pod-start": true}
+	for _, rb := range Builtin() {
+		if rb.Description == "" || len(rb.Findings) == 0 {
+			t.Errorf("runbook %s lacks a description or findings", rb.Name)
+		}
+		delete(want, rb.Name)
+	}
+	if len(want) > 0 {
+		t.Fatalf("missing built-in runbooks %v", want)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "pod-start.yaml"), []byte("name: pod-start\nsteps: [{id: a, tool: t}]\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "custom.yml"), []byte("name: custom\nsteps: [{id: a, tool: t}]\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o600)
+	t.Setenv(DirEnv, dir)
+	rbs, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, rb := range rbs {
+		names = append(names, rb.Name)
+		if rb.Name == "pod-start" && len(rb.Steps) != 1 {
+			t.Fatalf("built-in pod-start not replaced")
+		}
+	}
+	if strings.Join(names, ",") != "custom,node-health,pod-start,runtime-health" {
+		t.Fatalf("unexpected runbooks %v", names)
+	}
+
+	os.WriteFile(filepath.Join(dir, "dup.yaml"), []byte("name: custom\nsteps: [{id: a, tool: t}]\n"), 0o600)
+	if _, err := FromEnv(); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
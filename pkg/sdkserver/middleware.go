@@ -0,0 +1,259 @@
+package sdkserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/harche/crio-mcp-server/pkg/audit"
+	"github.com/harche/crio-mcp-server/pkg/authz"
+	"github.com/harche/crio-mcp-server/pkg/confirm"
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
+	"github.com/harche/crio-mcp-server/pkg/openshift"
+	"github.com/harche/crio-mcp-server/pkg/redact"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var (
+	auditOnce sync.Once
+	auditMW   server.ToolHandlerMiddleware
+
+	redactOnce sync.Once
+	redactor   *redact.Redactor
+	redactErr  error
+
+	policyOnce   sync.Once
+	policyMW     server.ToolHandlerMiddleware
+	policyFilter server.ToolFilterFunc
+
+	confirmOnce sync.Once
+	confirmMW   server.ToolHandlerMiddleware
+)
+
+// auditMiddleware returns the audit middleware configured from the
+// environment, or nil when auditing is off. The log is opened once per
+// process so that every registered server appends to the same hash chain.
+// Logged arguments are redacted with the same rules as tool results.
+func auditMiddleware() server.ToolHandlerMiddleware {
+	auditOnce.Do(func() {
+		l, err := audit.FromEnv()
+		var r *redact.Redactor
+		if l != nil {
+			r, err = loadRedactor()
+		}
+		switch {
+		case err != nil:
+			auditMW = audit.Unavailable(err)
+		case l != nil:
+			auditMW = audit.Middleware(l, r, openshift.CurrentServer)
+		}
+	})
+	return auditMW
+}
+
+// loadRedactor returns the redactor with the built-in detectors and any
+// patterns configured in the environment, or the error loading them.
+func loadRedactor() (*redact.Redactor, error) {
+	redactOnce.Do(func() {
+		redactor, redactErr = redact.FromEnv()
+	})
+	return redactor, redactErr
+}
+
+// redactMiddleware returns the middleware that removes secrets from tool
+// results.
+func redactMiddleware() server.ToolHandlerMiddleware {
+	r, err := loadRedactor()
+	if err != nil {
+		return redact.Unavailable(err)
+	}
+	return redact.Middleware(r)
+}
+
+// policyMiddleware returns the authorization middleware and tool filter for
+// the policy named by CRIO_MCP_POLICY_FILE, or nils when no policy is set. A
+// policy that cannot be loaded rejects every call and hides every tool.
+func policyMiddleware() (server.ToolHandlerMiddleware, server.ToolFilterFunc) {
+	policyOnce.Do(func() {
+		p, err := authz.PolicyFromEnv()
+		switch {
+		case err != nil:
+			policyMW = authz.Unavailable(err)
+			policyFilter = func(context.Context, []mcp.Tool) []mcp.Tool { return nil }
+		case p != nil:
+			policyMW = authz.Middleware(p, authz.Lookup{
+				Cluster:    openshift.CurrentServer,
+				NodeLabels: openshift.NodeLabels,
+				PoolNodes:  openshift.PoolNodes,
+				ToolNodes:  toolNodes,
+			})
+			policyFilter = authz.ToolFilter(p)
+		}
+	})
+	return policyMW, policyFilter
+}
+
+// toolNodes resolves the node of the tools that find it themselves, so that
+// a policy's nodeSelector is checked against the node they will run on.
+var toolNodes = map[string]func(context.Context, map[string]any) ([]string, error){
+	"diagnose_pod": podNode,
+}
+
+// podNode returns the node the pod named by the namespace and pod_name
+// arguments is scheduled to.
+func podNode(ctx context.Context, args map[string]any) ([]string, error) {
+	ns, _ := args["namespace"].(string)
+	pod, _ := args["pod_name"].(string)
+	if ns == "" || pod == "" {
+		return nil, fmt.Errorf("namespace and pod_name are required")
+	}
+	node, err := openshift.PodNode(ctx, ns, pod)
+	if err != nil {
+		return nil, err
+	}
+	if node == "" {
+		return nil, fmt.Errorf("pod %s/%s is not scheduled to a node", ns, pod)
+	}
+	return []string{node}, nil
+}
+
+// confirmMiddleware returns the middleware that holds back risky calls until
+// they are confirmed, or nil when CRIO_MCP_REQUIRE_CONFIRMATION is false.
+// Tokens are issued once per process so that any registered server accepts
+// them.
+func confirmMiddleware() server.ToolHandlerMiddleware {
+	confirmOnce.Do(func() {
+		if confirm.Required() {
+			confirmMW = confirm.Middleware(confirm.New())
+		}
+	})
+	return confirmMW
+}
+
+// ImpersonateEnv enables impersonation of the authenticated caller.
+const ImpersonateEnv = "CRIO_MCP_IMPERSONATE"
+
+// impersonationEnabled reports whether ImpersonateEnv is set. A value that is
+// not a valid boolean enables impersonation, the more restrictive choice.
+func impersonationEnabled() bool {
+	v := os.Getenv(ImpersonateEnv)
+	if v == "" {
+		return false
+	}
+	on, err := strconv.ParseBool(v)
+	return on || err != nil
+}
+
+// Environment variables setting the prefixes added to impersonated names.
+const (
+	ImpersonateUserPrefixEnv  = "CRIO_MCP_IMPERSONATE_USER_PREFIX"
+	ImpersonateGroupPrefixEnv = "CRIO_MCP_IMPERSONATE_GROUP_PREFIX"
+)
+
+// DefaultImpersonatePrefix is added to impersonated OIDC users and groups
+// when the prefix variables are unset, like the API server's
+// --oidc-username-prefix and --oidc-groups-prefix.
+const DefaultImpersonatePrefix = "oidc:"
+
+// impersonatePrefix returns the value of env, or DefaultImpersonatePrefix if
+// it is unset. An empty value disables the prefix.
+func impersonatePrefix(env string) string {
+	if v, ok := os.LookupEnv(env); ok {
+		return v
+	}
+	return DefaultImpersonatePrefix
+}
+
+// impersonation maps an authenticated caller to the user and groups oc acts
+// as. Names from an OIDC provider are prefixed to keep them apart from
+// cluster users and groups, and from the names of static tokens, which are
+// chosen by the administrator and used as they are. Names in the reserved
+// system: namespace, such as system:masters, are refused.
+func impersonation(id authz.Identity) (openshift.Impersonation, error) {
+	var userPrefix, groupPrefix string
+	if id.Issuer != "" {
+		userPrefix, groupPrefix = impersonatePrefix(ImpersonateUserPrefixEnv), impersonatePrefix(ImpersonateGroupPrefixEnv)
+	}
+	imp := openshift.Impersonation{User: userPrefix + id.Subject}
+	if strings.HasPrefix(imp.User, "system:") {
+		return openshift.Impersonation{}, fmt.Errorf("impersonation of user %q is not allowed", imp.User)
+	}
+	for _, g := range id.Groups {
+		g = groupPrefix + g
+		if strings.HasPrefix(g, "system:") {
+			return openshift.Impersonation{}, fmt.Errorf("impersonation of group %q is not allowed", g)
+		}
+		imp.Groups = append(imp.Groups, g)
+	}
+	return imp, nil
+}
+
+// impersonate makes every oc invocation of a call run as the authenticated
+// caller, so the cluster's RBAC decides what the caller may do. Calls without
+// an authenticated caller are rejected rather than run with the server's own
+// credentials.
+func impersonate(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id := authz.FromContext(ctx)
+		if !id.Authenticated() {
+			return mcp.NewToolResultError("impersonation is enabled but the caller is not authenticated"), nil
+		}
+		imp, err := impersonation(id)
+		if err != nil {
+			return toolError(err), nil
+		}
+		ctx = openshift.WithImpersonation(ctx, imp)
+		return next(ctx, req)
+	}
+}
+
+// toolError returns the error result for a failed call. Operations the
+// cluster refused are reported first, followed by anything else oc printed,
+// so they are not mistaken for transient failures.
+func toolError(err error) *mcp.CallToolResult {
+	var fe *openshift.ForbiddenError
+	if errors.As(err, &fe) {
+		return mcp.NewToolResultError(fe.Error())
+	}
+	return mcp.NewToolResultError(err.Error())
+}
+
+// toolMiddleware returns the middleware applied to every handler registered
+// by RegisterTools, outermost first.
+func toolMiddleware() []server.ToolHandlerMiddleware {
+	var mw []server.ToolHandlerMiddleware
+	if a := auditMiddleware(); a != nil {
+		mw = append(mw, a)
+	}
+	// Dry runs are planned by the rest of the chain, which records the
+	// policy and confirmation decisions instead of enforcing them.
+	mw = append(mw, dryrun.Middleware(dryrun.Forced(), openshift.CurrentServer))
+	// Authorization runs inside auditing so that denied calls are recorded.
+	if p, _ := policyMiddleware(); p != nil {
+		mw = append(mw, p)
+	}
+	if impersonationEnabled() {
+		mw = append(mw, impersonate)
+	}
+	// Confirmation runs after authorization so that tokens are only issued
+	// for calls the caller may make.
+	if c := confirmMiddleware(); c != nil {
+		mw = append(mw, c)
+	}
+	// Redaction runs inside auditing so the recorded output hash matches
+	// what the client actually received.
+	return append(mw, redactMiddleware())
+}
+
+// wrap applies mw to h so that mw[0] runs first.
+func wrap(h server.ToolHandlerFunc, mw []server.ToolHandlerMiddleware) server.ToolHandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
@@ -0,0 +1,297 @@
+package sdkserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/harche/crio-mcp-server/pkg/audit"
+	"github.com/harche/crio-mcp-server/pkg/authz"
+	"github.com/harche/crio-mcp-server/pkg/confirm"
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
+	"github.com/harche/crio-mcp-server/pkg/openshift"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestRegisterToolsAudits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv(audit.LogFileEnv, path)
+	auditOnce = sync.Once{}
+	defer func() { auditOnce = sync.Once{}; auditMW = nil }()
+	origOutput := openshift.Output
+	openshift.Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte("https://api.example.com:6443\n"), nil
+	}
+	defer func() { openshift.Output = origOutput }()
+
+	s := server.NewMCPServer("test", "0.0.1")
+	RegisterTools(s)
+	msg := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"attach_case_file","arguments":{"case_id":"0123","offline_token":"s3cret"}}}`
+	resp := s.HandleMessage(context.Background(), json.RawMessage(msg))
+	out, _ := json.Marshal(resp)
+	if !strings.Contains(string(out), "required argument") {
+		t.Fatalf("unexpected response %s", out)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Fatalf("secret logged: %s", data)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a started and an outcome record: %s", data)
+	}
+	var rec audit.Record
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatalf("unexpected log %s: %v", data, err)
+	}
+	if rec.Tool != "attach_case_file" || rec.Status != audit.StatusError {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+}
+
+func TestRegisterToolsRedacts(t *testing.T) {
+	t.Setenv(audit.LogFileEnv, "")
+	t.Setenv(audit.SyslogEnv, "")
+	auditOnce, redactOnce = sync.Once{}, sync.Once{}
+	defer func() {
+		auditOnce, redactOnce = sync.Once{}, sync.Once{}
+		auditMW, redactor, redactErr = nil, nil, nil
+	}()
+	origRun := openshift.Run
+	openshift.Run = func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte("KUBELET_PASSWORD=hunter2\n"), nil
+	}
+	defer func() { openshift.Run = origRun }()
+
+	s := server.NewMCPServer("test", "0.0.1")
+	RegisterTools(s)
+	msg := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"debug_node","arguments":{"node_name":"n1","commands":["env"]}}}`
+	out, _ := json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(msg)))
+	if strings.Contains(string(out), "hunter2") {
+		t.Fatalf("secret returned: %s", out)
+	}
+	if !strings.Contains(string(out), "redacted 1 secret (password: 1)") {
+		t.Fatalf("missing redaction note: %s", out)
+	}
+}
+
+func TestRegisterToolsPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(path, []byte("rules:\n  - subjects: [bob]\n    tools: [search_kcs, get_cve]\n"), 0o600)
+	t.Setenv(authz.PolicyFileEnv, path)
+	policyOnce = sync.Once{}
+	defer func() { policyOnce = sync.Once{}; policyMW, policyFilter = nil, nil }()
+
+	s := server.NewMCPServer("test", "0.0.1")
+	RegisterTools(s)
+	ctx := authz.WithIdentity(context.Background(), authz.Identity{Subject: "bob"})
+	out, _ := json.Marshal(s.HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)))
+	var list struct {
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	json.Unmarshal(out, &list)
+	var names []string
+	for _, tool := range list.Result.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "get_cve,search_kcs" {
+		t.Fatalf("unexpected tools %v", names)
+	}
+
+	msg := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"debug_node","arguments":{"node_name":"n1"}}}`
+	out, _ = json.Marshal(s.HandleMessage(ctx, json.RawMessage(msg)))
+	if !strings.Contains(string(out), "permission denied: bob may not use debug_node") {
+		t.Fatalf("unexpected response %s", out)
+	}
+}
+
+func TestPodNode(t *testing.T) {
+	origOutput := openshift.Output
+	defer func() { openshift.Output = origOutput }()
+	openshift.Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if strings.Join(args, " ") == "get pod -n openshift-etcd etcd-0 -o jsonpath={.spec.nodeName}" {
+			return []byte("master-0"), nil
+		}
+		return nil, nil
+	}
+	nodes, err := podNode(context.Background(), map[string]any{"namespace": "openshift-etcd", "pod_name": "etcd-0"})
+	if err != nil || len(nodes) != 1 || nodes[0] != "master-0" {
+		t.Fatalf("unexpected nodes %v: %v", nodes, err)
+	}
+	if _, err := podNode(context.Background(), map[string]any{"namespace": "app", "pod_name": "pending"}); err == nil || !strings.Contains(err.Error(), "not scheduled") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestImpersonation(t *testing.T) {
+	t.Setenv(ImpersonateEnv, "true")
+	for _, env := range []string{ImpersonateUserPrefixEnv, ImpersonateGroupPrefixEnv} {
+		// t.Setenv restores the variable after the test; the prefix defaults
+		// apply only while it is unset.
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+	origRun := openshift.Run
+	defer func() { openshift.Run = origRun }()
+	var as openshift.Impersonation
+	openshift.Run = func(ctx context.Context, args ...string) ([]byte, error) {
+		as, _ = openshift.ImpersonationFrom(ctx)
+		return nil, &openshift.ForbiddenError{As: as.User, Message: `pods is forbidden: User "bob" cannot create resource "pods"`, Err: errors.New("exit status 1")}
+	}
+	h := wrap(handleDebugNode, toolMiddleware())
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "debug_node", Arguments: map[string]any{"node_name": "n1"}}}
+
+	res, _ := h(context.Background(), req)
+	if !res.IsError || !strings.Contains(res.Content[0].(mcp.TextContent).Text, "not authenticated") {
+		t.Fatalf("anonymous call not rejected: %+v", res)
+	}
+
+	const issuer = "https://idp.example.com"
+	ctx := authz.WithIdentity(context.Background(), authz.Identity{Issuer: issuer, Subject: "bob", Groups: []string{"support"}})
+	res, _ = h(ctx, req)
+	if as.User != "oidc:bob" || len(as.Groups) != 1 || as.Groups[0] != "oidc:support" {
+		t.Fatalf("oc not impersonating the caller: %+v", as)
+	}
+	want := `forbidden by cluster RBAC for oidc:bob: pods is forbidden: User "bob" cannot create resource "pods"`
+	if !res.IsError || res.Content[0].(mcp.TextContent).Text != want {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	t.Setenv(ImpersonateUserPrefixEnv, "")
+	t.Setenv(ImpersonateGroupPrefixEnv, "")
+	as = openshift.Impersonation{}
+	for _, id := range []authz.Identity{
+		{Issuer: issuer, Subject: "system:admin"},
+		{Issuer: issuer, Subject: "bob", Groups: []string{"system:masters"}},
+		{Subject: "system:admin"},
+	} {
+		res, _ = h(authz.WithIdentity(context.Background(), id), req)
+		if !res.IsError || !strings.Contains(res.Content[0].(mcp.TextContent).Text, "is not allowed") || as.User != "" {
+			t.Fatalf("%+v: system name impersonated: %+v %+v", id, as, res)
+		}
+	}
+	res, _ = h(ctx, req)
+	if as.User != "bob" || as.Groups[0] != "support" {
+		t.Fatalf("prefix not disabled: %+v", as)
+	}
+
+	// Static tokens name cluster users directly and never get the prefix.
+	t.Setenv(ImpersonateUserPrefixEnv, "oidc:")
+	res, _ = h(authz.WithIdentity(context.Background(), authz.Identity{Subject: "ci-bot", Groups: []string{"ci"}}), req)
+	if as.User != "ci-bot" || as.Groups[0] != "ci" {
+		t.Fatalf("static token prefixed: %+v", as)
+	}
+}
+
+func TestRegisterToolsConfirms(t *testing.T) {
+	t.Setenv(audit.LogFileEnv, "")
+	t.Setenv(audit.SyslogEnv, "")
+	auditOnce, confirmOnce = sync.Once{}, sync.Once{}
+	defer func() {
+		auditOnce, confirmOnce = sync.Once{}, sync.Once{}
+		auditMW, confirmMW = nil, nil
+	}()
+	origRun := openshift.Run
+	defer func() { openshift.Run = origRun }()
+	var ran []string
+	openshift.Run = func(ctx context.Context, args ...string) ([]byte, error) {
+		ran = append(ran, strings.Join(args, " "))
+		return []byte("removed"), nil
+	}
+
+	s := server.NewMCPServer("test", "0.0.1")
+	RegisterTools(s)
+	call := func(token string) string {
+		args := map[string]any{"node_name": "n1", "args": []any{"rmp", "abc"}}
+		if token != "" {
+			args[confirm.TokenArg] = token
+		}
+		msg, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": map[string]any{"name": "run_crictl", "arguments": args}})
+		out, _ := json.Marshal(s.HandleMessage(context.Background(), msg))
+		return string(out)
+	}
+	out := call("")
+	if len(ran) != 0 || !strings.Contains(out, "confirmation required") || !strings.Contains(out, "crictl rmp abc") {
+		t.Fatalf("unconfirmed call ran or was not held back: %v %s", ran, out)
+	}
+	// The prompt is JSON-encoded, so the quotes around the token are escaped.
+	_, token, _ := strings.Cut(out, confirm.TokenArg+`=\"`)
+	token, _, _ = strings.Cut(token, `\"`)
+	if out := call(token); len(ran) != 1 || !strings.Contains(out, "removed") {
+		t.Fatalf("confirmed call did not run: %v %s", ran, out)
+	}
+
+	// Tools that run caller-supplied shell share one classifier.
+	for tool, cmd := range map[string]string{"traverse_cgroupfs": "systemctl restart crio", "debug_node": "crictl stopp abc"} {
+		msg, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": map[string]any{"name": tool, "arguments": map[string]any{"node_name": "n1", "commands": []any{cmd}}}})
+		out, _ := json.Marshal(s.HandleMessage(context.Background(), msg))
+		if len(ran) != 1 || !strings.Contains(string(out), "confirmation required") {
+			t.Fatalf("%s: unconfirmed %q ran or was not held back: %v %s", tool, cmd, ran, out)
+		}
+	}
+}
+
+func TestRegisterToolsDryRun(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv(audit.LogFileEnv, "")
+	t.Setenv(audit.SyslogEnv, "")
+	auditOnce, confirmOnce = sync.Once{}, sync.Once{}
+	defer func() {
+		auditOnce, confirmOnce = sync.Once{}, sync.Once{}
+		auditMW, confirmMW = nil, nil
+	}()
+
+	s := server.NewMCPServer("test", "0.0.1")
+	RegisterTools(s)
+	list, _ := json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)))
+	if n := strings.Count(string(list), `"dry_run"`); n == 0 || n != strings.Count(string(list), `"inputSchema"`) {
+		t.Fatalf("dry_run missing from some tools: %s", list)
+	}
+
+	msg := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"debug_node","arguments":{"node_name":"n1","commands":["uptime","systemctl restart crio"],"dry_run":true}}}`
+	out, _ := json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(msg)))
+	for _, want := range []string{
+		"dry run of debug_node: nothing was executed",
+		"node: n1",
+		"confirmation: required because",
+		`oc debug node/n1 -- chroot /host sh -c uptime`,
+		`oc debug node/n1 -- chroot /host sh -c 'systemctl restart crio'`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("dry run missing %q: %s", want, out)
+		}
+	}
+	if strings.Contains(string(out), "would fail") || strings.Contains(string(out), "isError") {
+		t.Fatalf("dry run reported a failure: %s", out)
+	}
+
+	t.Setenv(dryrun.Env, "true")
+	s = server.NewMCPServer("test", "0.0.1")
+	RegisterTools(s)
+	msg = `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"collect_sosreport","arguments":{"node_name":"n1","case_id":"0123"}}}`
+	out, _ = json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(msg)))
+	if !strings.Contains(string(out), "oc debug node/n1 -- chroot /host toolbox -- sosreport -k crio.all=on -k crio.logs=on --batch --case-id=0123") {
+		t.Fatalf("forced dry run did not plan sosreport: %s", out)
+	}
+
+	msg = `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"run_runbook","arguments":{"name":"runtime-health","params":{"node":"n1"}}}}`
+	out, _ = json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(msg)))
+	if strings.Count(string(out), "dry run of") != 1 || !strings.Contains(string(out), `tool calls:\n  debug_node\n`) ||
+		!strings.Contains(string(out), "oc debug node/n1 -- chroot /host sh -c 'crictl info'") {
+		t.Fatalf("forced dry run of a runbook did not collect its steps: %s", out)
+	}
+}
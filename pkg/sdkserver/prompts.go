@@ -0,0 +1,23 @@
+package sdkserver
+
+import (
+	"github.com/harche/crio-mcp-server/pkg/prompts"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RegisterPrompts adds the troubleshooting prompts to the provided MCP
+// server: the built-in runbooks and any found in CRIO_MCP_PROMPTS_DIR. If
+// that directory cannot be loaded nothing is registered and the error is
+// returned.
+func RegisterPrompts(s *server.MCPServer) error {
+	ps, err := prompts.FromEnv()
+	if err != nil {
+		return err
+	}
+	sp := make([]server.ServerPrompt, len(ps))
+	for i, p := range ps {
+		sp[i] = p.ServerPrompt()
+	}
+	s.AddPrompts(sp...)
+	return nil
+}
@@ -0,0 +1,39 @@
+package sdkserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/harche/crio-mcp-server/pkg/prompts"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestRegisterPrompts(t *testing.T) {
+	s := server.NewMCPServer("test", "0.0.1")
+	if err := RegisterPrompts(s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list, _ := json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)))
+	if !strings.Contains(string(list), `"name":"crashloop-no-logs"`) || !strings.Contains(string(list), `"required":true`) {
+		t.Fatalf("unexpected prompt list %s", list)
+	}
+
+	msg := `{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":{"name":"node-notready-pleg","arguments":{"node":"worker-3"}}}`
+	out, _ := json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(msg)))
+	if !strings.Contains(string(out), `"role":"user"`) || !strings.Contains(string(out), "Node worker-3 goes NotReady") {
+		t.Fatalf("unexpected prompt %s", out)
+	}
+
+	msg = `{"jsonrpc":"2.0","id":3,"method":"prompts/get","params":{"name":"node-notready-pleg"}}`
+	out, _ = json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(msg)))
+	if !strings.Contains(string(out), "argument node is required") {
+		t.Fatalf("missing argument accepted: %s", out)
+	}
+
+	t.Setenv(prompts.DirEnv, "/does/not/exist")
+	if err := RegisterPrompts(server.NewMCPServer("test", "0.0.1")); err == nil {
+		t.Fatal("expected error for missing prompts directory")
+	}
+}
@@ -4,17 +4,46 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/harche/crio-mcp-server/pkg/artifacts"
+	"github.com/harche/crio-mcp-server/pkg/confirm"
+	"github.com/harche/crio-mcp-server/pkg/dryrun"
+	"github.com/harche/crio-mcp-server/pkg/exposure"
+	"github.com/harche/crio-mcp-server/pkg/goroutines"
+	"github.com/harche/crio-mcp-server/pkg/imagestorage"
+	"github.com/harche/crio-mcp-server/pkg/mustgather"
+	"github.com/harche/crio-mcp-server/pkg/nodehealth"
 	"github.com/harche/crio-mcp-server/pkg/openshift"
+	"github.com/harche/crio-mcp-server/pkg/orphans"
+	"github.com/harche/crio-mcp-server/pkg/poddiag"
 	"github.com/harche/crio-mcp-server/pkg/redhat"
+	"github.com/harche/crio-mcp-server/pkg/runbook"
+	"github.com/harche/crio-mcp-server/pkg/sosreport"
 	mcp "github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// confirmationTokenParam is accepted by tools whose risky calls must be
+// confirmed before they run.
+var confirmationTokenParam = mcp.WithString(confirm.TokenArg,
+	mcp.Description("Token returned by a previous call that required confirmation. Only pass it after the user approved the exact command shown."),
+)
+
+// dryRunParam is added to every tool by RegisterTools.
+var dryRunParam = mcp.WithBoolean(dryrun.Arg,
+	mcp.Description("If true, return the exact commands and requests the call would make, the target cluster and node, and the policy decision, without executing anything"),
+	mcp.DefaultBool(false),
+)
+
 // debugNodeTool defines the debug_node MCP tool.
 var debugNodeTool = mcp.NewTool(
 	"debug_node",
@@ -31,13 +60,14 @@ Use "oc -h" to discover the full set of commands and flags provided by the OpenS
 		mcp.Items(map[string]any{"type": "string"}),
 	),
 	mcp.WithBoolean("collect_files",
-		mcp.Description("If true, copy the specified paths back and return them as resources"),
+		mcp.Description("If true, copy the specified paths into a tar.gz artifact, with secrets redacted from text files, and return its location and manifest"),
 		mcp.DefaultBool(false),
 	),
 	mcp.WithArray("paths",
-		mcp.Description("File or directory paths on the host to retrieve (requires collect_files=true)"),
+		mcp.Description("Absolute file or directory paths on the host to retrieve (requires collect_files=true). Paths must be within the server's allowed host paths, such as /etc/crio, /etc/kubernetes or /var/log; credentials and pod volumes are always excluded"),
 		mcp.Items(map[string]any{"type": "string"}),
 	),
+	confirmationTokenParam,
 )
 
 // nodeLogsTool defines the collect_node_logs MCP tool.
@@ -53,7 +83,7 @@ var nodeLogsTool = mcp.NewTool(
 		mcp.Description("RFC3339 timestamp or relative value understood by journalctl/oc (e.g. '2h')"),
 	),
 	mcp.WithBoolean("compress",
-		mcp.Description("If true, return logs as a gzip tarball resource instead of inline text"),
+		mcp.Description("If true, stream the logs to a gzip-compressed artifact, with secrets redacted, and return its location instead of inline text"),
 		mcp.DefaultBool(false),
 	),
 )
@@ -86,6 +116,52 @@ oc adm must-gather can scoop up almost every artifact engineers or support need
 		mcp.Description("Additional arguments passed directly to oc adm must-gather"),
 		mcp.Items(map[string]any{"type": "string"}),
 	),
+	mcp.WithString("case_id",
+		mcp.Description("Red Hat support case the gathered data belongs to"),
+	),
+	mcp.WithBoolean("upload",
+		mcp.Description("If true, archive the gathered directory and attach it to the support case given by case_id. Without dest_dir the data is written to a new directory in the artifact store; a dest_dir outside the artifact store is refused."),
+		mcp.DefaultBool(false),
+	),
+	mcp.WithString("offline_token",
+		mcp.Description("Offline access token used to authenticate the upload (default: the token configured on the server)"),
+	),
+	confirmationTokenParam,
+)
+
+// analyzeMustGatherTool defines the analyze_must_gather MCP tool.
+var analyzeMustGatherTool = mcp.NewTool(
+	"analyze_must_gather",
+	mcp.WithTitleAnnotation("Query a must-gather offline"),
+	mcp.WithDescription(`Indexes a must-gather directory or tarball and answers questions about it without contacting the cluster.
+
+Use this on output from collect_must_gather or on customer-provided bundles. Queries cover cluster operator status, node conditions and taints, MachineConfigPool state, failing pods and CRI-O or kubelet journal lines. Status queries show only unhealthy objects unless all=true.`),
+	mcp.WithString("path",
+		mcp.Description("Local must-gather directory or .tar/.tar.gz archive in the artifact directory or CRIO_MCP_READ_DIRS"),
+		mcp.Required(),
+	),
+	mcp.WithString("query",
+		mcp.Description("What to report"),
+		mcp.Enum(mustgather.QueryNames...),
+		mcp.DefaultString(mustgather.QuerySummary),
+	),
+	mcp.WithString("node",
+		mcp.Description("Limit node, pod and log queries to this node"),
+	),
+	mcp.WithString("namespace",
+		mcp.Description("Limit pod queries to this namespace"),
+	),
+	mcp.WithString("pattern",
+		mcp.Description("Regular expression for log queries (default matches errors, failures and panics)"),
+	),
+	mcp.WithBoolean("all",
+		mcp.Description("Include healthy objects in status queries"),
+		mcp.DefaultBool(false),
+	),
+	mcp.WithNumber("max_lines",
+		mcp.Description("Maximum number of log lines to return"),
+		mcp.DefaultNumber(200),
+	),
 )
 
 // crictlTool defines the run_crictl MCP tool.
@@ -105,6 +181,7 @@ Use the -h flag to discover available subcommands.`),
 		mcp.Description("Arguments passed directly to crictl (default: ['ps'])"),
 		mcp.Items(map[string]any{"type": "string"}),
 	),
+	confirmationTokenParam,
 )
 
 // cgroupfsTool defines the traverse_cgroupfs MCP tool.
@@ -122,13 +199,72 @@ Cgroup files are the ground truth for how the Linux kernel enforces every pod's
 		mcp.Description("Shell commands executed inside the debug pod (default: list memory.current for all pods)"),
 		mcp.Items(map[string]any{"type": "string"}),
 	),
+	confirmationTokenParam,
+)
+
+// nodeProfileTool defines the capture_node_profile MCP tool.
+var nodeProfileTool = mcp.NewTool(
+	"capture_node_profile",
+	mcp.WithTitleAnnotation("Capture CRI-O and kubelet pprof profiles from a node"),
+	mcp.WithDescription(`Captures pprof profiles from a single node without running a full must-gather.
+
+CRI-O is profiled over its unix socket, which requires enable_profile_unix_socket in crio.conf. The kubelet is profiled through the apiserver node proxy at /api/v1/nodes/<node>/proxy/debug/pprof. Each profile is stored as an artifact and summarized with "go tool pprof -top".`),
+	mcp.WithString("node_name",
+		mcp.Description("Node to profile"),
+		mcp.Required(),
+	),
+	mcp.WithString("component",
+		mcp.Description("Component to profile"),
+		mcp.Enum("both", openshift.ComponentCrio, openshift.ComponentKubelet),
+		mcp.DefaultString("both"),
+	),
+	mcp.WithString("profile_type",
+		mcp.Description("Profile type to capture"),
+		mcp.Enum(openshift.ProfileTypes...),
+		mcp.DefaultString("cpu"),
+	),
+	mcp.WithNumber("seconds",
+		mcp.Description("Sampling duration for cpu profiles, or delta window for the other types (default: 30 for cpu, snapshot otherwise)"),
+	),
+)
+
+// crioGoroutinesTool defines the dump_crio_goroutines MCP tool.
+var crioGoroutinesTool = mcp.NewTool(
+	"dump_crio_goroutines",
+	mcp.WithTitleAnnotation("Dump and analyze CRI-O goroutine stacks"),
+	mcp.WithDescription(`Sends SIGUSR1 to CRI-O on the node, retrieves the /tmp/crio-goroutine-stacks-*.log file it writes, or the crio journal when the stacks were logged there, and summarizes it.
+
+Goroutines are grouped by wait reason and call stack with counts and blocked durations, and groups waiting on mutexes or semaphores are highlighted as likely lock contention. This is the first thing to look at when CRI-O hangs or requests time out. The raw dump is stored as an artifact.`),
+	mcp.WithString("node_name",
+		mcp.Description("Node running the CRI-O instance to dump"),
+		mcp.Required(),
+	),
+	mcp.WithNumber("max_stacks",
+		mcp.Description("Maximum number of grouped stacks to print (0 for all)"),
+		mcp.DefaultNumber(30),
+	),
+)
+
+// goroutineDumpTool defines the analyze_goroutine_dump MCP tool.
+var goroutineDumpTool = mcp.NewTool(
+	"analyze_goroutine_dump",
+	mcp.WithTitleAnnotation("Analyze a Go goroutine dump file"),
+	mcp.WithDescription("Parses a local goroutine stack dump, for example from an artifact or a sosreport, and groups stacks the same way as dump_crio_goroutines without contacting the cluster."),
+	mcp.WithString("path",
+		mcp.Description("Local path of the goroutine dump file in the artifact directory or CRIO_MCP_READ_DIRS"),
+		mcp.Required(),
+	),
+	mcp.WithNumber("max_stacks",
+		mcp.Description("Maximum number of grouped stacks to print (0 for all)"),
+		mcp.DefaultNumber(30),
+	),
 )
 
 // handleDebugNode executes oc debug with the provided arguments.
 func handleDebugNode(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	nodeName, err := req.RequireString("node_name")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	if req.GetBool("collect_files", false) {
 		pathsAny, _ := req.GetArguments()["paths"].([]any)
@@ -137,16 +273,30 @@ func handleDebugNode(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 			for i, p := range pathsAny {
 				paths[i] = fmt.Sprint(p)
 			}
-			data, err := openshift.CopyFilesFromNode(ctx, nodeName, paths)
+			red, err := loadRedactor()
+			if err != nil {
+				return toolError(err), nil
+			}
+			w, err := artifacts.Create(fmt.Sprintf("debug-node-%s-%s.tar.gz", nodeName, time.Now().UTC().Format("20060102T150405Z")))
+			if err != nil {
+				return toolError(err), nil
+			}
+			defer w.Abort()
+			// Text files are redacted as the archive streams to disk.
+			aw := red.NewArchiveWriter(w)
+			archive, err := openshift.CopyFilesFromNode(ctx, nodeName, paths, aw)
+			rep, redactErr := aw.Close()
 			if err != nil {
-				return mcp.NewToolResultError(err.Error()), nil
+				return toolError(err), nil
+			}
+			if redactErr != nil {
+				return toolError(fmt.Errorf("redact archive from node %s: %w", nodeName, redactErr)), nil
 			}
-			res := mcp.BlobResourceContents{
-				URI:      "debug-node-files.tar.gz",
-				MIMEType: "application/tar+gzip",
-				Blob:     base64.StdEncoding.EncodeToString(data),
+			art, err := w.Commit()
+			if err != nil {
+				return toolError(err), nil
 			}
-			return mcp.NewToolResultResource("collected files", res), nil
+			return mcp.NewToolResultText(fmt.Sprintf("archive saved to %s (%s)\ncollected files: %s", art, rep, archive.FormatManifest())), nil
 		}
 	}
 
@@ -157,8 +307,12 @@ func handleDebugNode(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 	var output bytes.Buffer
 	for _, cmd := range commands {
 		out, err := openshift.DebugNode(ctx, nodeName, fmt.Sprint(cmd))
+		if errors.Is(err, dryrun.ErrSkipped) {
+			// The commands are independent, so a dry run lists them all.
+			continue
+		}
 		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+			return toolError(err), nil
 		}
 		output.WriteString(out)
 	}
@@ -169,35 +323,42 @@ func handleDebugNode(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 func handleNodeLogs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	nodeName, err := req.RequireString("node_name")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	since := req.GetString("since", "")
-	compressLogs := req.GetBool("compress", false)
-	out, err := openshift.NodeLogs(ctx, nodeName, since)
-	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
-	}
-
-	if !compressLogs {
+	if !req.GetBool("compress", false) {
+		out, err := openshift.NodeLogs(ctx, nodeName, since)
+		if err != nil {
+			return toolError(err), nil
+		}
 		return mcp.NewToolResultText(out), nil
 	}
 
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	if _, err := gz.Write([]byte(out)); err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+	red, err := loadRedactor()
+	if err != nil {
+		return toolError(err), nil
+	}
+	w, err := artifacts.Create(fmt.Sprintf("node-logs-%s-%s.txt.gz", nodeName, time.Now().UTC().Format("20060102T150405Z")))
+	if err != nil {
+		return toolError(err), nil
+	}
+	defer w.Abort()
+	gz := gzip.NewWriter(w)
+	rw := red.NewWriter(gz)
+	if err := openshift.StreamNodeLogs(ctx, nodeName, since, rw); err != nil {
+		return toolError(err), nil
+	}
+	if err := rw.Close(); err != nil {
+		return toolError(err), nil
 	}
 	if err := gz.Close(); err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
-
-	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
-	res := mcp.BlobResourceContents{
-		URI:      fmt.Sprintf("node-logs-%s.txt.gz", nodeName),
-		MIMEType: "application/gzip",
-		Blob:     encoded,
+	art, err := w.Commit()
+	if err != nil {
+		return toolError(err), nil
 	}
-	return mcp.NewToolResultResource("Node logs compressed", res), nil
+	return mcp.NewToolResultText(fmt.Sprintf("node logs compressed to %s (%s)", art, rw.Report())), nil
 }
 
 // handlePprof runs "go tool pprof" with the provided arguments and returns the output.
@@ -210,12 +371,119 @@ func handlePprof(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRes
 	for i, a := range argsAny {
 		args[i] = fmt.Sprint(a)
 	}
+	out, err := runPprof(ctx, args...)
+	if err != nil {
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(out), nil
+}
+
+// runPprof executes "go tool pprof" with args and returns its combined output.
+func runPprof(ctx context.Context, args ...string) (string, error) {
+	if p := dryrun.FromContext(ctx); p != nil {
+		return "", p.Record(dryrun.Command("go", append([]string{"tool", "pprof"}, args...)...))
+	}
 	cmd := exec.CommandContext(ctx, "go", append([]string{"tool", "pprof"}, args...)...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Errorf("pprof failed: %w: %s", err, out).Error()), nil
+		return "", fmt.Errorf("pprof failed: %w: %s", err, out)
 	}
-	return mcp.NewToolResultText(string(out)), nil
+	return string(out), nil
+}
+
+// handleNodeProfile captures pprof profiles from CRI-O and/or the kubelet on a
+// node, stores them as artifacts and returns a top-N summary of each.
+func handleNodeProfile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	nodeName, err := req.RequireString("node_name")
+	if err != nil {
+		return toolError(err), nil
+	}
+	component := req.GetString("component", "both")
+	profile := req.GetString("profile_type", "cpu")
+	seconds := req.GetInt("seconds", 0)
+
+	components := []string{component}
+	if component == "both" {
+		components = []string{openshift.ComponentCrio, openshift.ComponentKubelet}
+	}
+	var output bytes.Buffer
+	for _, c := range components {
+		data, err := openshift.NodeProfile(ctx, nodeName, c, profile, seconds)
+		if errors.Is(err, dryrun.ErrSkipped) {
+			continue
+		}
+		if err != nil {
+			return toolError(err), nil
+		}
+		name := fmt.Sprintf("%s-%s-%s-%s.pb.gz", c, profile, nodeName, time.Now().UTC().Format("20060102T150405Z"))
+		art, err := artifacts.Save(name, data)
+		if err != nil {
+			return toolError(err), nil
+		}
+		fmt.Fprintf(&output, "== %s %s profile: %s\n", c, profile, art)
+		summary, err := runPprof(ctx, "-top", "-nodecount=20", art.Path)
+		if err != nil {
+			fmt.Fprintf(&output, "summary unavailable: %v\n", err)
+			continue
+		}
+		output.WriteString(summary)
+	}
+	return mcp.NewToolResultText(output.String()), nil
+}
+
+// handleCrioGoroutines dumps CRI-O goroutine stacks on a node and summarizes them.
+func handleCrioGoroutines(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	nodeName, err := req.RequireString("node_name")
+	if err != nil {
+		return toolError(err), nil
+	}
+	data, err := openshift.CrioGoroutines(ctx, nodeName)
+	if err != nil {
+		return toolError(err), nil
+	}
+	name := fmt.Sprintf("crio-goroutine-stacks-%s-%s.log", nodeName, time.Now().UTC().Format("20060102T150405Z"))
+	art, err := artifacts.Save(name, data)
+	if err != nil {
+		return toolError(err), nil
+	}
+	summary, err := summarizeGoroutines(bytes.NewReader(data), req.GetInt("max_stacks", 30))
+	if err != nil {
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("dump stored at %s\n\n%s", art, summary)), nil
+}
+
+// handleGoroutineDump analyzes a goroutine dump stored on the local filesystem.
+func handleGoroutineDump(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolError(err), nil
+	}
+	path, err = artifacts.ResolveReadable(path)
+	if err != nil {
+		return toolError(err), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return toolError(err), nil
+	}
+	defer f.Close()
+	summary, err := summarizeGoroutines(f, req.GetInt("max_stacks", 30))
+	if err != nil {
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(summary), nil
+}
+
+func summarizeGoroutines(r io.Reader, maxStacks int) (string, error) {
+	gs, err := goroutines.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	if len(gs) == 0 {
+		return "", fmt.Errorf("no goroutines found in dump")
+	}
+	return goroutines.Analyze(gs).Format(maxStacks), nil
 }
 
 // handleMustGather executes oc adm must-gather with the provided arguments.
@@ -226,9 +494,73 @@ func handleMustGather(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTo
 	for i, a := range extraAny {
 		extras[i] = fmt.Sprint(a)
 	}
+	caseID := req.GetString("case_id", "")
+	upload := req.GetBool("upload", false)
+	if upload && caseID == "" {
+		return mcp.NewToolResultError("case_id is required when upload is true"), nil
+	}
+	created := ""
+	switch {
+	case upload && dest == "":
+		dir, err := artifacts.NewDir("must-gather")
+		if err != nil {
+			return toolError(err), nil
+		}
+		dest, created = dir, dir
+	case upload:
+		// Only data in the artifact store may be sent to a case.
+		dir, err := artifacts.Resolve(dest)
+		if err != nil {
+			return toolError(fmt.Errorf("dest_dir must be an existing directory in the artifact store to upload: %w", err)), nil
+		}
+		dest = dir
+	}
 	out, err := openshift.MustGather(ctx, dest, extras)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		if created != "" {
+			os.RemoveAll(created)
+		}
+		return toolError(err), nil
+	}
+	if !upload {
+		return mcp.NewToolResultText(out), nil
+	}
+	art, err := artifacts.SaveDir(fmt.Sprintf("must-gather-%s-%s.tar.gz", caseID, time.Now().UTC().Format("20060102T150405Z")), dest)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("%s\n%v", out, err)), nil
+	}
+	result := fmt.Sprintf("%s\narchive written to %s", out, art)
+	up, err := redhat.UploadAttachment(ctx, req.GetString("offline_token", ""), caseID, art.Path)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("%s\n%v", result, err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("%s\n%s", result, uploadSummary(up))), nil
+}
+
+// handleAnalyzeMustGather answers a query against a local must-gather.
+func handleAnalyzeMustGather(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, err := req.RequireString("path")
+	if err != nil {
+		return toolError(err), nil
+	}
+	path, err = artifacts.ResolveReadable(path)
+	if err != nil {
+		return toolError(err), nil
+	}
+	bundle, err := mustgather.Open(path)
+	if err != nil {
+		return toolError(err), nil
+	}
+	defer bundle.Close()
+	out, err := bundle.Query(req.GetString("query", mustgather.QuerySummary), mustgather.QueryOptions{
+		Node:      req.GetString("node", ""),
+		Namespace: req.GetString("namespace", ""),
+		Pattern:   req.GetString("pattern", ""),
+		All:       req.GetBool("all", false),
+		MaxLines:  req.GetInt("max_lines", 200),
+	})
+	if err != nil {
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -237,7 +569,7 @@ func handleMustGather(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTo
 func handleCrictl(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	nodeName, err := req.RequireString("node_name")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	argsAny, _ := req.GetArguments()["args"].([]any)
 	args := make([]string, len(argsAny))
@@ -249,7 +581,7 @@ func handleCrictl(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 	}
 	out, err := openshift.Crictl(ctx, nodeName, args)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -258,7 +590,7 @@ func handleCrictl(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 func handleTraverseCgroupfs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	nodeName, err := req.RequireString("node_name")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	cmdsAny, _ := req.GetArguments()["commands"].([]any)
 	var script string
@@ -273,7 +605,7 @@ func handleTraverseCgroupfs(ctx context.Context, req mcp.CallToolRequest) (*mcp.
 	}
 	out, err := openshift.DebugNode(ctx, nodeName, script)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -282,7 +614,7 @@ func handleTraverseCgroupfs(ctx context.Context, req mcp.CallToolRequest) (*mcp.
 var sosReportTool = mcp.NewTool(
 	"collect_sosreport",
 	mcp.WithTitleAnnotation("Collect sosreport from a node"),
-	mcp.WithDescription(`Runs "sosreport" in a debug pod via toolbox to collect node diagnostics.`),
+	mcp.WithDescription(`Runs "sosreport" in a debug pod via toolbox to collect node diagnostics. The generated archive is copied back into the local artifact store so it can be inspected with analyze_sosreport.`),
 	mcp.WithString("node_name",
 		mcp.Description("Node to collect sosreport from"),
 		mcp.Required(),
@@ -290,6 +622,135 @@ var sosReportTool = mcp.NewTool(
 	mcp.WithString("case_id",
 		mcp.Description("Optional Red Hat support case ID"),
 	),
+	mcp.WithBoolean("upload",
+		mcp.Description("If true, attach the retrieved archive to the support case given by case_id"),
+		mcp.DefaultBool(false),
+	),
+	mcp.WithString("offline_token",
+		mcp.Description("Offline access token used to authenticate the upload (default: the token configured on the server)"),
+	),
+)
+
+// attachCaseFileTool defines the attach_case_file MCP tool.
+var attachCaseFileTool = mcp.NewTool(
+	"attach_case_file",
+	mcp.WithTitleAnnotation("Attach a local file to a support case"),
+	mcp.WithDescription("Uploads a file from the artifact directory, such as a retrieved sosreport or must-gather archive, to a Red Hat support case. Files outside the artifact directory are refused."),
+	mcp.WithString("path",
+		mcp.Description("Path of the file to attach; it must be in the artifact directory"),
+		mcp.Required(),
+	),
+	mcp.WithString("case_id",
+		mcp.Description("Red Hat support case number"),
+		mcp.Required(),
+	),
+	mcp.WithString("offline_token",
+		mcp.Description("Offline access token for authentication (default: the token configured on the server)"),
+	),
+)
+
+// listCasesTool defines the list_cases MCP tool.
+var listCasesTool = mcp.NewTool(
+	"list_cases",
+	mcp.WithTitleAnnotation("List support cases"),
+	mcp.WithDescription("Lists Red Hat support cases visible to the account, with number, severity, status and summary."),
+	mcp.WithString("status",
+		mcp.Description("Only return cases in this status (e.g. 'Waiting on Red Hat', 'Waiting on Customer')"),
+	),
+	mcp.WithString("keyword",
+		mcp.Description("Only return cases whose summary or description matches this keyword"),
+	),
+	mcp.WithBoolean("include_closed",
+		mcp.Description("Include closed cases"),
+		mcp.DefaultBool(false),
+	),
+	mcp.WithNumber("max_results",
+		mcp.Description("Maximum number of cases to return"),
+		mcp.DefaultNumber(20),
+	),
+	mcp.WithString("offline_token",
+		mcp.Description("Offline access token for authentication (default: the token configured on the server)"),
+	),
+)
+
+// getCaseTool defines the get_case MCP tool.
+var getCaseTool = mcp.NewTool(
+	"get_case",
+	mcp.WithTitleAnnotation("Read a support case"),
+	mcp.WithDescription("Retrieves a Red Hat support case including its description and comment history."),
+	mcp.WithString("case_id",
+		mcp.Description("Red Hat support case number"),
+		mcp.Required(),
+	),
+	mcp.WithString("offline_token",
+		mcp.Description("Offline access token for authentication (default: the token configured on the server)"),
+	),
+)
+
+// addCaseCommentTool defines the add_case_comment MCP tool.
+var addCaseCommentTool = mcp.NewTool(
+	"add_case_comment",
+	mcp.WithTitleAnnotation("Comment on a support case"),
+	mcp.WithDescription("Adds a public comment to a Red Hat support case. The comment is visible to Red Hat support and everyone with access to the case. Disabled unless the server runs with REDHAT_ALLOW_CASE_COMMENTS=true."),
+	mcp.WithString("case_id",
+		mcp.Description("Red Hat support case number"),
+		mcp.Required(),
+	),
+	mcp.WithString("comment",
+		mcp.Description("Comment text"),
+		mcp.Required(),
+	),
+	mcp.WithString("offline_token",
+		mcp.Description("Offline access token for authentication (default: the token configured on the server)"),
+	),
+)
+
+// createCaseTool defines the create_case MCP tool.
+var createCaseTool = mcp.NewTool(
+	"create_case",
+	mcp.WithTitleAnnotation("Open a support case"),
+	mcp.WithDescription("Opens a new Red Hat support case and returns its case number, which can be passed as case_id to collect_sosreport, collect_must_gather and attach_case_file."),
+	mcp.WithString("summary",
+		mcp.Description("One-line summary of the problem"),
+		mcp.Required(),
+	),
+	mcp.WithString("description",
+		mcp.Description("Detailed description: what happened, impact, and what has been tried"),
+		mcp.Required(),
+	),
+	mcp.WithString("product",
+		mcp.Description("Product the case is about"),
+		mcp.DefaultString("OpenShift Container Platform"),
+	),
+	mcp.WithString("version",
+		mcp.Description("Product version (e.g. 4.14)"),
+	),
+	mcp.WithString("severity",
+		mcp.Description("Case severity"),
+		mcp.Enum(redhat.CaseSeverities...),
+		mcp.DefaultString("3 (Normal)"),
+	),
+	mcp.WithString("offline_token",
+		mcp.Description("Offline access token for authentication (default: the token configured on the server)"),
+	),
+)
+
+// analyzeSosReportTool defines the analyze_sosreport MCP tool.
+var analyzeSosReportTool = mcp.NewTool(
+	"analyze_sosreport",
+	mcp.WithTitleAnnotation("Summarize container runtime state from a sosreport"),
+	mcp.WithDescription(`Extracts container runtime state from a local sosreport archive or directory without contacting the cluster.
+
+The summary covers crictl ps/pods/images/info output from the crio plugin, CRI-O configuration files, storage.conf, kernel messages of interest, systemd unit states and runtime package versions. Works on archives retrieved by collect_sosreport as well as customer-provided tarballs.`),
+	mcp.WithString("path",
+		mcp.Description("Local sosreport archive (.tar.xz, .tar.gz) or extracted directory in the artifact directory or CRIO_MCP_READ_DIRS"),
+		mcp.Required(),
+	),
+	mcp.WithString("section",
+		mcp.Description("Section to return"),
+		mcp.Enum(sosreport.SectionNames...),
+		mcp.DefaultString(sosreport.SectionSummary),
+	),
 )
 
 // networkLogsTool defines the gather_network_logs MCP tool.
@@ -358,6 +819,81 @@ var podLogsTool = mcp.NewTool(
 	),
 )
 
+// diagnosePodTool defines the diagnose_pod MCP tool.
+var diagnosePodTool = mcp.NewTool(
+	"diagnose_pod",
+	mcp.WithTitleAnnotation("Find why a pod does not start"),
+	mcp.WithDescription(`Works out at which stage a pod fails to start and returns the evidence.
+
+Reads the pod's events, finds its node, looks up its sandbox with "crictl pods --name", inspects the sandbox and its containers, reads the node journal for lines about the sandbox and reads the logs of a container that exited. The verdict names the earliest stage that failed: scheduling, volume mount, sandbox creation, CNI, image pull, container create, container start or runtime exit, or none when the pod is running.`),
+	mcp.WithString("namespace",
+		mcp.Description("Namespace of the pod"),
+		mcp.Required(),
+	),
+	mcp.WithString("pod_name",
+		mcp.Description("Name of the pod"),
+		mcp.Required(),
+	),
+	mcp.WithString("since",
+		mcp.Description("How far back to read the node journal, as understood by oc adm node-logs (default '-1h')"),
+	),
+)
+
+// nodeHealthReportTool defines the node_health_report MCP tool.
+var nodeHealthReportTool = mcp.NewTool(
+	"node_health_report",
+	mcp.WithTitleAnnotation("Score the health of a node"),
+	mcp.WithDescription(`Gathers a node's health from the runtime's point of view and scores each area as ok, warning, critical or unknown.
+
+Reads the node's conditions and taints, then runs one debug pod that collects "crictl info", the state and restart count of the crio and kubelet units, disk and inode usage of /var/lib/containers, /var/lib/kubelet and /, pressure stall information from /proc/pressure and OOM and other kernel errors of the last hour. CPU and memory usage come from "oc adm top nodes". Returns a summary, worst areas first, followed by the full report as JSON.`),
+	mcp.WithString("node_name",
+		mcp.Description("Node to inspect"),
+		mcp.Required(),
+	),
+)
+
+// analyzeImageStorageTool defines the analyze_image_storage MCP tool.
+var analyzeImageStorageTool = mcp.NewTool(
+	"analyze_image_storage",
+	mcp.WithTitleAnnotation("Analyze container image storage on a node"),
+	mcp.WithDescription(`Reports what uses the container storage under /var/lib/containers on a node and how much space could be freed.
+
+Runs one debug pod that reads "crictl images", "crictl ps -a" and "crictl pods", the usage of the filesystem, the overlay driver's images.json, layers.json and containers.json and the size of every layer directory. Lists images largest first with their size, the size only they use and the containers that use them, and reports unused and dangling images, layers no image or container refers to, layer directories storage does not know and containers left in storage that CRI-O does not list. Returns a summary followed by the full report as JSON. Nothing is removed; use prune_image_storage for that.`),
+	mcp.WithString("node_name",
+		mcp.Description("Node to inspect"),
+		mcp.Required(),
+	),
+)
+
+// pruneImageStorageTool defines the prune_image_storage MCP tool.
+var pruneImageStorageTool = mcp.NewTool(
+	"prune_image_storage",
+	mcp.WithTitleAnnotation("Remove images from a node"),
+	mcp.WithDescription(`Removes images from a node with "crictl rmi". Without images it runs "crictl rmi --prune", which removes every image no container uses. Pods that need a removed image pull it again. Every call must be confirmed.`),
+	mcp.WithString("node_name",
+		mcp.Description("Node to prune"),
+		mcp.Required(),
+	),
+	mcp.WithArray("images",
+		mcp.Description("IDs or references of the images to remove, for example those analyze_image_storage reports unused (default: all unused images)"),
+		mcp.Items(map[string]any{"type": "string"}),
+	),
+	confirmationTokenParam,
+)
+
+// runtimeOrphansTool defines the find_runtime_orphans MCP tool.
+var runtimeOrphansTool = mcp.NewTool(
+	"find_runtime_orphans",
+	mcp.WithTitleAnnotation("Find orphaned sandboxes, containers and conmon processes"),
+	mcp.WithDescription(`Cross-references the pods the API server places on a node with the sandboxes and containers CRI-O lists, the conmon, runc and crun processes running on the node and the network namespaces under /var/run/netns.
+
+Reports each class of inconsistency: pods without sandbox, sandboxes without pod, containers without sandbox, conmon processes without container or of exited containers, running containers without conmon, long-running runc or crun calls, network namespaces without sandbox and ready sandboxes whose network namespace is gone. Pods, sandboxes and conmon processes younger than two minutes are ignored. Returns a summary followed by the full report as JSON. Nothing is removed.`),
+	mcp.WithString("node_name",
+		mcp.Description("Node to inspect"),
+		mcp.Required(),
+	),
+)
+
 // nodeConfigTool defines the collect_node_config MCP tool.
 var nodeConfigTool = mcp.NewTool(
 	"collect_node_config",
@@ -373,7 +909,7 @@ var nodeConfigTool = mcp.NewTool(
 var kcsSearchTool = mcp.NewTool(
 	"search_kcs",
 	mcp.WithTitleAnnotation("Search Red Hat Knowledge Base"),
-	mcp.WithDescription("Queries the Customer Portal Knowledge Base via the Case Management API."),
+	mcp.WithDescription("Queries the Customer Portal Knowledge Base and returns ranked results with title, URL, products, last modified date and abstract, issue and resolution snippets. Use get_kcs_article to read a result in full."),
 	mcp.WithString("query",
 		mcp.Description("Search query string"),
 		mcp.Required(),
@@ -382,10 +918,33 @@ var kcsSearchTool = mcp.NewTool(
 		mcp.Description("Number of results to return"),
 		mcp.DefaultNumber(20),
 	),
+	mcp.WithString("product",
+		mcp.Description("Restrict results to a product (e.g. 'OpenShift Container Platform', 'Red Hat Enterprise Linux')"),
+	),
+	mcp.WithString("version",
+		mcp.Description("Restrict results to a product version (e.g. '4.14', '9')"),
+	),
+	mcp.WithNumber("max_chars",
+		mcp.Description("Approximate output budget in characters; lower-ranked results are dropped to fit"),
+		mcp.DefaultNumber(8000),
+	),
 	mcp.WithString("offline_token",
-		mcp.Description("Offline access token for authentication"),
+		mcp.Description("Offline access token for authentication (default: the token configured on the server)"),
+	),
+)
+
+// kcsArticleTool defines the get_kcs_article MCP tool.
+var kcsArticleTool = mcp.NewTool(
+	"get_kcs_article",
+	mcp.WithTitleAnnotation("Read a Red Hat Knowledge Base article"),
+	mcp.WithDescription("Fetches the full text of a single Knowledge Base solution or article, including environment, issue, root cause, resolution and diagnostic steps."),
+	mcp.WithString("id",
+		mcp.Description("Document ID, e.g. the number in https://access.redhat.com/solutions/1234567"),
 		mcp.Required(),
 	),
+	mcp.WithString("offline_token",
+		mcp.Description("Offline access token for authentication (default: the token configured on the server)"),
+	),
 )
 
 // cveInfoTool defines the get_cve MCP tool.
@@ -399,16 +958,232 @@ var cveInfoTool = mcp.NewTool(
 	),
 )
 
+// searchCVEsTool defines the search_cves MCP tool.
+var searchCVEsTool = mcp.NewTool(
+	"search_cves",
+	mcp.WithTitleAnnotation("Search CVEs"),
+	mcp.WithDescription("Lists CVEs from the Red Hat Security Data API affecting the given packages, optionally filtered by severity and public date, newest first. Useful for finding the runtime CVEs published since a cluster's last upgrade."),
+	mcp.WithArray("packages",
+		mcp.Description("Package names to search (default: ['cri-o', 'runc', 'conmon', 'crun', 'containers-common'])"),
+		mcp.Items(map[string]any{"type": "string"}),
+	),
+	mcp.WithString("severity",
+		mcp.Description("Only return CVEs with this severity"),
+		mcp.Enum(redhat.CVESeverities...),
+	),
+	mcp.WithString("after",
+		mcp.Description("Only return CVEs published after this date (YYYY-MM-DD)"),
+	),
+	mcp.WithString("before",
+		mcp.Description("Only return CVEs published before this date (YYYY-MM-DD)"),
+	),
+	mcp.WithNumber("per_page",
+		mcp.Description("Maximum number of CVEs returned per package"),
+		mcp.DefaultNumber(50),
+	),
+)
+
+// erratumTool defines the get_erratum MCP tool.
+var erratumTool = mcp.NewTool(
+	"get_erratum",
+	mcp.WithTitleAnnotation("Fetch an erratum"),
+	mcp.WithDescription("Retrieves a Red Hat advisory (RHSA, RHBA or RHEA) including its synopsis, severity, CVEs and the fixed package NVRs."),
+	mcp.WithString("advisory_id",
+		mcp.Description("Advisory identifier (e.g. RHSA-2024:0670)"),
+		mcp.Required(),
+	),
+	mcp.WithArray("packages",
+		mcp.Description("Only list fixed builds of these package names (default: all)"),
+		mcp.Items(map[string]any{"type": "string"}),
+	),
+	mcp.WithString("offline_token",
+		mcp.Description("Offline access token for authentication (default: the token configured on the server)"),
+	),
+)
+
+// cveExposureTool defines the check_cve_exposure MCP tool.
+var cveExposureTool = mcp.NewTool(
+	"check_cve_exposure",
+	mcp.WithTitleAnnotation("Check CVE exposure"),
+	mcp.WithDescription("Compares a CVE's Red Hat Security Data (fixed errata and package states) with the cri-o, runc, crun, conmon and kernel RPMs and the RHCOS build installed on a node, reporting each package as affected, fixed or not affected together with the fixing erratum. Given a MachineConfigPool instead of a node, every node in the pool is checked and a fleet exposure table is returned."),
+	mcp.WithString("cve_id",
+		mcp.Description("CVE identifier (e.g. CVE-2024-21626)"),
+		mcp.Required(),
+	),
+	mcp.WithString("node_name",
+		mcp.Description("Node to check"),
+	),
+	mcp.WithString("pool",
+		mcp.Description("MachineConfigPool whose nodes are checked (e.g. worker); use instead of node_name"),
+	),
+)
+
 // handleSosReport executes sosreport on the target node using toolbox.
 func handleSosReport(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	nodeName, err := req.RequireString("node_name")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	caseID := req.GetString("case_id", "")
+	upload := req.GetBool("upload", false)
+	if upload && caseID == "" {
+		return mcp.NewToolResultError("case_id is required when upload is true"), nil
+	}
 	out, err := openshift.SosReport(ctx, nodeName, caseID)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
+	}
+	archive := openshift.SosReportArchive(out)
+	if archive == "" {
+		return mcp.NewToolResultText(out), nil
+	}
+	w, err := artifacts.Create(path.Base(archive))
+	if err != nil {
+		return toolError(err), nil
+	}
+	defer w.Abort()
+	if err := openshift.StreamNodeFile(ctx, nodeName, archive, w); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("%s\nretrieving %s failed: %v", out, archive, err)), nil
+	}
+	art, err := w.Commit()
+	if err != nil {
+		return toolError(err), nil
+	}
+	sum, err := openshift.NodeFileChecksum(ctx, nodeName, archive)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("verifying %s failed: %v", archive, err)), nil
+	}
+	if sum != art.SHA256 {
+		return mcp.NewToolResultError(fmt.Sprintf("checksum mismatch for %s: node %s, local %s", archive, sum, art.SHA256)), nil
+	}
+	result := fmt.Sprintf("%s\narchive retrieved to %s", out, art)
+	if !upload {
+		return mcp.NewToolResultText(result), nil
+	}
+	up, err := redhat.UploadAttachment(ctx, req.GetString("offline_token", ""), caseID, art.Path)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("%s\n%v", result, err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("%s\n%s", result, uploadSummary(up))), nil
+}
+
+// handleAttachCaseFile uploads a local file to a support case.
+func handleAttachCaseFile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	p, err := req.RequireString("path")
+	if err != nil {
+		return toolError(err), nil
+	}
+	caseID, err := req.RequireString("case_id")
+	if err != nil {
+		return toolError(err), nil
+	}
+	p, err = artifacts.Resolve(p)
+	if err != nil {
+		return toolError(err), nil
+	}
+	up, err := redhat.UploadAttachment(ctx, req.GetString("offline_token", ""), caseID, p)
+	if err != nil {
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(uploadSummary(up)), nil
+}
+
+// handleListCases lists support cases.
+func handleListCases(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	cases, err := redhat.ListCases(ctx, redhat.CaseFilter{
+		Status:        req.GetString("status", ""),
+		Keyword:       req.GetString("keyword", ""),
+		IncludeClosed: req.GetBool("include_closed", false),
+		MaxResults:    req.GetInt("max_results", 20),
+	}, req.GetString("offline_token", ""))
+	if err != nil {
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(redhat.FormatCases(cases)), nil
+}
+
+// handleGetCase returns a support case with its comments.
+func handleGetCase(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	caseID, err := req.RequireString("case_id")
+	if err != nil {
+		return toolError(err), nil
+	}
+	cs, err := redhat.GetCase(ctx, caseID, req.GetString("offline_token", ""))
+	if err != nil {
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(redhat.FormatCase(cs)), nil
+}
+
+// handleAddCaseComment posts a comment to a support case.
+func handleAddCaseComment(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	caseID, err := req.RequireString("case_id")
+	if err != nil {
+		return toolError(err), nil
+	}
+	comment, err := req.RequireString("comment")
+	if err != nil {
+		return toolError(err), nil
+	}
+	cm, err := redhat.AddCaseComment(ctx, caseID, comment, req.GetString("offline_token", ""))
+	if err != nil {
+		return toolError(err), nil
+	}
+	msg := "comment added to case " + caseID
+	if cm.ID != "" {
+		msg += " (id " + cm.ID + ")"
+	}
+	return mcp.NewToolResultText(msg), nil
+}
+
+// handleCreateCase opens a support case.
+func handleCreateCase(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	summary, err := req.RequireString("summary")
+	if err != nil {
+		return toolError(err), nil
+	}
+	description, err := req.RequireString("description")
+	if err != nil {
+		return toolError(err), nil
+	}
+	cs, err := redhat.CreateCase(ctx, redhat.NewCase{
+		Summary:     summary,
+		Description: description,
+		Product:     req.GetString("product", "OpenShift Container Platform"),
+		Version:     req.GetString("version", ""),
+		Severity:    req.GetString("severity", "3 (Normal)"),
+	}, req.GetString("offline_token", ""))
+	if err != nil {
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("created case %s: %s", cs.CaseNumber, cs.Summary)), nil
+}
+
+func uploadSummary(up *redhat.UploadResult) string {
+	msg := fmt.Sprintf("attached %s (%d bytes, sha256 %s) to case %s", up.FileName, up.Size, up.SHA256, up.CaseNumber)
+	if up.Resumed {
+		msg += " (resumed)"
+	}
+	return msg
+}
+
+// handleAnalyzeSosReport summarizes a local sosreport.
+func handleAnalyzeSosReport(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	p, err := req.RequireString("path")
+	if err != nil {
+		return toolError(err), nil
+	}
+	p, err = artifacts.ResolveReadable(p)
+	if err != nil {
+		return toolError(err), nil
+	}
+	report, err := sosreport.Load(p)
+	if err != nil {
+		return toolError(err), nil
+	}
+	out, err := report.Format(req.GetString("section", sosreport.SectionSummary))
+	if err != nil {
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -418,7 +1193,7 @@ func handleNetworkLogs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallT
 	dest := req.GetString("dest_dir", "")
 	out, err := openshift.NetworkLogs(ctx, dest)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -428,7 +1203,7 @@ func handleProfilingNode(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 	dest := req.GetString("dest_dir", "")
 	out, err := openshift.ProfilingNode(ctx, dest)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -437,7 +1212,7 @@ func handleProfilingNode(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 func handleEvents(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	out, err := openshift.Events(ctx)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -446,7 +1221,7 @@ func handleEvents(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 func handleNodeMetrics(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	out, err := openshift.NodeMetrics(ctx)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -455,11 +1230,11 @@ func handleNodeMetrics(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallT
 func handlePrometheusQuery(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	q, err := req.RequireString("query")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	out, err := openshift.PrometheusQuery(ctx, q)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -468,30 +1243,261 @@ func handlePrometheusQuery(ctx context.Context, req mcp.CallToolRequest) (*mcp.C
 func handlePodLogs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	ns, err := req.RequireString("namespace")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	pod, err := req.RequireString("pod_name")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	container := req.GetString("container", "")
 	since := req.GetString("since", "")
 	out, err := openshift.PodLogs(ctx, ns, pod, container, since)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(out), nil
+}
+
+// diagnoseLogLines is the number of container log lines diagnose_pod reports.
+const diagnoseLogLines = 10
+
+// handleDiagnosePod collects the state of a pod from events, crictl, the node
+// journal and the pod's logs and reports the stage at which it fails. What
+// cannot be collected is listed in the verdict rather than failing the call.
+func handleDiagnosePod(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ns, err := req.RequireString("namespace")
+	if err != nil {
+		return toolError(err), nil
+	}
+	pod, err := req.RequireString("pod_name")
+	if err != nil {
+		return toolError(err), nil
+	}
+	since := req.GetString("since", "-1h")
+	in := poddiag.Input{Namespace: ns, Pod: pod}
+	failed := func(what string, err error) {
+		// A dry run lists the commands, not their absence.
+		if !errors.Is(err, dryrun.ErrSkipped) {
+			in.Errors = append(in.Errors, fmt.Sprintf("%s: %v", what, err))
+		}
+	}
+
+	events, err := openshift.Events(ctx)
+	if err != nil {
+		failed("events", err)
+	}
+	in.Events = poddiag.ParseEvents(events, ns, pod)
+	// Events expire after an hour, so the pod's spec is asked first.
+	in.Node, err = openshift.PodNode(ctx, ns, pod)
+	if err != nil {
+		failed("pod", err)
+	}
+	if in.Node == "" {
+		in.Node = poddiag.ScheduledNode(in.Events)
+	}
+	if in.Node == "" {
+		return mcp.NewToolResultText(poddiag.Diagnose(in).Format()), nil
+	}
+
+	out, err := openshift.Crictl(ctx, in.Node, []string{"pods", "--namespace", ns, "--name", pod, "-o", "json"})
+	if err != nil {
+		failed("crictl pods", err)
+	} else if sbs, err := poddiag.ParseSandboxes([]byte(out), ns, pod); err != nil {
+		failed("crictl pods", err)
+	} else if len(sbs) > 0 {
+		in.Sandbox = &sbs[0]
+	}
+	if in.Sandbox != nil {
+		out, err := openshift.Crictl(ctx, in.Node, []string{"ps", "-a", "--pod", in.Sandbox.ID, "-o", "json"})
+		if err != nil {
+			failed("crictl ps", err)
+		} else if in.Containers, err = poddiag.ParseContainers([]byte(out)); err != nil {
+			failed("crictl ps", err)
+		}
+		for i := range in.Containers {
+			c := &in.Containers[i]
+			out, err := openshift.Crictl(ctx, in.Node, []string{"inspect", c.ID})
+			if err == nil {
+				err = poddiag.ParseInspect([]byte(out), c)
+			}
+			if err != nil {
+				failed("crictl inspect "+c.Name, err)
+			}
+		}
+	}
+
+	journal, err := openshift.NodeLogs(ctx, in.Node, since)
+	if err != nil {
+		failed("node journal", err)
+	}
+	keys := []string{ns + "/" + pod, pod + "_" + ns}
+	if in.Sandbox != nil {
+		keys = append(keys, in.Sandbox.ID)
+	}
+	in.Journal = poddiag.JournalLines(journal, keys...)
+
+	for _, c := range in.Containers {
+		if c.State != "CONTAINER_EXITED" || c.ExitCode == 0 {
+			continue
+		}
+		logs, err := openshift.PodLogs(ctx, ns, pod, c.Name, "")
+		if err != nil {
+			failed("logs of "+c.Name, err)
+			break
+		}
+		lines := strings.Split(strings.TrimRight(logs, "\n"), "\n")
+		if len(lines) > diagnoseLogLines {
+			lines = lines[len(lines)-diagnoseLogLines:]
+		}
+		for _, l := range lines {
+			in.Logs = append(in.Logs, c.Name+": "+l)
+		}
+		break
+	}
+	return mcp.NewToolResultText(poddiag.Diagnose(in).Format()), nil
+}
+
+// report is a node analysis with a text summary and a JSON form.
+type report interface {
+	Summary() string
+	JSON() (string, error)
+}
+
+// reportResult returns the summary of r followed by its JSON.
+func reportResult(r report) *mcp.CallToolResult {
+	js, err := r.JSON()
+	if err != nil {
+		return toolError(err)
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{
+		mcp.NewTextContent(r.Summary()),
+		mcp.NewTextContent(js),
+	}}
+}
+
+// handleNodeHealthReport collects the data for a nodehealth.Report and
+// returns its summary and JSON. Areas whose data cannot be collected are
+// scored unknown rather than failing the call.
+func handleNodeHealthReport(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	nodeName, err := req.RequireString("node_name")
+	if err != nil {
+		return toolError(err), nil
+	}
+	r := nodehealth.New(nodeName)
+	data, err := openshift.NodeJSON(ctx, nodeName)
+	if err == nil {
+		err = r.ParseNode(data)
+	}
+	if err != nil {
+		r.Fail(err, nodehealth.AreaConditions)
+	}
+	out, err := openshift.DebugNode(ctx, nodeName, nodehealth.Script)
+	if err != nil {
+		r.Fail(err, nodehealth.AreaRuntime, nodehealth.AreaServices, nodehealth.AreaDisk,
+			nodehealth.AreaInodes, nodehealth.AreaPressure, nodehealth.AreaKernel)
+	} else {
+		r.ParseDebug(out)
+	}
+	metrics, err := openshift.NodeMetrics(ctx)
+	if err == nil {
+		err = r.ParseMetrics(metrics)
+	}
+	if err != nil {
+		r.Fail(err, nodehealth.AreaResources)
+	}
+	r.Evaluate()
+	return reportResult(r), nil
+}
+
+// handleAnalyzeImageStorage runs imagestorage.Script on a node and returns
+// the summary and JSON of its analysis.
+func handleAnalyzeImageStorage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	nodeName, err := req.RequireString("node_name")
+	if err != nil {
+		return toolError(err), nil
+	}
+	out, err := openshift.DebugNode(ctx, nodeName, imagestorage.Script)
+	if err != nil {
+		return toolError(err), nil
+	}
+	r := imagestorage.Analyze(nodeName, out)
+	return reportResult(r), nil
+}
+
+// handlePruneImageStorage removes images from a node. The confirm
+// middleware holds every call back until it is confirmed.
+func handlePruneImageStorage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	nodeName, err := req.RequireString("node_name")
+	if err != nil {
+		return toolError(err), nil
+	}
+	imagesAny, _ := req.GetArguments()["images"].([]any)
+	images := make([]string, len(imagesAny))
+	for i, a := range imagesAny {
+		images[i] = fmt.Sprint(a)
+	}
+	args, err := imagestorage.PruneArgs(images)
+	if err != nil {
+		return toolError(err), nil
+	}
+	out, err := openshift.Crictl(ctx, nodeName, args)
+	if err != nil {
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
 
+// handleFindRuntimeOrphans collects the data for an orphans.Report and
+// returns its summary and JSON. Classes whose sources cannot be collected
+// are reported as not checked rather than failing the call.
+func handleFindRuntimeOrphans(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	nodeName, err := req.RequireString("node_name")
+	if err != nil {
+		return toolError(err), nil
+	}
+	r := orphans.New(nodeName)
+	pods, err := openshift.NodePods(ctx, nodeName)
+	if err == nil {
+		err = r.ParsePods(pods)
+	}
+	if err != nil {
+		r.Fail(err, orphans.SourcePods)
+	}
+	out, err := openshift.Crictl(ctx, nodeName, []string{"pods", "-o", "json"})
+	if err == nil {
+		err = r.ParseSandboxes([]byte(out))
+	}
+	if err != nil {
+		r.Fail(err, orphans.SourceSandboxes)
+	}
+	out, err = openshift.Crictl(ctx, nodeName, []string{"ps", "-a", "-o", "json"})
+	if err == nil {
+		err = r.ParseContainers([]byte(out))
+	}
+	if err != nil {
+		r.Fail(err, orphans.SourceContainers)
+	}
+	// Processes and namespaces are read last: whatever started after the
+	// listings above is younger than orphans.Grace and ignored.
+	out, err = openshift.DebugNode(ctx, nodeName, orphans.Script)
+	if err != nil {
+		r.Fail(err, orphans.SourceInspect, orphans.SourceProcesses, orphans.SourceNetns)
+	} else {
+		r.ParseDebug(out)
+	}
+	r.Evaluate()
+	return reportResult(r), nil
+}
+
 // handleNodeConfig collects kubelet and CRI-O configuration from a node.
 func handleNodeConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	nodeName, err := req.RequireString("node_name")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	out, err := openshift.NodeConfig(ctx, nodeName)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
@@ -500,51 +1506,282 @@ func handleNodeConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTo
 func handleSearchKCS(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	q, err := req.RequireString("query")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
+	}
+	results, err := redhat.SearchKCS(ctx, redhat.KCSQuery{
+		Query:   q,
+		Rows:    req.GetInt("rows", 20),
+		Product: req.GetString("product", ""),
+		Version: req.GetString("version", ""),
+	}, req.GetString("offline_token", ""))
+	if err != nil {
+		return toolError(err), nil
 	}
-	rows := req.GetInt("rows", 20)
-	token, err := req.RequireString("offline_token")
+	return mcp.NewToolResultText(redhat.FormatKCSResults(results, req.GetInt("max_chars", 8000))), nil
+}
+
+// handleKCSArticle fetches a single knowledge base document.
+func handleKCSArticle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := req.RequireString("id")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
-	out, err := redhat.SearchKCS(q, rows, token)
+	art, err := redhat.KCSArticle(ctx, id, req.GetString("offline_token", ""))
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
-	return mcp.NewToolResultText(out), nil
+	return mcp.NewToolResultText(redhat.FormatKCSArticle(art)), nil
 }
 
 // handleCVEInfo fetches CVE information from the Security Data API.
 func handleCVEInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	id, err := req.RequireString("cve_id")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
-	out, err := redhat.CVEInfo(id)
+	out, err := redhat.CVEInfo(ctx, id)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return toolError(err), nil
 	}
 	return mcp.NewToolResultText(out), nil
 }
 
+// stringArgs returns the string array argument name, or nil if it is unset.
+func stringArgs(req mcp.CallToolRequest, name string) []string {
+	raw, _ := req.GetArguments()[name].([]any)
+	var out []string
+	for _, a := range raw {
+		out = append(out, fmt.Sprint(a))
+	}
+	return out
+}
+
+// handleSearchCVEs lists CVEs from the Security Data API.
+func handleSearchCVEs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	packages := stringArgs(req, "packages")
+	if len(packages) == 0 {
+		packages = redhat.RuntimePackages
+	}
+	list, err := redhat.SearchCVEs(ctx, redhat.CVEQuery{
+		Packages: packages,
+		Severity: req.GetString("severity", ""),
+		After:    req.GetString("after", ""),
+		Before:   req.GetString("before", ""),
+		PerPage:  req.GetInt("per_page", 50),
+	})
+	if err != nil {
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(redhat.FormatCVEList(list, packages)), nil
+}
+
+// handleGetErratum fetches an advisory and its fixed packages.
+func handleGetErratum(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := req.RequireString("advisory_id")
+	if err != nil {
+		return toolError(err), nil
+	}
+	e, err := redhat.GetErratum(ctx, id, req.GetString("offline_token", ""))
+	if err != nil {
+		return toolError(err), nil
+	}
+	return mcp.NewToolResultText(redhat.FormatErratum(e, stringArgs(req, "packages"))), nil
+}
+
+// exposureParallelism bounds the number of concurrent `oc debug` sessions
+// used when checking a MachineConfigPool.
+const exposureParallelism = 5
+
+// handleCheckCVEExposure reports whether a node, or every node in a pool, is
+// exposed to a CVE.
+func handleCheckCVEExposure(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := req.RequireString("cve_id")
+	if err != nil {
+		return toolError(err), nil
+	}
+	nodeName := req.GetString("node_name", "")
+	pool := req.GetString("pool", "")
+	if (nodeName == "") == (pool == "") {
+		return mcp.NewToolResultError("exactly one of node_name or pool is required"), nil
+	}
+	cve, err := redhat.CVEDetails(ctx, id)
+	if err != nil {
+		return toolError(err), nil
+	}
+	if nodeName != "" {
+		r := nodeExposure(ctx, cve, nodeName)
+		if r.Err != nil {
+			return mcp.NewToolResultError(r.Err.Error()), nil
+		}
+		return mcp.NewToolResultText(r.Format(cve)), nil
+	}
+
+	nodes, err := openshift.PoolNodes(ctx, pool)
+	if err != nil {
+		return toolError(err), nil
+	}
+	if len(nodes) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("machineconfigpool %s has no nodes", pool)), nil
+	}
+	reports := make([]exposure.Report, len(nodes))
+	sem := make(chan struct{}, exposureParallelism)
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			reports[i] = nodeExposure(ctx, cve, n)
+		}(i, n)
+	}
+	wg.Wait()
+	return mcp.NewToolResultText(exposure.FormatFleet(cve, reports)), nil
+}
+
+// nodeExposure collects a node's package inventory and checks it against cve.
+func nodeExposure(ctx context.Context, cve *redhat.CVE, nodeName string) exposure.Report {
+	out, err := openshift.NodeInventory(ctx, nodeName)
+	if err != nil {
+		return exposure.Report{Inventory: exposure.Inventory{Node: nodeName}, Err: err}
+	}
+	return exposure.Check(cve, exposure.ParseInventory(nodeName, out, openshift.InventorySeparator))
+}
+
+// runbookTool defines the run_runbook MCP tool. Its description lists rbs
+// and their parameters.
+func runbookTool(rbs []*runbook.Runbook) mcp.Tool {
+	var desc strings.Builder
+	desc.WriteString("Runs a diagnostic runbook: a fixed sequence of this server's tools whose outputs feed later steps, for example finding a pod's node and then inspecting its sandbox there. Returns one report with the findings first and the output of every step. Each step is authorized, confirmed and audited like a direct call.\n\nAvailable runbooks:")
+	names := make([]string, len(rbs))
+	for i, rb := range rbs {
+		names[i] = rb.Name
+		fmt.Fprintf(&desc, "\n- %s: %s", rb.Name, rb.Description)
+		for _, p := range rb.Params {
+			req := ""
+			if p.Required {
+				req = ", required"
+			}
+			fmt.Fprintf(&desc, "\n  - %s (param%s): %s", p.Name, req, p.Description)
+		}
+	}
+	return mcp.NewTool(
+		"run_runbook",
+		mcp.WithTitleAnnotation("Run a diagnostic runbook"),
+		mcp.WithDescription(desc.String()),
+		mcp.WithString("name",
+			mcp.Description("Name of the runbook to run"),
+			mcp.Required(),
+			func(schema map[string]any) {
+				// An empty enum would reject every name, hiding the load error.
+				if len(names) > 0 {
+					mcp.Enum(names...)(schema)
+				}
+			},
+		),
+		mcp.WithObject("params",
+			mcp.Description("Runbook parameters by name, e.g. {\"namespace\": \"app\", \"pod\": \"web-1\"}"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+	)
+}
+
+// runbookHandler returns the handler of run_runbook. Steps call the handlers
+// in tools. If the runbooks could not be loaded, every call fails with
+// loadErr.
+func runbookHandler(rbs []*runbook.Runbook, loadErr error, tools runbook.Tools) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if loadErr != nil {
+			return toolError(loadErr), nil
+		}
+		name, err := req.RequireString("name")
+		if err != nil {
+			return toolError(err), nil
+		}
+		var rb *runbook.Runbook
+		for _, r := range rbs {
+			if r.Name == name {
+				rb = r
+			}
+		}
+		if rb == nil {
+			return mcp.NewToolResultError(fmt.Sprintf("unknown runbook %s", name)), nil
+		}
+		params := map[string]string{}
+		if m, ok := req.GetArguments()["params"].(map[string]any); ok {
+			for k, v := range m {
+				params[k] = fmt.Sprint(v)
+			}
+		}
+		report, err := rb.Run(ctx, tools, params)
+		if err != nil {
+			return toolError(err), nil
+		}
+		return mcp.NewToolResultText(report.String()), nil
+	}
+}
+
 // RegisterTools registers all available tools with the provided server.
+// Every handler is wrapped in the middleware returned by toolMiddleware, which
+// redacts secrets from results, includes the audit log when
+// CRIO_MCP_AUDIT_LOG or CRIO_MCP_AUDIT_SYSLOG is set and enforces the policy
+// named by CRIO_MCP_POLICY_FILE. With a policy, tool listings only show the
+// tools the caller may use. run_runbook runs the built-in runbooks and those
+// in CRIO_MCP_RUNBOOKS_DIR through the wrapped handlers of the other tools.
 func RegisterTools(s *server.MCPServer) {
-	s.AddTools(
-		server.ServerTool{Tool: debugNodeTool, Handler: handleDebugNode},
-		server.ServerTool{Tool: nodeLogsTool, Handler: handleNodeLogs},
-		server.ServerTool{Tool: pprofTool, Handler: handlePprof},
-		server.ServerTool{Tool: mustGatherTool, Handler: handleMustGather},
-		server.ServerTool{Tool: crictlTool, Handler: handleCrictl},
-		server.ServerTool{Tool: cgroupfsTool, Handler: handleTraverseCgroupfs},
-		server.ServerTool{Tool: sosReportTool, Handler: handleSosReport},
-		server.ServerTool{Tool: networkLogsTool, Handler: handleNetworkLogs},
-		server.ServerTool{Tool: profilingTool, Handler: handleProfilingNode},
-		server.ServerTool{Tool: eventsTool, Handler: handleEvents},
-		server.ServerTool{Tool: prometheusQueryTool, Handler: handlePrometheusQuery},
-		server.ServerTool{Tool: nodeMetricsTool, Handler: handleNodeMetrics},
-		server.ServerTool{Tool: podLogsTool, Handler: handlePodLogs},
-		server.ServerTool{Tool: nodeConfigTool, Handler: handleNodeConfig},
-		server.ServerTool{Tool: kcsSearchTool, Handler: handleSearchKCS},
-		server.ServerTool{Tool: cveInfoTool, Handler: handleCVEInfo},
-	)
+	tools := []server.ServerTool{
+		{Tool: debugNodeTool, Handler: handleDebugNode},
+		{Tool: nodeLogsTool, Handler: handleNodeLogs},
+		{Tool: pprofTool, Handler: handlePprof},
+		{Tool: nodeProfileTool, Handler: handleNodeProfile},
+		{Tool: crioGoroutinesTool, Handler: handleCrioGoroutines},
+		{Tool: goroutineDumpTool, Handler: handleGoroutineDump},
+		{Tool: mustGatherTool, Handler: handleMustGather},
+		{Tool: analyzeMustGatherTool, Handler: handleAnalyzeMustGather},
+		{Tool: crictlTool, Handler: handleCrictl},
+		{Tool: cgroupfsTool, Handler: handleTraverseCgroupfs},
+		{Tool: sosReportTool, Handler: handleSosReport},
+		{Tool: analyzeSosReportTool, Handler: handleAnalyzeSosReport},
+		{Tool: attachCaseFileTool, Handler: handleAttachCaseFile},
+		{Tool: networkLogsTool, Handler: handleNetworkLogs},
+		{Tool: profilingTool, Handler: handleProfilingNode},
+		{Tool: eventsTool, Handler: handleEvents},
+		{Tool: prometheusQueryTool, Handler: handlePrometheusQuery},
+		{Tool: nodeMetricsTool, Handler: handleNodeMetrics},
+		{Tool: podLogsTool, Handler: handlePodLogs},
+		{Tool: diagnosePodTool, Handler: handleDiagnosePod},
+		{Tool: nodeHealthReportTool, Handler: handleNodeHealthReport},
+		{Tool: analyzeImageStorageTool, Handler: handleAnalyzeImageStorage},
+		{Tool: pruneImageStorageTool, Handler: handlePruneImageStorage},
+		{Tool: runtimeOrphansTool, Handler: handleFindRuntimeOrphans},
+		{Tool: nodeConfigTool, Handler: handleNodeConfig},
+		{Tool: kcsSearchTool, Handler: handleSearchKCS},
+		{Tool: kcsArticleTool, Handler: handleKCSArticle},
+		{Tool: cveInfoTool, Handler: handleCVEInfo},
+		{Tool: cveExposureTool, Handler: handleCheckCVEExposure},
+		{Tool: searchCVEsTool, Handler: handleSearchCVEs},
+		{Tool: erratumTool, Handler: handleGetErratum},
+		{Tool: listCasesTool, Handler: handleListCases},
+		{Tool: getCaseTool, Handler: handleGetCase},
+		{Tool: addCaseCommentTool, Handler: handleAddCaseComment},
+		{Tool: createCaseTool, Handler: handleCreateCase},
+	}
+	mw := toolMiddleware()
+	for i := range tools {
+		dryRunParam(&tools[i].Tool)
+		tools[i].Handler = wrap(tools[i].Handler, mw)
+	}
+	byName := runbook.Tools{}
+	for _, t := range tools {
+		byName[t.Tool.Name] = t.Handler
+	}
+	rbs, err := runbook.FromEnv()
+	rb := server.ServerTool{Tool: runbookTool(rbs), Handler: runbookHandler(rbs, err, byName)}
+	dryRunParam(&rb.Tool)
+	rb.Handler = wrap(rb.Handler, mw)
+	tools = append(tools, rb)
+	if _, filter := policyMiddleware(); filter != nil {
+		server.WithToolFilter(filter)(s)
+	}
+	s.AddTools(tools...)
 }
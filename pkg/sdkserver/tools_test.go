@@ -1,16 +1,27 @@
 package sdkserver
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/harche/crio-mcp-server/pkg/artifacts"
 	"github.com/harche/crio-mcp-server/pkg/openshift"
 	"github.com/harche/crio-mcp-server/pkg/redhat"
+	"github.com/harche/crio-mcp-server/pkg/runbook"
 	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
 )
 
 func withRunMock(t *testing.T, expected []string, output string, err error, f func()) {
@@ -52,6 +63,78 @@ func TestHandleDebugNode(t *testing.T) {
 	})
 }
 
+func TestHandleDebugNodeCollectFiles(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	tw.WriteHeader(&tar.Header{Name: "etc/crio/crio.conf", Typeflag: tar.TypeReg, Mode: 0o644, Size: 6})
+	tw.Write([]byte("[crio]"))
+	secret := []byte("[crio.image]\npassword = \"hunter2\"\n")
+	tw.WriteHeader(&tar.Header{Name: "etc/crio/crio.conf.d/10-registry.conf", Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(secret))})
+	tw.Write(secret)
+	tw.Close()
+	zw.Close()
+	origDir := artifacts.Dir
+	artifacts.Dir = t.TempDir()
+	defer func() { artifacts.Dir = origDir }()
+	orig := openshift.Stream
+	defer func() { openshift.Stream = orig }()
+	openshift.Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"node_name":     "test",
+		"collect_files": true,
+		"paths":         []any{"/etc/crio"},
+	}}}
+	res, err := handleDebugNode(context.Background(), req)
+	if err != nil || res.IsError {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+	if !strings.Contains(text(res), "/etc/crio/crio.conf 6") {
+		t.Fatalf("manifest missing from %q", text(res))
+	}
+	saved, _ := filepath.Glob(filepath.Join(artifacts.Dir, "debug-node-test-*.tar.gz"))
+	if len(saved) != 1 || !strings.Contains(text(res), saved[0]) {
+		t.Fatalf("archive not saved: %v, %q", saved, text(res))
+	}
+	if !strings.Contains(text(res), "redacted 1 secret (password: 1)") {
+		t.Fatalf("redaction not reported in %q", text(res))
+	}
+	f, err := os.Open(saved[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+	if files["etc/crio/crio.conf"] != "[crio]" || files["etc/crio/crio.conf.d/10-registry.conf"] != "[crio.image]\npassword = [REDACTED:password]\n" {
+		t.Fatalf("unexpected archive content %q", files)
+	}
+
+	req.Params.Arguments.(map[string]any)["paths"] = []any{"/etc/shadow"}
+	res, _ = handleDebugNode(context.Background(), req)
+	if !res.IsError || !strings.Contains(text(res), "denied by the host path policy") {
+		t.Fatalf("expected denial, got %q", text(res))
+	}
+}
+
 func TestHandleDebugNodeMissingArg(t *testing.T) {
 	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{}}}
 	res, err := handleDebugNode(context.Background(), req)
@@ -80,6 +163,63 @@ func TestHandleNodeLogs(t *testing.T) {
 	})
 }
 
+func TestHandleNodeLogsCompressStreamsToArtifact(t *testing.T) {
+	const size = 64 << 20
+	origDir := artifacts.Dir
+	artifacts.Dir = t.TempDir()
+	defer func() { artifacts.Dir = origDir }()
+	orig := openshift.Stream
+	defer func() { openshift.Stream = orig }()
+	openshift.Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+		if fmt.Sprint(args) != "[adm node-logs node1]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		line := []byte(strings.Repeat("crio[1]: level=info msg=\"pulled image\"\n", 1024))
+		for n := 0; n < size; n += len(line) {
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+		}
+		_, err := w.Write([]byte("kubelet: --password=hunter2\n"))
+		return err
+	}
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"node_name": "node1",
+		"compress":  true,
+	}}}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	res, err := handleNodeLogs(context.Background(), req)
+	runtime.ReadMemStats(&after)
+	if err != nil || res.IsError {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > size/4 {
+		t.Fatalf("allocated %d bytes compressing %d bytes of logs", alloc, size)
+	}
+	saved, _ := filepath.Glob(filepath.Join(artifacts.Dir, "node-logs-node1-*.txt.gz"))
+	if len(saved) != 1 || !strings.Contains(text(res), saved[0]) {
+		t.Fatalf("logs not saved: %v, %q", saved, text(res))
+	}
+	f, err := os.Open(saved[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	if n, err := io.Copy(&logs, zr); err != nil || n < size {
+		t.Fatalf("decompressed %d bytes: %v", n, err)
+	}
+	if !bytes.HasSuffix(logs.Bytes(), []byte("--password=[REDACTED:password]\n")) || !strings.Contains(text(res), "redacted 1 secret") {
+		t.Fatalf("logs not redacted: %q", text(res))
+	}
+}
+
 func TestHandlePprofArgsRequired(t *testing.T) {
 	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{}}}
 	res, err := handlePprof(context.Background(), req)
@@ -283,13 +423,14 @@ func TestHandleNodeConfig(t *testing.T) {
 }
 
 func TestHandleSearchKCS(t *testing.T) {
+	redhat.ClearTokenCache()
 	calls := 0
 	redhat.Do = func(req *http.Request) (*http.Response, error) {
 		calls++
 		if calls == 1 {
 			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"access_token":"tok"}`))}, nil
 		}
-		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("result"))}, nil
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"response":{"docs":[{"id":"1","publishedTitle":"result","view_uri":"https://access.redhat.com/solutions/1"}]}}`))}, nil
 	}
 	defer func() { redhat.Do = http.DefaultClient.Do }()
 
@@ -301,7 +442,35 @@ func TestHandleSearchKCS(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if res.IsError || text(res) != "result" {
+	if res.IsError || text(res) != "1. result\n   https://access.redhat.com/solutions/1\n" {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+}
+
+func TestHandleKCSArticle(t *testing.T) {
+	redhat.ClearTokenCache()
+	calls := 0
+	redhat.Do = func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"access_token":"tok"}`))}, nil
+		}
+		if !strings.Contains(req.URL.RawQuery, "id%3A%221234%22") {
+			t.Fatalf("unexpected query %s", req.URL.RawQuery)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"response":{"docs":[{"id":"1234","publishedTitle":"crio hangs","solution_resolution":"restart crio"}]}}`))}, nil
+	}
+	defer func() { redhat.Do = http.DefaultClient.Do }()
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"id":            "1234",
+		"offline_token": "off",
+	}}}
+	res, err := handleKCSArticle(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || !strings.Contains(text(res), "crio hangs") || !strings.Contains(text(res), "restart crio") {
 		t.Fatalf("unexpected result: %v", text(res))
 	}
 }
@@ -322,3 +491,876 @@ func TestHandleCVEInfo(t *testing.T) {
 		t.Fatalf("unexpected result: %v", text(res))
 	}
 }
+
+func TestHandleNodeProfile(t *testing.T) {
+	origDir := artifacts.Dir
+	artifacts.Dir = t.TempDir()
+	defer func() { artifacts.Dir = origDir }()
+
+	origOutput := openshift.Output
+	defer func() { openshift.Output = origOutput }()
+	openshift.Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != "[get --raw /api/v1/nodes/n1/proxy/debug/pprof/goroutine]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte("not a profile"), nil
+	}
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"node_name":    "n1",
+		"component":    "kubelet",
+		"profile_type": "goroutine",
+	}}}
+	res, err := handleNodeProfile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError {
+		t.Fatalf("unexpected error result: %v", text(res))
+	}
+	if !strings.Contains(text(res), artifacts.Dir) || !strings.Contains(text(res), "kubelet goroutine profile") {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+}
+
+func TestHandleGoroutineDump(t *testing.T) {
+	origDir := artifacts.Dir
+	artifacts.Dir = t.TempDir()
+	defer func() { artifacts.Dir = origDir }()
+	path := filepath.Join(artifacts.Dir, "stacks.log")
+	dump := "goroutine 7 [semacquire, 4 minutes]:\nsync.runtime_Semacquire(0x1)\n\t/go/src/runtime/sema.go:62 +0x25\nmain.worker()\n\t/src/main.go:10 +0x1\n\n" +
+		"goroutine 8 [semacquire, 2 minutes]:\nsync.runtime_Semacquire(0x1)\n\t/go/src/runtime/sema.go:62 +0x25\nmain.worker()\n\t/src/main.go:10 +0x1\n"
+	if err := os.WriteFile(path, []byte(dump), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"path": path,
+	}}}
+	res, err := handleGoroutineDump(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || !strings.Contains(text(res), "2 goroutine(s) waiting in semacquire at main.worker") {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+}
+
+func TestHandleAnalyzeMustGather(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(artifacts.ReadDirsEnv, filepath.Dir(dir))
+	co := filepath.Join(dir, "image", "cluster-scoped-resources", "config.openshift.io", "clusteroperators", "etcd.yaml")
+	if err := os.MkdirAll(filepath.Dir(co), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	content := "metadata: {name: etcd}\nstatus:\n  conditions:\n  - {type: Available, status: \"False\", message: \"quorum lost\"}\n"
+	if err := os.WriteFile(co, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"path":  dir,
+		"query": "cluster_operators",
+	}}}
+	res, err := handleAnalyzeMustGather(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || !strings.Contains(text(res), "etcd\tFalse") || !strings.Contains(text(res), "quorum lost") {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+}
+
+func TestAnalyzeToolsRefuseOutsidePaths(t *testing.T) {
+	origDir := artifacts.Dir
+	artifacts.Dir = t.TempDir()
+	defer func() { artifacts.Dir = origDir }()
+	t.Setenv(artifacts.ReadDirsEnv, "")
+	outside := t.TempDir()
+	for name, h := range map[string]server.ToolHandlerFunc{
+		"analyze_goroutine_dump": handleGoroutineDump,
+		"analyze_must_gather":    handleAnalyzeMustGather,
+		"analyze_sosreport":      handleAnalyzeSosReport,
+	} {
+		req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{"path": outside}}}
+		res, _ := h(context.Background(), req)
+		if !res.IsError || !strings.Contains(text(res), "is not in the artifact directory") {
+			t.Errorf("%s: outside path read: %s", name, text(res))
+		}
+	}
+}
+
+func TestHandleSosReportRetrievesArchive(t *testing.T) {
+	origDir := artifacts.Dir
+	artifacts.Dir = t.TempDir()
+	defer func() { artifacts.Dir = origDir }()
+	origStream := openshift.Stream
+	openshift.Stream = func(ctx context.Context, w io.Writer, args ...string) error {
+		if args[len(args)-1] != "/var/tmp/sosreport-n1-2025.tar.xz" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		_, err := io.WriteString(w, "hello")
+		return err
+	}
+	defer func() { openshift.Stream = origStream }()
+	origRun := openshift.Run
+	openshift.Run = func(ctx context.Context, args ...string) ([]byte, error) {
+		if strings.HasPrefix(args[len(args)-1], "sha256sum") {
+			return []byte("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  /var/tmp/sosreport-n1-2025.tar.xz\n"), nil
+		}
+		return []byte("saved in:\n  /host/var/tmp/sosreport-n1-2025.tar.xz\n"), nil
+	}
+	defer func() { openshift.Run = origRun }()
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"node_name": "n1",
+	}}}
+	res, err := handleSosReport(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || !strings.Contains(text(res), filepath.Join(artifacts.Dir, "sosreport-n1-2025.tar.xz")) {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+}
+
+func TestHandleSosReportUploadRequiresCase(t *testing.T) {
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"node_name": "n1",
+		"upload":    true,
+	}}}
+	res, err := handleSosReport(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.IsError {
+		t.Fatalf("expected error result")
+	}
+}
+
+// caseStandIn starts a local stand-in for the SSO and Case Management APIs
+// and installs a client for it as the redhat default.
+func caseStandIn(t *testing.T, allowComments bool, h http.HandlerFunc) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"tok"}`)
+			return
+		}
+		h(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	c, err := redhat.NewClient(redhat.Config{
+		CaseAPIBase:       srv.URL,
+		SSOTokenURL:       srv.URL + "/token",
+		MaxRetries:        -1,
+		AllowCaseComments: allowComments,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redhat.SetDefault(c)
+	t.Cleanup(func() { redhat.SetDefault(nil) })
+}
+
+func TestHandleAttachCaseFile(t *testing.T) {
+	origDir := artifacts.Dir
+	artifacts.Dir = t.TempDir()
+	defer func() { artifacts.Dir = origDir }()
+	var uploaded []string
+	caseStandIn(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, hdr, err := r.FormFile("file")
+		if r.URL.Path != "/cases/0123/attachments" || err != nil {
+			t.Errorf("unexpected request %s %s: %v", r.Method, r.URL.Path, err)
+			return
+		}
+		uploaded = append(uploaded, hdr.Filename)
+		w.WriteHeader(http.StatusCreated)
+	})
+	art, err := artifacts.Save("sosreport.tar.xz", []byte("sos"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{art.Path, kubeconfig, filepath.Join(artifacts.Dir, "..", filepath.Base(filepath.Dir(kubeconfig)), "kubeconfig")} {
+		req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+			"path":          p,
+			"case_id":       "0123",
+			"offline_token": "off",
+		}}}
+		res, err := handleAttachCaseFile(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p == art.Path && res.IsError {
+			t.Fatalf("unexpected result: %v", text(res))
+		}
+		if p != art.Path && (!res.IsError || !strings.Contains(text(res), "not in the artifact directory")) {
+			t.Fatalf("%s: unexpected result: %v", p, text(res))
+		}
+	}
+	if fmt.Sprint(uploaded) != "[sosreport.tar.xz]" {
+		t.Fatalf("unexpected uploads %v", uploaded)
+	}
+}
+
+func TestHandleAddCaseCommentRequiresOptIn(t *testing.T) {
+	caseStandIn(t, false, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"case_id":       "0123",
+		"comment":       "hello",
+		"offline_token": "off",
+	}}}
+	res, err := handleAddCaseComment(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.IsError || !strings.Contains(text(res), "REDHAT_ALLOW_CASE_COMMENTS") {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+}
+
+func TestHandleAddCaseComment(t *testing.T) {
+	var got map[string]any
+	caseStandIn(t, true, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/cases/0123/comments" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"c9","commentBody":"hello","isPublic":true}`)
+	})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"case_id":       "0123",
+		"comment":       "hello",
+		"offline_token": "off",
+	}}}
+	res, err := handleAddCaseComment(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || text(res) != "comment added to case 0123 (id c9)" || got["commentBody"] != "hello" {
+		t.Fatalf("unexpected result: %v (server saw %v)", text(res), got)
+	}
+}
+
+func TestHandleCreateCase(t *testing.T) {
+	var got map[string]any
+	caseStandIn(t, false, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Location", "/support/v1/cases/0456")
+		w.WriteHeader(http.StatusCreated)
+	})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"summary":       "crio hangs",
+		"description":   "pods stuck in ContainerCreating",
+		"offline_token": "off",
+	}}}
+	res, err := handleCreateCase(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || text(res) != "created case 0456: crio hangs" {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+	if got["product"] != "OpenShift Container Platform" || got["severity"] != "3 (Normal)" {
+		t.Fatalf("unexpected request %v", got)
+	}
+}
+
+func TestHandleMustGatherUpload(t *testing.T) {
+	origDir := artifacts.Dir
+	artifacts.Dir = t.TempDir()
+	defer func() { artifacts.Dir = origDir }()
+	origRun := openshift.Run
+	openshift.Run = func(ctx context.Context, args ...string) ([]byte, error) {
+		dest := strings.TrimPrefix(args[2], "--dest-dir=")
+		if !strings.HasPrefix(dest, artifacts.Dir) {
+			t.Errorf("unexpected args %v", args)
+		}
+		return []byte("done"), os.WriteFile(filepath.Join(dest, "timestamp"), []byte("now"), 0o600)
+	}
+	defer func() { openshift.Run = origRun }()
+	var uploaded int
+	caseStandIn(t, false, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/cases/0123/attachments":
+			f, _, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("no file in upload: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(f)
+			uploaded += len(data)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"case_id":       "0123",
+		"upload":        true,
+		"offline_token": "off",
+	}}}
+	res, err := handleMustGather(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || !strings.Contains(text(res), "to case 0123") || uploaded == 0 {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+}
+
+func TestHandleMustGatherUploadOutsideArtifacts(t *testing.T) {
+	origDir := artifacts.Dir
+	artifacts.Dir = t.TempDir()
+	defer func() { artifacts.Dir = origDir }()
+	origRun := openshift.Run
+	openshift.Run = func(ctx context.Context, args ...string) ([]byte, error) {
+		t.Fatalf("must-gather ran: %v", args)
+		return nil, nil
+	}
+	defer func() { openshift.Run = origRun }()
+	caseStandIn(t, false, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"dest_dir":      t.TempDir(),
+		"case_id":       "0123",
+		"upload":        true,
+		"offline_token": "off",
+	}}}
+	res, err := handleMustGather(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.IsError || !strings.Contains(text(res), "not in the artifact directory") {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+}
+
+const exposureCVE = `{"name":"CVE-2024-21626","threat_severity":"Important","affected_release":[{"product_name":"Red Hat OpenShift Container Platform 4.14","advisory":"RHSA-2024:0670","cpe":"cpe:/a:redhat:openshift:4.14::el9","package":"runc-4:1.1.12-1.rhaos4.14.el9"}]}`
+
+func exposureMocks(inventory map[string]string) func() {
+	origDo, origRun, origOutput := redhat.Do, openshift.Run, openshift.Output
+	redhat.Do = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(exposureCVE))}, nil
+	}
+	openshift.Run = func(ctx context.Context, args ...string) ([]byte, error) {
+		out, ok := inventory[args[1]]
+		if !ok {
+			return []byte("not found"), fmt.Errorf("exit status 1")
+		}
+		return []byte(out), nil
+	}
+	openshift.Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		switch fmt.Sprint(args) {
+		case "[get machineconfigpool worker -o json]":
+			return []byte(`{"spec":{"nodeSelector":{"matchLabels":{"node-role.kubernetes.io/worker":""}}}}`), nil
+		case "[get nodes -l node-role.kubernetes.io/worker= -o name]":
+			return []byte("node/w1\nnode/w2\nnode/w3\n"), nil
+		}
+		return nil, fmt.Errorf("unexpected args %v", args)
+	}
+	return func() { redhat.Do, openshift.Run, openshift.Output = origDo, origRun, origOutput }
+}
+
+func TestHandleCheckCVEExposureNode(t *testing.T) {
+	defer exposureMocks(map[string]string{
+		"node/w1": "runc 4:1.1.10-1.rhaos4.14.el9\n--- os-release ---\nOPENSHIFT_VERSION=\"4.14\"\nRHEL_VERSION=\"9.2\"\n",
+	})()
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"cve_id":    "CVE-2024-21626",
+		"node_name": "w1",
+	}}}
+	res, err := handleCheckCVEExposure(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || !strings.Contains(text(res), "Result: EXPOSED") || !strings.Contains(text(res), "RHSA-2024:0670") {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+}
+
+func TestHandleCheckCVEExposurePool(t *testing.T) {
+	defer exposureMocks(map[string]string{
+		"node/w1": "runc 4:1.1.10-1.rhaos4.14.el9\n--- os-release ---\nOPENSHIFT_VERSION=\"4.14\"\n",
+		"node/w2": "runc 4:1.1.12-1.rhaos4.14.el9\n--- os-release ---\nOPENSHIFT_VERSION=\"4.14\"\n",
+	})()
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"cve_id": "CVE-2024-21626",
+		"pool":   "worker",
+	}}}
+	res, err := handleCheckCVEExposure(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := text(res)
+	for _, want := range []string{"| w1 | YES |", "| w2 | no |", "| w3 | error:", "1 of 2 nodes exposed, 1 could not be checked"} {
+		if res.IsError || !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %v", want, out)
+		}
+	}
+}
+
+func TestHandleCheckCVEExposureArgs(t *testing.T) {
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"cve_id":    "CVE-2024-21626",
+		"node_name": "w1",
+		"pool":      "worker",
+	}}}
+	res, err := handleCheckCVEExposure(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.IsError {
+		t.Fatal("expected error result")
+	}
+}
+
+func TestHandleSearchCVEsDefaultPackages(t *testing.T) {
+	var packages []string
+	redhat.Do = func(req *http.Request) (*http.Response, error) {
+		packages = append(packages, req.URL.Query().Get("package"))
+		if req.URL.Query().Get("after") != "2024-01-01" {
+			t.Fatalf("unexpected query %s", req.URL.RawQuery)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`[{"CVE":"CVE-2024-21626","severity":"important","public_date":"2024-01-31T00:00:00Z"}]`))}, nil
+	}
+	defer func() { redhat.Do = http.DefaultClient.Do }()
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"after": "2024-01-01",
+	}}}
+	res, err := handleSearchCVEs(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || !strings.HasPrefix(text(res), "CVE-2024-21626 [important]") {
+		t.Fatalf("unexpected result: %v", text(res))
+	}
+	if fmt.Sprint(packages) != fmt.Sprint(redhat.RuntimePackages) {
+		t.Fatalf("unexpected packages %v", packages)
+	}
+}
+
+func TestHandleGetErratum(t *testing.T) {
+	redhat.ClearTokenCache()
+	redhat.Do = func(req *http.Request) (*http.Response, error) {
+		body := `{"access_token":"tok"}`
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/packages"):
+			body = `{"body":[{"name":"runc","epoch":"4","version":"1.1.12","release":"1.rhaos4.14.el9"},{"name":"kernel","epoch":"0","version":"5.14.0","release":"1.el9"}]}`
+		case strings.Contains(req.URL.Path, "/errata/"):
+			body = `{"body":{"id":"RHSA-2024:0670","synopsis":"runc security update","cves":"CVE-2024-21626"}}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+	defer func() { redhat.Do = http.DefaultClient.Do }()
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"advisory_id":   "RHSA-2024:0670",
+		"packages":      []any{"runc"},
+		"offline_token": "off",
+	}}}
+	res, err := handleGetErratum(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := text(res)
+	if res.IsError || !strings.Contains(out, "- runc-4:1.1.12-1.rhaos4.14.el9") || strings.Contains(out, "kernel") {
+		t.Fatalf("unexpected result: %v", out)
+	}
+}
+
+// clusterMock mocks openshift.Run with canned answers. Debug pod
+// commands are answered by the entry whose key prefixes the shell command,
+// other oc invocations by their joined arguments.
+func clusterMock(t *testing.T, answers map[string]string) {
+	t.Helper()
+	orig := openshift.Run
+	t.Cleanup(func() { openshift.Run = orig })
+	openshift.Run = func(ctx context.Context, args ...string) ([]byte, error) {
+		cmd := strings.Join(args, " ")
+		if args[0] == "debug" {
+			cmd = args[0] + " " + args[1] + " " + args[len(args)-1]
+		}
+		for k, v := range answers {
+			if strings.HasPrefix(cmd, k) {
+				return []byte(v), nil
+			}
+		}
+		return []byte("error: not found"), fmt.Errorf("exit status 1")
+	}
+}
+
+// callRunbook runs a runbook through a registered server and returns the
+// report text.
+func callRunbook(t *testing.T, name string, params map[string]any) string {
+	t.Helper()
+	s := server.NewMCPServer("test", "0.0.1")
+	RegisterTools(s)
+	req, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": 1, "method": "tools/call",
+		"params": map[string]any{"name": "run_runbook", "arguments": map[string]any{"name": name, "params": params}},
+	})
+	out, _ := json.Marshal(s.HandleMessage(context.Background(), req))
+	var resp struct {
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil || len(resp.Result.Content) == 0 {
+		t.Fatalf("unexpected response %s", out)
+	}
+	if resp.Result.IsError {
+		t.Fatalf("runbook failed: %s", resp.Result.Content[0].Text)
+	}
+	return resp.Result.Content[0].Text
+}
+
+func TestRunRunbookPodStart(t *testing.T) {
+	clusterMock(t, map[string]string{
+		"get events -A": `NAMESPACE   LAST SEEN   TYPE      REASON      OBJECT        MESSAGE
+app         2m          Normal    Scheduled   pod/web-1     Successfully assigned app/web-1 to worker-0
+app         1m          Warning   BackOff     pod/web-1     Back-off restarting failed container web in pod web-1
+app         1m          Warning   BackOff     pod/web-10    Back-off restarting failed container web in pod web-10
+other       1m          Warning   FailedMount pod/db-0      MountVolume.SetUp failed for volume "data"
+`,
+		"logs -n app web-1": "starting\nfatal error: runtime: out of memory\n",
+		"debug node/worker-0 crictl pods --namespace app --name '^web-1$' -q":  "0123456789abcdef\n",
+		"debug node/worker-0 crictl ps -a --pod 0123456789abcdef -q":           "fedcba9876543210\n",
+		"debug node/worker-0 crictl inspect fedcba9876543210":                  "{\n  \"status\": {\n    \"state\": \"CONTAINER_EXITED\",\n    \"exitCode\": 137,\n    \"reason\": \"OOMKilled\",\n    \"message\": \"\"\n  },\n  \"info\": {\"pid\": 0}\n}\n",
+		"debug node/worker-0 journalctl -u crio --since -1h --no-pager | grep": "time=\"2024\" level=info msg=\"Created container fedcba9876543210: app/web-1/web\"\n",
+	})
+	out := callRunbook(t, "pod-start", map[string]any{"namespace": "app", "pod": "web-1"})
+	for _, want := range []string{
+		"runbook pod-start: Pod start failure\nparams: namespace=app pod=web-1\n",
+		"  - Warning events: BackOff.\n",
+		"  - A container was OOM killed.",
+		"  - Containers exited with codes 137;",
+		"1. events (collect_events): ok\n   Events of the pod\n   | app         2m          Normal    Scheduled   pod/web-1",
+		"5. inspect[fedcba9876543210] (run_crictl): ok\n   Container state\n   |     \"state\": \"CONTAINER_EXITED\",\n",
+		"6. crio_journal (debug_node): ok\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("report lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "web-10") || strings.Contains(out, "FailedMount") || strings.Contains(out, "no sandbox") {
+		t.Fatalf("report includes another pod:\n%s", out)
+	}
+}
+
+func TestRunRunbookNodeHealth(t *testing.T) {
+	clusterMock(t, map[string]string{
+		"adm top nodes": `NAME       CPU(cores)   CPU%   MEMORY(bytes)   MEMORY%
+worker-0   250m         6%     15000Mi         95%
+worker-1   <unknown>    <unknown>   <unknown>  <unknown>
+`,
+		"debug node/worker-0 n='worker-0'": "worker-0 unit kubelet active\nworker-0 unit crio failed\nworker-0 disk /var/lib/containers 91%\nworker-0 disk /var/lib/kubelet 40%\nworker-0 load 0.5 0.4 0.3 cpus 4\nworker-0 oom 2\n",
+	})
+	out := callRunbook(t, "node-health", nil)
+	for _, want := range []string{
+		"  - Nodes without metrics, possibly NotReady or with a stuck kubelet: worker-1.\n",
+		"  - Nodes using 90% or more of their memory: worker-0.\n",
+		"  - Services not active: worker-0 unit crio.",
+		"  - Filesystems at 85% or more: worker-0 disk /var/lib/containers 91%.",
+		"  - Kernel OOM kills in the last hour on worker-0.",
+		"2. checks[worker-0] (debug_node): ok\n",
+		"3. checks[worker-1] (debug_node): failed\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("report lacks %q:\n%s", want, out)
+		}
+	}
+
+	out = callRunbook(t, "node-health", map[string]any{"node": "worker-0"})
+	if strings.Contains(out, "worker-1]") || !strings.Contains(out, "2. checks[worker-0] (debug_node): ok\n") {
+		t.Fatalf("node param did not restrict the checks:\n%s", out)
+	}
+}
+
+func TestRunRunbookRuntimeHealth(t *testing.T) {
+	clusterMock(t, map[string]string{
+		`debug node/n1 echo "active`:                "active active\nrestarts 3\nrunning 12\nexited 2\nnotready 0\nconmon 12\nstorage 42%\n",
+		"debug node/n1 crictl info":                 `{"status": {"conditions": [{"type": "RuntimeReady", "status": true, "reason": ""}, {"type": "NetworkReady", "status": false, "reason": "NetworkPluginNotReady"}]}}`,
+		"debug node/n1 crictl ps -a --state exited": "CONTAINER  IMAGE  CREATED  STATE  NAME\nabc  img  1m  Exited  web\n",
+		"debug node/n1 journalctl -u crio":          "time=\"1\" level=error msg=\"first\"\ntime=\"2\" level=error msg=\"Error adding network: timeout\"\n",
+	})
+	out := callRunbook(t, "runtime-health", map[string]any{"node": "n1"})
+	for _, want := range []string{
+		"  - systemd restarted CRI-O 3 times.",
+		"  - Runtime conditions not met: NetworkReady.",
+		"  - 2 CRI-O errors in the last hour, most recently: Error adding network: timeout\n",
+		"3. exited_containers (run_crictl): ok\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("report lacks %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"CRI-O is ", "sandboxes are not ready", "storage is"} {
+		if strings.Contains(out, unwanted) {
+			t.Fatalf("unexpected finding %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestRunRunbookErrors(t *testing.T) {
+	s := server.NewMCPServer("test", "0.0.1")
+	RegisterTools(s)
+	for _, tc := range []struct{ args, want string }{
+		{`{"name":"nope"}`, "unknown runbook nope"},
+		{`{"name":"pod-start","params":{"pod":"x"}}`, "param namespace is required"},
+	} {
+		msg := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"run_runbook","arguments":` + tc.args + `}}`
+		out, _ := json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(msg)))
+		if !strings.Contains(string(out), tc.want) || !strings.Contains(string(out), `"isError":true`) {
+			t.Fatalf("unexpected response %s", out)
+		}
+	}
+
+	t.Setenv(runbook.DirEnv, filepath.Join(t.TempDir(), "missing"))
+	s = server.NewMCPServer("test", "0.0.1")
+	RegisterTools(s)
+	msg := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"run_runbook","arguments":{"name":"pod-start"}}}`
+	out, _ := json.Marshal(s.HandleMessage(context.Background(), json.RawMessage(msg)))
+	if !strings.Contains(string(out), "load runbooks from") {
+		t.Fatalf("unexpected response %s", out)
+	}
+}
+
+func TestHandleDiagnosePod(t *testing.T) {
+	origOutput := openshift.Output
+	defer func() { openshift.Output = origOutput }()
+	openshift.Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != "[get pod -n app web-1 -o jsonpath={.spec.nodeName}]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte("worker-0"), nil
+	}
+	clusterMock(t, map[string]string{
+		// The Scheduled event has expired; only the back-off remains.
+		"get events -A": "NAMESPACE LAST SEEN TYPE REASON OBJECT MESSAGE\napp 1m Warning BackOff pod/web-1 Back-off restarting failed container web in pod web-1\n",
+		"debug node/worker-0 crictl pods --namespace app --name web-1 -o json": `{"items": [{"id": "0123456789abcdef", "metadata": {"name": "web-1", "namespace": "app"}, "state": "SANDBOX_READY", "createdAt": "1"}]}`,
+		"debug node/worker-0 crictl ps -a --pod 0123456789abcdef -o json":      `{"containers": [{"id": "c1", "metadata": {"name": "web", "attempt": 4}, "state": "CONTAINER_EXITED"}]}`,
+		"debug node/worker-0 crictl inspect c1":                                `{"status": {"state": "CONTAINER_EXITED", "exitCode": 2, "reason": "Error"}}`,
+		"adm node-logs worker-0 --since -1h":                                   "Jan 01 kubelet[2]: E0101 pod_workers.go:1 \"Error syncing pod, skipping\" pod=\"app/web-1\"\nJan 01 crio[1]: level=info msg=\"Started container\" app/web-1\n",
+		"logs -n app web-1 -c web":                                             "starting\nconfig: missing DATABASE_URL\n",
+	})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "diagnose_pod", Arguments: map[string]any{"namespace": "app", "pod_name": "web-1"}}}
+	res, err := handleDiagnosePod(context.Background(), req)
+	if err != nil || res.IsError {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+	want := `pod: app/web-1
+node: worker-0
+sandbox: 0123456789abc SANDBOX_READY
+stage: runtime exit
+verdict: Container web exited with code 2 (Error).
+evidence:
+- [crictl] container web (attempt 4) CONTAINER_EXITED exit code 2 reason Error
+- [event] Warning BackOff: Back-off restarting failed container web in pod web-1
+- [logs] web: starting
+- [logs] web: config: missing DATABASE_URL
+- [crictl] sandbox 0123456789abc SANDBOX_READY
+`
+	if out := text(res); !strings.HasPrefix(out, want) || strings.Contains(out, "not collected") {
+		t.Fatalf("unexpected verdict:\n%s", out)
+	}
+}
+
+func TestHandleDiagnosePodUnscheduled(t *testing.T) {
+	origOutput := openshift.Output
+	defer func() { openshift.Output = origOutput }()
+	openshift.Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		return nil, nil
+	}
+	clusterMock(t, map[string]string{
+		"get events -A": "app 1m Warning FailedScheduling pod/web-1 0/3 nodes are available: 3 node(s) had untolerated taint.\n",
+	})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "diagnose_pod", Arguments: map[string]any{"namespace": "app", "pod_name": "web-1"}}}
+	res, _ := handleDiagnosePod(context.Background(), req)
+	out := text(res)
+	if !strings.Contains(out, "node: (not scheduled)\nstage: scheduling\nverdict: The scheduler cannot place the pod: 0/3 nodes are available") {
+		t.Fatalf("unexpected verdict:\n%s", out)
+	}
+}
+
+func TestHandleAnalyzeImageStorage(t *testing.T) {
+	clusterMock(t, map[string]string{
+		"debug node/worker-0 echo '### images'": `### images
+{"images": [{"id": "abc", "repoTags": ["quay.io/acme/app:v1"], "size": "2048"}]}
+### containers
+{"containers": []}
+### pods
+{"items": []}
+### df
+Filesystem 1024-blocks Used Available Capacity Mounted on
+/dev/sda4 100 90 10 90% /var
+### end
+`,
+	})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "analyze_image_storage", Arguments: map[string]any{"node_name": "worker-0"}}}
+	res, err := handleAnalyzeImageStorage(context.Background(), req)
+	if err != nil || res.IsError || len(res.Content) != 2 {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+	summary := res.Content[0].(mcp.TextContent).Text
+	for _, want := range []string{
+		"filesystem: /var 90% used",
+		"images: 1, 1 unused, 0 dangling",
+		"reclaimable: about 2.0KiB",
+		"- overlay: no output from the node",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Fatalf("summary lacks %q:\n%s", want, summary)
+		}
+	}
+	if !strings.Contains(res.Content[1].(mcp.TextContent).Text, `"unused": true`) {
+		t.Fatalf("unexpected JSON %s", res.Content[1].(mcp.TextContent).Text)
+	}
+
+	req.Params.Arguments = map[string]any{"node_name": "worker-1"}
+	if res, _ := handleAnalyzeImageStorage(context.Background(), req); !res.IsError {
+		t.Fatalf("expected an error for an unreachable node")
+	}
+}
+
+func TestHandlePruneImageStorage(t *testing.T) {
+	clusterMock(t, map[string]string{
+		"debug node/worker-0 crictl rmi --prune":              "Deleted: quay.io/acme/app:v1\n",
+		"debug node/worker-0 crictl rmi abc quay.io/acme/x:1": "Deleted: abc\n",
+	})
+	call := func(args map[string]any) *mcp.CallToolResult {
+		res, err := handlePruneImageStorage(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "prune_image_storage", Arguments: args}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	if out := text(call(map[string]any{"node_name": "worker-0"})); out != "Deleted: quay.io/acme/app:v1\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if out := text(call(map[string]any{"node_name": "worker-0", "images": []any{"abc", "quay.io/acme/x:1"}})); out != "Deleted: abc\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if res := call(map[string]any{"node_name": "worker-0", "images": []any{"abc && reboot"}}); !res.IsError || !strings.Contains(text(res), "invalid image reference") {
+		t.Fatalf("unexpected result %v", res)
+	}
+}
+
+func TestHandleFindRuntimeOrphans(t *testing.T) {
+	origOutput := openshift.Output
+	defer func() { openshift.Output = origOutput }()
+	openshift.Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != "[get pods -A --field-selector spec.nodeName=worker-0 -o json]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte(`{"items": [{"metadata": {"namespace": "app", "name": "web-1", "uid": "u1", "creationTimestamp": "2020-01-01T00:00:00Z"}, "status": {"phase": "Running"}}]}`), nil
+	}
+	clusterMock(t, map[string]string{
+		"debug node/worker-0 crictl pods -o json": `{"items": [
+  {"id": "s1", "metadata": {"name": "web-1", "namespace": "app", "uid": "u1"}, "state": "SANDBOX_READY", "createdAt": "1577836800000000000"},
+  {"id": "s2", "metadata": {"name": "web-0", "namespace": "app", "uid": "u0"}, "state": "SANDBOX_READY", "createdAt": "1577836800000000000"}
+]}`,
+		"debug node/worker-0 crictl ps -a -o json": `{"containers": [{"id": "c1", "podSandboxId": "s1", "metadata": {"name": "web"}, "state": "CONTAINER_RUNNING"}]}`,
+		"debug node/worker-0 echo '### inspect'": `### inspect
+{"status": {"id": "s1"}, "info": {"runtimeSpec": {"linux": {"namespaces": [{"type": "network", "path": "/var/run/netns/ns1"}]}}}}
+{"status": {"id": "s2"}, "info": {"runtimeSpec": {"linux": {"namespaces": [{"type": "network", "path": "/var/run/netns/ns2"}]}}}}
+### processes
+100 3600 conmon /usr/bin/conmon -c c1 -n k8s_web
+101 3600 conmon /usr/bin/conmon -c c9 -n k8s_gone
+### netns
+ns1
+ns2
+### end
+`,
+	})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "find_runtime_orphans", Arguments: map[string]any{"node_name": "worker-0"}}}
+	res, err := handleFindRuntimeOrphans(context.Background(), req)
+	if err != nil || res.IsError || len(res.Content) != 2 {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+	summary := res.Content[0].(mcp.TextContent).Text
+	for _, want := range []string{
+		"node worker-0: 2 inconsistencies\napi pods: 1, sandboxes: 2, containers: 1, runtime processes: 2, network namespaces: 2\n",
+		"sandboxes without pod (1):\n",
+		"- s2 app/web-0 SANDBOX_READY\n",
+		"conmon without container (1):\n",
+		"- c9: conmon pid 101 running for 1h0m0s\n",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Fatalf("summary lacks %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "not checked") {
+		t.Fatalf("unexpected summary:\n%s", summary)
+	}
+	if !strings.Contains(res.Content[1].(mcp.TextContent).Text, `"found": 2`) {
+		t.Fatalf("unexpected JSON %s", res.Content[1].(mcp.TextContent).Text)
+	}
+}
+
+func TestHandleNodeHealthReport(t *testing.T) {
+	origOutput := openshift.Output
+	defer func() { openshift.Output = origOutput }()
+	openshift.Output = func(ctx context.Context, args ...string) ([]byte, error) {
+		if fmt.Sprint(args) != "[get node worker-0 -o json]" {
+			t.Fatalf("unexpected args %v", args)
+		}
+		return []byte(`{"status": {"conditions": [{"type": "Ready", "status": "True"}]}}`), nil
+	}
+	clusterMock(t, map[string]string{
+		"debug node/worker-0 echo '### runtime'": `### runtime
+{"status": {"conditions": [{"type": "RuntimeReady", "status": true}, {"type": "NetworkReady", "status": true}]}}
+### services
+Id=crio.service
+ActiveState=active
+SubState=running
+NRestarts=2
+### disk
+Filesystem 1024-blocks Used Available Capacity Mounted on
+/dev/sda4 100 40 60 40% /var
+### inodes
+Filesystem Inodes IUsed IFree IUse% Mounted on
+/dev/sda4 100 5 95 5% /var
+### pressure
+resource io
+some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+### kernel
+### end
+`,
+	})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "node_health_report", Arguments: map[string]any{"node_name": "worker-0"}}}
+	res, err := handleNodeHealthReport(context.Background(), req)
+	if err != nil || res.IsError || len(res.Content) != 2 {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+	summary := res.Content[0].(mcp.TextContent).Text
+	want := `node worker-0: warning
+services   warning
+  - crio was restarted 2 times
+resources  unknown
+  - not collected: oc adm top nodes failed: exit status 1: error: not found
+`
+	if !strings.HasPrefix(summary, want) {
+		t.Fatalf("unexpected summary:\n%s", summary)
+	}
+	var report struct {
+		Status string            `json:"status"`
+		Errors map[string]string `json:"errors"`
+		Disks  []map[string]any  `json:"disks"`
+	}
+	if err := json.Unmarshal([]byte(res.Content[1].(mcp.TextContent).Text), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != "warning" || len(report.Errors) != 1 || report.Disks[0]["percent"] != 40.0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
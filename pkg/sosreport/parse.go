@@ -0,0 +1,379 @@
+package sosreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Package is an installed RPM of interest to the container runtime.
+type Package struct {
+	Name string
+	NVR  string
+}
+
+// Container is a row from "crictl ps -a".
+type Container struct {
+	ID      string
+	Name    string
+	State   string
+	Attempt string
+	PodID   string
+}
+
+// Sandbox is a row from "crictl pods".
+type Sandbox struct {
+	ID        string
+	Name      string
+	Namespace string
+	State     string
+}
+
+// Unit is a systemd unit and its state from "systemctl list-units".
+type Unit struct {
+	Name   string
+	Load   string
+	Active string
+	Sub    string
+}
+
+// RuntimeCondition is a condition reported by "crictl info".
+type RuntimeCondition struct {
+	Type    string `json:"type"`
+	Status  bool   `json:"status"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// Report is the structured content of a sosreport.
+type Report struct {
+	Source            string
+	Hostname          string
+	Kernel            string
+	Packages          []Package
+	Containers        []Container
+	Pods              []Sandbox
+	Images            int
+	DanglingImages    int
+	RuntimeConditions []RuntimeCondition
+	// CrioConfig maps crio configuration file paths to their contents.
+	CrioConfig    map[string]string
+	StorageConfig string
+	// Crictl maps crictl command output files to their contents.
+	Crictl         map[string]string
+	KernelMessages []string
+	Units          []Unit
+}
+
+// runtimePackages are the RPMs reported in Report.Packages.
+var runtimePackages = []string{
+	"cri-o", "cri-tools", "runc", "crun", "conmon", "conmon-rs", "containers-common",
+	"container-selinux", "podman", "skopeo", "kernel", "openshift-kubelet",
+	"openshift-hyperkube", "systemd",
+}
+
+// runtimeUnits are systemd units always reported regardless of their state.
+var runtimeUnits = []string{"crio.service", "kubelet.service", "crio-wipe.service", "ovs-vswitchd.service", "NetworkManager.service"}
+
+var kernelErrorRE = regexp.MustCompile(`(?i)error|fail|oom|out of memory|killed process|hung task|blocked for more than|call trace|segfault|soft lockup|I/O error`)
+
+// maxKernelMessages caps the number of kernel lines kept.
+const maxKernelMessages = 100
+
+func parse(files map[string][]byte) *Report {
+	r := &Report{CrioConfig: map[string]string{}, Crictl: map[string]string{}}
+	r.Hostname = strings.TrimSpace(string(files["hostname"]))
+	r.Kernel = strings.TrimSpace(string(files["uname"]))
+	for name, data := range files {
+		switch {
+		case name == "etc/crio/crio.conf" || strings.HasPrefix(name, "etc/crio/crio.conf.d/"):
+			r.CrioConfig["/"+name] = string(data)
+		case strings.HasPrefix(name, "sos_commands/crio/"):
+			r.Crictl[strings.TrimPrefix(name, "sos_commands/crio/")] = string(data)
+		}
+	}
+	r.StorageConfig = string(files["etc/containers/storage.conf"])
+	r.Packages = parsePackages(string(files["installed-rpms"]))
+	r.Containers = parseContainers(r.crictlOutput("crictl_ps_-a", "crictl_ps"))
+	r.Pods = parsePods(r.crictlOutput("crictl_pods"))
+	r.Images, r.DanglingImages = countImages(r.crictlOutput("crictl_images", "crictl_images_--digests"))
+	r.RuntimeConditions = parseRuntimeConditions(r.crictlOutput("crictl_info"))
+	r.KernelMessages = grepLines(string(files["sos_commands/kernel/dmesg"]), kernelErrorRE, maxKernelMessages)
+	units := string(files["sos_commands/systemd/systemctl_list-units_--all"])
+	if units == "" {
+		units = string(files["sos_commands/systemd/systemctl_list-units"])
+	}
+	r.Units = parseUnits(units)
+	return r
+}
+
+// crictlOutput returns the first crictl output file present among names.
+func (r *Report) crictlOutput(names ...string) string {
+	for _, n := range names {
+		if out, ok := r.Crictl[n]; ok {
+			return out
+		}
+	}
+	return ""
+}
+
+func parsePackages(rpms string) []Package {
+	var out []Package
+	for _, line := range strings.Split(rpms, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		nvr := fields[0]
+		for _, name := range runtimePackages {
+			rest, ok := strings.CutPrefix(nvr, name+"-")
+			if ok && rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+				out = append(out, Package{Name: name, NVR: nvr})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NVR < out[j].NVR })
+	return out
+}
+
+// stateIndex returns the index of the first field that is one of states.
+// crictl tables contain free-form columns such as "2 hours ago", so the
+// state column is located by value rather than by position.
+func stateIndex(fields []string, states ...string) int {
+	for i, f := range fields {
+		for _, s := range states {
+			if f == s {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseContainers(out string) []Container {
+	var cs []Container
+	for _, line := range tableRows(out) {
+		fields := strings.Fields(line)
+		i := stateIndex(fields, "Running", "Exited", "Created", "Unknown")
+		if i < 0 || len(fields) < i+3 {
+			continue
+		}
+		c := Container{ID: fields[0], State: fields[i], Name: fields[i+1], Attempt: fields[i+2]}
+		if len(fields) > i+3 {
+			c.PodID = fields[i+3]
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+func parsePods(out string) []Sandbox {
+	var ps []Sandbox
+	for _, line := range tableRows(out) {
+		fields := strings.Fields(line)
+		i := stateIndex(fields, "Ready", "NotReady")
+		if i < 0 || len(fields) < i+3 {
+			continue
+		}
+		ps = append(ps, Sandbox{ID: fields[0], State: fields[i], Name: fields[i+1], Namespace: fields[i+2]})
+	}
+	return ps
+}
+
+func countImages(out string) (total, dangling int) {
+	for _, line := range tableRows(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		total++
+		if fields[0] == "<none>" || fields[1] == "<none>" {
+			dangling++
+		}
+	}
+	return total, dangling
+}
+
+// tableRows returns the non-empty rows of a crictl table without its header.
+func tableRows(out string) []string {
+	var rows []string
+	for i, line := range strings.Split(out, "\n") {
+		if i == 0 || strings.TrimSpace(line) == "" {
+			continue
+		}
+		rows = append(rows, line)
+	}
+	return rows
+}
+
+func parseRuntimeConditions(out string) []RuntimeCondition {
+	var info struct {
+		Status struct {
+			Conditions []RuntimeCondition `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		return nil
+	}
+	return info.Status.Conditions
+}
+
+func parseUnits(out string) []Unit {
+	var units []Unit
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(strings.TrimLeft(line, " ●*"))
+		if len(fields) < 4 || !strings.Contains(fields[0], ".") {
+			continue
+		}
+		u := Unit{Name: fields[0], Load: fields[1], Active: fields[2], Sub: fields[3]}
+		if u.Active == "failed" || contains(runtimeUnits, u.Name) {
+			units = append(units, u)
+		}
+	}
+	return units
+}
+
+func grepLines(out string, re *regexp.Regexp, max int) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if re.MatchString(line) {
+			lines = append(lines, line)
+			if len(lines) == max {
+				break
+			}
+		}
+	}
+	return lines
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Sections supported by Report.Format.
+const (
+	SectionSummary    = "summary"
+	SectionCrictl     = "crictl"
+	SectionCrioConfig = "crio_config"
+	SectionKernel     = "kernel"
+	SectionUnits      = "units"
+	SectionPackages   = "packages"
+)
+
+// SectionNames lists the supported section names.
+var SectionNames = []string{SectionSummary, SectionCrictl, SectionCrioConfig, SectionKernel, SectionUnits, SectionPackages}
+
+// Format renders a section of the report as plain text.
+func (r *Report) Format(section string) (string, error) {
+	var b strings.Builder
+	switch section {
+	case SectionSummary:
+		r.writeSummary(&b)
+	case SectionCrictl:
+		for _, name := range sortedKeys(r.Crictl) {
+			fmt.Fprintf(&b, "== %s\n%s\n", name, strings.TrimRight(r.Crictl[name], "\n"))
+		}
+	case SectionCrioConfig:
+		for _, name := range sortedKeys(r.CrioConfig) {
+			fmt.Fprintf(&b, "== %s\n%s\n", name, strings.TrimRight(r.CrioConfig[name], "\n"))
+		}
+		if r.StorageConfig != "" {
+			fmt.Fprintf(&b, "== /etc/containers/storage.conf\n%s\n", strings.TrimRight(r.StorageConfig, "\n"))
+		}
+	case SectionKernel:
+		for _, l := range r.KernelMessages {
+			b.WriteString(l + "\n")
+		}
+	case SectionUnits:
+		r.writeUnits(&b)
+	case SectionPackages:
+		for _, p := range r.Packages {
+			b.WriteString(p.NVR + "\n")
+		}
+	default:
+		return "", fmt.Errorf("unknown section %q (valid: %s)", section, strings.Join(SectionNames, ", "))
+	}
+	return b.String(), nil
+}
+
+func (r *Report) writeSummary(b *strings.Builder) {
+	fmt.Fprintf(b, "sosreport: %s\n", r.Source)
+	if r.Hostname != "" {
+		fmt.Fprintf(b, "hostname: %s\n", r.Hostname)
+	}
+	if r.Kernel != "" {
+		fmt.Fprintf(b, "uname: %s\n", r.Kernel)
+	}
+	if len(r.Packages) > 0 {
+		b.WriteString("\nPackages:\n")
+		for _, p := range r.Packages {
+			fmt.Fprintf(b, "  %s\n", p.NVR)
+		}
+	}
+	if len(r.RuntimeConditions) > 0 {
+		b.WriteString("\nRuntime conditions:\n")
+		for _, c := range r.RuntimeConditions {
+			fmt.Fprintf(b, "  %s=%t %s %s\n", c.Type, c.Status, c.Reason, c.Message)
+		}
+	}
+	states := map[string]int{}
+	for _, c := range r.Containers {
+		states[c.State]++
+	}
+	fmt.Fprintf(b, "\nContainers: %d", len(r.Containers))
+	for _, s := range sortedKeys(states) {
+		fmt.Fprintf(b, ", %d %s", states[s], s)
+	}
+	b.WriteString("\n")
+	for _, c := range r.Containers {
+		if c.State != "Running" && c.Attempt != "0" {
+			fmt.Fprintf(b, "  %s %s attempt %s (pod %s)\n", c.State, c.Name, c.Attempt, c.PodID)
+		}
+	}
+	notReady := 0
+	for _, p := range r.Pods {
+		if p.State != "Ready" {
+			notReady++
+		}
+	}
+	fmt.Fprintf(b, "Pod sandboxes: %d, %d NotReady\n", len(r.Pods), notReady)
+	fmt.Fprintf(b, "Images: %d, %d dangling\n", r.Images, r.DanglingImages)
+	if len(r.CrioConfig) > 0 {
+		fmt.Fprintf(b, "CRI-O config files: %s\n", strings.Join(sortedKeys(r.CrioConfig), ", "))
+	}
+	if len(r.Units) > 0 {
+		b.WriteString("\n")
+		r.writeUnits(b)
+	}
+	fmt.Fprintf(b, "\nKernel messages of interest: %d\n", len(r.KernelMessages))
+	for i, l := range r.KernelMessages {
+		if i == 10 {
+			fmt.Fprintf(b, "  ... use section=kernel for the rest\n")
+			break
+		}
+		fmt.Fprintf(b, "  %s\n", l)
+	}
+}
+
+func (r *Report) writeUnits(b *strings.Builder) {
+	b.WriteString("UNIT\tLOAD\tACTIVE\tSUB\n")
+	for _, u := range r.Units {
+		fmt.Fprintf(b, "%s\t%s\t%s\t%s\n", u.Name, u.Load, u.Active, u.Sub)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,160 @@
+// Package sosreport extracts container runtime state from a sosreport
+// archive or directory. It works purely offline so customer-provided reports
+// can be analyzed without cluster access.
+package sosreport
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// maxFileSize caps how much of any single file is loaded into memory.
+const maxFileSize = 8 << 20
+
+// wanted reports whether a file, relative to the sosreport root, is used by
+// the analysis.
+func wanted(rel string) bool {
+	switch rel {
+	case "hostname", "uname", "installed-rpms", "etc/containers/storage.conf",
+		"etc/crio/crio.conf", "sos_commands/kernel/dmesg",
+		"sos_commands/systemd/systemctl_list-units",
+		"sos_commands/systemd/systemctl_list-units_--all":
+		return true
+	}
+	dir := path.Dir(rel)
+	return dir == "sos_commands/crio" || dir == "etc/crio/crio.conf.d" ||
+		dir == "sos_commands/rpm"
+}
+
+// Load reads the relevant files from the sosreport at p, which may be an
+// extracted directory or a tar archive compressed with xz, gzip or bzip2.
+func Load(p string) (*Report, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	var files map[string][]byte
+	if fi.IsDir() {
+		files, err = readDir(p)
+	} else {
+		files, err = readArchive(p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s does not look like a sosreport", p)
+	}
+	r := parse(files)
+	r.Source = p
+	return r, nil
+}
+
+func readDir(root string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() || !wanted(rel) {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxFileSize))
+		if err != nil {
+			return err
+		}
+		files[rel] = data
+		return nil
+	})
+	return files, err
+}
+
+// readArchive streams a sosreport tarball and keeps the wanted files. The
+// first path component, the sosreport-<host>-<date> directory, is stripped.
+// Symlinks such as installed-rpms are resolved after the whole archive has
+// been read.
+func readArchive(p string) (map[string][]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := decompress(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", p, err)
+	}
+	files := map[string][]byte{}
+	links := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", p, err)
+		}
+		rel := stripRoot(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			if wanted(rel) {
+				links[rel] = path.Clean(path.Join(path.Dir(rel), hdr.Linkname))
+			}
+		case tar.TypeReg:
+			if !wanted(rel) {
+				continue
+			}
+			data, err := io.ReadAll(io.LimitReader(tr, maxFileSize))
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", p, err)
+			}
+			files[rel] = data
+		}
+	}
+	for name, target := range links {
+		if data, ok := files[target]; ok {
+			files[name] = data
+		}
+	}
+	return files, nil
+}
+
+func stripRoot(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if _, rest, ok := strings.Cut(name, "/"); ok {
+		return rest
+	}
+	return ""
+}
+
+// decompress detects the compression format from its magic bytes.
+func decompress(r *bufio.Reader) (io.Reader, error) {
+	magic, _ := r.Peek(6)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return xz.NewReader(r)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(r)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(r), nil
+	}
+	return r, nil
+}
@@ -0,0 +1,132 @@
+package sosreport
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+const rpmFile = "sos_commands/rpm/sh_-c_rpm_--nodigest_-qa"
+
+var fixture = map[string]string{
+	"hostname": "worker-0.example.com\n",
+	rpmFile: "cri-o-1.29.1-3.rhaos4.16.el9.x86_64    Mon Jan  1 00:00:00 2025\n" +
+		"cri-tools-1.29.0-1.el9.x86_64    Mon Jan  1 00:00:00 2025\n" +
+		"kernel-5.14.0-427.el9.x86_64    Mon Jan  1 00:00:00 2025\n" +
+		"kernel-modules-5.14.0-427.el9.x86_64    Mon Jan  1 00:00:00 2025\n",
+	"sos_commands/crio/crictl_ps_-a": "CONTAINER           IMAGE               CREATED             STATE               NAME                ATTEMPT             POD ID              POD\n" +
+		"abc123              quay.io/a@sha256    2 hours ago         Running             web                 0                   pod111              web-1\n" +
+		"def456              quay.io/b@sha256    5 minutes ago       Exited              sidecar             7                   pod111              web-1\n",
+	"sos_commands/crio/crictl_pods": "POD ID              CREATED             STATE               NAME                NAMESPACE           ATTEMPT             RUNTIME\n" +
+		"pod111              2 hours ago         Ready               web-1               app                 0                   (default)\n" +
+		"pod222              3 hours ago         NotReady            old-1               app                 0                   (default)\n",
+	"sos_commands/crio/crictl_images": "IMAGE                TAG                 IMAGE ID            SIZE\n" +
+		"quay.io/a            latest              1111                10MB\n" +
+		"<none>               <none>              2222                20MB\n",
+	"sos_commands/crio/crictl_info":   `{"status":{"conditions":[{"type":"RuntimeReady","status":true},{"type":"NetworkReady","status":false,"reason":"NetworkPluginNotReady","message":"no CNI configuration file"}]}}`,
+	"etc/crio/crio.conf.d/00-default": "[crio.runtime]\ndefault_runtime = \"crun\"\n",
+	"sos_commands/kernel/dmesg":       "[    1.0] Linux version 5.14\n[  100.0] Memory cgroup out of memory: Killed process 1234 (java)\n",
+	"sos_commands/systemd/systemctl_list-units_--all": "  UNIT                 LOAD   ACTIVE SUB     DESCRIPTION\n" +
+		"  crio.service         loaded active running Container Runtime Interface for OCI (CRI-O)\n" +
+		"● foo.service          loaded failed failed  Foo\n" +
+		"  sshd.service         loaded active running OpenSSH server daemon\n",
+	"var/log/messages": "ignored",
+}
+
+func writeArchive(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "sosreport-worker-0-2025-01-01-abcdef.tar.xz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	xw, err := xz.NewWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(xw)
+	root := "sosreport-worker-0-2025-01-01-abcdef/"
+	for name, content := range fixture {
+		hdr := &tar.Header{Name: root + name, Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: root + "installed-rpms", Linkname: rpmFile, Typeflag: tar.TypeSymlink}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := xw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadArchive(t *testing.T) {
+	r, err := Load(writeArchive(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Hostname != "worker-0.example.com" {
+		t.Fatalf("unexpected hostname %q", r.Hostname)
+	}
+	if len(r.Packages) != 3 || r.Packages[0].Name != "cri-o" {
+		t.Fatalf("unexpected packages %+v", r.Packages)
+	}
+	if len(r.Containers) != 2 || r.Containers[1].Name != "sidecar" || r.Containers[1].Attempt != "7" {
+		t.Fatalf("unexpected containers %+v", r.Containers)
+	}
+	if len(r.Pods) != 2 || r.Pods[1].State != "NotReady" || r.Pods[1].Namespace != "app" {
+		t.Fatalf("unexpected pods %+v", r.Pods)
+	}
+	if r.Images != 2 || r.DanglingImages != 1 {
+		t.Fatalf("unexpected images %d/%d", r.Images, r.DanglingImages)
+	}
+	if len(r.RuntimeConditions) != 2 || r.RuntimeConditions[1].Status {
+		t.Fatalf("unexpected conditions %+v", r.RuntimeConditions)
+	}
+	if len(r.KernelMessages) != 1 || len(r.Units) != 2 {
+		t.Fatalf("unexpected kernel/units %v %+v", r.KernelMessages, r.Units)
+	}
+	if _, ok := r.CrioConfig["/etc/crio/crio.conf.d/00-default"]; !ok {
+		t.Fatalf("missing crio config %v", r.CrioConfig)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range fixture {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := r.Format(SectionSummary)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"Containers: 2, 1 Exited, 1 Running", "Exited sidecar attempt 7", "Pod sandboxes: 2, 1 NotReady", "NetworkReady=false", "foo.service\tloaded\tfailed"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("summary missing %q:\n%s", want, out)
+		}
+	}
+	if _, err := r.Format("bogus"); err == nil {
+		t.Fatal("expected error for unknown section")
+	}
+}